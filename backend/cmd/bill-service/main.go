@@ -1,10 +1,17 @@
 package main
 
 import (
+	"backend/internal/audit"
 	"backend/internal/bill"
+	"backend/internal/events"
+	"backend/internal/idempotency"
 	"backend/internal/image"
 	"backend/internal/receipt"
+	"backend/internal/reminder"
 	"backend/internal/tab"
+	"backend/internal/tabsync"
+	"backend/internal/template"
+	"backend/internal/webhook"
 	"backend/pkg/database"
 	"fmt"
 	"log"
@@ -25,6 +32,9 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	auditRepo := audit.NewActivityRepository(db)
+	auditService := audit.NewAuditService(auditRepo)
+
 	repo := bill.NewBillRepository(db)
 	service := bill.NewBillService(repo)
 	handler := bill.NewBillHandler(service)
@@ -35,13 +45,34 @@ func main() {
 	}
 
 	imgRepo := image.NewImageRepository(db)
-	imgService := image.NewImageService(imgRepo)
+	imgService := image.NewImageService(imgRepo, image.QuotaFromEnv())
 
 	tabRepo := tab.NewTabRepository(db)
 	tabService := tab.NewTabService(tabRepo, imgService)
 	tabHandler := tab.NewTabHandler(tabService)
 
 	imgHandler := image.NewImageHandler(imgService, tabService, uploadDir)
+	auditHandler := audit.NewAuditHandler(auditService, tabService)
+
+	eventRepo := events.NewEventRepository(db)
+	eventService := events.NewEventService(eventRepo)
+	eventHandler := events.NewEventHandler(eventService, tabService)
+
+	webhookRepo := webhook.NewWebhookRepository(db)
+	webhookService := webhook.NewWebhookService(webhookRepo, webhook.AllowPrivateFromEnv())
+	webhookHandler := webhook.NewWebhookHandler(webhookService, tabService)
+
+	reminderService := reminder.NewReminderService(reminder.NewReminderRepository(db))
+	reminderHandler := reminder.NewReminderHandler(reminderService, tabService)
+
+	templateService := template.NewTemplateService(template.NewTemplateRepository(db))
+	templateHandler := template.NewTemplateHandler(templateService, tabService)
+
+	syncService := tabsync.NewSyncService(tabsync.NewSyncRepository(db))
+	syncHandler := tabsync.NewSyncHandler(syncService, tabService)
+
+	// Retries of create requests sent with an Idempotency-Key replay the first response
+	idempotent := idempotency.Middleware(idempotency.NewKeyRepository(db), idempotency.TTLFromEnv())
 
 	// Receipt parsing (optional — degrades gracefully if ANTHROPIC_API_KEY is not set)
 	var receiptHandler *receipt.Handler
@@ -74,28 +105,50 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins: origins,
 		AllowMethods: []string{"GET", "POST", "PATCH", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Member-Token"},
-		ExposeHeaders: []string{"Content-Length"},
+		AllowHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Member-Token", "X-Creator-Token", "Last-Event-ID", "Idempotency-Key", "If-Match", "If-None-Match"},
+		ExposeHeaders: []string{"Content-Length", "ETag", "Idempotent-Replayed", "X-Quota-Bytes-Used", "X-Quota-Bytes-Remaining", "X-Quota-Images-Used", "X-Quota-Images-Remaining"},
 	}))
 	r.GET("/health", getHealth)
 	r.GET("/api/bills/:id", handler.GetBill)
-	r.POST("/api/bills", handler.CreateBill)
+	r.POST("/api/bills", idempotent, handler.CreateBill)
 	r.PATCH("/api/bills/:id/shares/:shareId", handler.UpdatePersonSharePaid)
-	r.POST("/api/tabs", tabHandler.CreateTab)
+	r.POST("/api/tabs", idempotent, tabHandler.CreateTab)
 	r.GET("/api/tabs/:id", tabHandler.GetTab)
 	r.POST("/api/tabs/:id/bills", tabHandler.AddBillToTab)
+	r.DELETE("/api/tabs/:id/bills/:billId", tabHandler.RemoveBillFromTab)
+	r.POST("/api/tabs/:id/bills/:billId/move", tabHandler.MoveBill)
 	r.PATCH("/api/tabs/:id", tabHandler.UpdateTab)
 	r.POST("/api/tabs/:id/finalize", tabHandler.FinalizeTab)
+	r.POST("/api/tabs/:id/reopen", tabHandler.ReopenTab)
+	r.POST("/api/tabs/:id/periods/close", tabHandler.ClosePeriod)
+	r.GET("/api/tabs/:id/balances", tabHandler.GetBalances)
 	r.GET("/api/tabs/:id/settlements", tabHandler.GetSettlements)
 	r.PATCH("/api/tabs/:id/settlements/:settlementId", tabHandler.UpdateSettlement)
-	r.POST("/api/tabs/:id/join", tabHandler.JoinTab)
+	r.POST("/api/tabs/:id/join", idempotent, tabHandler.JoinTab)
 	r.GET("/api/tabs/:id/members", tabHandler.GetMembers)
+	r.GET("/api/tabs/:id/activity", auditHandler.ListActivity)
+	r.GET("/api/tabs/:id/events", eventHandler.StreamTabEvents)
+	r.GET("/api/tabs/:id/changes", syncHandler.GetChanges)
+	r.POST("/api/tabs/:id/changes", syncHandler.PushChanges)
+	r.POST("/api/tabs/:id/webhooks", idempotent, webhookHandler.CreateWebhook)
+	r.GET("/api/tabs/:id/webhooks", webhookHandler.ListWebhooks)
+	r.PATCH("/api/tabs/:id/webhooks/:webhookId", webhookHandler.UpdateWebhook)
+	r.DELETE("/api/tabs/:id/webhooks/:webhookId", webhookHandler.DeleteWebhook)
+	r.GET("/api/tabs/:id/webhooks/:webhookId/deliveries", webhookHandler.ListDeliveries)
+	r.GET("/api/tabs/:id/reminders", reminderHandler.GetReminders)
+	r.PUT("/api/tabs/:id/reminders", reminderHandler.UpdateReminders)
+	r.POST("/api/tabs/:id/templates", idempotent, templateHandler.CreateTemplate)
+	r.GET("/api/tabs/:id/templates", templateHandler.ListTemplates)
+	r.PUT("/api/tabs/:id/templates/:templateId", templateHandler.UpdateTemplate)
+	r.DELETE("/api/tabs/:id/templates/:templateId", templateHandler.DeleteTemplate)
+	r.POST("/api/tabs/:id/members/:memberId/aliases", idempotent, tabHandler.AddMemberAlias)
+	r.DELETE("/api/tabs/:id/aliases/:aliasId", tabHandler.RemoveMemberAlias)
 
 	if receiptHandler != nil {
 		r.POST("/api/receipts/parse", receiptHandler.ParseReceipt)
 	}
 
-	r.POST("/api/tabs/:id/images", imgHandler.UploadImage)
+	r.POST("/api/tabs/:id/images", idempotent, imgHandler.UploadImage)
 	r.GET("/api/tabs/:id/images", imgHandler.ListImages)
 	r.PATCH("/api/tabs/:id/images/:imageId", imgHandler.UpdateImage)
 	r.DELETE("/api/tabs/:id/images/:imageId", imgHandler.DeleteImage)
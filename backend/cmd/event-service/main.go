@@ -1 +1,80 @@
-package eventservice
+package main
+
+import (
+	"backend/internal/events"
+	"backend/internal/idempotency"
+	"backend/internal/reminder"
+	"backend/internal/template"
+	"backend/internal/webhook"
+	"backend/pkg/database"
+	"backend/pkg/models"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	db, err := database.InitDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	allowPrivate := webhook.AllowPrivateFromEnv()
+	webhookRepo := webhook.NewWebhookRepository(db)
+	webhookService := webhook.NewWebhookService(webhookRepo, allowPrivate)
+	webhookWorker := webhook.NewWorker(webhookRepo, allowPrivate)
+
+	// Reminders go out through the tab's webhooks and, when SMTP is configured, by email
+	reminderRepo := reminder.NewReminderRepository(db)
+	channels := []reminder.Channel{reminder.NewWebhookChannel(webhookService)}
+	var emailWorker *reminder.EmailWorker
+	if smtpConfig := reminder.SMTPFromEnv(); smtpConfig != nil {
+		channels = append(channels, reminder.NewEmailChannel(*smtpConfig, reminderRepo))
+		emailWorker = reminder.NewEmailWorker(reminderRepo, *smtpConfig)
+	} else {
+		fmt.Println("Email reminders disabled: SMTP_ADDR is not set")
+	}
+	reminderService := reminder.NewReminderService(reminderRepo, channels...)
+	reminderScheduler := reminder.NewScheduler(reminderRepo)
+	templateScheduler := template.NewScheduler(template.NewTemplateRepository(db))
+	idempotencyPurger := idempotency.NewPurger(idempotency.NewKeyRepository(db))
+
+	outbox := events.NewOutboxRepository(db)
+	dispatcher := events.NewDispatcher(outbox, events.HandlerFunc(logEvent), webhookService, reminderService)
+
+	r := gin.Default()
+	r.GET("/health", getHealth)
+	go func() {
+		fmt.Println("Event service starting on :8082")
+		if err := http.ListenAndServe(":8082", r); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	go webhookWorker.Run(ctx)
+	go reminderScheduler.Run(ctx)
+	if emailWorker != nil {
+		go emailWorker.Run(ctx)
+	}
+	go templateScheduler.Run(ctx)
+	go idempotencyPurger.Run(ctx)
+	dispatcher.Run(ctx)
+}
+
+func logEvent(ctx context.Context, event models.Event) error {
+	log.Printf("event %d %s on %s: %s", event.ID, event.Type, event.PartitionKey, event.Payload)
+	return nil
+}
+
+func getHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "event service - ok"})
+}
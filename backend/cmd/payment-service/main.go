@@ -1 +1,82 @@
-package paymentservice
+package main
+
+import (
+	"backend/internal/bill"
+	"backend/internal/idempotency"
+	"backend/internal/image"
+	"backend/internal/payments"
+	"backend/internal/tab"
+	"backend/pkg/database"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	db, err := database.InitDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	billService := bill.NewBillService(bill.NewBillRepository(db))
+	imgService := image.NewImageService(image.NewImageRepository(db), image.QuotaFromEnv())
+	tabService := tab.NewTabService(tab.NewTabRepository(db), imgService)
+
+	paymentRepo := payments.NewPaymentRepository(db)
+	paymentService := payments.NewPaymentService(paymentRepo)
+	handler := payments.NewPaymentHandler(paymentService, tabService, billService)
+
+	// Retries of payments sent with an Idempotency-Key replay the first response
+	idempotent := idempotency.Middleware(idempotency.NewKeyRepository(db), idempotency.TTLFromEnv())
+
+	r := gin.Default()
+
+	// Security headers
+	r.Use(func(c *gin.Context) {
+		c.Header("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("X-Frame-Options", "DENY")
+		c.Header("Referrer-Policy", "strict-origin-when-cross-origin")
+		c.Next()
+	})
+
+	// CORS — restrict to allowed origins
+	origins := []string{"https://billingtonapp.vercel.app"}
+	if extra := os.Getenv("CORS_ORIGINS"); extra != "" {
+		for _, o := range strings.Split(extra, ",") {
+			if trimmed := strings.TrimSpace(o); trimmed != "" {
+				origins = append(origins, trimmed)
+			}
+		}
+	}
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:  origins,
+		AllowMethods:  []string{"GET", "POST", "DELETE", "OPTIONS"},
+		AllowHeaders:  []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Member-Token", "X-Creator-Token", "Idempotency-Key"},
+		ExposeHeaders: []string{"Content-Length"},
+	}))
+
+	r.GET("/health", getHealth)
+	r.POST("/api/tabs/:id/settlements/:settlementId/payments", idempotent, handler.RecordSettlementPayment)
+	r.GET("/api/tabs/:id/payments", handler.ListTabPayments)
+	r.POST("/api/tabs/:id/payments/:paymentId/confirm", handler.ConfirmTabPayment)
+	r.POST("/api/tabs/:id/payments/:paymentId/dispute", handler.DisputeTabPayment)
+	r.DELETE("/api/tabs/:id/payments/:paymentId", handler.VoidTabPayment)
+	r.POST("/api/bills/:id/shares/:shareId/payments", idempotent, handler.RecordSharePayment)
+	r.GET("/api/bills/:id/payments", handler.ListBillPayments)
+	r.POST("/api/bills/:id/payments/:paymentId/confirm", handler.ConfirmBillPayment)
+	r.POST("/api/bills/:id/payments/:paymentId/dispute", handler.DisputeBillPayment)
+	r.DELETE("/api/bills/:id/payments/:paymentId", handler.VoidBillPayment)
+
+	fmt.Println("Payment service starting on :8083")
+	log.Fatal(http.ListenAndServe(":8083", r))
+}
+
+func getHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "payment service - ok"})
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"backend/internal/tabauth"
+	"backend/pkg/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TabResolver looks up tabs for token validation. Satisfied by tab.TabService.
+type TabResolver interface {
+	GetTabByRef(ref string) (*models.Tab, error)
+}
+
+type AuditHandler struct {
+	service AuditService
+	tabs    TabResolver
+}
+
+func NewAuditHandler(service AuditService, tabs TabResolver) *AuditHandler {
+	return &AuditHandler{service: service, tabs: tabs}
+}
+
+// ListActivity handles GET /api/tabs/:id/activity?before=&limit=
+// Returns the tab's audit trail newest first; pass next_before as ?before= for the next page.
+func (h *AuditHandler) ListActivity(c *gin.Context) {
+	t := tabauth.ValidateTab(c, h.tabs)
+	if t == nil {
+		return
+	}
+
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = parsed
+	}
+	var before uint
+	if raw := c.Query("before"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before cursor"})
+			return
+		}
+		before = uint(parsed)
+	}
+
+	activity, err := h.service.List(t.ID, before, limit)
+	if err != nil {
+		log.Printf("internal error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
+		return
+	}
+
+	models.NewMemberRefs(t.Members).Activity(activity)
+
+	var nextBefore *uint
+	if len(activity) == limit {
+		nextBefore = &activity[len(activity)-1].ID
+	}
+	c.JSON(http.StatusOK, gin.H{"activity": activity, "next_before": nextBefore})
+}
@@ -0,0 +1,33 @@
+package audit
+
+import (
+	"backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ActivityRepository only reads: entries are inserted by Record in the
+// transaction of the change they describe, and never changed.
+type ActivityRepository interface {
+	ListByTab(tabID uint, beforeID uint, limit int) ([]models.TabActivity, error)
+}
+
+type activityRepository struct {
+	db *gorm.DB
+}
+
+// ListByTab returns a tab's activity newest first. Pass the last ID of the
+// previous page as beforeID, or 0 for the first page.
+func (r *activityRepository) ListByTab(tabID uint, beforeID uint, limit int) ([]models.TabActivity, error) {
+	query := r.db.Where("tab_id = ?", tabID)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	var activity []models.TabActivity
+	err := query.Order("id DESC").Limit(limit).Find(&activity).Error
+	return activity, err
+}
+
+func NewActivityRepository(db *gorm.DB) ActivityRepository {
+	return &activityRepository{db: db}
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"backend/pkg/models"
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// AnonymousActor is recorded when a change is made with only the tab's access
+// token and no member token for that tab.
+const AnonymousActor = "anonymous token holder"
+
+// Entry describes one change to record. Before and After are marshalled to
+// JSON; leave either nil when it doesn't apply (e.g. Before on a create).
+type Entry struct {
+	TabID  uint
+	Actor  *models.TabMember
+	Action string
+	Target string
+	Before interface{}
+	After  interface{}
+}
+
+type AuditService interface {
+	List(tabID uint, beforeID uint, limit int) ([]models.TabActivity, error)
+}
+
+type auditService struct {
+	repo ActivityRepository
+}
+
+// Record writes an audit entry. Pass the transaction that makes the change,
+// as with events.Record, so the entry commits or rolls back with it.
+func Record(tx *gorm.DB, entry Entry) error {
+	activity, err := newActivity(entry)
+	if err != nil {
+		return err
+	}
+	return tx.Create(activity).Error
+}
+
+func (s *auditService) List(tabID uint, beforeID uint, limit int) ([]models.TabActivity, error) {
+	return s.repo.ListByTab(tabID, beforeID, limit)
+}
+
+func newActivity(entry Entry) (*models.TabActivity, error) {
+	activity := &models.TabActivity{
+		TabID:     entry.TabID,
+		ActorName: AnonymousActor,
+		Action:    entry.Action,
+		Target:    entry.Target,
+	}
+	// A member token only identifies the actor on its own tab
+	if entry.Actor != nil && entry.Actor.TabID == entry.TabID {
+		id := entry.Actor.ID
+		activity.ActorMemberID = &id
+		activity.ActorName = entry.Actor.DisplayName
+	}
+	var err error
+	if activity.Before, err = marshalOptional(entry.Before); err != nil {
+		return nil, err
+	}
+	if activity.After, err = marshalOptional(entry.After); err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
+
+func marshalOptional(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func NewAuditService(repo ActivityRepository) AuditService {
+	return &auditService{repo: repo}
+}
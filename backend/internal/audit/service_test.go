@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"backend/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ── Mock ActivityRepository ─────────────────────────────────────
+
+type mockActivityRepository struct {
+	created []models.TabActivity
+}
+
+// record stores the entry as Record would.
+func (m *mockActivityRepository) record(t *testing.T, entry Entry) {
+	t.Helper()
+	activity, err := newActivity(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activity.ID = uint(len(m.created) + 1)
+	m.created = append(m.created, *activity)
+}
+
+func (m *mockActivityRepository) ListByTab(tabID uint, beforeID uint, limit int) ([]models.TabActivity, error) {
+	var result []models.TabActivity
+	for i := len(m.created) - 1; i >= 0; i-- {
+		a := m.created[i]
+		if a.TabID != tabID || (beforeID > 0 && a.ID >= beforeID) {
+			continue
+		}
+		result = append(result, a)
+		if len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// ── Mock TabResolver ────────────────────────────────────────────
+
+type mockTabResolver struct {
+	tab *models.Tab
+}
+
+func (m *mockTabResolver) GetTabByRef(ref string) (*models.Tab, error) {
+	if m.tab == nil || ref != m.tab.PublicID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return m.tab, nil
+}
+
+// ── Tests ───────────────────────────────────────────────────────
+
+func TestNewActivity_AttributesMemberOnOwnTab(t *testing.T) {
+	alice := &models.TabMember{ID: 7, TabID: 1, DisplayName: "Alice"}
+	a, err := newActivity(Entry{
+		TabID:  1,
+		Actor:  alice,
+		Action: "tab.updated",
+		Target: "tab",
+		Before: gin.H{"name": "Trip"},
+		After:  gin.H{"name": "Beach Trip"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if a.ActorMemberID == nil || *a.ActorMemberID != 7 || a.ActorName != "Alice" {
+		t.Errorf("expected actor Alice (7), got %v %q", a.ActorMemberID, a.ActorName)
+	}
+	if string(a.Before) != `{"name":"Trip"}` || string(a.After) != `{"name":"Beach Trip"}` {
+		t.Errorf("unexpected before/after: %s / %s", a.Before, a.After)
+	}
+}
+
+func TestNewActivity_AnonymousActor(t *testing.T) {
+	entries := []Entry{
+		// No member token at all
+		{TabID: 1, Action: "bill.added", After: gin.H{"total": 12.5}},
+		// A member token from a different tab does not identify anyone here
+		{TabID: 1, Actor: &models.TabMember{ID: 9, TabID: 2, DisplayName: "Mallory"}, Action: "bill.removed"},
+	}
+	var created []*models.TabActivity
+	for _, entry := range entries {
+		a, err := newActivity(entry)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		created = append(created, a)
+	}
+
+	for _, a := range created {
+		if a.ActorMemberID != nil || a.ActorName != AnonymousActor {
+			t.Errorf("%s: expected anonymous actor, got %v %q", a.Action, a.ActorMemberID, a.ActorName)
+		}
+	}
+	if created[0].Before != nil {
+		t.Errorf("expected no before state on a create, got %s", created[0].Before)
+	}
+	if created[1].Before != nil || created[1].After != nil {
+		t.Error("expected nil before/after to stay empty")
+	}
+}
+
+func TestNewActivity_UnmarshalableState(t *testing.T) {
+	// Fails the change rather than recording a partial entry
+	if _, err := newActivity(Entry{TabID: 1, Action: "tab.updated", After: func() {}}); err == nil {
+		t.Error("expected an error for unmarshalable state")
+	}
+}
+
+func listActivity(h *AuditHandler, query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/tabs/tab1/activity"+query, nil)
+	c.Params = gin.Params{{Key: "id", Value: "tab1"}}
+	h.ListActivity(c)
+	return w
+}
+
+func TestListActivity_Paginates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &mockActivityRepository{}
+	svc := NewAuditService(repo)
+	for i := 0; i < 3; i++ {
+		repo.record(t, Entry{TabID: 1, Action: "bill.added"})
+	}
+	repo.record(t, Entry{TabID: 2, Action: "bill.added"})
+
+	h := NewAuditHandler(svc, &mockTabResolver{tab: &models.Tab{ID: 1, PublicID: "tab1", AccessToken: "secret"}})
+
+	w := listActivity(h, "?t=secret&limit=2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var page struct {
+		Activity   []models.TabActivity `json:"activity"`
+		NextBefore *uint                `json:"next_before"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Activity) != 2 || page.Activity[0].ID != 3 || page.Activity[1].ID != 2 {
+		t.Fatalf("expected entries 3 and 2 newest first, got %+v", page.Activity)
+	}
+	if page.NextBefore == nil || *page.NextBefore != 2 {
+		t.Fatalf("expected next_before 2, got %v", page.NextBefore)
+	}
+
+	w = listActivity(h, "?t=secret&limit=2&before=2")
+	page.NextBefore = nil
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Activity) != 1 || page.Activity[0].ID != 1 || page.NextBefore != nil {
+		t.Errorf("expected last page with entry 1, got %+v next=%v", page.Activity, page.NextBefore)
+	}
+}
+
+func TestListActivity_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewAuditHandler(NewAuditService(&mockActivityRepository{}), &mockTabResolver{tab: &models.Tab{ID: 1, PublicID: "tab1", AccessToken: "secret"}})
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"?t=wrong", http.StatusForbidden},
+		{"?t=secret&limit=0", http.StatusBadRequest},
+		{"?t=secret&limit=101", http.StatusBadRequest},
+		{"?t=secret&before=abc", http.StatusBadRequest},
+		{"?t=secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := listActivity(h, tt.query); w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.query, tt.want, w.Code)
+		}
+	}
+}
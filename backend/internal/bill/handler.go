@@ -1,13 +1,15 @@
 package bill
 
 import (
+	"backend/internal/payments"
+	"backend/pkg/etag"
 	"backend/pkg/models"
 	"backend/pkg/security"
 	"crypto/subtle"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -38,13 +40,9 @@ func (h *BillHandler) CreateBill(c *gin.Context) {
 		return
 	}
 
-	// Sanitize user-provided strings
-	bill.Name = security.SanitizeString(bill.Name)
-	for i := range bill.Items {
-		bill.Items[i].Name = security.SanitizeString(bill.Items[i].Name)
-	}
-	for i := range bill.PersonShares {
-		bill.PersonShares[i].PersonName = security.SanitizeString(bill.PersonShares[i].PersonName)
+	if err := SanitizeBill(&bill); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
 	}
 
 	token, err := security.GenerateSecureToken()
@@ -53,26 +51,78 @@ func (h *BillHandler) CreateBill(c *gin.Context) {
 		c.JSON(500, gin.H{"error": "an internal error occurred"})
 		return
 	}
+	creatorToken, err := security.GenerateSecureToken()
+	if err != nil {
+		log.Printf("internal error: %v", err)
+		c.JSON(500, gin.H{"error": "an internal error occurred"})
+		return
+	}
 	bill.AccessToken = token
+	bill.CreatorToken = creatorToken
 	//Call service
 	err = h.service.CreateBill(&bill)
 
 	//Return response based on result
 	if err != nil {
+		if IsSplitError(err) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("internal error: %v", err)
 		c.JSON(500, gin.H{"error": "an internal error occurred"})
 		return
 	}
 
-	// Return created bill with ID
+	// Return created bill with its public ID
 	c.JSON(201, gin.H{
-		"bill_id":      bill.ID,
-		"access_token": token,
-		"share_url":    fmt.Sprintf("%s/b/%d?t=%s", appDomain(), bill.ID, token),
+		"public_id":     bill.PublicID,
+		"access_token":  token,
+		"creator_token": creatorToken,
+		"share_url":     fmt.Sprintf("%s/b/%s?t=%s", appDomain(), bill.PublicID, token),
 	})
 }
 
-// getBillAndValidate parses the ID, fetches the bill, and validates the token.
+// SanitizeBill cleans the user-provided strings of a submitted bill,
+// normalizes its payment methods and clears what the server derives. Returns
+// an error if a payment method is invalid.
+func SanitizeBill(bill *models.Bill) error {
+	bill.Name = security.SanitizeString(bill.Name)
+	for i := range bill.Items {
+		bill.Items[i].Name = security.SanitizeString(bill.Items[i].Name)
+	}
+	for i, method := range bill.PaymentMethods {
+		normalized, err := payments.NormalizeMethod(method)
+		if err != nil {
+			return err
+		}
+		bill.PaymentMethods[i] = normalized
+	}
+	// Where the bill lives and how it got there is decided by the server
+	bill.ID = 0
+	bill.PublicID = ""
+	bill.TabID = nil
+	bill.AddedByMemberID = nil
+	bill.TabPeriod = 0
+	bill.TemplateID = nil
+	bill.ScheduledFor = nil
+	bill.ClientID = ""
+	// Versions are counted by the server
+	bill.Version = 0
+	for i := range bill.PersonShares {
+		bill.PersonShares[i].PersonName = security.SanitizeString(bill.PersonShares[i].PersonName)
+		// Paid state is derived from recorded payments
+		bill.PersonShares[i].PaymentState = models.PaymentState{}
+	}
+	for i := range bill.Splits {
+		bill.Splits[i].PersonName = security.SanitizeString(bill.Splits[i].PersonName)
+	}
+	for i := range bill.TipExcluded {
+		bill.TipExcluded[i] = security.SanitizeString(bill.TipExcluded[i])
+	}
+	return nil
+}
+
+// getBillAndValidate resolves the bill by public or legacy numeric ID and validates the token.
 // Returns the bill on success or writes an error and returns nil.
 func (h *BillHandler) getBillAndValidate(c *gin.Context) *models.Bill {
 	id := c.Param("id")
@@ -91,14 +141,12 @@ func (h *BillHandler) getBillAndValidate(c *gin.Context) *models.Bill {
 		return nil
 	}
 
-	idUint, err := strconv.ParseUint(id, 10, 32)
-	if err != nil {
-		c.JSON(400, gin.H{"error": "invalid id format"})
-		return nil
-	}
-
-	bill, err := h.service.GetBill(uint(idUint))
+	bill, err := h.service.GetBillByRef(id)
 	if err != nil {
+		if errors.Is(err, security.ErrInvalidRef) {
+			c.JSON(400, gin.H{"error": "invalid id format"})
+			return nil
+		}
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(404, gin.H{"error": "bill not found"})
 			return nil
@@ -122,6 +170,13 @@ func (h *BillHandler) GetBill(c *gin.Context) {
 	}
 
 	bill.AccessToken = ""
+	tag := etag.For(bill.Version)
+	c.Header("ETag", tag)
+	if etag.NoneMatch(c.GetHeader("If-None-Match"), tag) {
+		c.Status(304)
+		return
+	}
+	payments.AttachShareLinks(bill)
 	c.JSON(200, bill)
 }
 
@@ -131,34 +186,52 @@ func (h *BillHandler) UpdatePersonSharePaid(c *gin.Context) {
 		return
 	}
 
-	shareID, err := strconv.ParseUint(c.Param("shareId"), 10, 32)
-	if err != nil {
+	version, ok := etag.IfMatch(c, bill.Version, "bill")
+	if !ok {
+		return
+	}
+
+	shareRef := c.Param("shareId")
+	if _, _, err := security.ParseRef(shareRef); err != nil {
 		c.JSON(400, gin.H{"error": "invalid share id"})
 		return
 	}
 
 	// Verify the share belongs to this bill
-	found := false
-	for _, s := range bill.PersonShares {
-		if s.ID == uint(shareID) {
-			found = true
+	var share *models.PersonShare
+	for i := range bill.PersonShares {
+		if security.MatchesRef(shareRef, bill.PersonShares[i].ID, bill.PersonShares[i].PublicID) {
+			share = &bill.PersonShares[i]
 			break
 		}
 	}
-	if !found {
+	if share == nil {
 		c.JSON(404, gin.H{"error": "share not found on this bill"})
 		return
 	}
 
-	var body struct {
-		Paid *bool `json:"paid"`
-	}
-	if err := c.ShouldBindJSON(&body); err != nil || body.Paid == nil {
-		c.JSON(400, gin.H{"error": "paid field required"})
+	var body payments.StatusRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{"error": "status field required"})
 		return
 	}
 
-	if err := h.service.UpdatePersonSharePaid(uint(shareID), *body.Paid); err != nil {
+	// With the creator token the caller is the payee and can confirm or
+	// dispute; anyone else with the link marks their share sent.
+	action, err := body.Action(payments.BillCaller(bill, payments.CreatorToken(c)), share.MemberID)
+	if err == nil {
+		body.Reason = security.SanitizeString(body.Reason)
+		err = h.service.MarkPersonShare(bill.ID, share.ID, action, body.Reason, version)
+	}
+	if errors.Is(err, etag.ErrChanged) {
+		etag.Changed(c, "bill")
+		return
+	}
+	if err != nil {
+		if code := payments.StatusCode(err); code != 0 {
+			c.JSON(code, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("internal error: %v", err)
 		c.JSON(500, gin.H{"error": "an internal error occurred"})
 		return
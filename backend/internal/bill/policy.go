@@ -0,0 +1,188 @@
+package bill
+
+import (
+	"backend/pkg/models"
+	"errors"
+	"math"
+	"strings"
+)
+
+// Tax and tip policies. Proportional divides by each person's subtotal (for
+// tax, their taxable subtotal); equal divides evenly among the people it
+// applies to.
+const (
+	PolicyProportional = "proportional"
+	PolicyEqual        = "equal"
+)
+
+// Tip bases: what a tip percentage is taken of, and what proportional tips
+// are divided by.
+const (
+	TipPreTax  = "pre_tax"
+	TipPostTax = "post_tax"
+)
+
+var (
+	ErrUnknownPolicy       = errors.New("tax_policy and tip_policy must be proportional or equal")
+	ErrUnknownTipBase      = errors.New("tip_base must be pre_tax or post_tax")
+	ErrUnknownTipExcluded  = errors.New("tip_excluded must name people on the bill")
+	ErrNoTipPayers         = errors.New("everyone is excluded from the tip")
+	ErrNoTaxableItems      = errors.New("tax needs at least one taxable item")
+	ErrPolicyNotApplicable = errors.New("tax and tip policies don't apply to exact splits")
+)
+
+// normalizePolicies fills in the default policies and checks the rest.
+func normalizePolicies(bill *models.Bill) error {
+	bill.TaxPolicy = normalizeChoice(bill.TaxPolicy, PolicyProportional)
+	bill.TipPolicy = normalizeChoice(bill.TipPolicy, PolicyProportional)
+	bill.TipBase = normalizeChoice(bill.TipBase, TipPreTax)
+	for _, policy := range []string{bill.TaxPolicy, bill.TipPolicy} {
+		if policy != PolicyProportional && policy != PolicyEqual {
+			return ErrUnknownPolicy
+		}
+	}
+	if bill.TipBase != TipPreTax && bill.TipBase != TipPostTax {
+		return ErrUnknownTipBase
+	}
+
+	excluded := make([]string, 0, len(bill.TipExcluded))
+	for _, name := range bill.TipExcluded {
+		if name = strings.TrimSpace(name); name == "" {
+			return ErrUnknownTipExcluded
+		}
+		excluded = append(excluded, name)
+	}
+	bill.TipExcluded = excluded
+	return nil
+}
+
+// defaultPolicies reports whether the bill leaves tax and tip to the
+// defaults, as exact splits require.
+func defaultPolicies(bill *models.Bill) bool {
+	return bill.TaxPolicy == PolicyProportional && bill.TipPolicy == PolicyProportional &&
+		bill.TipBase == TipPreTax && len(bill.TipExcluded) == 0
+}
+
+func normalizeChoice(value, fallback string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// checkAmounts validates the bill's amounts and fills in the tip from
+// tip_percentage, and the total, when they are omitted.
+func checkAmounts(bill *models.Bill) error {
+	for _, amount := range []float64{bill.Subtotal, bill.Tax, bill.TipAmount, bill.TipPercentage, bill.ServiceCharge, bill.DeliveryFee, bill.Total} {
+		if amount < 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+			return ErrNegativeAmount
+		}
+	}
+
+	if bill.TipAmount == 0 && bill.TipPercentage > 0 {
+		base := toCents(bill.Subtotal)
+		if bill.TipBase == TipPostTax {
+			base += toCents(bill.Tax)
+		}
+		bill.TipAmount = cents(int64(math.Round(float64(base) * bill.TipPercentage / 100)))
+	}
+
+	sum := toCents(bill.Subtotal) + toCents(bill.Tax) + toCents(bill.TipAmount) + toCents(bill.ServiceCharge) + toCents(bill.DeliveryFee)
+	if bill.Total == 0 {
+		bill.Total = cents(sum)
+	}
+	if sum != toCents(bill.Total) {
+		return ErrTotalMismatch
+	}
+	return nil
+}
+
+// taxWeights divides tax by taxable subtotal, or evenly among the people
+// with taxable items.
+func taxWeights(bill *models.Bill, portions []portion) ([]float64, error) {
+	weights := make([]float64, len(portions))
+	var sum float64
+	for i, p := range portions {
+		if p.taxable <= 0 {
+			continue
+		}
+		weights[i] = p.taxable
+		if bill.TaxPolicy == PolicyEqual {
+			weights[i] = 1
+		}
+		sum += weights[i]
+	}
+	if sum == 0 && toCents(bill.Tax) != 0 {
+		return nil, ErrNoTaxableItems
+	}
+	return weights, nil
+}
+
+// tipWeights divides the tip by subtotal (plus tax share, for post-tax tips),
+// or evenly, among everyone who isn't excluded from it. taxWeights are the
+// weights the tax was divided by.
+func tipWeights(bill *models.Bill, portions []portion, taxWeights []float64) ([]float64, error) {
+	var taxTotal float64
+	for _, w := range taxWeights {
+		taxTotal += w
+	}
+
+	excluded := make(map[string]bool, len(bill.TipExcluded))
+	for _, name := range bill.TipExcluded {
+		excluded[strings.ToLower(name)] = true
+	}
+
+	weights := make([]float64, len(portions))
+	var sum float64
+	for i, p := range portions {
+		key := strings.ToLower(p.name)
+		if excluded[key] {
+			delete(excluded, key)
+			continue
+		}
+		base := p.share
+		if bill.TipBase == TipPostTax && taxTotal > 0 {
+			base += float64(toCents(bill.Tax)) * taxWeights[i] / taxTotal
+		}
+		if base <= 0 {
+			continue
+		}
+		weights[i] = base
+		if bill.TipPolicy == PolicyEqual {
+			weights[i] = 1
+		}
+		sum += weights[i]
+	}
+	if len(excluded) > 0 {
+		return nil, ErrUnknownTipExcluded
+	}
+	if sum == 0 && toCents(bill.TipAmount) != 0 {
+		return nil, ErrNoTipPayers
+	}
+	return weights, nil
+}
+
+// feeWeights divides service charges by subtotal and delivery fees evenly,
+// among the people with something on the bill. A bill of nothing but fees
+// divides them evenly among everyone on it.
+func feeWeights(portions []portion, proportional bool) []float64 {
+	weights := make([]float64, len(portions))
+	var sum float64
+	for i, p := range portions {
+		if p.share <= 0 {
+			continue
+		}
+		weights[i] = 1
+		if proportional {
+			weights[i] = p.share
+		}
+		sum += weights[i]
+	}
+	if sum == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	return weights
+}
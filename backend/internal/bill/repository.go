@@ -1,17 +1,24 @@
 package bill
 
 import (
+	"backend/internal/audit"
+	"backend/internal/events"
+	"backend/internal/payments"
 	"backend/pkg/models"
+	"backend/pkg/security"
 
+	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type BillRepository interface {
 	Create(bill *models.Bill) error
 	GetById(id uint) (bill *models.Bill, err error)
+	GetByPublicID(publicID string) (bill *models.Bill, err error)
 	Update(bill *models.Bill) error
 	Delete(id uint) error
-	UpdatePersonSharePaid(id uint, paid bool) error
+	MarkPersonShare(billID uint, id uint, action payments.Action, reason string, ifVersion *uint) error
+	GetMembersByRef(refs []security.Ref) ([]models.TabMember, error)
 }
 
 type billRepository struct {
@@ -19,7 +26,16 @@ type billRepository struct {
 }
 
 func (b *billRepository) Create(bill *models.Bill) error {
-	return b.db.Create(bill).Error
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(bill).Error; err != nil {
+			return err
+		}
+		return events.RecordBill(tx, bill.ID, bill.TabID, events.BillCreated{
+			BillID: security.Ref(bill.PublicID),
+			Name:   bill.Name,
+			Total:  bill.Total,
+		})
+	})
 }
 
 func (b *billRepository) Delete(id uint) error {
@@ -28,20 +44,103 @@ func (b *billRepository) Delete(id uint) error {
 
 func (b *billRepository) GetById(id uint) (bill *models.Bill, err error) {
 	bill = &models.Bill{}
-	err = b.db.
+	if err = b.preloaded().First(bill, id).Error; err != nil {
+		return bill, err
+	}
+	return bill, b.fillMemberRefs(bill)
+}
+
+func (b *billRepository) GetByPublicID(publicID string) (bill *models.Bill, err error) {
+	bill = &models.Bill{}
+	if err = b.preloaded().Where("public_id = ?", publicID).First(bill).Error; err != nil {
+		return bill, err
+	}
+	return bill, b.fillMemberRefs(bill)
+}
+
+// fillMemberRefs fills in the bill's member references from its tab's
+// members. Bills outside a tab have none.
+func (b *billRepository) fillMemberRefs(bill *models.Bill) error {
+	if bill.TabID == nil {
+		return nil
+	}
+	var members []models.TabMember
+	if err := b.db.Select("id", "public_id").Where("tab_id = ?", *bill.TabID).Find(&members).Error; err != nil {
+		return err
+	}
+	models.NewMemberRefs(members).Bill(bill)
+	return nil
+}
+
+// GetMembersByRef returns the members refs name, by public or legacy numeric
+// ID. Refs that name no member are skipped.
+func (b *billRepository) GetMembersByRef(refs []security.Ref) ([]models.TabMember, error) {
+	var ids []uint
+	var publicIDs []string
+	for _, ref := range refs {
+		id, publicID, err := security.ParseRef(string(ref))
+		if err != nil {
+			continue
+		}
+		if publicID != "" {
+			publicIDs = append(publicIDs, publicID)
+		} else {
+			ids = append(ids, id)
+		}
+	}
+	members := []models.TabMember{}
+	if len(ids) == 0 && len(publicIDs) == 0 {
+		return members, nil
+	}
+	err := b.db.Select("id", "public_id").Where("id IN ? OR public_id IN ?", ids, publicIDs).Find(&members).Error
+	return members, err
+}
+
+func (b *billRepository) preloaded() *gorm.DB {
+	return b.db.
 		Preload("Items.Assignments").
 		Preload("Participants").
-		Preload("PersonShares").
-		First(bill, id).Error
-	return bill, err
+		Preload("Splits", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("PersonShares")
 }
 
 func (b *billRepository) Update(bill *models.Bill) error {
 	return b.db.Session(&gorm.Session{FullSaveAssociations: true}).Updates(bill).Error
 }
 
-func (b *billRepository) UpdatePersonSharePaid(id uint, paid bool) error {
-	return b.db.Model(&models.PersonShare{}).Where("id = ?", id).Update("paid", paid).Error
+// MarkPersonShare records, confirms, disputes or voids the share's payments
+// as action requires; its derived paid state follows. With ifVersion set, the
+// bill must still be at that version. Bills on a tab record the change in its
+// activity.
+func (b *billRepository) MarkPersonShare(billID uint, id uint, action payments.Action, reason string, ifVersion *uint) error {
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		if err := events.RequireVersion(tx, &models.Bill{}, billID, ifVersion); err != nil {
+			return err
+		}
+		bill := &models.Bill{}
+		if err := tx.Select("id", "public_id", "tab_id").First(bill, billID).Error; err != nil {
+			return err
+		}
+		share := &models.PersonShare{}
+		if err := tx.First(share, id).Error; err != nil {
+			return err
+		}
+		if err := payments.Apply(tx, payments.Target{ShareID: id}, action, reason); err != nil {
+			return err
+		}
+		if bill.TabID == nil {
+			return nil
+		}
+		// Bill links carry no member identity, so the change is attributed to
+		// the anonymous token holder.
+		return audit.Record(tx, audit.Entry{
+			TabID:  *bill.TabID,
+			Action: "share." + string(action),
+			Target: "bill:" + bill.PublicID + "/share:" + share.PublicID,
+			Before: gin.H{"person_name": share.PersonName, "status": share.Status, "amount_paid": share.AmountPaid},
+			After:  gin.H{"person_name": share.PersonName, "reason": reason},
+		})
+	})
 }
 
 func NewBillRepository(db *gorm.DB) BillRepository {
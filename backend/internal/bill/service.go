@@ -1,18 +1,35 @@
 package bill
 
-import "backend/pkg/models"
+import (
+	"backend/internal/payments"
+	"backend/pkg/models"
+	"backend/pkg/security"
+)
 
 type BillService interface {
 	CreateBill(bill *models.Bill) error
 	GetBill(id uint) (bill *models.Bill, err error)
-	UpdatePersonSharePaid(id uint, paid bool) error
+	GetBillByRef(ref string) (bill *models.Bill, err error)
+	MarkPersonShare(billID uint, id uint, action payments.Action, reason string, ifVersion *uint) error
 }
 
 type billService struct {
 	repo BillRepository
 }
 
+// CreateBill links the bill's member references, validates its split and,
+// for bill-level split modes, computes its person shares before saving it.
 func (b *billService) CreateBill(bill *models.Bill) error {
+	members, err := b.repo.GetMembersByRef(memberRefsOf(bill))
+	if err != nil {
+		return err
+	}
+	if err := ResolveMembers(bill, models.NewMemberRefs(members)); err != nil {
+		return err
+	}
+	if err := ApplySplit(bill); err != nil {
+		return err
+	}
 	return b.repo.Create(bill)
 }
 
@@ -20,8 +37,20 @@ func (b *billService) GetBill(id uint) (bill *models.Bill, err error) {
 	return b.repo.GetById(id)
 }
 
-func (b *billService) UpdatePersonSharePaid(id uint, paid bool) error {
-	return b.repo.UpdatePersonSharePaid(id, paid)
+// GetBillByRef looks a bill up by public ID, or by numeric ID for legacy links.
+func (b *billService) GetBillByRef(ref string) (bill *models.Bill, err error) {
+	id, publicID, err := security.ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if publicID != "" {
+		return b.repo.GetByPublicID(publicID)
+	}
+	return b.repo.GetById(id)
+}
+
+func (b *billService) MarkPersonShare(billID uint, id uint, action payments.Action, reason string, ifVersion *uint) error {
+	return b.repo.MarkPersonShare(billID, id, action, reason, ifVersion)
 }
 
 func NewBillService(repo BillRepository) BillService {
@@ -1,15 +1,25 @@
 package bill
 
 import (
+	"backend/internal/payments"
 	"backend/pkg/models"
+	"backend/pkg/security"
 	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/gin-gonic/gin"
 )
 
 // ── Mock BillRepository ─────────────────────────────────────────
 
 type mockBillRepository struct {
-	bills map[uint]*models.Bill
+	bills   map[uint]*models.Bill
+	members []models.TabMember
 
 	createErr          error
 	getByIdErr         error
@@ -17,8 +27,9 @@ type mockBillRepository struct {
 	deleteErr          error
 	updateSharePaidErr error
 
-	updatedShareID   uint
-	updatedSharePaid bool
+	updatedShareID     uint
+	updatedShareAction payments.Action
+	updatedShareReason string
 }
 
 func newMockRepo() *mockBillRepository {
@@ -47,40 +58,518 @@ func (m *mockBillRepository) GetById(id uint) (*models.Bill, error) {
 	return bill, nil
 }
 
+func (m *mockBillRepository) GetByPublicID(publicID string) (*models.Bill, error) {
+	for _, bill := range m.bills {
+		if bill.PublicID == publicID {
+			return bill, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
 func (m *mockBillRepository) Update(bill *models.Bill) error { return m.updateErr }
 func (m *mockBillRepository) Delete(id uint) error           { return m.deleteErr }
 
-func (m *mockBillRepository) UpdatePersonSharePaid(id uint, paid bool) error {
+func (m *mockBillRepository) GetMembersByRef(refs []security.Ref) ([]models.TabMember, error) {
+	var result []models.TabMember
+	for _, member := range m.members {
+		for _, ref := range refs {
+			if security.MatchesRef(string(ref), member.ID, member.PublicID) {
+				result = append(result, member)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (m *mockBillRepository) MarkPersonShare(billID uint, id uint, action payments.Action, reason string, ifVersion *uint) error {
 	m.updatedShareID = id
-	m.updatedSharePaid = paid
+	m.updatedShareAction = action
+	m.updatedShareReason = reason
 	return m.updateSharePaidErr
 }
 
 // ── Tests ───────────────────────────────────────────────────────
 
-func TestUpdatePersonSharePaid_Success(t *testing.T) {
+func TestMarkPersonShare_Success(t *testing.T) {
 	repo := newMockRepo()
 	svc := NewBillService(repo)
 
-	err := svc.UpdatePersonSharePaid(5, true)
+	err := svc.MarkPersonShare(1, 5, payments.ActionDispute, "never arrived", nil)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
 	if repo.updatedShareID != 5 {
 		t.Errorf("expected share ID 5, got %d", repo.updatedShareID)
 	}
-	if !repo.updatedSharePaid {
-		t.Error("expected paid to be true")
+	if repo.updatedShareAction != payments.ActionDispute || repo.updatedShareReason != "never arrived" {
+		t.Errorf("expected dispute with reason, got %q %q", repo.updatedShareAction, repo.updatedShareReason)
 	}
 }
 
-func TestUpdatePersonSharePaid_Error(t *testing.T) {
+func TestMarkPersonShare_Error(t *testing.T) {
 	repo := newMockRepo()
 	repo.updateSharePaidErr = errors.New("db error")
 	svc := NewBillService(repo)
 
-	err := svc.UpdatePersonSharePaid(5, true)
+	err := svc.MarkPersonShare(1, 5, payments.ActionSend, "", nil)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
 }
+
+func TestGetBillByRef(t *testing.T) {
+	repo := newMockRepo()
+	repo.bills[3] = &models.Bill{ID: 3, PublicID: "4zX9kQ2mN8pL1vR7tY3wBc"}
+	svc := NewBillService(repo)
+
+	for _, ref := range []string{"3", "4zX9kQ2mN8pL1vR7tY3wBc"} {
+		bill, err := svc.GetBillByRef(ref)
+		if err != nil {
+			t.Fatalf("expected no error for %q, got %v", ref, err)
+		}
+		if bill.ID != 3 {
+			t.Errorf("expected bill 3 for %q, got %d", ref, bill.ID)
+		}
+	}
+
+	if _, err := svc.GetBillByRef("../3"); !errors.Is(err, security.ErrInvalidRef) {
+		t.Errorf("expected ErrInvalidRef, got %v", err)
+	}
+}
+
+func splitBill(mode string, splits ...models.BillSplit) *models.Bill {
+	return &models.Bill{
+		Subtotal:  80.00,
+		Tax:       6.40,
+		TipAmount: 13.60,
+		Total:     100.00,
+		Items:     []models.BillItem{{Name: "Pizza", Price: 50}, {Name: "Wings", Price: 30}},
+		SplitMode: mode,
+		Splits:    splits,
+	}
+}
+
+func shareTotals(bill *models.Bill) []float64 {
+	var totals []float64
+	for _, s := range bill.PersonShares {
+		totals = append(totals, s.Total)
+	}
+	return totals
+}
+
+func TestCreateBill_SplitModes(t *testing.T) {
+	cases := []struct {
+		name string
+		bill *models.Bill
+		want []float64
+	}{
+		{"equal", splitBill("equal", models.BillSplit{PersonName: "Alice"}, models.BillSplit{PersonName: "Bob"}, models.BillSplit{PersonName: "Cara"}), []float64{33.34, 33.33, 33.33}},
+		{"shares", splitBill("shares", models.BillSplit{PersonName: "Alice", Value: 2}, models.BillSplit{PersonName: "Bob", Value: 1}, models.BillSplit{PersonName: "Cara", Value: 1}), []float64{50, 25, 25}},
+		{"exact", splitBill("exact", models.BillSplit{PersonName: "Alice", Value: 60.01}, models.BillSplit{PersonName: "Bob", Value: 39.99}), []float64{60.01, 39.99}},
+		{"percentage", splitBill("percentage", models.BillSplit{PersonName: "Alice", Value: 70}, models.BillSplit{PersonName: "Bob", Value: 30}), []float64{70, 30}},
+	}
+	for _, tc := range cases {
+		svc := NewBillService(newMockRepo())
+		if err := svc.CreateBill(tc.bill); err != nil {
+			t.Fatalf("%s: expected no error, got %v", tc.name, err)
+		}
+		got := shareTotals(tc.bill)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: expected %d shares, got %v", tc.name, len(tc.want), got)
+		}
+		var subtotal, tax, tip float64
+		for i, share := range tc.bill.PersonShares {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: share %d total = %.2f, want %.2f", tc.name, i, got[i], tc.want[i])
+			}
+			if toCents(share.Subtotal+share.TaxShare+share.TipShare) != toCents(share.Total) {
+				t.Errorf("%s: share %d parts don't add up: %+v", tc.name, i, share)
+			}
+			subtotal += share.Subtotal
+			tax += share.TaxShare
+			tip += share.TipShare
+		}
+		if toCents(subtotal) != 8000 || toCents(tax) != 640 || toCents(tip) != 1360 {
+			t.Errorf("%s: shares add up to %.2f/%.2f/%.2f", tc.name, subtotal, tax, tip)
+		}
+	}
+}
+
+func TestCreateBill_SplitItemDetails(t *testing.T) {
+	bill := splitBill("percentage", models.BillSplit{PersonName: "Alice", Value: 100}, models.BillSplit{PersonName: "Bob", Value: 0})
+	if err := NewBillService(newMockRepo()).CreateBill(bill); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	alice, bob := bill.PersonShares[0], bill.PersonShares[1]
+	if len(alice.Items) != 2 || alice.Items[0].Amount != 50 || alice.Items[0].IsShared {
+		t.Errorf("unexpected items for Alice: %+v", alice.Items)
+	}
+	if len(bob.Items) != 0 || bob.Total != 0 {
+		t.Errorf("expected Bob to owe nothing, got %+v", bob)
+	}
+}
+
+func TestCreateBill_SplitValidation(t *testing.T) {
+	alice := models.BillSplit{PersonName: "Alice", Value: 50}
+	bob := models.BillSplit{PersonName: "Bob", Value: 50}
+	mismatched := splitBill("equal", alice, bob)
+	mismatched.Total = 99
+
+	cases := []struct {
+		name string
+		bill *models.Bill
+		want error
+	}{
+		{"unknown mode", splitBill("thirds", alice), ErrUnknownSplitMode},
+		{"no splits", splitBill("equal"), ErrSplitsRequired},
+		{"splits without mode", splitBill("", alice), ErrUnexpectedSplits},
+		{"duplicate person", splitBill("equal", alice, models.BillSplit{PersonName: " alice "}), ErrInvalidSplit},
+		{"negative value", splitBill("exact", models.BillSplit{PersonName: "Alice", Value: -1}), ErrInvalidSplit},
+		{"zero shares", splitBill("shares", alice, models.BillSplit{PersonName: "Bob"}), ErrInvalidShares},
+		{"exact short", splitBill("exact", alice, models.BillSplit{PersonName: "Bob", Value: 49.99}), ErrExactMismatch},
+		{"percent over", splitBill("percentage", alice, models.BillSplit{PersonName: "Bob", Value: 51}), ErrPercentMismatch},
+		{"total mismatch", mismatched, ErrTotalMismatch},
+	}
+	for _, tc := range cases {
+		repo := newMockRepo()
+		err := NewBillService(repo).CreateBill(tc.bill)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: error = %v, want %v", tc.name, err, tc.want)
+		}
+		if len(repo.bills) != 0 {
+			t.Errorf("%s: expected bill not to be saved", tc.name)
+		}
+	}
+}
+
+func TestCreateBill_ItemsModeKeepsShares(t *testing.T) {
+	bill := &models.Bill{Total: 10, PersonShares: []models.PersonShare{{PersonName: "Alice", Total: 10}}}
+	if err := NewBillService(newMockRepo()).CreateBill(bill); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if bill.SplitMode != SplitItems || len(bill.PersonShares) != 1 || bill.PersonShares[0].Total != 10 {
+		t.Errorf("expected client shares to be kept, got %s %+v", bill.SplitMode, bill.PersonShares)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	got := allocate(5, []float64{1, 1, 1})
+	if got[0] != 2 || got[1] != 2 || got[2] != 1 {
+		t.Errorf("expected leftover cents to go first, got %v", got)
+	}
+	if got := allocate(1000, []float64{0, 0}); got[0] != 0 || got[1] != 0 {
+		t.Errorf("expected zeros for zero weights, got %v", got)
+	}
+}
+
+// policyBill is $100 of items across three people: Alice has the steak, Bob
+// the wine and the salad is shared three ways.
+func policyBill(wineExempt bool) *models.Bill {
+	return &models.Bill{
+		Subtotal:  100,
+		Tax:       8,
+		TipAmount: 18,
+		Items: []models.BillItem{
+			{Name: "Steak", Price: 40, Assignments: []models.ItemAssignment{{PersonName: "Alice", Percentage: 100}}},
+			{Name: "Wine", Price: 30, TaxExempt: wineExempt, Assignments: []models.ItemAssignment{{PersonName: "Bob", Percentage: 100}}},
+			{Name: "Salad", Price: 30, Assignments: []models.ItemAssignment{
+				{PersonName: "Alice", Percentage: 33.34}, {PersonName: "bob", Percentage: 33.33}, {PersonName: "Cara", Percentage: 33.33},
+			}},
+		},
+	}
+}
+
+func closeTo(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance+1e-9
+}
+
+func TestCreateBill_PolicyCombinations(t *testing.T) {
+	for _, taxPolicy := range []string{PolicyProportional, PolicyEqual} {
+		for _, tipPolicy := range []string{PolicyProportional, PolicyEqual} {
+			for _, tipBase := range []string{TipPreTax, TipPostTax} {
+				for _, exempt := range []bool{false, true} {
+					for _, excludeCara := range []bool{false, true} {
+						for _, fees := range []bool{false, true} {
+							name := fmt.Sprintf("tax=%s tip=%s base=%s exempt=%v exclude=%v fees=%v", taxPolicy, tipPolicy, tipBase, exempt, excludeCara, fees)
+							bill := policyBill(exempt)
+							bill.TaxPolicy, bill.TipPolicy, bill.TipBase = taxPolicy, tipPolicy, tipBase
+							if excludeCara {
+								bill.TipExcluded = []string{"cara"}
+							}
+							if fees {
+								bill.ServiceCharge, bill.DeliveryFee = 5, 3.01
+							}
+							checkPolicyShares(t, name, bill)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func checkPolicyShares(t *testing.T, name string, bill *models.Bill) {
+	t.Helper()
+	if err := NewBillService(newMockRepo()).CreateBill(bill); err != nil {
+		t.Errorf("%s: expected no error, got %v", name, err)
+		return
+	}
+	shares := bill.PersonShares
+	if len(shares) != 3 || shares[0].PersonName != "Alice" || shares[1].PersonName != "Bob" || shares[2].PersonName != "Cara" {
+		t.Errorf("%s: unexpected people %+v", name, shares)
+		return
+	}
+
+	var subtotal, tax, tip, service, delivery, total float64
+	for _, s := range shares {
+		parts := s.Subtotal + s.TaxShare + s.TipShare + s.ServiceChargeShare + s.DeliveryFeeShare
+		if toCents(parts) != toCents(s.Total) {
+			t.Errorf("%s: %s's parts add up to %.2f, total %.2f", name, s.PersonName, parts, s.Total)
+		}
+		subtotal += s.Subtotal
+		tax += s.TaxShare
+		tip += s.TipShare
+		service += s.ServiceChargeShare
+		delivery += s.DeliveryFeeShare
+		total += s.Total
+	}
+	for _, c := range []struct {
+		field     string
+		got, want float64
+	}{
+		{"subtotal", subtotal, bill.Subtotal}, {"tax", tax, bill.Tax}, {"tip", tip, bill.TipAmount},
+		{"service charge", service, bill.ServiceCharge}, {"delivery fee", delivery, bill.DeliveryFee}, {"total", total, bill.Total},
+	} {
+		if toCents(c.got) != toCents(c.want) {
+			t.Errorf("%s: %s shares add up to %.2f, want %.2f", name, c.field, c.got, c.want)
+		}
+	}
+
+	// Subtotals follow the items: Alice 40 + 10, Bob 30 + 10, Cara 10
+	if shares[0].Subtotal != 50 || shares[1].Subtotal != 40 || shares[2].Subtotal != 10 {
+		t.Errorf("%s: unexpected subtotals %.2f/%.2f/%.2f", name, shares[0].Subtotal, shares[1].Subtotal, shares[2].Subtotal)
+	}
+
+	// Tax is divided by taxable subtotal, or evenly
+	taxable := []float64{50, 40, 10}
+	if bill.Items[1].TaxExempt {
+		taxable[1] = 10
+	}
+	for i, s := range shares {
+		want := bill.Tax * taxable[i] / (taxable[0] + taxable[1] + taxable[2])
+		if bill.TaxPolicy == PolicyEqual {
+			want = bill.Tax / 3
+		}
+		if !closeTo(s.TaxShare, want, 0.01) {
+			t.Errorf("%s: %s's tax = %.2f, want about %.2f", name, s.PersonName, s.TaxShare, want)
+		}
+	}
+
+	// Tip is divided among everyone not excluded, by pre- or post-tax
+	// amount, or evenly
+	bases := make([]float64, 3)
+	var baseTotal float64
+	for i, s := range shares {
+		if i == 2 && len(bill.TipExcluded) > 0 {
+			continue
+		}
+		bases[i] = s.Subtotal
+		if bill.TipBase == TipPostTax {
+			bases[i] += s.TaxShare
+		}
+		if bill.TipPolicy == PolicyEqual {
+			bases[i] = 1
+		}
+		baseTotal += bases[i]
+	}
+	for i, s := range shares {
+		want := bill.TipAmount * bases[i] / baseTotal
+		if !closeTo(s.TipShare, want, 0.02) {
+			t.Errorf("%s: %s's tip = %.2f, want about %.2f", name, s.PersonName, s.TipShare, want)
+		}
+	}
+
+	// Service charges follow subtotal; delivery fees are even
+	for i, s := range shares {
+		if want := bill.ServiceCharge * s.Subtotal / 100; !closeTo(s.ServiceChargeShare, want, 0.01) {
+			t.Errorf("%s: %s's service charge = %.2f, want about %.2f", name, shares[i].PersonName, s.ServiceChargeShare, want)
+		}
+		if want := bill.DeliveryFee / 3; !closeTo(s.DeliveryFeeShare, want, 0.01) {
+			t.Errorf("%s: %s's delivery fee = %.2f, want about %.2f", name, shares[i].PersonName, s.DeliveryFeeShare, want)
+		}
+	}
+}
+
+func TestCreateBill_PoliciesWithSplitModes(t *testing.T) {
+	bill := splitBill("equal", models.BillSplit{PersonName: "Alice"}, models.BillSplit{PersonName: "Bob"})
+	bill.TipExcluded = []string{"Bob"}
+	bill.Items[1].TaxExempt = true // $30 of the $80 is exempt, split evenly
+	if err := NewBillService(newMockRepo()).CreateBill(bill); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	alice, bob := bill.PersonShares[0], bill.PersonShares[1]
+	if alice.TipShare != 13.60 || bob.TipShare != 0 {
+		t.Errorf("expected Alice to cover the tip, got %.2f/%.2f", alice.TipShare, bob.TipShare)
+	}
+	if alice.TaxShare != 3.20 || bob.TaxShare != 3.20 {
+		t.Errorf("expected tax split evenly, got %.2f/%.2f", alice.TaxShare, bob.TaxShare)
+	}
+}
+
+func TestCreateBill_TipPercentage(t *testing.T) {
+	for _, tc := range []struct {
+		base string
+		tip  float64
+	}{
+		{TipPreTax, 20.00},
+		{TipPostTax, 21.60},
+	} {
+		bill := policyBill(false)
+		bill.TipAmount, bill.TipPercentage, bill.TipBase = 0, 20, tc.base
+		if err := NewBillService(newMockRepo()).CreateBill(bill); err != nil {
+			t.Fatalf("%s: expected no error, got %v", tc.base, err)
+		}
+		if bill.TipAmount != tc.tip || bill.Total != 108+tc.tip {
+			t.Errorf("%s: expected tip %.2f and total %.2f, got %.2f and %.2f", tc.base, tc.tip, 108+tc.tip, bill.TipAmount, bill.Total)
+		}
+	}
+}
+
+func TestCreateBill_ExactSplitWithFees(t *testing.T) {
+	bill := splitBill("exact", models.BillSplit{PersonName: "Alice", Value: 66.67}, models.BillSplit{PersonName: "Bob", Value: 38.33})
+	bill.DeliveryFee = 5
+	bill.Total = 105
+	if err := NewBillService(newMockRepo()).CreateBill(bill); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for i, want := range []float64{66.67, 38.33} {
+		s := bill.PersonShares[i]
+		parts := s.Subtotal + s.TaxShare + s.TipShare + s.ServiceChargeShare + s.DeliveryFeeShare
+		if s.Total != want || toCents(parts) != toCents(want) {
+			t.Errorf("share %d: total %.2f from parts %.2f, want %.2f", i, s.Total, parts, want)
+		}
+	}
+}
+
+func TestCreateBill_PolicyValidation(t *testing.T) {
+	everyoneExcluded := policyBill(false)
+	everyoneExcluded.TipExcluded = []string{"Alice", "Bob", "Cara"}
+	allExempt := policyBill(false)
+	for i := range allExempt.Items {
+		allExempt.Items[i].TaxExempt = true
+	}
+	badPercent := policyBill(false)
+	badPercent.Items[2].Assignments[0].Percentage = 50
+	unassigned := policyBill(false)
+	unassigned.Items[0].Assignments = nil
+	shortSubtotal := policyBill(false)
+	shortSubtotal.Subtotal, shortSubtotal.Total = 90, 116
+	exactPolicy := splitBill("exact", models.BillSplit{PersonName: "Alice", Value: 100})
+	exactPolicy.TipPolicy = PolicyEqual
+
+	withPolicy := func(change func(b *models.Bill)) *models.Bill {
+		b := policyBill(false)
+		change(b)
+		return b
+	}
+	cases := []struct {
+		name string
+		bill *models.Bill
+		want error
+	}{
+		{"unknown tax policy", withPolicy(func(b *models.Bill) { b.TaxPolicy = "random" }), ErrUnknownPolicy},
+		{"unknown tip policy", withPolicy(func(b *models.Bill) { b.TipPolicy = "whoever" }), ErrUnknownPolicy},
+		{"unknown tip base", withPolicy(func(b *models.Bill) { b.TipBase = "after_dessert" }), ErrUnknownTipBase},
+		{"unknown excluded person", withPolicy(func(b *models.Bill) { b.TipExcluded = []string{"Dave"} }), ErrUnknownTipExcluded},
+		{"negative fee", withPolicy(func(b *models.Bill) { b.DeliveryFee = -1 }), ErrNegativeAmount},
+		{"fees not in total", withPolicy(func(b *models.Bill) { b.ServiceCharge, b.Total = 5, 126 }), ErrTotalMismatch},
+		{"everyone excluded", everyoneExcluded, ErrNoTipPayers},
+		{"nothing taxable", allExempt, ErrNoTaxableItems},
+		{"assignments short", badPercent, ErrAssignmentPercent},
+		{"unassigned item", unassigned, ErrUnassignedItem},
+		{"subtotal mismatch", shortSubtotal, ErrSubtotalMismatch},
+		{"exact with policy", exactPolicy, ErrPolicyNotApplicable},
+		{"sent shares with policy", withPolicy(func(b *models.Bill) {
+			b.TipPolicy = PolicyEqual
+			b.PersonShares = []models.PersonShare{{PersonName: "Al", Total: 121}}
+		}), ErrSharesNotComputed},
+		{"sent shares with fee", withPolicy(func(b *models.Bill) {
+			b.ServiceCharge, b.Total = 5, 126
+			b.PersonShares = []models.PersonShare{{PersonName: "Al", Total: 126}}
+		}), ErrSharesNotComputed},
+	}
+	for _, tc := range cases {
+		if err := NewBillService(newMockRepo()).CreateBill(tc.bill); !errors.Is(err, tc.want) {
+			t.Errorf("%s: error = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestCreateBill_IgnoresServerOwnedFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newMockRepo()
+	h := NewBillHandler(NewBillService(repo))
+
+	body := `{"name": "Cab", "subtotal": 20, "total": 20, "split_mode": "equal",
+		"splits": [{"person_name": "Al"}, {"person_name": "Bo"}],
+		"id": 99, "public_id": "chosenByTheClient00000", "tab_id": 7, "added_by_member_id": 3,
+		"tab_period": 2, "template_id": 4, "scheduled_for": "2026-10-01T00:00:00Z", "version": 5}`
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/bills", strings.NewReader(body))
+	h.CreateBill(c)
+
+	if w.Code != 201 {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	b := repo.bills[1]
+	if b == nil {
+		t.Fatalf("expected the bill stored as a new row, got %v", repo.bills)
+	}
+	if b.TabID != nil || b.AddedByMemberID != nil || b.TabPeriod != 0 || b.TemplateID != nil || b.ScheduledFor != nil {
+		t.Errorf("expected a standalone bill, got tab %v member %v period %d template %v run %v",
+			b.TabID, b.AddedByMemberID, b.TabPeriod, b.TemplateID, b.ScheduledFor)
+	}
+	if b.PublicID == "chosenByTheClient00000" || b.Version != 0 {
+		t.Errorf("expected server-assigned public ID and version, got %q %d", b.PublicID, b.Version)
+	}
+	if strings.Contains(w.Body.String(), "bill_id") {
+		t.Errorf("expected no numeric ID in the response, got %s", w.Body.String())
+	}
+
+	// Whatever reaches the database gets a public ID of the server's choosing
+	stored := &models.Bill{PublicID: "chosenByTheClient00000"}
+	if err := stored.BeforeCreate(nil); err != nil || stored.PublicID == "chosenByTheClient00000" {
+		t.Errorf("expected the public ID replaced on create, got %q (%v)", stored.PublicID, err)
+	}
+}
+
+func TestCreateBill_ResolvesMembers(t *testing.T) {
+	repo := newMockRepo()
+	repo.members = []models.TabMember{{ID: 4, PublicID: "Kq3Vb8LmT2xYp9Rw5NcZa1"}, {ID: 6, PublicID: "Lm3Vb8LmT2xYp9Rw5NcZa1"}}
+	svc := NewBillService(repo)
+
+	bill := splitBill("equal",
+		models.BillSplit{PersonName: "Alice", MemberRef: "Kq3Vb8LmT2xYp9Rw5NcZa1"},
+		models.BillSplit{PersonName: "Bob", MemberRef: "6"},
+		models.BillSplit{PersonName: "Cara"})
+	if err := svc.CreateBill(bill); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var linked []uint
+	for _, share := range bill.PersonShares {
+		if share.MemberID != nil {
+			linked = append(linked, *share.MemberID)
+		}
+	}
+	if len(linked) != 2 || linked[0] != 4 || linked[1] != 6 {
+		t.Errorf("expected shares linked to members 4 and 6, got %v", linked)
+	}
+
+	stranger := splitBill("equal", models.BillSplit{PersonName: "Alice", MemberRef: "Zz3Vb8LmT2xYp9Rw5NcZa1"})
+	if err := svc.CreateBill(stranger); !errors.Is(err, ErrUnknownMember) {
+		t.Errorf("expected ErrUnknownMember, got %v", err)
+	}
+}
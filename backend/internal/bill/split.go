@@ -0,0 +1,437 @@
+package bill
+
+import (
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Split modes. Items divides the bill by item assignments; the others divide
+// the whole bill by its splits.
+const (
+	SplitItems      = "items"
+	SplitEqual      = "equal"
+	SplitShares     = "shares"
+	SplitExact      = "exact"
+	SplitPercentage = "percentage"
+)
+
+const maxSplits = 100
+
+var (
+	ErrUnknownSplitMode  = errors.New("split_mode must be items, equal, shares, exact or percentage")
+	ErrSplitsRequired    = errors.New("splits are required for this split_mode")
+	ErrUnexpectedSplits  = errors.New("splits need a split_mode of equal, shares, exact or percentage")
+	ErrTooManySplits     = errors.New("too many splits")
+	ErrInvalidSplit      = errors.New("each split needs a unique person_name and a non-negative value")
+	ErrInvalidShares     = errors.New("shares must be greater than zero")
+	ErrTotalMismatch     = errors.New("total must equal subtotal + tax + tip_amount + service_charge + delivery_fee")
+	ErrExactMismatch     = errors.New("exact amounts must add up to the bill total")
+	ErrPercentMismatch   = errors.New("percentages must add up to 100")
+	ErrNegativeAmount    = errors.New("bill amounts must not be negative")
+	ErrUnassignedItem    = errors.New("every item needs at least one assignment")
+	ErrAssignmentPercent = errors.New("each item's assignments must add up to 100 percent")
+	ErrSubtotalMismatch  = errors.New("item prices must add up to subtotal")
+	ErrUnknownMember     = errors.New("member_id must be a member of the tab")
+	ErrSharesNotComputed = errors.New("person_shares sent with the bill can't be combined with tax or tip policies, service_charge or delivery_fee")
+)
+
+// portion is one person's part of the bill's items, in cents, before tax,
+// tip and fees are divided. share and taxable are unrounded, so proportional
+// divisions follow the split rather than its rounding.
+type portion struct {
+	name     string
+	memberID *uint
+	items    []models.ItemDetail
+	subtotal int64
+	share    float64
+	taxable  float64
+}
+
+// ApplySplit validates the bill's split mode and policies and computes its
+// person shares. Bills in items mode that arrive with person shares are
+// stored as sent, as older clients expect, so they may not use the policies
+// or fees those clients can't have divided. Every amount is divided to the
+// cent, so the shares add up to the bill exactly.
+func ApplySplit(bill *models.Bill) error {
+	mode := strings.ToLower(strings.TrimSpace(bill.SplitMode))
+	if mode == "" {
+		mode = SplitItems
+	}
+	bill.SplitMode = mode
+	if err := normalizePolicies(bill); err != nil {
+		return err
+	}
+
+	var portions []portion
+	r := &rounding{}
+	switch mode {
+	case SplitItems:
+		if len(bill.Splits) > 0 {
+			return ErrUnexpectedSplits
+		}
+		if len(bill.PersonShares) > 0 {
+			if !defaultPolicies(bill) || bill.ServiceCharge != 0 || bill.DeliveryFee != 0 {
+				return ErrSharesNotComputed
+			}
+			return nil
+		}
+		if len(bill.Items) == 0 {
+			return nil
+		}
+		if err := checkAmounts(bill); err != nil {
+			return err
+		}
+		var err error
+		if portions, err = itemPortions(bill); err != nil {
+			return err
+		}
+	case SplitEqual, SplitShares, SplitExact, SplitPercentage:
+		if mode == SplitExact && !defaultPolicies(bill) {
+			return ErrPolicyNotApplicable
+		}
+		if err := checkAmounts(bill); err != nil {
+			return err
+		}
+		weights, err := splitWeights(bill, mode)
+		if err != nil {
+			return err
+		}
+		portions = splitPortions(bill, weights, r)
+	default:
+		return ErrUnknownSplitMode
+	}
+
+	shares, err := computeShares(bill, portions, r)
+	if err != nil {
+		return err
+	}
+	if mode == SplitExact {
+		// Each total is the amount given; the subtotal absorbs any cent the
+		// separate divisions rounded away.
+		for i := range shares {
+			diff := toCents(bill.Splits[i].Value) - toCents(shares[i].Total)
+			shares[i].Subtotal = cents(toCents(shares[i].Subtotal) + diff)
+			shares[i].Total = bill.Splits[i].Value
+		}
+	}
+	bill.PersonShares = shares
+	return nil
+}
+
+// computeShares divides tax, tip and fees over the portions per the bill's
+// policies.
+func computeShares(bill *models.Bill, portions []portion, r *rounding) ([]models.PersonShare, error) {
+	weights, err := taxWeights(bill, portions)
+	if err != nil {
+		return nil, err
+	}
+	taxes := r.allocate(toCents(bill.Tax), weights)
+	if weights, err = tipWeights(bill, portions, weights); err != nil {
+		return nil, err
+	}
+	tips := r.allocate(toCents(bill.TipAmount), weights)
+	serviceCharges := r.allocate(toCents(bill.ServiceCharge), feeWeights(portions, true))
+	deliveryFees := r.allocate(toCents(bill.DeliveryFee), feeWeights(portions, false))
+
+	shares := make([]models.PersonShare, len(portions))
+	for i, p := range portions {
+		shares[i] = models.PersonShare{
+			PersonName:         p.name,
+			MemberID:           p.memberID,
+			Items:              p.items,
+			Subtotal:           cents(p.subtotal),
+			TaxShare:           cents(taxes[i]),
+			TipShare:           cents(tips[i]),
+			ServiceChargeShare: cents(serviceCharges[i]),
+			DeliveryFeeShare:   cents(deliveryFees[i]),
+			Total:              cents(p.subtotal + taxes[i] + tips[i] + serviceCharges[i] + deliveryFees[i]),
+		}
+	}
+	return shares, nil
+}
+
+// itemPortions divides each item among its assignments, merging people by
+// case-insensitive name in the order they first appear.
+func itemPortions(bill *models.Bill) ([]portion, error) {
+	var portions []portion
+	index := make(map[string]int)
+	var subtotal int64
+	for _, item := range bill.Items {
+		if len(item.Assignments) == 0 {
+			return nil, ErrUnassignedItem
+		}
+		weights := make([]float64, len(item.Assignments))
+		var percent float64
+		assigned := 0
+		for i, a := range item.Assignments {
+			if strings.TrimSpace(a.PersonName) == "" || a.Percentage < 0 || math.IsNaN(a.Percentage) {
+				return nil, ErrAssignmentPercent
+			}
+			weights[i] = a.Percentage
+			percent += a.Percentage
+			if a.Percentage > 0 {
+				assigned++
+			}
+		}
+		if math.Abs(percent-100) > 0.01 {
+			return nil, ErrAssignmentPercent
+		}
+
+		price := toCents(item.Price)
+		subtotal += price
+		amounts := allocate(price, weights)
+		for i, a := range item.Assignments {
+			name := strings.TrimSpace(a.PersonName)
+			key := strings.ToLower(name)
+			j, ok := index[key]
+			if !ok {
+				j = len(portions)
+				index[key] = j
+				portions = append(portions, portion{name: name, items: []models.ItemDetail{}})
+			}
+			p := &portions[j]
+			if p.memberID == nil {
+				p.memberID = a.MemberID
+			}
+			if amounts[i] == 0 {
+				continue
+			}
+			p.items = append(p.items, models.ItemDetail{Name: item.Name, Amount: cents(amounts[i]), IsShared: assigned > 1})
+			p.subtotal += amounts[i]
+			p.share += float64(amounts[i])
+			if !item.TaxExempt {
+				p.taxable += float64(amounts[i])
+			}
+		}
+	}
+	if subtotal != toCents(bill.Subtotal) {
+		return nil, ErrSubtotalMismatch
+	}
+	return portions, nil
+}
+
+// splitPortions divides the subtotal by the split weights. Items, if the
+// bill lists any, are divided the same way so each share can show them.
+func splitPortions(bill *models.Bill, weights []float64, r *rounding) []portion {
+	subtotals := r.allocate(toCents(bill.Subtotal), weights)
+	taxableCents := toCents(bill.Subtotal)
+	items := make([][]int64, len(bill.Items))
+	shared := 0
+	var total float64
+	for _, w := range weights {
+		if w > 0 {
+			shared++
+		}
+		total += w
+	}
+	for i, item := range bill.Items {
+		items[i] = allocate(toCents(item.Price), weights)
+		if item.TaxExempt {
+			taxableCents -= toCents(item.Price)
+		}
+	}
+
+	portions := make([]portion, len(bill.Splits))
+	for i, split := range bill.Splits {
+		details := []models.ItemDetail{}
+		for j, item := range bill.Items {
+			if items[j][i] != 0 {
+				details = append(details, models.ItemDetail{Name: item.Name, Amount: cents(items[j][i]), IsShared: shared > 1})
+			}
+		}
+		portions[i] = portion{
+			name:     split.PersonName,
+			memberID: split.MemberID,
+			items:    details,
+			subtotal: subtotals[i],
+			share:    float64(toCents(bill.Subtotal)) * weights[i] / total,
+			taxable:  float64(taxableCents) * weights[i] / total,
+		}
+	}
+	return portions
+}
+
+// splitWeights validates the splits for mode and returns the weight each
+// split carries.
+func splitWeights(bill *models.Bill, mode string) ([]float64, error) {
+	if len(bill.Splits) == 0 {
+		return nil, ErrSplitsRequired
+	}
+	if len(bill.Splits) > maxSplits {
+		return nil, ErrTooManySplits
+	}
+
+	seen := make(map[string]bool)
+	weights := make([]float64, len(bill.Splits))
+	var sum float64
+	for i := range bill.Splits {
+		split := &bill.Splits[i]
+		split.PersonName = strings.TrimSpace(split.PersonName)
+		key := strings.ToLower(split.PersonName)
+		if key == "" || seen[key] || split.Value < 0 || math.IsNaN(split.Value) || math.IsInf(split.Value, 0) {
+			return nil, ErrInvalidSplit
+		}
+		seen[key] = true
+
+		switch mode {
+		case SplitEqual:
+			split.Value = 0
+			weights[i] = 1
+		case SplitShares:
+			if split.Value == 0 {
+				return nil, ErrInvalidShares
+			}
+			weights[i] = split.Value
+		case SplitExact:
+			split.Value = cents(toCents(split.Value))
+			weights[i] = split.Value
+		case SplitPercentage:
+			weights[i] = split.Value
+		}
+		sum += weights[i]
+	}
+
+	switch mode {
+	case SplitExact:
+		if toCents(sum) != toCents(bill.Total) {
+			return nil, ErrExactMismatch
+		}
+	case SplitPercentage:
+		if math.Abs(sum-100) > 0.01 {
+			return nil, ErrPercentMismatch
+		}
+	}
+	return weights, nil
+}
+
+// allocate divides amount cents in proportion to weights. Cents lost to
+// rounding go to the largest remainders, earliest first, so the parts always
+// add up to amount.
+func allocate(amount int64, weights []float64) []int64 {
+	return (&rounding{}).allocate(amount, weights)
+}
+
+// rounding allocates several amounts over the same people. Ties for a
+// leftover cent go to whoever has had the fewest so far, so one person
+// doesn't collect a cent from every amount.
+type rounding struct {
+	extra []int64
+}
+
+func (r *rounding) allocate(amount int64, weights []float64) []int64 {
+	if len(r.extra) != len(weights) {
+		r.extra = make([]int64, len(weights))
+	}
+	parts := make([]int64, len(weights))
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return parts
+	}
+
+	remainders := make([]float64, len(weights))
+	var assigned int64
+	for i, w := range weights {
+		exact := float64(amount) * w / total
+		parts[i] = int64(math.Floor(exact))
+		remainders[i] = exact - float64(parts[i])
+		assigned += parts[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		i, j := order[a], order[b]
+		if math.Abs(remainders[i]-remainders[j]) > 1e-9 {
+			return remainders[i] > remainders[j]
+		}
+		return r.extra[i] < r.extra[j]
+	})
+	for _, i := range order[:amount-assigned] {
+		parts[i]++
+		r.extra[i]++
+	}
+	return parts
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func cents(c int64) float64 {
+	return float64(c) / 100
+}
+
+// ResolveMembers links the bill's splits, item assignments and person shares
+// to the members their member_id names, by public or legacy numeric ID.
+// Returns ErrUnknownMember if one names none of refs.
+func ResolveMembers(bill *models.Bill, refs models.MemberRefs) error {
+	resolve := func(ref security.Ref) (*uint, error) {
+		id, ok := refs.Resolve(ref)
+		if !ok {
+			return nil, ErrUnknownMember
+		}
+		return id, nil
+	}
+	var err error
+	for i := range bill.Items {
+		for j := range bill.Items[i].Assignments {
+			a := &bill.Items[i].Assignments[j]
+			if a.MemberID, err = resolve(a.MemberRef); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range bill.Splits {
+		if bill.Splits[i].MemberID, err = resolve(bill.Splits[i].MemberRef); err != nil {
+			return err
+		}
+	}
+	for i := range bill.PersonShares {
+		if bill.PersonShares[i].MemberID, err = resolve(bill.PersonShares[i].MemberRef); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memberRefsOf returns every member reference on the bill.
+func memberRefsOf(bill *models.Bill) []security.Ref {
+	var refs []security.Ref
+	for _, item := range bill.Items {
+		for _, a := range item.Assignments {
+			refs = append(refs, a.MemberRef)
+		}
+	}
+	for _, split := range bill.Splits {
+		refs = append(refs, split.MemberRef)
+	}
+	for _, share := range bill.PersonShares {
+		refs = append(refs, share.MemberRef)
+	}
+	return refs
+}
+
+// IsSplitError reports whether err is one of the validation errors
+// ApplySplit returns for a bill the client got wrong.
+func IsSplitError(err error) bool {
+	for _, target := range []error{
+		ErrUnknownSplitMode, ErrSplitsRequired, ErrUnexpectedSplits, ErrTooManySplits, ErrInvalidSplit,
+		ErrInvalidShares, ErrTotalMismatch, ErrExactMismatch, ErrPercentMismatch, ErrNegativeAmount,
+		ErrUnassignedItem, ErrAssignmentPercent, ErrSubtotalMismatch, ErrUnknownMember, ErrSharesNotComputed,
+		ErrUnknownPolicy, ErrUnknownTipBase, ErrUnknownTipExcluded, ErrNoTipPayers, ErrNoTaxableItems, ErrPolicyNotApplicable,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
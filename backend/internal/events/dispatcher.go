@@ -0,0 +1,141 @@
+package events
+
+import (
+	"backend/pkg/models"
+	"context"
+	"log"
+	"time"
+)
+
+// Handler consumes dispatched events. Delivery is at-least-once: an event is
+// redelivered to every handler if any handler fails or the dispatcher stops
+// before recording success, so handlers must tolerate duplicates.
+type Handler interface {
+	Handle(ctx context.Context, event models.Event) error
+}
+
+// HandlerFunc adapts a function to the Handler interface.
+type HandlerFunc func(ctx context.Context, event models.Event) error
+
+func (f HandlerFunc) Handle(ctx context.Context, event models.Event) error {
+	return f(ctx, event)
+}
+
+const (
+	partitionsPerTick = 50
+	eventsPerBatch    = 100
+	baseRetryDelay    = 5 * time.Second
+	maxRetryDelay     = 10 * time.Minute
+	maxAttempts       = 20
+)
+
+// Dispatcher delivers outbox events to handlers. Events in the same partition
+// (one tab, or one standalone bill) are delivered strictly in order: a failing
+// event holds back the rest of its partition until it succeeds or exhausts its
+// attempts. Partition locks let several dispatchers run side by side.
+type Dispatcher struct {
+	repo     OutboxRepository
+	handlers []Handler
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewDispatcher(repo OutboxRepository, handlers ...Handler) *Dispatcher {
+	return &Dispatcher{
+		repo:     repo,
+		handlers: handlers,
+		interval: time.Second,
+		now:      time.Now,
+	}
+}
+
+// Run dispatches due events until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DispatchOnce(ctx); err != nil {
+			log.Printf("event dispatch: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce works through every partition with a due event and returns the
+// number of events delivered.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	keys, err := d.repo.DuePartitions(d.now(), partitionsPerTick)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		n, err := d.dispatchPartition(ctx, key)
+		if err != nil {
+			log.Printf("event dispatch for %s: %v", key, err)
+		}
+		delivered += n
+	}
+	return delivered, nil
+}
+
+func (d *Dispatcher) dispatchPartition(ctx context.Context, key string) (int, error) {
+	delivered := 0
+	_, err := d.repo.WithPartition(key, func(p Partition) error {
+		pending, err := p.Pending(eventsPerBatch)
+		if err != nil {
+			return err
+		}
+		for _, event := range pending {
+			now := d.now()
+			if event.NextAttemptAt != nil && event.NextAttemptAt.After(now) {
+				return nil
+			}
+			if err := d.deliver(ctx, event); err != nil {
+				attempts := event.Attempts + 1
+				if attempts >= maxAttempts {
+					log.Printf("event %d (%s) failed after %d attempts, skipping: %v", event.ID, event.Type, attempts, err)
+					if err := p.MarkFailed(event.ID, attempts, now, err.Error()); err != nil {
+						return err
+					}
+					continue
+				}
+				return p.MarkRetry(event.ID, attempts, now.Add(retryDelay(attempts)), err.Error())
+			}
+			if err := p.MarkDispatched(event.ID, now); err != nil {
+				return err
+			}
+			delivered++
+		}
+		return nil
+	})
+	return delivered, err
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, event models.Event) error {
+	for _, h := range d.handlers {
+		if err := h.Handle(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryDelay doubles from baseRetryDelay with each attempt, capped at maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
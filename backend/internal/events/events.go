@@ -0,0 +1,179 @@
+package events
+
+import (
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"encoding/json"
+	"time"
+)
+
+// Types lists every event type, in the order they are documented.
+var Types = []string{
+	TabUpdated{}.EventType(),
+	TabFinalized{}.EventType(),
+	TabReopened{}.EventType(),
+	PeriodClosed{}.EventType(),
+	BillCreated{}.EventType(),
+	BillAdded{}.EventType(),
+	BillRemoved{}.EventType(),
+	SharePaid{}.EventType(),
+	MemberJoined{}.EventType(),
+	MemberAliasAdded{}.EventType(),
+	MemberAliasRemoved{}.EventType(),
+	ImageUploaded{}.EventType(),
+	ImageProcessed{}.EventType(),
+	ImageDeleted{}.EventType(),
+	SettlementPaid{}.EventType(),
+	PaymentRecorded{}.EventType(),
+	PaymentVoided{}.EventType(),
+	PaymentReceived{}.EventType(),
+	PaymentDisputed{}.EventType(),
+	SettlementReminder{}.EventType(),
+}
+
+// DomainEvent is a typed change recorded to the event log.
+type DomainEvent interface {
+	EventType() string
+}
+
+// View is an event as published in the event stream and webhook deliveries,
+// with its tab named by public ID. The event's own ID is the stream cursor.
+type View struct {
+	ID        uint            `json:"id"`
+	TabID     string          `json:"tab_id,omitempty"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// NewView returns the published form of an event on the tab with the given
+// public ID.
+func NewView(e models.Event, tabPublicID string) View {
+	return View{ID: e.ID, TabID: tabPublicID, Type: e.Type, Payload: e.Payload, CreatedAt: e.CreatedAt}
+}
+
+type TabUpdated struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type TabFinalized struct {
+	FinalizedAt time.Time `json:"finalized_at"`
+}
+
+type TabReopened struct{}
+
+type PeriodClosed struct {
+	Period      int `json:"period"`
+	Settlements int `json:"settlements"`
+}
+
+// Payloads name records by public ID. Events logged before that carry numeric
+// IDs, which security.Ref also decodes.
+
+type BillCreated struct {
+	BillID security.Ref `json:"bill_id"`
+	Name   string       `json:"name"`
+	Total  float64      `json:"total"`
+}
+
+type BillAdded struct {
+	BillID   security.Ref `json:"bill_id"`
+	MemberID security.Ref `json:"member_id,omitempty"`
+}
+
+type BillRemoved struct {
+	BillID security.Ref `json:"bill_id"`
+}
+
+type SharePaid struct {
+	ShareID security.Ref `json:"share_id"`
+	Paid    bool         `json:"paid"`
+}
+
+type MemberJoined struct {
+	MemberID    security.Ref `json:"member_id"`
+	DisplayName string       `json:"display_name"`
+	Role        string       `json:"role"`
+}
+
+type MemberAliasAdded struct {
+	AliasID  security.Ref `json:"alias_id"`
+	MemberID security.Ref `json:"member_id"`
+	Name     string       `json:"name"`
+}
+
+type MemberAliasRemoved struct {
+	AliasID security.Ref `json:"alias_id"`
+}
+
+type ImageUploaded struct {
+	ImageID security.Ref `json:"image_id"`
+}
+
+type ImageProcessed struct {
+	ImageID   security.Ref `json:"image_id"`
+	Processed bool         `json:"processed"`
+}
+
+type ImageDeleted struct {
+	ImageID security.Ref `json:"image_id"`
+}
+
+type SettlementPaid struct {
+	SettlementID security.Ref `json:"settlement_id"`
+	Paid         bool         `json:"paid"`
+}
+
+type PaymentRecorded struct {
+	PaymentID     security.Ref `json:"payment_id"`
+	SettlementID  security.Ref `json:"settlement_id,omitempty"`
+	PersonShareID security.Ref `json:"person_share_id,omitempty"`
+	Payer         string       `json:"payer"`
+	Amount        float64      `json:"amount"`
+	Method        string       `json:"method"`
+	Status        string       `json:"status"`
+}
+
+type PaymentVoided struct {
+	PaymentID security.Ref `json:"payment_id"`
+}
+
+// SettlementReminder is emitted by the reminder scheduler for an unpaid
+// settlement on a finalized tab. Reminder counts from 1.
+type SettlementReminder struct {
+	SettlementID security.Ref `json:"settlement_id"`
+	PersonName   string       `json:"person_name"`
+	Outstanding  float64      `json:"outstanding"`
+	Reminder     int          `json:"reminder"`
+}
+
+type PaymentReceived struct {
+	PaymentID security.Ref `json:"payment_id"`
+}
+
+type PaymentDisputed struct {
+	PaymentID security.Ref `json:"payment_id"`
+	Reason    string       `json:"reason,omitempty"`
+}
+
+func (TabUpdated) EventType() string         { return "tab.updated" }
+func (TabFinalized) EventType() string       { return "tab.finalized" }
+func (TabReopened) EventType() string        { return "tab.reopened" }
+func (PeriodClosed) EventType() string       { return "tab.period_closed" }
+func (BillCreated) EventType() string        { return "bill.created" }
+func (BillAdded) EventType() string          { return "bill.added" }
+func (BillRemoved) EventType() string        { return "bill.removed" }
+func (SharePaid) EventType() string          { return "share.paid" }
+func (MemberJoined) EventType() string       { return "member.joined" }
+func (MemberAliasAdded) EventType() string   { return "member.alias_added" }
+func (MemberAliasRemoved) EventType() string { return "member.alias_removed" }
+func (ImageUploaded) EventType() string      { return "image.uploaded" }
+func (ImageProcessed) EventType() string     { return "image.processed" }
+func (ImageDeleted) EventType() string       { return "image.deleted" }
+func (SettlementPaid) EventType() string     { return "settlement.paid" }
+func (PaymentRecorded) EventType() string    { return "payment.recorded" }
+func (PaymentVoided) EventType() string      { return "payment.voided" }
+func (PaymentReceived) EventType() string    { return "payment.received" }
+func (PaymentDisputed) EventType() string    { return "payment.disputed" }
+func (SettlementReminder) EventType() string { return "settlement.reminder" }
@@ -1 +1,116 @@
 package events
+
+import (
+	"backend/internal/tabauth"
+	"backend/pkg/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TabResolver looks up tabs for token validation. Satisfied by tab.TabService.
+type TabResolver interface {
+	GetTabByRef(ref string) (*models.Tab, error)
+}
+
+type EventHandler struct {
+	service      EventService
+	tabs         TabResolver
+	pollInterval time.Duration
+	heartbeat    time.Duration
+}
+
+func NewEventHandler(service EventService, tabs TabResolver) *EventHandler {
+	return &EventHandler{
+		service:      service,
+		tabs:         tabs,
+		pollInterval: time.Second,
+		heartbeat:    15 * time.Second,
+	}
+}
+
+// StreamTabEvents handles GET /api/tabs/:id/events?t=token
+// Streams the tab's event log as Server-Sent Events. Clients resume after a
+// disconnect by sending the last seen event ID in the Last-Event-ID header
+// (browsers do this automatically) or the last_event_id query param.
+func (h *EventHandler) StreamTabEvents(c *gin.Context) {
+	t := tabauth.ValidateTab(c, h.tabs)
+	if t == nil {
+		return
+	}
+
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	var cursor uint
+	if lastID != "" {
+		parsed, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+			return
+		}
+		cursor = uint(parsed)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", 3000)
+	c.Writer.Flush()
+
+	poll := time.NewTicker(h.pollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		next, err := h.writeEvents(c, t, cursor)
+		if err != nil {
+			log.Printf("event stream for tab %d: %v", t.ID, err)
+			return
+		}
+		cursor = next
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeEvents sends every event after cursor and returns the new cursor.
+func (h *EventHandler) writeEvents(c *gin.Context, tab *models.Tab, cursor uint) (uint, error) {
+	for {
+		batch, err := h.service.Since(tab.ID, cursor)
+		if err != nil {
+			return cursor, err
+		}
+		for _, e := range batch {
+			data, err := json.Marshal(NewView(e, tab.PublicID))
+			if err != nil {
+				return cursor, err
+			}
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+			cursor = e.ID
+		}
+		if len(batch) > 0 {
+			c.Writer.Flush()
+		}
+		if len(batch) < maxBatch {
+			return cursor, nil
+		}
+	}
+}
@@ -1 +1,205 @@
 package events
+
+import (
+	"backend/pkg/etag"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EventRepository interface {
+	ListSince(tabID uint, afterID uint, limit int) ([]models.Event, error)
+}
+
+type eventRepository struct {
+	db *gorm.DB
+}
+
+// Record appends a tab event to the log and bumps the tab's version. Pass the
+// transaction that makes the change so the event commits or rolls back with
+// it.
+func Record(tx *gorm.DB, tabID uint, event DomainEvent) error {
+	return record(tx, &tabID, nil, event, true)
+}
+
+// RecordUnversioned appends a tab event to the log like Record but leaves the
+// tab's version alone, for bookkeeping such as reminders that shouldn't make
+// clients' If-Match versions stale. It still takes the tab's row lock.
+func RecordUnversioned(tx *gorm.DB, tabID uint, event DomainEvent) error {
+	return record(tx, &tabID, nil, event, false)
+}
+
+// RecordBill appends a bill event to the log and bumps the bill's version, and
+// its tab's if it has one. Bills on a tab share the tab's partition so their
+// events are dispatched in order with the tab's.
+func RecordBill(tx *gorm.DB, billID uint, tabID *uint, event DomainEvent) error {
+	return record(tx, tabID, &billID, event, true)
+}
+
+// RequireVersion locks the tab or bill row in model with the given id and
+// fails with etag.ErrChanged unless it is still at version. A nil version
+// requires nothing. Call it first in the transaction of a conditional write.
+func RequireVersion(tx *gorm.DB, model interface{}, id uint, version *uint) error {
+	if version == nil {
+		return nil
+	}
+	result := tx.Model(model).Where("id = ? AND version = ?", id, *version).UpdateColumn("version", gorm.Expr("version"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return etag.ErrChanged
+	}
+	return nil
+}
+
+// PublicRef returns the public ID of the row in model with the given id, for
+// naming it in an event payload.
+func PublicRef(tx *gorm.DB, model interface{}, id uint) (security.Ref, error) {
+	var publicIDs []string
+	if err := tx.Model(model).Where("id = ?", id).Limit(1).Pluck("public_id", &publicIDs).Error; err != nil {
+		return "", err
+	}
+	if len(publicIDs) == 0 {
+		return "", gorm.ErrRecordNotFound
+	}
+	return security.Ref(publicIDs[0]), nil
+}
+
+func record(tx *gorm.DB, tabID *uint, billID *uint, event DomainEvent, bump bool) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	// Bumping the tab's version first takes its row lock, so a tab's events
+	// get their IDs in commit order and can serve as a sync cursor. Unversioned
+	// events take the lock with a no-op update.
+	if tabID != nil {
+		version := gorm.Expr("version")
+		if bump {
+			version = gorm.Expr("version + 1")
+		}
+		if err := tx.Model(&models.Tab{}).Where("id = ?", *tabID).UpdateColumn("version", version).Error; err != nil {
+			return err
+		}
+	}
+	if billID != nil {
+		if err := tx.Model(&models.Bill{}).Where("id = ?", *billID).UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Create(&models.Event{
+		TabID:        tabID,
+		BillID:       billID,
+		Type:         event.EventType(),
+		Payload:      data,
+		PartitionKey: partitionKey(tabID, billID),
+	}).Error
+}
+
+func partitionKey(tabID *uint, billID *uint) string {
+	if tabID != nil {
+		return fmt.Sprintf("tab:%d", *tabID)
+	}
+	return fmt.Sprintf("bill:%d", *billID)
+}
+
+func (r *eventRepository) ListSince(tabID uint, afterID uint, limit int) ([]models.Event, error) {
+	var events []models.Event
+	err := r.db.Where("tab_id = ? AND id > ?", tabID, afterID).Order("id ASC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func NewEventRepository(db *gorm.DB) EventRepository {
+	return &eventRepository{db: db}
+}
+
+// OutboxRepository reads undispatched events for the dispatcher.
+type OutboxRepository interface {
+	// DuePartitions returns partitions whose oldest undispatched event is due.
+	DuePartitions(now time.Time, limit int) ([]string, error)
+	// WithPartition runs fn in a transaction holding the partition's lock.
+	// Returns false without calling fn if another dispatcher holds it.
+	WithPartition(key string, fn func(p Partition) error) (bool, error)
+}
+
+// Partition gives access to one partition's pending events while its lock is held.
+type Partition interface {
+	Pending(limit int) ([]models.Event, error)
+	MarkDispatched(id uint, at time.Time) error
+	MarkRetry(id uint, attempts int, next time.Time, lastError string) error
+	MarkFailed(id uint, attempts int, at time.Time, lastError string) error
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func (r *outboxRepository) DuePartitions(now time.Time, limit int) ([]string, error) {
+	var keys []string
+	err := r.db.Raw(`
+		SELECT partition_key FROM (
+			SELECT DISTINCT ON (partition_key) partition_key, id, next_attempt_at
+			FROM events
+			WHERE dispatched_at IS NULL AND failed_at IS NULL
+			ORDER BY partition_key, id
+		) heads
+		WHERE next_attempt_at IS NULL OR next_attempt_at <= ?
+		ORDER BY id
+		LIMIT ?`, now, limit).Scan(&keys).Error
+	return keys, err
+}
+
+func (r *outboxRepository) WithPartition(key string, fn func(p Partition) error) (bool, error) {
+	locked := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(hashtext(?))", key).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		return fn(&partition{tx: tx, key: key})
+	})
+	return locked, err
+}
+
+type partition struct {
+	tx  *gorm.DB
+	key string
+}
+
+func (p *partition) Pending(limit int) ([]models.Event, error) {
+	var events []models.Event
+	err := p.tx.Where("partition_key = ? AND dispatched_at IS NULL AND failed_at IS NULL", p.key).
+		Order("id ASC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func (p *partition) MarkDispatched(id uint, at time.Time) error {
+	return p.tx.Model(&models.Event{}).Where("id = ?", id).Update("dispatched_at", at).Error
+}
+
+func (p *partition) MarkRetry(id uint, attempts int, next time.Time, lastError string) error {
+	return p.tx.Model(&models.Event{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":        attempts,
+		"next_attempt_at": next,
+		"last_error":      lastError,
+	}).Error
+}
+
+func (p *partition) MarkFailed(id uint, attempts int, at time.Time, lastError string) error {
+	return p.tx.Model(&models.Event{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":   attempts,
+		"failed_at":  at,
+		"last_error": lastError,
+	}).Error
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
@@ -1 +1,23 @@
 package events
+
+import "backend/pkg/models"
+
+// maxBatch caps how many events a single read returns.
+const maxBatch = 100
+
+type EventService interface {
+	Since(tabID uint, afterID uint) ([]models.Event, error)
+}
+
+type eventService struct {
+	repo EventRepository
+}
+
+// Since returns up to maxBatch events on the tab newer than afterID, oldest first.
+func (s *eventService) Since(tabID uint, afterID uint) ([]models.Event, error) {
+	return s.repo.ListSince(tabID, afterID, maxBatch)
+}
+
+func NewEventService(repo EventRepository) EventService {
+	return &eventService{repo: repo}
+}
@@ -0,0 +1,375 @@
+package events
+
+import (
+	"backend/pkg/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ── Mock EventRepository ────────────────────────────────────────
+
+type mockEventRepository struct {
+	mu     sync.Mutex
+	events []models.Event
+	limits []int
+}
+
+func (m *mockEventRepository) ListSince(tabID uint, afterID uint, limit int) ([]models.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limits = append(m.limits, limit)
+	var result []models.Event
+	for _, e := range m.events {
+		if e.TabID != nil && *e.TabID == tabID && e.ID > afterID && len(result) < limit {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockEventRepository) append(e models.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, e)
+}
+
+// ── Mock TabResolver ────────────────────────────────────────────
+
+type mockTabResolver struct {
+	tab *models.Tab
+}
+
+func (m *mockTabResolver) GetTabByRef(ref string) (*models.Tab, error) {
+	if m.tab == nil || ref != "1" {
+		return nil, errors.New("record not found")
+	}
+	return m.tab, nil
+}
+
+func newEvent(id uint, tabID uint, eventType string) models.Event {
+	return models.Event{ID: id, TabID: &tabID, Type: eventType, Payload: json.RawMessage(`{}`), PartitionKey: partitionKey(&tabID, nil)}
+}
+
+func TestSince_UsesBatchLimit(t *testing.T) {
+	repo := &mockEventRepository{events: []models.Event{
+		newEvent(1, 1, BillAdded{}.EventType()),
+		newEvent(2, 2, BillAdded{}.EventType()),
+		newEvent(3, 1, MemberJoined{}.EventType()),
+	}}
+	svc := NewEventService(repo)
+
+	events, err := svc.Since(1, 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(events) != 1 || events[0].ID != 3 {
+		t.Errorf("expected only event 3, got %+v", events)
+	}
+	if repo.limits[0] != maxBatch {
+		t.Errorf("expected limit %d, got %d", maxBatch, repo.limits[0])
+	}
+}
+
+// streamFor runs the SSE handler until cancel is called and returns the body.
+func streamFor(t *testing.T, h *EventHandler, target string, header http.Header, run func()) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+
+	done := make(chan struct{})
+	go func() {
+		h.StreamTabEvents(c)
+		close(done)
+	}()
+	run()
+	cancel()
+	<-done
+	return w
+}
+
+func TestStreamTabEvents_ResumesAfterLastEventID(t *testing.T) {
+	repo := &mockEventRepository{events: []models.Event{
+		newEvent(1, 1, BillAdded{}.EventType()),
+		newEvent(2, 1, MemberJoined{}.EventType()),
+		newEvent(3, 2, BillAdded{}.EventType()),
+	}}
+	h := NewEventHandler(NewEventService(repo), &mockTabResolver{tab: &models.Tab{ID: 1, PublicID: "Tb4Hn7Qx2Lm9Rw5Vc8NzA1", AccessToken: "tok"}})
+	h.pollInterval = 5 * time.Millisecond
+
+	header := http.Header{"Last-Event-Id": {"1"}}
+	w := streamFor(t, h, "/api/tabs/1/events?t=tok", header, func() {
+		time.Sleep(20 * time.Millisecond)
+		repo.append(newEvent(4, 1, SettlementPaid{}.EventType()))
+		time.Sleep(30 * time.Millisecond)
+	})
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %q", ct)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "id: 1\n") {
+		t.Error("expected event 1 to be skipped after Last-Event-ID")
+	}
+	if strings.Contains(body, "id: 3\n") {
+		t.Error("expected events from other tabs to be excluded")
+	}
+	if !strings.Contains(body, "id: 2\nevent: member.joined\n") {
+		t.Errorf("expected replayed member.joined event, got %q", body)
+	}
+	if !strings.Contains(body, "id: 4\nevent: settlement.paid\n") {
+		t.Errorf("expected live settlement.paid event, got %q", body)
+	}
+	if !strings.Contains(body, `"id":2,"tab_id":"Tb4Hn7Qx2Lm9Rw5Vc8NzA1","type":"member.joined"`) {
+		t.Errorf("expected events to name the tab by public ID, got %q", body)
+	}
+}
+
+func TestStreamTabEvents_TokenMismatch(t *testing.T) {
+	repo := &mockEventRepository{}
+	h := NewEventHandler(NewEventService(repo), &mockTabResolver{tab: &models.Tab{ID: 1, AccessToken: "tok"}})
+
+	w := streamFor(t, h, "/api/tabs/1/events?t=wrong", nil, func() {})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", w.Code)
+	}
+	if len(repo.limits) != 0 {
+		t.Error("expected no events to be read")
+	}
+}
+
+func TestStreamTabEvents_InvalidLastEventID(t *testing.T) {
+	h := NewEventHandler(NewEventService(&mockEventRepository{}), &mockTabResolver{tab: &models.Tab{ID: 1, AccessToken: "tok"}})
+
+	w := streamFor(t, h, "/api/tabs/1/events?t=tok&last_event_id=abc", nil, func() {})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+// ── Mock OutboxRepository ───────────────────────────────────────
+
+type mockOutbox struct {
+	events []*models.Event
+	locked map[string]bool
+}
+
+func newMockOutbox(events ...models.Event) *mockOutbox {
+	m := &mockOutbox{locked: make(map[string]bool)}
+	for i := range events {
+		m.events = append(m.events, &events[i])
+	}
+	return m
+}
+
+func (m *mockOutbox) DuePartitions(now time.Time, limit int) ([]string, error) {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, e := range m.events {
+		if e.DispatchedAt != nil || e.FailedAt != nil || seen[e.PartitionKey] {
+			continue
+		}
+		seen[e.PartitionKey] = true
+		if e.NextAttemptAt == nil || !e.NextAttemptAt.After(now) {
+			keys = append(keys, e.PartitionKey)
+		}
+	}
+	return keys, nil
+}
+
+func (m *mockOutbox) WithPartition(key string, fn func(p Partition) error) (bool, error) {
+	if m.locked[key] {
+		return false, nil
+	}
+	return true, fn(&mockPartition{outbox: m, key: key})
+}
+
+type mockPartition struct {
+	outbox *mockOutbox
+	key    string
+}
+
+func (p *mockPartition) find(id uint) *models.Event {
+	for _, e := range p.outbox.events {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+func (p *mockPartition) Pending(limit int) ([]models.Event, error) {
+	var result []models.Event
+	for _, e := range p.outbox.events {
+		if e.PartitionKey == p.key && e.DispatchedAt == nil && e.FailedAt == nil && len(result) < limit {
+			result = append(result, *e)
+		}
+	}
+	return result, nil
+}
+
+func (p *mockPartition) MarkDispatched(id uint, at time.Time) error {
+	p.find(id).DispatchedAt = &at
+	return nil
+}
+
+func (p *mockPartition) MarkRetry(id uint, attempts int, next time.Time, lastError string) error {
+	e := p.find(id)
+	e.Attempts, e.NextAttemptAt, e.LastError = attempts, &next, lastError
+	return nil
+}
+
+func (p *mockPartition) MarkFailed(id uint, attempts int, at time.Time, lastError string) error {
+	e := p.find(id)
+	e.Attempts, e.FailedAt, e.LastError = attempts, &at, lastError
+	return nil
+}
+
+// recordingHandler records delivered event IDs and fails for IDs in failOn.
+type recordingHandler struct {
+	delivered []uint
+	failOn    map[uint]bool
+}
+
+func (h *recordingHandler) Handle(ctx context.Context, event models.Event) error {
+	if h.failOn[event.ID] {
+		return errors.New("handler unavailable")
+	}
+	h.delivered = append(h.delivered, event.ID)
+	return nil
+}
+
+func TestDispatchOnce_DeliversInOrder(t *testing.T) {
+	outbox := newMockOutbox(
+		newEvent(1, 1, BillAdded{}.EventType()),
+		newEvent(2, 2, MemberJoined{}.EventType()),
+		newEvent(3, 1, TabFinalized{}.EventType()),
+	)
+	handler := &recordingHandler{}
+	d := NewDispatcher(outbox, handler)
+
+	n, err := d.DispatchOnce(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 delivered, got %d", n)
+	}
+	// Partition tab:1 is drained in ID order before tab:2
+	want := []uint{1, 3, 2}
+	for i, id := range want {
+		if handler.delivered[i] != id {
+			t.Fatalf("expected delivery order %v, got %v", want, handler.delivered)
+		}
+	}
+	for _, e := range outbox.events {
+		if e.DispatchedAt == nil {
+			t.Errorf("expected event %d marked dispatched", e.ID)
+		}
+	}
+}
+
+func TestDispatchOnce_FailureHoldsBackPartition(t *testing.T) {
+	outbox := newMockOutbox(
+		newEvent(1, 1, BillAdded{}.EventType()),
+		newEvent(2, 1, TabFinalized{}.EventType()),
+		newEvent(3, 2, MemberJoined{}.EventType()),
+	)
+	handler := &recordingHandler{failOn: map[uint]bool{1: true}}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	d := NewDispatcher(outbox, handler)
+	d.now = func() time.Time { return now }
+
+	if _, err := d.DispatchOnce(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(handler.delivered) != 1 || handler.delivered[0] != 3 {
+		t.Fatalf("expected only the other tab's event delivered, got %v", handler.delivered)
+	}
+	failed := outbox.events[0]
+	if failed.Attempts != 1 || failed.NextAttemptAt == nil || !failed.NextAttemptAt.Equal(now.Add(baseRetryDelay)) {
+		t.Errorf("expected retry scheduled after %v, got attempts=%d next=%v", baseRetryDelay, failed.Attempts, failed.NextAttemptAt)
+	}
+	if failed.LastError != "handler unavailable" {
+		t.Errorf("expected last error recorded, got %q", failed.LastError)
+	}
+
+	// Not yet due: nothing is retried and the later event stays queued
+	if _, err := d.DispatchOnce(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(handler.delivered) != 1 {
+		t.Fatalf("expected no deliveries before retry is due, got %v", handler.delivered)
+	}
+
+	// Once due and the handler recovers, both events go out in order
+	now = now.Add(baseRetryDelay)
+	handler.failOn = nil
+	if _, err := d.DispatchOnce(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(handler.delivered) != 3 || handler.delivered[1] != 1 || handler.delivered[2] != 2 {
+		t.Errorf("expected events 1 then 2 after recovery, got %v", handler.delivered)
+	}
+}
+
+func TestDispatchOnce_GivesUpAfterMaxAttempts(t *testing.T) {
+	poison := newEvent(1, 1, BillAdded{}.EventType())
+	poison.Attempts = maxAttempts - 1
+	outbox := newMockOutbox(poison, newEvent(2, 1, TabFinalized{}.EventType()))
+	handler := &recordingHandler{failOn: map[uint]bool{1: true}}
+	d := NewDispatcher(outbox, handler)
+
+	if _, err := d.DispatchOnce(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if outbox.events[0].FailedAt == nil {
+		t.Error("expected event 1 marked failed")
+	}
+	if len(handler.delivered) != 1 || handler.delivered[0] != 2 {
+		t.Errorf("expected the next event to be delivered, got %v", handler.delivered)
+	}
+}
+
+func TestDispatchOnce_SkipsLockedPartition(t *testing.T) {
+	outbox := newMockOutbox(newEvent(1, 1, BillAdded{}.EventType()))
+	outbox.locked["tab:1"] = true
+	handler := &recordingHandler{}
+	d := NewDispatcher(outbox, handler)
+
+	n, err := d.DispatchOnce(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if n != 0 || len(handler.delivered) != 0 {
+		t.Errorf("expected nothing delivered from a locked partition, got %v", handler.delivered)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	if got := retryDelay(1); got != baseRetryDelay {
+		t.Errorf("retryDelay(1) = %v, want %v", got, baseRetryDelay)
+	}
+	if got := retryDelay(3); got != 4*baseRetryDelay {
+		t.Errorf("retryDelay(3) = %v, want %v", got, 4*baseRetryDelay)
+	}
+	if got := retryDelay(30); got != maxRetryDelay {
+		t.Errorf("retryDelay(30) = %v, want %v", got, maxRetryDelay)
+	}
+}
@@ -0,0 +1,192 @@
+package idempotency
+
+import (
+	"backend/pkg/models"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxKeyLength = 255
+	// maxBodySize covers the largest image upload plus multipart overhead.
+	maxBodySize = 11 << 20
+	defaultTTL  = 24 * time.Hour
+)
+
+// TTLFromEnv reads IDEMPOTENCY_KEY_TTL_HOURS, falling back to 24 hours.
+func TTLFromEnv() time.Duration {
+	if v := os.Getenv("IDEMPOTENCY_KEY_TTL_HOURS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Hour
+		}
+		log.Printf("ignoring invalid IDEMPOTENCY_KEY_TTL_HOURS %q", v)
+	}
+	return defaultTTL
+}
+
+// Middleware makes requests sent with an Idempotency-Key header safe to
+// retry. The first request with a key runs; its response is stored if it
+// succeeds (2xx) and replayed, with an Idempotent-Replayed header, to every
+// retry until the key expires after ttl. Failed requests free the key so they
+// can be retried. Reusing a key for a different request (another body, query
+// or token) gets a 422, and a retry while the first request is still running
+// gets a 409. Requests without the header are passed through.
+func Middleware(repo KeyRepository, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBodySize+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "bad request"})
+			return
+		}
+		if len(body) > maxBodySize {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fp := fingerprint(c.Request, body)
+		now := time.Now()
+		record, claimed, err := repo.Claim(&models.IdempotencyKey{
+			Scope:       c.Request.Method + " " + c.Request.URL.Path,
+			Key:         key,
+			Fingerprint: fp,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(ttl),
+		}, now)
+		if err != nil {
+			log.Printf("internal error: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
+			return
+		}
+		if !claimed {
+			replay(c, record, fp)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= 200 && status < 300 {
+			err = repo.Complete(record.ID, status, storedHeader(c.Writer.Header()), recorder.body.Bytes())
+		} else {
+			err = repo.Release(record.ID)
+		}
+		if err != nil {
+			log.Printf("idempotency key %d: %v", record.ID, err)
+		}
+	}
+}
+
+// replay answers a request whose key was already claimed by an earlier one.
+func replay(c *gin.Context, record *models.IdempotencyKey, fp string) {
+	switch {
+	case record.Fingerprint != fp:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+	case record.Status == 0:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+	default:
+		for name, values := range record.Header {
+			c.Writer.Header()[name] = values
+		}
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.Status, record.Header.Get("Content-Type"), record.Body)
+		c.Abort()
+	}
+}
+
+// fingerprint hashes what makes a request distinct: its method, path, query,
+// credentials and body. Multipart bodies are hashed part by part, so a retry
+// that picks a new boundary still matches.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, s := range []string{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization"), r.Header.Get("X-Member-Token")} {
+		io.WriteString(h, s)
+		h.Write([]byte{0})
+	}
+
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || !hashParts(h, body, params["boundary"]) {
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashParts writes each part's name, filename, type and content to h. It
+// reports false if body isn't valid multipart, leaving h to hash it whole.
+func hashParts(h io.Writer, body []byte, boundary string) bool {
+	var parts bytes.Buffer
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false
+		}
+		for _, s := range []string{part.FormName(), part.FileName(), part.Header.Get("Content-Type")} {
+			parts.WriteString(s)
+			parts.WriteByte(0)
+		}
+		content := sha256.New()
+		if _, err := io.Copy(content, part); err != nil {
+			return false
+		}
+		parts.Write(content.Sum(nil))
+	}
+	h.Write(parts.Bytes())
+	return true
+}
+
+// storedHeader is the part of a response's header worth replaying. CORS and
+// length headers are left for each request to set.
+func storedHeader(header http.Header) http.Header {
+	stored := http.Header{}
+	for name, values := range header {
+		if strings.HasPrefix(name, "Access-Control-") || name == "Content-Length" || name == "Vary" {
+			continue
+		}
+		stored[name] = append([]string(nil), values...)
+	}
+	return stored
+}
+
+// responseRecorder keeps a copy of the response body as it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,207 @@
+package idempotency
+
+import (
+	"backend/pkg/models"
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ── Mock KeyRepository ──────────────────────────────────────────
+
+type mockKeyRepository struct {
+	keys     map[string]*models.IdempotencyKey
+	released int
+}
+
+func newMockKeyRepository() *mockKeyRepository {
+	return &mockKeyRepository{keys: map[string]*models.IdempotencyKey{}}
+}
+
+func (m *mockKeyRepository) Claim(record *models.IdempotencyKey, now time.Time) (*models.IdempotencyKey, bool, error) {
+	if existing, ok := m.keys[record.Scope+"|"+record.Key]; ok && existing.ExpiresAt.After(now) {
+		return existing, false, nil
+	}
+	record.ID = uint(len(m.keys) + 1)
+	m.keys[record.Scope+"|"+record.Key] = record
+	return record, true, nil
+}
+
+func (m *mockKeyRepository) Complete(id uint, status int, header http.Header, body []byte) error {
+	for _, k := range m.keys {
+		if k.ID == id {
+			k.Status, k.Header, k.Body = status, header, body
+		}
+	}
+	return nil
+}
+
+func (m *mockKeyRepository) Release(id uint) error {
+	for name, k := range m.keys {
+		if k.ID == id {
+			delete(m.keys, name)
+			m.released++
+		}
+	}
+	return nil
+}
+
+func (m *mockKeyRepository) Purge(now time.Time) (int64, error) {
+	return 0, nil
+}
+
+// ── Tests ───────────────────────────────────────────────────────
+
+// newTestRouter serves POST /api/tabs with a handler that counts its calls
+// and responds with status.
+func newTestRouter(repo KeyRepository, calls *int, status int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/tabs", Middleware(repo, time.Hour), func(c *gin.Context) {
+		*calls++
+		c.Header("X-Quota-Images-Used", "1")
+		c.JSON(status, gin.H{"id": *calls})
+	})
+	return r
+}
+
+func post(r *gin.Engine, key, query, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/tabs"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware_ReplaysResponse(t *testing.T) {
+	calls := 0
+	r := newTestRouter(newMockKeyRepository(), &calls, http.StatusCreated)
+
+	first := post(r, "key-1", "", "application/json", `{"name":"Trip"}`)
+	second := post(r, "key-1", "", "application/json", `{"name":"Trip"}`)
+	if calls != 1 {
+		t.Fatalf("expected the handler to run once, ran %d times", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("expected the original 201 %s, got %d %s", first.Body, second.Code, second.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("expected only the replay marked")
+	}
+	if second.Header().Get("X-Quota-Images-Used") != "1" || second.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("expected the original headers replayed, got %v", second.Header())
+	}
+}
+
+func TestMiddleware_WithoutKey(t *testing.T) {
+	calls := 0
+	r := newTestRouter(newMockKeyRepository(), &calls, http.StatusCreated)
+	post(r, "", "", "application/json", `{}`)
+	post(r, "", "", "application/json", `{}`)
+	if calls != 2 {
+		t.Errorf("expected both requests handled, got %d", calls)
+	}
+}
+
+func TestMiddleware_RejectsDifferentRequest(t *testing.T) {
+	calls := 0
+	r := newTestRouter(newMockKeyRepository(), &calls, http.StatusCreated)
+	post(r, "key-1", "?t=token", "application/json", `{"name":"Trip"}`)
+
+	if w := post(r, "key-1", "?t=token", "application/json", `{"name":"Other"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for another body, got %d", w.Code)
+	}
+	if w := post(r, "key-1", "?t=other", "application/json", `{"name":"Trip"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for another token, got %d", w.Code)
+	}
+	if calls != 1 {
+		t.Errorf("expected the handler to run once, ran %d times", calls)
+	}
+}
+
+func TestMiddleware_ReleasesFailedRequests(t *testing.T) {
+	calls := 0
+	repo := newMockKeyRepository()
+	r := newTestRouter(repo, &calls, http.StatusBadRequest)
+	post(r, "key-1", "", "application/json", `{}`)
+	w := post(r, "key-1", "", "application/json", `{}`)
+	if calls != 2 || repo.released != 2 {
+		t.Errorf("expected both attempts handled and released, got %d calls, %d released", calls, repo.released)
+	}
+	if w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("expected the retry not to be a replay")
+	}
+}
+
+func TestMiddleware_InProgress(t *testing.T) {
+	calls := 0
+	repo := newMockKeyRepository()
+	r := newTestRouter(repo, &calls, http.StatusCreated)
+	req := httptest.NewRequest(http.MethodPost, "/api/tabs", strings.NewReader(`{}`))
+	repo.keys["POST /api/tabs|key-1"] = &models.IdempotencyKey{
+		ID:          1,
+		Fingerprint: fingerprint(req, []byte(`{}`)),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+
+	if w := post(r, "key-1", "", "", `{}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 while the first request runs, got %d", w.Code)
+	}
+	if calls != 0 {
+		t.Errorf("expected the handler not to run, ran %d times", calls)
+	}
+}
+
+func TestMiddleware_KeyTooLong(t *testing.T) {
+	calls := 0
+	r := newTestRouter(newMockKeyRepository(), &calls, http.StatusCreated)
+	if w := post(r, strings.Repeat("k", maxKeyLength+1), "", "application/json", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+	if calls != 0 {
+		t.Errorf("expected the handler not to run, ran %d times", calls)
+	}
+}
+
+func multipartBody(t *testing.T, boundary, content string) (string, string) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.SetBoundary(boundary); err != nil {
+		t.Fatal(err)
+	}
+	part, err := w.CreateFormFile("image", "receipt.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	w.Close()
+	return w.FormDataContentType(), buf.String()
+}
+
+func TestMiddleware_MultipartIgnoresBoundary(t *testing.T) {
+	calls := 0
+	r := newTestRouter(newMockKeyRepository(), &calls, http.StatusCreated)
+
+	contentType, body := multipartBody(t, "first-boundary", "jpeg bytes")
+	post(r, "key-1", "", contentType, body)
+	contentType, body = multipartBody(t, "second-boundary", "jpeg bytes")
+	if w := post(r, "key-1", "", contentType, body); w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("expected a retry with a new boundary replayed, got %d", w.Code)
+	}
+	contentType, body = multipartBody(t, "third-boundary", "other bytes")
+	if w := post(r, "key-1", "", contentType, body); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for another file, got %d", w.Code)
+	}
+	if calls != 1 {
+		t.Errorf("expected the handler to run once, ran %d times", calls)
+	}
+}
@@ -0,0 +1,35 @@
+package idempotency
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Purger periodically deletes expired idempotency keys. Expired keys are
+// already ignored when claimed, so purging only keeps the table small.
+type Purger struct {
+	repo     KeyRepository
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewPurger(repo KeyRepository) *Purger {
+	return &Purger{repo: repo, interval: time.Hour, now: time.Now}
+}
+
+// Run purges expired keys until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.repo.Purge(p.now()); err != nil {
+			log.Printf("idempotency purger: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package idempotency
+
+import (
+	"backend/pkg/models"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// abandonedAfter is how long a request can stay in progress before its key is
+// treated as abandoned (say, by a crashed server) and can be claimed again.
+const abandonedAfter = 2 * time.Minute
+
+type KeyRepository interface {
+	Claim(record *models.IdempotencyKey, now time.Time) (*models.IdempotencyKey, bool, error)
+	Complete(id uint, status int, header http.Header, body []byte) error
+	Release(id uint) error
+	Purge(now time.Time) (int64, error)
+}
+
+type keyRepository struct {
+	db *gorm.DB
+}
+
+// Claim stores record as an in-progress request and reports true, unless its
+// scope and key are already taken, in which case it returns the existing
+// record and false. A key that has expired or was abandoned is taken over.
+func (r *keyRepository) Claim(record *models.IdempotencyKey, now time.Time) (*models.IdempotencyKey, bool, error) {
+	existing := &models.IdempotencyKey{}
+	claimed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			claimed = true
+			return nil
+		}
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND key = ?", record.Scope, record.Key).
+			First(existing).Error
+		if err != nil {
+			return err
+		}
+		inProgress := existing.Status == 0 && existing.CreatedAt.After(now.Add(-abandonedAfter))
+		if existing.ExpiresAt.After(now) && (existing.Status != 0 || inProgress) {
+			return nil
+		}
+
+		record.ID = existing.ID
+		claimed = true
+		return tx.Model(&models.IdempotencyKey{ID: existing.ID}).
+			Select("fingerprint", "status", "header", "body", "created_at", "expires_at").
+			Updates(record).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if claimed {
+		return record, true, nil
+	}
+	return existing, false, nil
+}
+
+// Complete stores the response to a claimed request.
+func (r *keyRepository) Complete(id uint, status int, header http.Header, body []byte) error {
+	return r.db.Model(&models.IdempotencyKey{ID: id}).
+		Select("status", "header", "body").
+		Updates(&models.IdempotencyKey{Status: status, Header: header, Body: body}).Error
+}
+
+// Release frees a claimed key so the request can be retried.
+func (r *keyRepository) Release(id uint) error {
+	return r.db.Delete(&models.IdempotencyKey{}, id).Error
+}
+
+// Purge deletes expired keys and returns how many it deleted.
+func (r *keyRepository) Purge(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
+
+func NewKeyRepository(db *gorm.DB) KeyRepository {
+	return &keyRepository{db: db}
+}
@@ -3,9 +3,11 @@ package image
 import (
 	"backend/internal/tab"
 	"backend/pkg/models"
+	"backend/pkg/security"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -38,7 +40,23 @@ func NewImageHandler(service ImageService, tabService tab.TabService, uploadDir
 	}
 }
 
-// validateTabToken parses the tab ID, fetches the tab, and checks the token.
+// requestMember resolves the caller's member token from X-Member-Token or ?m=, if any.
+func (h *ImageHandler) requestMember(c *gin.Context) *models.TabMember {
+	memberToken := c.GetHeader("X-Member-Token")
+	if memberToken == "" {
+		memberToken = c.Query("m")
+	}
+	if memberToken == "" {
+		return nil
+	}
+	member, err := h.tabService.GetMemberByToken(memberToken)
+	if err != nil {
+		return nil
+	}
+	return member
+}
+
+// validateTabToken resolves the tab by public or legacy numeric ID and checks the token.
 // Returns the tab on success or writes an error response and returns nil.
 func (h *ImageHandler) validateTabToken(c *gin.Context) *models.Tab {
 	id := c.Param("id")
@@ -52,14 +70,12 @@ func (h *ImageHandler) validateTabToken(c *gin.Context) *models.Tab {
 		urlToken = c.Query("t")
 	}
 
-	idUint, err := strconv.ParseUint(id, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
-		return nil
-	}
-
-	t, err := h.tabService.GetTab(uint(idUint))
+	t, err := h.tabService.GetTabByRef(id)
 	if err != nil {
+		if errors.Is(err, security.ErrInvalidRef) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			return nil
+		}
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "tab not found"})
 			return nil
@@ -103,14 +119,14 @@ func (h *ImageHandler) UploadImage(c *gin.Context) {
 	}
 	defer file.Close()
 
-	// Detect MIME type from first 512 bytes
-	buf := make([]byte, 512)
-	n, err := file.Read(buf)
-	if err != nil && err != io.EOF {
+	data, err := io.ReadAll(file)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
 		return
 	}
-	mimeType := http.DetectContentType(buf[:n])
+
+	// Detect MIME type from first 512 bytes
+	mimeType := http.DetectContentType(data[:min(len(data), 512)])
 
 	allowed := map[string]bool{
 		"image/jpeg": true,
@@ -124,12 +140,6 @@ func (h *ImageHandler) UploadImage(c *gin.Context) {
 		return
 	}
 
-	// Seek back to beginning
-	if _, err := file.Seek(0, io.SeekStart); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process file"})
-		return
-	}
-
 	// Generate random filename with validated extension
 	ext := strings.ToLower(filepath.Ext(header.Filename))
 	validExts := map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".heic": true, ".heif": true}
@@ -140,55 +150,99 @@ func (h *ImageHandler) UploadImage(c *gin.Context) {
 	rand.Read(randBytes)
 	filename := hex.EncodeToString(randBytes) + ext
 
-	// Create directory
-	dir := filepath.Join(h.uploadDir, "tabs", fmt.Sprintf("%d", t.ID))
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create directory"})
-		return
+	// Files live under the tab's public ID so upload URLs don't reveal tab numbering
+	url := fmt.Sprintf("/uploads/tabs/%s/%s", t.PublicID, filename)
+
+	uploadedBy := c.Query("uploaded_by")
+	member := h.requestMember(c)
+	if member != nil {
+		uploadedBy = member.DisplayName
 	}
 
-	// Write file to disk
-	dst, err := os.Create(filepath.Join(dir, filename))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
+	image := &models.TabImage{
+		TabID:          t.ID,
+		Filename:       filename,
+		URL:            url,
+		Size:           int64(len(data)),
+		MimeType:       mimeType,
+		Processed:      false,
+		UploadedBy:     uploadedBy,
+		ContentHash:    contentHash(data),
+		PerceptualHash: perceptualHash(data),
+	}
+
+	// Reserve quota before touching disk so rejected uploads leave nothing behind
+	if err := h.service.Create(image, member); err != nil {
+		if errors.Is(err, ErrQuotaExceeded) {
+			h.respondQuotaExceeded(c, t.ID)
+			return
+		}
+		var dup *DuplicateImageError
+		if errors.As(err, &dup) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this image has already been uploaded to the tab", "code": "duplicate_image", "image": dup.Existing})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save image record"})
 		return
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
+	dir := filepath.Join(h.uploadDir, "tabs", t.PublicID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		h.discard(image, member)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create directory"})
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, filename), data, 0644); err != nil {
+		h.discard(image, member)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to write file"})
 		return
 	}
 
-	url := fmt.Sprintf("/uploads/tabs/%d/%s", t.ID, filename)
+	h.setQuotaHeaders(c, t.ID)
+	c.JSON(http.StatusCreated, image)
+}
 
-	uploadedBy := c.Query("uploaded_by")
-	memberToken := c.GetHeader("X-Member-Token")
-	if memberToken == "" {
-		memberToken = c.Query("m")
-	}
-	if memberToken != "" {
-		if member, err := h.tabService.GetMemberByToken(memberToken); err == nil {
-			uploadedBy = member.DisplayName
-		}
+// discard removes an image record whose file could not be written. Its
+// deletion is recorded like the upload was.
+func (h *ImageHandler) discard(image *models.TabImage, member *models.TabMember) {
+	if err := h.service.Delete(image.ID, h.uploadDir, member); err != nil {
+		log.Printf("failed to discard image %d: %v", image.ID, err)
 	}
+}
 
-	image := &models.TabImage{
-		TabID:      t.ID,
-		Filename:   filename,
-		URL:        url,
-		Size:       header.Size,
-		MimeType:   mimeType,
-		Processed:  false,
-		UploadedBy: uploadedBy,
+func (h *ImageHandler) respondQuotaExceeded(c *gin.Context, tabID uint) {
+	resp := gin.H{"error": "tab image storage quota exceeded", "code": "quota_exceeded"}
+	if usage, err := h.service.GetUsage(tabID); err == nil {
+		resp["quota"] = quotaBody(usage)
 	}
+	c.JSON(http.StatusRequestEntityTooLarge, resp)
+}
 
-	if err := h.service.Create(image); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save image record"})
+// setQuotaHeaders reports the tab's storage usage alongside image responses.
+// Remaining values are -1 when the corresponding limit is disabled.
+func (h *ImageHandler) setQuotaHeaders(c *gin.Context, tabID uint) {
+	usage, err := h.service.GetUsage(tabID)
+	if err != nil {
+		log.Printf("failed to load image usage for tab %d: %v", tabID, err)
 		return
 	}
+	writeQuotaHeaders(c, usage)
+}
 
-	c.JSON(http.StatusCreated, image)
+func writeQuotaHeaders(c *gin.Context, usage *Usage) {
+	c.Header("X-Quota-Bytes-Used", strconv.FormatInt(usage.UsedBytes, 10))
+	c.Header("X-Quota-Bytes-Remaining", strconv.FormatInt(usage.RemainingBytes(), 10))
+	c.Header("X-Quota-Images-Used", strconv.Itoa(usage.UsedImages))
+	c.Header("X-Quota-Images-Remaining", strconv.Itoa(usage.RemainingImages()))
+}
+
+func quotaBody(u *Usage) gin.H {
+	return gin.H{
+		"used_bytes":       u.UsedBytes,
+		"remaining_bytes":  u.RemainingBytes(),
+		"used_images":      u.UsedImages,
+		"remaining_images": u.RemainingImages(),
+	}
 }
 
 // ListImages handles GET /api/tabs/:id/images?t=token
@@ -205,6 +259,7 @@ func (h *ImageHandler) ListImages(c *gin.Context) {
 		return
 	}
 
+	h.setQuotaHeaders(c, t.ID)
 	c.JSON(http.StatusOK, images)
 }
 
@@ -215,15 +270,13 @@ func (h *ImageHandler) UpdateImage(c *gin.Context) {
 		return
 	}
 
-	imageID, err := strconv.ParseUint(c.Param("imageId"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image id"})
-		return
-	}
-
 	// Verify image belongs to this tab
-	image, err := h.service.GetByID(uint(imageID))
+	image, err := h.service.GetByRef(c.Param("imageId"))
 	if err != nil {
+		if errors.Is(err, security.ErrInvalidRef) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image id"})
+			return
+		}
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
 			return
@@ -245,7 +298,7 @@ func (h *ImageHandler) UpdateImage(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateProcessed(uint(imageID), *body.Processed); err != nil {
+	if err := h.service.UpdateProcessed(image.ID, *body.Processed, h.requestMember(c)); err != nil {
 		log.Printf("internal error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
 		return
@@ -266,15 +319,13 @@ func (h *ImageHandler) DeleteImage(c *gin.Context) {
 		return
 	}
 
-	imageID, err := strconv.ParseUint(c.Param("imageId"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image id"})
-		return
-	}
-
 	// Verify image belongs to this tab
-	image, err := h.service.GetByID(uint(imageID))
+	image, err := h.service.GetByRef(c.Param("imageId"))
 	if err != nil {
+		if errors.Is(err, security.ErrInvalidRef) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image id"})
+			return
+		}
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
 			return
@@ -288,7 +339,7 @@ func (h *ImageHandler) DeleteImage(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Delete(uint(imageID), h.uploadDir); err != nil {
+	if err := h.service.Delete(image.ID, h.uploadDir, h.requestMember(c)); err != nil {
 		log.Printf("internal error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
 		return
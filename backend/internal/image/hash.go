@@ -0,0 +1,132 @@
+package image
+
+import (
+	"backend/pkg/models"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	stdimage "image"
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"math/bits"
+	"strconv"
+)
+
+// nearDuplicateDistance is the maximum Hamming distance between two perceptual
+// hashes for the images to be considered near-duplicates.
+const nearDuplicateDistance = 6
+
+// DuplicateImageError is returned when a tab already holds a byte-identical upload.
+type DuplicateImageError struct {
+	Existing *models.TabImage
+}
+
+func (e *DuplicateImageError) Error() string {
+	return fmt.Sprintf("image duplicates existing image %d", e.Existing.ID)
+}
+
+// contentHash returns the hex-encoded SHA-256 of the raw upload bytes.
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// perceptualHash computes a 64-bit difference hash (dHash) of the image.
+// The image is reduced to a 9x8 grayscale grid and each bit records whether a
+// cell is brighter than its right-hand neighbour, so re-encodes, resizes and
+// small crops of the same photo land within a few bits of each other.
+// Returns "" for formats the standard library cannot decode (WebP, HEIC).
+func perceptualHash(data []byte) string {
+	img, _, err := stdimage.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	b := img.Bounds()
+	if b.Dx() < 9 || b.Dy() < 8 {
+		return ""
+	}
+
+	var grid [8][9]float64
+	for gy := 0; gy < 8; gy++ {
+		for gx := 0; gx < 9; gx++ {
+			grid[gy][gx] = cellLuminance(img, b, gx, gy)
+		}
+	}
+
+	var hash uint64
+	for gy := 0; gy < 8; gy++ {
+		for gx := 0; gx < 8; gx++ {
+			hash <<= 1
+			if grid[gy][gx] < grid[gy][gx+1] {
+				hash |= 1
+			}
+		}
+	}
+	return strconv.FormatUint(hash, 16)
+}
+
+// cellLuminance averages the luminance of one grid cell, sampling at most
+// 16x16 pixels so large photos stay cheap to hash.
+func cellLuminance(img stdimage.Image, b stdimage.Rectangle, gx, gy int) float64 {
+	x0 := b.Min.X + gx*b.Dx()/9
+	x1 := b.Min.X + (gx+1)*b.Dx()/9
+	y0 := b.Min.Y + gy*b.Dy()/8
+	y1 := b.Min.Y + (gy+1)*b.Dy()/8
+	stepX := max((x1-x0)/16, 1)
+	stepY := max((y1-y0)/16, 1)
+
+	var sum float64
+	var n int
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// hashDistance returns the number of differing bits between two perceptual
+// hashes, or -1 if either is missing or malformed.
+func hashDistance(a, b string) int {
+	if a == "" || b == "" {
+		return -1
+	}
+	x, err := strconv.ParseUint(a, 16, 64)
+	if err != nil {
+		return -1
+	}
+	y, err := strconv.ParseUint(b, 16, 64)
+	if err != nil {
+		return -1
+	}
+	return bits.OnesCount64(x ^ y)
+}
+
+// flagNearDuplicates marks each image that looks like an earlier upload in the
+// same list, pointing NearDuplicateOf at the oldest visually similar image.
+func flagNearDuplicates(images []models.TabImage) {
+	for i := range images {
+		oldest := -1
+		for j := range images {
+			if i == j || !images[j].CreatedAt.Before(images[i].CreatedAt) {
+				continue
+			}
+			d := hashDistance(images[i].PerceptualHash, images[j].PerceptualHash)
+			if d < 0 || d > nearDuplicateDistance {
+				continue
+			}
+			if oldest < 0 || images[j].CreatedAt.Before(images[oldest].CreatedAt) {
+				oldest = j
+			}
+		}
+		if oldest >= 0 {
+			images[i].NearDuplicateOf = images[oldest].PublicID
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package image
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+)
+
+const (
+	defaultMaxTabBytes  = 200 << 20 // 200 MB
+	defaultMaxTabImages = 100
+)
+
+// ErrQuotaExceeded is returned when an upload would push a tab past its storage quota.
+var ErrQuotaExceeded = errors.New("tab image quota exceeded")
+
+// Quota caps the total image storage a single tab may hold.
+// A zero value for either field disables that limit.
+type Quota struct {
+	MaxBytes  int64
+	MaxImages int
+}
+
+// Usage reports a tab's current image storage alongside its quota.
+type Usage struct {
+	UsedBytes  int64
+	UsedImages int
+	Quota      Quota
+}
+
+// RemainingBytes returns how many bytes the tab may still upload, or -1 if unlimited.
+func (u Usage) RemainingBytes() int64 {
+	if u.Quota.MaxBytes <= 0 {
+		return -1
+	}
+	return max(u.Quota.MaxBytes-u.UsedBytes, 0)
+}
+
+// RemainingImages returns how many images the tab may still upload, or -1 if unlimited.
+func (u Usage) RemainingImages() int {
+	if u.Quota.MaxImages <= 0 {
+		return -1
+	}
+	return max(u.Quota.MaxImages-u.UsedImages, 0)
+}
+
+// Allows reports whether one more image of the given size fits within the quota.
+func (u Usage) Allows(size int64) bool {
+	if u.Quota.MaxImages > 0 && u.UsedImages+1 > u.Quota.MaxImages {
+		return false
+	}
+	if u.Quota.MaxBytes > 0 && u.UsedBytes+size > u.Quota.MaxBytes {
+		return false
+	}
+	return true
+}
+
+// QuotaFromEnv reads TAB_IMAGE_MAX_BYTES and TAB_IMAGE_MAX_COUNT, falling back to defaults.
+func QuotaFromEnv() Quota {
+	q := Quota{MaxBytes: defaultMaxTabBytes, MaxImages: defaultMaxTabImages}
+	if v := os.Getenv("TAB_IMAGE_MAX_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			q.MaxBytes = n
+		} else {
+			log.Printf("ignoring invalid TAB_IMAGE_MAX_BYTES %q", v)
+		}
+	}
+	if v := os.Getenv("TAB_IMAGE_MAX_COUNT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			q.MaxImages = n
+		} else {
+			log.Printf("ignoring invalid TAB_IMAGE_MAX_COUNT %q", v)
+		}
+	}
+	return q
+}
@@ -1,17 +1,26 @@
 package image
 
 import (
+	"backend/internal/audit"
+	"backend/internal/events"
 	"backend/pkg/models"
+	"backend/pkg/security"
+	"errors"
 
+	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ImageRepository interface {
 	Create(image *models.TabImage) error
+	CreateChecked(image *models.TabImage, quota Quota, actor *models.TabMember) error
 	GetByTabID(tabID uint) ([]models.TabImage, error)
 	GetByID(id uint) (*models.TabImage, error)
-	UpdateProcessed(id uint, processed bool) error
-	Delete(id uint) error
+	GetByPublicID(publicID string) (*models.TabImage, error)
+	GetUsage(tabID uint) (bytes int64, count int, err error)
+	UpdateProcessed(id uint, processed bool, actor *models.TabMember) error
+	Delete(id uint, actor *models.TabMember) error
 }
 
 type imageRepository struct {
@@ -22,6 +31,48 @@ func (r *imageRepository) Create(image *models.TabImage) error {
 	return r.db.Create(image).Error
 }
 
+// CreateChecked inserts the image only if the tab stays within quota and holds
+// no byte-identical image. The tab row is locked for the duration so concurrent
+// uploads cannot both squeeze under the limit or both slip past the duplicate check.
+func (r *imageRepository) CreateChecked(image *models.TabImage, quota Quota, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Tab{}, image.TabID).Error; err != nil {
+			return err
+		}
+		if image.ContentHash != "" {
+			existing := &models.TabImage{}
+			err := tx.Where("tab_id = ? AND content_hash = ?", image.TabID, image.ContentHash).First(existing).Error
+			if err == nil {
+				return &DuplicateImageError{Existing: existing}
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+		bytes, count, err := tabUsage(tx, image.TabID)
+		if err != nil {
+			return err
+		}
+		usage := Usage{UsedBytes: bytes, UsedImages: count, Quota: quota}
+		if !usage.Allows(image.Size) {
+			return ErrQuotaExceeded
+		}
+		if err := tx.Create(image).Error; err != nil {
+			return err
+		}
+		if err := events.Record(tx, image.TabID, events.ImageUploaded{ImageID: security.Ref(image.PublicID)}); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  image.TabID,
+			Actor:  actor,
+			Action: "image.uploaded",
+			Target: "image:" + image.PublicID,
+			After:  gin.H{"filename": image.Filename, "size": image.Size, "mime_type": image.MimeType},
+		})
+	})
+}
+
 func (r *imageRepository) GetByTabID(tabID uint) ([]models.TabImage, error) {
 	var images []models.TabImage
 	err := r.db.Where("tab_id = ?", tabID).Order("created_at DESC").Find(&images).Error
@@ -34,12 +85,72 @@ func (r *imageRepository) GetByID(id uint) (*models.TabImage, error) {
 	return image, err
 }
 
-func (r *imageRepository) UpdateProcessed(id uint, processed bool) error {
-	return r.db.Model(&models.TabImage{}).Where("id = ?", id).Update("processed", processed).Error
+func (r *imageRepository) GetByPublicID(publicID string) (*models.TabImage, error) {
+	image := &models.TabImage{}
+	err := r.db.Where("public_id = ?", publicID).First(image).Error
+	return image, err
+}
+
+func (r *imageRepository) GetUsage(tabID uint) (int64, int, error) {
+	return tabUsage(r.db, tabID)
+}
+
+func (r *imageRepository) UpdateProcessed(id uint, processed bool, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		image := &models.TabImage{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "public_id", "tab_id", "processed").First(image, id).Error; err != nil {
+			return err
+		}
+		before := image.Processed
+		if err := tx.Model(image).Update("processed", processed).Error; err != nil {
+			return err
+		}
+		if err := events.Record(tx, image.TabID, events.ImageProcessed{ImageID: security.Ref(image.PublicID), Processed: processed}); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  image.TabID,
+			Actor:  actor,
+			Action: "image.processed",
+			Target: "image:" + image.PublicID,
+			Before: gin.H{"processed": before},
+			After:  gin.H{"processed": processed},
+		})
+	})
+}
+
+func (r *imageRepository) Delete(id uint, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		image := &models.TabImage{}
+		if err := tx.First(image, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(image).Error; err != nil {
+			return err
+		}
+		if err := events.Record(tx, image.TabID, events.ImageDeleted{ImageID: security.Ref(image.PublicID)}); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  image.TabID,
+			Actor:  actor,
+			Action: "image.deleted",
+			Target: "image:" + image.PublicID,
+			Before: gin.H{"filename": image.Filename, "size": image.Size, "uploaded_by": image.UploadedBy, "processed": image.Processed},
+		})
+	})
 }
 
-func (r *imageRepository) Delete(id uint) error {
-	return r.db.Delete(&models.TabImage{}, id).Error
+func tabUsage(db *gorm.DB, tabID uint) (int64, int, error) {
+	var row struct {
+		Bytes int64
+		Count int
+	}
+	err := db.Model(&models.TabImage{}).
+		Select("COALESCE(SUM(size), 0) AS bytes, COUNT(*) AS count").
+		Where("tab_id = ?", tabID).
+		Scan(&row).Error
+	return row.Bytes, row.Count, err
 }
 
 func NewImageRepository(db *gorm.DB) ImageRepository {
@@ -2,52 +2,85 @@ package image
 
 import (
 	"backend/pkg/models"
-	"fmt"
+	"backend/pkg/security"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type ImageService interface {
-	Create(image *models.TabImage) error
+	Create(image *models.TabImage, actor *models.TabMember) error
 	GetByTabID(tabID uint) ([]models.TabImage, error)
 	GetByID(id uint) (*models.TabImage, error)
-	UpdateProcessed(id uint, processed bool) error
-	Delete(id uint, uploadDir string) error
+	GetByRef(ref string) (*models.TabImage, error)
+	GetUsage(tabID uint) (*Usage, error)
+	UpdateProcessed(id uint, processed bool, actor *models.TabMember) error
+	Delete(id uint, uploadDir string, actor *models.TabMember) error
 }
 
 type imageService struct {
-	repo ImageRepository
+	repo  ImageRepository
+	quota Quota
 }
 
-func (s *imageService) Create(image *models.TabImage) error {
-	return s.repo.Create(image)
+// Create stores the image record, failing with ErrQuotaExceeded if the tab is
+// full or a *DuplicateImageError if the same bytes were already uploaded.
+func (s *imageService) Create(image *models.TabImage, actor *models.TabMember) error {
+	return s.repo.CreateChecked(image, s.quota, actor)
 }
 
+// GetByTabID lists a tab's images with near-duplicates flagged.
 func (s *imageService) GetByTabID(tabID uint) ([]models.TabImage, error) {
-	return s.repo.GetByTabID(tabID)
+	images, err := s.repo.GetByTabID(tabID)
+	if err != nil {
+		return nil, err
+	}
+	flagNearDuplicates(images)
+	return images, nil
 }
 
 func (s *imageService) GetByID(id uint) (*models.TabImage, error) {
 	return s.repo.GetByID(id)
 }
 
-func (s *imageService) UpdateProcessed(id uint, processed bool) error {
-	return s.repo.UpdateProcessed(id, processed)
+// GetByRef looks an image up by public ID, or by numeric ID for legacy clients.
+func (s *imageService) GetByRef(ref string) (*models.TabImage, error) {
+	id, publicID, err := security.ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if publicID != "" {
+		return s.repo.GetByPublicID(publicID)
+	}
+	return s.repo.GetByID(id)
+}
+
+func (s *imageService) GetUsage(tabID uint) (*Usage, error) {
+	bytes, count, err := s.repo.GetUsage(tabID)
+	if err != nil {
+		return nil, err
+	}
+	return &Usage{UsedBytes: bytes, UsedImages: count, Quota: s.quota}, nil
+}
+
+func (s *imageService) UpdateProcessed(id uint, processed bool, actor *models.TabMember) error {
+	return s.repo.UpdateProcessed(id, processed, actor)
 }
 
-func (s *imageService) Delete(id uint, uploadDir string) error {
+func (s *imageService) Delete(id uint, uploadDir string, actor *models.TabMember) error {
 	image, err := s.repo.GetByID(id)
 	if err != nil {
 		return err
 	}
 
-	// Delete file from disk
-	filePath := filepath.Join(uploadDir, "tabs", fmt.Sprintf("%d", image.TabID), image.Filename)
+	// Delete file from disk. The URL records which directory the upload went to:
+	// older images sit under the numeric tab ID, newer ones under the public ID.
+	filePath := filepath.Join(uploadDir, filepath.FromSlash(strings.TrimPrefix(image.URL, "/uploads/")))
 	os.Remove(filePath) // best-effort file deletion
 
-	return s.repo.Delete(id)
+	return s.repo.Delete(id, actor)
 }
 
-func NewImageService(repo ImageRepository) ImageService {
-	return &imageService{repo: repo}
+func NewImageService(repo ImageRepository, quota Quota) ImageService {
+	return &imageService{repo: repo, quota: quota}
 }
@@ -0,0 +1,250 @@
+package image
+
+import (
+	"backend/pkg/models"
+	"bytes"
+	"errors"
+	stdimage "image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+)
+
+// ── Mock ImageRepository ────────────────────────────────────────
+
+type mockImageRepository struct {
+	images []models.TabImage
+
+	usageBytes int64
+	usageCount int
+	usageErr   error
+
+	createdQuota Quota
+}
+
+func (m *mockImageRepository) Create(image *models.TabImage) error {
+	image.ID = uint(len(m.images) + 1)
+	m.images = append(m.images, *image)
+	return nil
+}
+
+func (m *mockImageRepository) CreateChecked(image *models.TabImage, quota Quota, actor *models.TabMember) error {
+	m.createdQuota = quota
+	for i := range m.images {
+		if image.ContentHash != "" && m.images[i].TabID == image.TabID && m.images[i].ContentHash == image.ContentHash {
+			return &DuplicateImageError{Existing: &m.images[i]}
+		}
+	}
+	usage := Usage{UsedBytes: m.usageBytes, UsedImages: m.usageCount, Quota: quota}
+	if !usage.Allows(image.Size) {
+		return ErrQuotaExceeded
+	}
+	return m.Create(image)
+}
+
+func (m *mockImageRepository) GetByTabID(tabID uint) ([]models.TabImage, error) {
+	var result []models.TabImage
+	for _, img := range m.images {
+		if img.TabID == tabID {
+			result = append(result, img)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockImageRepository) GetByID(id uint) (*models.TabImage, error) {
+	for i := range m.images {
+		if m.images[i].ID == id {
+			return &m.images[i], nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (m *mockImageRepository) GetByPublicID(publicID string) (*models.TabImage, error) {
+	for i := range m.images {
+		if m.images[i].PublicID == publicID {
+			return &m.images[i], nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (m *mockImageRepository) GetUsage(tabID uint) (int64, int, error) {
+	return m.usageBytes, m.usageCount, m.usageErr
+}
+
+func (m *mockImageRepository) UpdateProcessed(id uint, processed bool, actor *models.TabMember) error {
+	return nil
+}
+func (m *mockImageRepository) Delete(id uint, actor *models.TabMember) error { return nil }
+
+// ── Tests ───────────────────────────────────────────────────────
+
+func TestCreate_WithinQuota(t *testing.T) {
+	repo := &mockImageRepository{usageBytes: 500, usageCount: 2}
+	quota := Quota{MaxBytes: 1000, MaxImages: 3}
+	svc := NewImageService(repo, quota)
+
+	err := svc.Create(&models.TabImage{TabID: 1, Size: 500}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.createdQuota != quota {
+		t.Errorf("expected quota %+v passed to repository, got %+v", quota, repo.createdQuota)
+	}
+}
+
+func TestCreate_ByteQuotaExceeded(t *testing.T) {
+	repo := &mockImageRepository{usageBytes: 900, usageCount: 1}
+	svc := NewImageService(repo, Quota{MaxBytes: 1000, MaxImages: 10})
+
+	err := svc.Create(&models.TabImage{TabID: 1, Size: 101}, nil)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+}
+
+func TestCreate_ImageCountQuotaExceeded(t *testing.T) {
+	repo := &mockImageRepository{usageBytes: 10, usageCount: 3}
+	svc := NewImageService(repo, Quota{MaxBytes: 1000, MaxImages: 3})
+
+	err := svc.Create(&models.TabImage{TabID: 1, Size: 1}, nil)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+}
+
+func TestGetUsage_Remaining(t *testing.T) {
+	repo := &mockImageRepository{usageBytes: 1200, usageCount: 4}
+	svc := NewImageService(repo, Quota{MaxBytes: 1000, MaxImages: 10})
+
+	usage, err := svc.GetUsage(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if usage.RemainingBytes() != 0 {
+		t.Errorf("expected remaining bytes clamped to 0, got %d", usage.RemainingBytes())
+	}
+	if usage.RemainingImages() != 6 {
+		t.Errorf("expected 6 remaining images, got %d", usage.RemainingImages())
+	}
+}
+
+func TestGetUsage_Unlimited(t *testing.T) {
+	repo := &mockImageRepository{usageBytes: 1 << 30, usageCount: 500}
+	svc := NewImageService(repo, Quota{})
+
+	usage, err := svc.GetUsage(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if usage.RemainingBytes() != -1 || usage.RemainingImages() != -1 {
+		t.Errorf("expected unlimited quota to report -1, got %d bytes / %d images", usage.RemainingBytes(), usage.RemainingImages())
+	}
+	if !usage.Allows(1 << 30) {
+		t.Error("expected unlimited quota to allow any upload")
+	}
+}
+
+func TestCreate_DuplicateContent(t *testing.T) {
+	repo := &mockImageRepository{}
+	svc := NewImageService(repo, Quota{})
+
+	first := &models.TabImage{TabID: 1, ContentHash: "abc"}
+	if err := svc.Create(first, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err := svc.Create(&models.TabImage{TabID: 1, ContentHash: "abc"}, nil)
+	var dup *DuplicateImageError
+	if !errors.As(err, &dup) {
+		t.Fatalf("expected DuplicateImageError, got %v", err)
+	}
+	if dup.Existing.ID != first.ID {
+		t.Errorf("expected existing image %d, got %d", first.ID, dup.Existing.ID)
+	}
+
+	// Same bytes in a different tab are fine
+	if err := svc.Create(&models.TabImage{TabID: 2, ContentHash: "abc"}, nil); err != nil {
+		t.Errorf("expected no error for other tab, got %v", err)
+	}
+}
+
+func TestGetByTabID_FlagsNearDuplicates(t *testing.T) {
+	now := time.Now()
+	original := perceptualHash(encodePNG(t, gradient(90, 80, false)))
+	resized := perceptualHash(encodeJPEG(t, gradient(180, 160, false)))
+	different := perceptualHash(encodePNG(t, gradient(90, 80, true)))
+
+	repo := &mockImageRepository{images: []models.TabImage{
+		{ID: 3, PublicID: "img3", TabID: 1, PerceptualHash: different, CreatedAt: now},
+		{ID: 2, PublicID: "img2", TabID: 1, PerceptualHash: resized, CreatedAt: now.Add(-time.Minute)},
+		{ID: 1, PublicID: "img1", TabID: 1, PerceptualHash: original, CreatedAt: now.Add(-time.Hour)},
+	}}
+	svc := NewImageService(repo, Quota{})
+
+	images, err := svc.GetByTabID(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	flags := make(map[uint]string)
+	for _, img := range images {
+		flags[img.ID] = img.NearDuplicateOf
+	}
+	if flags[2] != "img1" {
+		t.Errorf("expected resized image 2 flagged as near-duplicate of img1, got %q", flags[2])
+	}
+	if flags[1] != "" {
+		t.Errorf("expected oldest image to be unflagged, got %q", flags[1])
+	}
+	if flags[3] != "" {
+		t.Errorf("expected different image to be unflagged, got %q", flags[3])
+	}
+}
+
+func TestPerceptualHash_UnsupportedFormat(t *testing.T) {
+	if h := perceptualHash([]byte("RIFF\x00\x00\x00\x00WEBPVP8 ")); h != "" {
+		t.Errorf("expected empty hash for undecodable data, got %q", h)
+	}
+}
+
+// gradient draws a horizontal grayscale ramp with a dark block, optionally mirrored.
+func gradient(w, h int, mirrored bool) stdimage.Image {
+	img := stdimage.NewGray(stdimage.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(x * 255 / w)
+			if x > w/3 && x < w/2 && y > h/4 && y < h/2 {
+				v = 10
+			}
+			if mirrored {
+				img.SetGray(w-1-x, y, color.Gray{Y: v})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: v})
+			}
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img stdimage.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img stdimage.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 70}); err != nil {
+		t.Fatalf("jpeg encode: %v", err)
+	}
+	return buf.Bytes()
+}
@@ -1 +1,362 @@
 package payments
+
+import (
+	"backend/internal/tabauth"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TabAccess looks up tabs and members for token validation. Satisfied by
+// tab.TabService.
+type TabAccess interface {
+	GetTabByRef(ref string) (*models.Tab, error)
+	GetMemberByToken(token string) (*models.TabMember, error)
+	GetMembers(tabID uint) ([]models.TabMember, error)
+}
+
+// BillResolver looks up bills for token validation. Satisfied by
+// bill.BillService.
+type BillResolver interface {
+	GetBillByRef(ref string) (*models.Bill, error)
+}
+
+type PaymentHandler struct {
+	service PaymentService
+	tabs    TabAccess
+	bills   BillResolver
+}
+
+func NewPaymentHandler(service PaymentService, tabs TabAccess, bills BillResolver) *PaymentHandler {
+	return &PaymentHandler{service: service, tabs: tabs, bills: bills}
+}
+
+type paymentBody struct {
+	Amount      float64    `json:"amount"`
+	Method      string     `json:"method"`
+	Payee       string     `json:"payee"`
+	Note        string     `json:"note"`
+	ExternalRef string     `json:"external_ref"`
+	PaidAt      *time.Time `json:"paid_at"`
+}
+
+func (b paymentBody) input() Input {
+	return Input{
+		Amount:      b.Amount,
+		Method:      b.Method,
+		Payee:       security.SanitizeString(b.Payee),
+		Note:        security.SanitizeString(b.Note),
+		ExternalRef: security.SanitizeString(b.ExternalRef),
+		PaidAt:      b.PaidAt,
+	}
+}
+
+// StatusRequest is the body of a settlement or share status change. Paid is
+// the older plain toggle, still accepted when Status is empty.
+type StatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+	Paid   *bool  `json:"paid"`
+}
+
+// Action resolves the request into the action the caller may take on a
+// settlement or share owed by payerMemberID.
+func (r StatusRequest) Action(caller Caller, payerMemberID *uint) (Action, error) {
+	status := strings.ToLower(strings.TrimSpace(r.Status))
+	if status == "" {
+		if r.Paid == nil {
+			return "", ErrInvalidStatus
+		}
+		status = LegacyStatus(caller, *r.Paid)
+	}
+	if len(r.Reason) > maxNoteLength {
+		return "", ErrFieldTooLong
+	}
+	return ActionFor(caller, status, payerMemberID)
+}
+
+// CreatorToken returns the bill creator token presented with the request.
+func CreatorToken(c *gin.Context) string {
+	if token := c.GetHeader("X-Creator-Token"); token != "" {
+		return token
+	}
+	return c.Query("c")
+}
+
+// StatusCode maps the errors of a status change or payment operation onto an
+// HTTP status, or returns 0 for errors that are not the client's.
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidAmount), errors.Is(err, ErrInvalidMethod), errors.Is(err, ErrInvalidPaidAt),
+		errors.Is(err, ErrFieldTooLong), errors.Is(err, ErrInvalidStatus):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotPayer), errors.Is(err, ErrNotPayee):
+		return http.StatusForbidden
+	case errors.Is(err, ErrSettlementNotFound), errors.Is(err, ErrShareNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrOverpayment), errors.Is(err, ErrAlreadyVoided), errors.Is(err, ErrNotPending):
+		return http.StatusConflict
+	}
+	return 0
+}
+
+// validateBill resolves the bill and checks its access token.
+// Returns the bill on success or writes an error and returns nil.
+func (h *PaymentHandler) validateBill(c *gin.Context) *models.Bill {
+	bill, err := h.bills.GetBillByRef(c.Param("id"))
+	if err != nil {
+		respondLookupError(c, err, "bill not found")
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(tabauth.RequestToken(c)), []byte(bill.AccessToken)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "token mismatch"})
+		return nil
+	}
+	return bill
+}
+
+// billMembers returns the members of the tab the bill is on, which its
+// payments refer to. Bills outside a tab have none.
+func (h *PaymentHandler) billMembers(bill *models.Bill) (models.MemberRefs, error) {
+	if bill.TabID == nil {
+		return models.MemberRefs{}, nil
+	}
+	members, err := h.tabs.GetMembers(*bill.TabID)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewMemberRefs(members), nil
+}
+
+// RecordSettlementPayment handles POST /api/tabs/:id/settlements/:settlementId/payments
+func (h *PaymentHandler) RecordSettlementPayment(c *gin.Context) {
+	t := tabauth.ValidateTab(c, h.tabs)
+	if t == nil {
+		return
+	}
+
+	var body paymentBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	member := tabauth.RequestMember(c, h.tabs)
+	payment, err := h.service.RecordForSettlement(t, c.Param("settlementId"), body.input(), TabCaller(t, member))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	models.NewMemberRefs(t.Members).Payment(payment)
+	c.JSON(http.StatusCreated, payment)
+}
+
+// ListTabPayments handles GET /api/tabs/:id/payments
+func (h *PaymentHandler) ListTabPayments(c *gin.Context) {
+	t := tabauth.ValidateTab(c, h.tabs)
+	if t == nil {
+		return
+	}
+
+	payments, err := h.service.ListForTab(t.ID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	members := models.NewMemberRefs(t.Members)
+	for i := range payments {
+		members.Payment(&payments[i])
+	}
+	c.JSON(http.StatusOK, payments)
+}
+
+// ConfirmTabPayment handles POST /api/tabs/:id/payments/:paymentId/confirm
+func (h *PaymentHandler) ConfirmTabPayment(c *gin.Context) {
+	h.changeTabPayment(c, func(scope Scope, caller Caller) (*models.Payment, error) {
+		return h.service.Confirm(scope, c.Param("paymentId"), caller)
+	})
+}
+
+// DisputeTabPayment handles POST /api/tabs/:id/payments/:paymentId/dispute
+func (h *PaymentHandler) DisputeTabPayment(c *gin.Context) {
+	reason, ok := disputeReason(c)
+	if !ok {
+		return
+	}
+	h.changeTabPayment(c, func(scope Scope, caller Caller) (*models.Payment, error) {
+		return h.service.Dispute(scope, c.Param("paymentId"), reason, caller)
+	})
+}
+
+// VoidTabPayment handles DELETE /api/tabs/:id/payments/:paymentId
+func (h *PaymentHandler) VoidTabPayment(c *gin.Context) {
+	h.changeTabPayment(c, func(scope Scope, caller Caller) (*models.Payment, error) {
+		return h.service.Void(scope, c.Param("paymentId"), caller)
+	})
+}
+
+// changeTabPayment validates the tab and makes change as the request's caller.
+func (h *PaymentHandler) changeTabPayment(c *gin.Context, change func(Scope, Caller) (*models.Payment, error)) {
+	t := tabauth.ValidateTab(c, h.tabs)
+	if t == nil {
+		return
+	}
+
+	member := tabauth.RequestMember(c, h.tabs)
+	payment, err := change(Scope{TabID: t.ID}, TabCaller(t, member))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	models.NewMemberRefs(t.Members).Payment(payment)
+	c.JSON(http.StatusOK, payment)
+}
+
+// RecordSharePayment handles POST /api/bills/:id/shares/:shareId/payments
+func (h *PaymentHandler) RecordSharePayment(c *gin.Context) {
+	bill := h.validateBill(c)
+	if bill == nil {
+		return
+	}
+
+	var body paymentBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	payment, err := h.service.RecordForShare(bill, c.Param("shareId"), body.input(), BillCaller(bill, CreatorToken(c)))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.respondBillPayment(c, http.StatusCreated, bill, payment)
+}
+
+// ListBillPayments handles GET /api/bills/:id/payments
+func (h *PaymentHandler) ListBillPayments(c *gin.Context) {
+	bill := h.validateBill(c)
+	if bill == nil {
+		return
+	}
+
+	payments, err := h.service.ListForBill(bill.ID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	members, err := h.billMembers(bill)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	for i := range payments {
+		members.Payment(&payments[i])
+	}
+	c.JSON(http.StatusOK, payments)
+}
+
+// ConfirmBillPayment handles POST /api/bills/:id/payments/:paymentId/confirm
+func (h *PaymentHandler) ConfirmBillPayment(c *gin.Context) {
+	h.changeBillPayment(c, func(scope Scope, caller Caller) (*models.Payment, error) {
+		return h.service.Confirm(scope, c.Param("paymentId"), caller)
+	})
+}
+
+// DisputeBillPayment handles POST /api/bills/:id/payments/:paymentId/dispute
+func (h *PaymentHandler) DisputeBillPayment(c *gin.Context) {
+	reason, ok := disputeReason(c)
+	if !ok {
+		return
+	}
+	h.changeBillPayment(c, func(scope Scope, caller Caller) (*models.Payment, error) {
+		return h.service.Dispute(scope, c.Param("paymentId"), reason, caller)
+	})
+}
+
+// VoidBillPayment handles DELETE /api/bills/:id/payments/:paymentId
+func (h *PaymentHandler) VoidBillPayment(c *gin.Context) {
+	h.changeBillPayment(c, func(scope Scope, caller Caller) (*models.Payment, error) {
+		return h.service.Void(scope, c.Param("paymentId"), caller)
+	})
+}
+
+// changeBillPayment is changeTabPayment for a bill, whose caller is the payee
+// when they present the bill's creator token.
+func (h *PaymentHandler) changeBillPayment(c *gin.Context, change func(Scope, Caller) (*models.Payment, error)) {
+	bill := h.validateBill(c)
+	if bill == nil {
+		return
+	}
+
+	payment, err := change(Scope{BillID: bill.ID}, BillCaller(bill, CreatorToken(c)))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.respondBillPayment(c, http.StatusOK, bill, payment)
+}
+
+// respondBillPayment writes a payment on the bill with its payer and payee
+// members.
+func (h *PaymentHandler) respondBillPayment(c *gin.Context, status int, bill *models.Bill, payment *models.Payment) {
+	members, err := h.billMembers(bill)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	members.Payment(payment)
+	c.JSON(status, payment)
+}
+
+// disputeReason reads the optional reason from a dispute request's body.
+func disputeReason(c *gin.Context) (string, bool) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return "", false
+	}
+	return security.SanitizeString(body.Reason), true
+}
+
+func (h *PaymentHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, security.ErrInvalidRef):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+	case err == gorm.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "payment not found"})
+	case StatusCode(err) != 0:
+		c.JSON(StatusCode(err), gin.H{"error": err.Error()})
+	default:
+		log.Printf("internal error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
+	}
+}
+
+func respondLookupError(c *gin.Context, err error, notFound string) {
+	if errors.Is(err, security.ErrInvalidRef) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		return
+	}
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return
+	}
+	log.Printf("internal error: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
+}
@@ -0,0 +1,521 @@
+package payments
+
+import (
+	"backend/internal/events"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Payment statuses. A payment the payer reports is sent until the payee
+// confirms it (received) or rejects it (disputed); one the payee records is
+// received straight away. Settlements and shares additionally derive unpaid
+// and partial.
+const (
+	StatusUnpaid   = "unpaid"
+	StatusPartial  = "partial"
+	StatusSent     = "sent"
+	StatusReceived = "received"
+	StatusDisputed = "disputed"
+)
+
+// Action is a change to all of a settlement's or share's payments at once,
+// made through its status rather than through individual payments.
+type Action string
+
+const (
+	// ActionSend records whatever is outstanding as sent by the payer.
+	ActionSend Action = "send"
+	// ActionReceive confirms every unconfirmed payment and records the rest
+	// of what is owed as received.
+	ActionReceive Action = "receive"
+	// ActionDispute disputes every payment awaiting confirmation.
+	ActionDispute Action = "dispute"
+	// ActionVoid voids every payment; only the payee may.
+	ActionVoid Action = "void"
+	// ActionRetract voids the payer's unconfirmed payments.
+	ActionRetract Action = "retract"
+)
+
+// Target is what an Action applies to: a tab settlement or a person share.
+// Exactly one ID is set.
+type Target struct {
+	SettlementID uint
+	ShareID      uint
+}
+
+// The functions below run inside the caller's transaction, like events.Record,
+// so a settlement's or share's paid state never disagrees with its payments.
+
+// Record inserts a payment against a tab payer (TabID and PayerKey set) or a
+// person share (BillID and PersonShareID set) and re-derives what it pays.
+// Payments without a status are recorded as received. Returns ErrOverpayment
+// if it exceeds what is still owed and not already awaiting confirmation.
+func Record(tx *gorm.DB, payment *models.Payment) error {
+	owed, pending, err := lockOutstanding(tx, payment)
+	if err != nil {
+		return err
+	}
+	if payment.Amount > owed-pending+0.005 {
+		return ErrOverpayment
+	}
+	if payment.Status == "" {
+		payment.Status = StatusReceived
+	}
+	if payment.Status == StatusReceived && payment.ReceivedAt == nil {
+		receivedAt := payment.PaidAt
+		payment.ReceivedAt = &receivedAt
+	}
+	if err := tx.Create(payment).Error; err != nil {
+		return err
+	}
+	recorded := events.PaymentRecorded{
+		PaymentID: security.Ref(payment.PublicID),
+		Payer:     payment.Payer,
+		Amount:    payment.Amount,
+		Method:    payment.Method,
+		Status:    payment.Status,
+	}
+	if payment.SettlementID != nil {
+		if recorded.SettlementID, err = events.PublicRef(tx, &models.TabSettlement{}, *payment.SettlementID); err != nil {
+			return err
+		}
+	}
+	if payment.PersonShareID != nil {
+		if recorded.PersonShareID, err = events.PublicRef(tx, &models.PersonShare{}, *payment.PersonShareID); err != nil {
+			return err
+		}
+	}
+	if err := recordEvent(tx, payment, recorded); err != nil {
+		return err
+	}
+	return sync(tx, payment)
+}
+
+// Receive confirms a sent or disputed payment. Returns ErrNotPending if it is
+// neither, and ErrOverpayment if it is now more than what is still owed.
+func Receive(tx *gorm.DB, payment *models.Payment, at time.Time) error {
+	owed, _, err := lockOutstanding(tx, payment)
+	if err != nil {
+		return err
+	}
+	if payment.Amount > owed+0.005 {
+		return ErrOverpayment
+	}
+	result := tx.Model(&models.Payment{}).
+		Where("id = ? AND voided_at IS NULL AND status IN ?", payment.ID, []string{StatusSent, StatusDisputed}).
+		Updates(map[string]interface{}{"status": StatusReceived, "received_at": at})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotPending
+	}
+	payment.Status = StatusReceived
+	payment.ReceivedAt = &at
+	if err := recordEvent(tx, payment, events.PaymentReceived{PaymentID: security.Ref(payment.PublicID)}); err != nil {
+		return err
+	}
+	return sync(tx, payment)
+}
+
+// Dispute marks a sent payment as not received. The payer can retract it or
+// the payee can still confirm it later.
+func Dispute(tx *gorm.DB, payment *models.Payment, at time.Time, reason string) error {
+	result := tx.Model(&models.Payment{}).
+		Where("id = ? AND voided_at IS NULL AND status = ?", payment.ID, StatusSent).
+		Updates(map[string]interface{}{"status": StatusDisputed, "disputed_at": at, "dispute_reason": reason})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotPending
+	}
+	payment.Status = StatusDisputed
+	payment.DisputedAt = &at
+	payment.DisputeReason = reason
+	err := recordEvent(tx, payment, events.PaymentDisputed{PaymentID: security.Ref(payment.PublicID), Reason: reason})
+	if err != nil {
+		return err
+	}
+	return sync(tx, payment)
+}
+
+// Void marks a payment void and re-derives what it paid. Voided payments stay
+// in the ledger but no longer count towards anything.
+func Void(tx *gorm.DB, payment *models.Payment, at time.Time) error {
+	result := tx.Model(&models.Payment{}).Where("id = ? AND voided_at IS NULL", payment.ID).Update("voided_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAlreadyVoided
+	}
+	payment.VoidedAt = &at
+	if err := recordEvent(tx, payment, events.PaymentVoided{PaymentID: security.Ref(payment.PublicID)}); err != nil {
+		return err
+	}
+	return sync(tx, payment)
+}
+
+// Apply backs the status shorthand on a settlement or share (see Action).
+// Callers are expected to have checked the action is allowed with ActionFor.
+func Apply(tx *gorm.DB, target Target, action Action, reason string) error {
+	t, err := loadTarget(tx, target)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	switch action {
+	case ActionSend:
+		amount := roundCents(t.outstanding - t.state.AmountPending)
+		if amount < 0.005 {
+			return nil
+		}
+		payment := t.base
+		payment.Status = StatusSent
+		payment.Amount = amount
+		payment.PaidAt = now
+		return Record(tx, &payment)
+
+	case ActionReceive:
+		var unconfirmed []models.Payment
+		err := t.payments().
+			Where("voided_at IS NULL AND status IN ?", []string{StatusSent, StatusDisputed}).
+			Order("id").
+			Find(&unconfirmed).Error
+		if err != nil {
+			return err
+		}
+		remaining := t.outstanding
+		for i := range unconfirmed {
+			if err := Receive(tx, &unconfirmed[i], now); err != nil {
+				return err
+			}
+			remaining -= unconfirmed[i].Amount
+		}
+		if roundCents(remaining) < 0.005 {
+			return nil
+		}
+		payment := t.base
+		payment.Status = StatusReceived
+		payment.Amount = roundCents(remaining)
+		payment.PaidAt = now
+		payment.ReceivedAt = &now
+		return Record(tx, &payment)
+
+	case ActionDispute:
+		var sent []models.Payment
+		err := t.payments().Where("voided_at IS NULL AND status = ?", StatusSent).Order("id").Find(&sent).Error
+		if err != nil {
+			return err
+		}
+		if len(sent) == 0 {
+			return ErrNotPending
+		}
+		for i := range sent {
+			if err := Dispute(tx, &sent[i], now, reason); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case ActionVoid:
+		return voidAll(tx, t.payments())
+
+	case ActionRetract:
+		return voidAll(tx, t.payments().Where("status <> ?", StatusReceived))
+	}
+	return ErrInvalidStatus
+}
+
+// ledgerTarget is a loaded Target: its current paid state, the payments made
+// against it, and the fields a new payment against it starts from.
+type ledgerTarget struct {
+	state       models.PaymentState
+	outstanding float64
+	payments    func() *gorm.DB
+	base        models.Payment
+}
+
+func loadTarget(tx *gorm.DB, target Target) (*ledgerTarget, error) {
+	t := &ledgerTarget{}
+	if target.SettlementID != 0 {
+		settlement := &models.TabSettlement{}
+		if err := tx.First(settlement, target.SettlementID).Error; err != nil {
+			return nil, err
+		}
+		key := models.PayerKey(settlement.MemberID, settlement.PersonName)
+		t.state = settlement.PaymentState
+		t.outstanding = settlement.Outstanding
+		t.payments = func() *gorm.DB {
+			return tx.Model(&models.Payment{}).Where("tab_id = ? AND payer_key = ?", settlement.TabID, key)
+		}
+		t.base = models.Payment{
+			TabID:         &settlement.TabID,
+			PayerKey:      key,
+			SettlementID:  &settlement.ID,
+			PayerMemberID: settlement.MemberID,
+			Payer:         settlement.PersonName,
+			Method:        MethodOther,
+		}
+		var creators []models.TabMember
+		if err := tx.Where("tab_id = ? AND role = ?", settlement.TabID, "creator").Limit(1).Find(&creators).Error; err != nil {
+			return nil, err
+		}
+		if len(creators) > 0 {
+			t.base.PayeeMemberID = &creators[0].ID
+			t.base.Payee = creators[0].DisplayName
+		}
+		return t, nil
+	}
+
+	share := &models.PersonShare{}
+	if err := tx.First(share, target.ShareID).Error; err != nil {
+		return nil, err
+	}
+	t.state = share.PaymentState
+	t.outstanding = share.Outstanding
+	t.payments = func() *gorm.DB {
+		return tx.Model(&models.Payment{}).Where("person_share_id = ?", share.ID)
+	}
+	t.base = models.Payment{
+		BillID:        &share.BillID,
+		PersonShareID: &share.ID,
+		PayerMemberID: share.MemberID,
+		Payer:         share.PersonName,
+		Method:        MethodOther,
+	}
+	return t, nil
+}
+
+// ledgerTotal sums one status's live payments for a payer or share.
+type ledgerTotal struct {
+	PayerKey   string
+	Status     string
+	Amount     float64
+	SentAt     *time.Time
+	ReceivedAt *time.Time
+	DisputedAt *time.Time
+}
+
+const totalsSelect = "payer_key, status, SUM(amount) AS amount, MAX(paid_at) AS sent_at, " +
+	"MAX(received_at) AS received_at, MAX(disputed_at) AS disputed_at"
+
+// derive computes the paid state of amount from its payment totals. Only
+// received payments count as paid; sent ones are pending until confirmed.
+func derive(amount float64, totals []ledgerTotal) models.PaymentState {
+	var state models.PaymentState
+	var disputed bool
+	for _, t := range totals {
+		switch t.Status {
+		case StatusReceived:
+			state.AmountPaid += t.Amount
+			state.ReceivedAt = latest(state.ReceivedAt, t.ReceivedAt)
+		case StatusSent:
+			state.AmountPending += t.Amount
+		case StatusDisputed:
+			disputed = true
+			state.DisputedAt = latest(state.DisputedAt, t.DisputedAt)
+		}
+		state.SentAt = latest(state.SentAt, t.SentAt)
+	}
+	state.AmountPaid = roundCents(state.AmountPaid)
+	state.AmountPending = roundCents(state.AmountPending)
+	state.Paid = isSettled(amount, state.AmountPaid)
+
+	switch {
+	case state.Paid:
+		state.Status = StatusReceived
+	case state.AmountPending > 0:
+		state.Status = StatusSent
+	case disputed:
+		state.Status = StatusDisputed
+	case state.AmountPaid > 0:
+		state.Status = StatusPartial
+	default:
+		state.Status = StatusUnpaid
+	}
+	if !state.Paid {
+		state.ReceivedAt = nil
+	}
+	if state.Status != StatusDisputed {
+		state.DisputedAt = nil
+	}
+	return state
+}
+
+// SyncSettlements re-derives the paid state of the tab's current settlements
+// from its payments. Run it whenever either side changes, including when a
+// new round of settlements is created.
+func SyncSettlements(tx *gorm.DB, tabID uint) error {
+	var settlements []models.TabSettlement
+	if err := tx.Where("tab_id = ? AND superseded_at IS NULL", tabID).Find(&settlements).Error; err != nil {
+		return err
+	}
+	var totals []ledgerTotal
+	err := tx.Model(&models.Payment{}).
+		Select(totalsSelect).
+		Where("tab_id = ? AND voided_at IS NULL", tabID).
+		Group("payer_key, status").
+		Scan(&totals).Error
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string][]ledgerTotal)
+	for _, t := range totals {
+		byKey[t.PayerKey] = append(byKey[t.PayerKey], t)
+	}
+
+	for _, s := range settlements {
+		state := derive(s.Amount, byKey[models.PayerKey(s.MemberID, s.PersonName)])
+		if sameState(state, s.PaymentState) {
+			continue
+		}
+		if err := tx.Model(&models.TabSettlement{}).Where("id = ?", s.ID).Updates(stateColumns(state)).Error; err != nil {
+			return err
+		}
+		if state.Paid != s.Paid {
+			if err := events.Record(tx, tabID, events.SettlementPaid{SettlementID: security.Ref(s.PublicID), Paid: state.Paid}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func syncShare(tx *gorm.DB, shareID uint) error {
+	share := &models.PersonShare{}
+	if err := tx.First(share, shareID).Error; err != nil {
+		return err
+	}
+	var totals []ledgerTotal
+	err := tx.Model(&models.Payment{}).
+		Select(totalsSelect).
+		Where("person_share_id = ? AND voided_at IS NULL", shareID).
+		Group("payer_key, status").
+		Scan(&totals).Error
+	if err != nil {
+		return err
+	}
+	state := derive(share.Total, totals)
+	if sameState(state, share.PaymentState) {
+		return nil
+	}
+	if err := tx.Model(&models.PersonShare{}).Where("id = ?", shareID).Updates(stateColumns(state)).Error; err != nil {
+		return err
+	}
+	if state.Paid == share.Paid {
+		return nil
+	}
+	bill := &models.Bill{}
+	if err := tx.Select("id", "tab_id").First(bill, share.BillID).Error; err != nil {
+		return err
+	}
+	return events.RecordBill(tx, bill.ID, bill.TabID, events.SharePaid{ShareID: security.Ref(share.PublicID), Paid: state.Paid})
+}
+
+// lockOutstanding locks the settlement or share a payment is for and returns
+// what is still owed on it and how much of that is awaiting confirmation, so
+// concurrent payments can't overpay.
+func lockOutstanding(tx *gorm.DB, payment *models.Payment) (owed, pending float64, err error) {
+	locking := clause.Locking{Strength: "UPDATE"}
+	if payment.TabID != nil {
+		var settlements []models.TabSettlement
+		err := tx.Clauses(locking).
+			Where("tab_id = ? AND superseded_at IS NULL", *payment.TabID).
+			Find(&settlements).Error
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, s := range settlements {
+			if models.PayerKey(s.MemberID, s.PersonName) == payment.PayerKey {
+				return s.Outstanding, s.AmountPending, nil
+			}
+		}
+		return 0, 0, gorm.ErrRecordNotFound
+	}
+	share := &models.PersonShare{}
+	if err := tx.Clauses(locking).First(share, *payment.PersonShareID).Error; err != nil {
+		return 0, 0, err
+	}
+	return share.Outstanding, share.AmountPending, nil
+}
+
+func voidAll(tx *gorm.DB, scope *gorm.DB) error {
+	var active []models.Payment
+	if err := scope.Where("voided_at IS NULL").Find(&active).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range active {
+		if err := Void(tx, &active[i], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func recordEvent(tx *gorm.DB, payment *models.Payment, event events.DomainEvent) error {
+	if payment.TabID != nil {
+		return events.Record(tx, *payment.TabID, event)
+	}
+	bill := &models.Bill{}
+	if err := tx.Select("id", "tab_id").First(bill, *payment.BillID).Error; err != nil {
+		return err
+	}
+	return events.RecordBill(tx, bill.ID, bill.TabID, event)
+}
+
+func sync(tx *gorm.DB, payment *models.Payment) error {
+	if payment.TabID != nil {
+		return SyncSettlements(tx, *payment.TabID)
+	}
+	return syncShare(tx, *payment.PersonShareID)
+}
+
+func stateColumns(s models.PaymentState) map[string]interface{} {
+	return map[string]interface{}{
+		"amount_paid":    s.AmountPaid,
+		"amount_pending": s.AmountPending,
+		"paid":           s.Paid,
+		"status":         s.Status,
+		"sent_at":        s.SentAt,
+		"received_at":    s.ReceivedAt,
+		"disputed_at":    s.DisputedAt,
+	}
+}
+
+func sameState(a, b models.PaymentState) bool {
+	return a.AmountPaid == b.AmountPaid && a.AmountPending == b.AmountPending && a.Paid == b.Paid &&
+		a.Status == b.Status && sameTime(a.SentAt, b.SentAt) &&
+		sameTime(a.ReceivedAt, b.ReceivedAt) && sameTime(a.DisputedAt, b.DisputedAt)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func latest(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// isSettled reports whether amountPaid covers amount, to the cent.
+func isSettled(amount, amountPaid float64) bool {
+	return amountPaid >= amount-0.005
+}
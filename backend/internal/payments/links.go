@@ -0,0 +1,196 @@
+package payments
+
+import (
+	"backend/pkg/models"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidVenmo   = errors.New("venmo handle must be 5-30 letters, numbers, - or _")
+	ErrInvalidCashtag = errors.New("cash app $cashtag must be 1-20 letters or numbers and include a letter")
+	ErrInvalidPayPal  = errors.New("paypal identifier must be a paypal.me link or username")
+	ErrInvalidZelle   = errors.New("zelle identifier must be an email address or US phone number")
+)
+
+var (
+	venmoHandle    = regexp.MustCompile(`^[A-Za-z0-9_-]{5,30}$`)
+	cashtag        = regexp.MustCompile(`^[A-Za-z0-9]{1,20}$`)
+	hasLetter      = regexp.MustCompile(`[A-Za-z]`)
+	paypalUsername = regexp.MustCompile(`^[A-Za-z0-9]{1,20}$`)
+	nonDigits      = regexp.MustCompile(`\D`)
+)
+
+// Provider maps a payment method's display name ("Venmo", "Cash App", ...)
+// to its method constant, or "" for providers without pay links.
+func Provider(name string) string {
+	switch strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "")) {
+	case "venmo":
+		return MethodVenmo
+	case "cashapp":
+		return MethodCashApp
+	case "paypal", "paypal.me":
+		return MethodPayPal
+	case "zelle":
+		return MethodZelle
+	}
+	return ""
+}
+
+// NormalizeMethod validates a payment method's identifier for its provider
+// and returns it in canonical form: @handle for Venmo, $cashtag for Cash App,
+// paypal.me/username for PayPal, and a lowercase email or +1 phone number for
+// Zelle. Methods from other providers are returned unchanged.
+func NormalizeMethod(m models.PaymentMethod) (models.PaymentMethod, error) {
+	id := strings.TrimSpace(m.Identifier)
+	var err error
+	switch Provider(m.Name) {
+	case MethodVenmo:
+		id, err = normalizeVenmo(id)
+	case MethodCashApp:
+		id, err = normalizeCashtag(id)
+	case MethodPayPal:
+		id, err = normalizePayPal(id)
+	case MethodZelle:
+		id, err = normalizeZelle(id)
+	}
+	if err != nil {
+		return m, err
+	}
+	m.Identifier = id
+	return m, nil
+}
+
+func normalizeVenmo(id string) (string, error) {
+	handle := strings.TrimPrefix(id, "@")
+	if !venmoHandle.MatchString(handle) {
+		return "", ErrInvalidVenmo
+	}
+	return "@" + handle, nil
+}
+
+func normalizeCashtag(id string) (string, error) {
+	tag := trimURLPrefix(id, "cash.app/")
+	tag = strings.TrimPrefix(tag, "$")
+	if !cashtag.MatchString(tag) || !hasLetter.MatchString(tag) {
+		return "", ErrInvalidCashtag
+	}
+	return "$" + tag, nil
+}
+
+func normalizePayPal(id string) (string, error) {
+	user := trimURLPrefix(id, "paypal.me/", "paypal.com/paypalme/")
+	user = strings.TrimSuffix(user, "/")
+	if !paypalUsername.MatchString(user) {
+		return "", ErrInvalidPayPal
+	}
+	return "paypal.me/" + user, nil
+}
+
+func normalizeZelle(id string) (string, error) {
+	if strings.Contains(id, "@") {
+		addr, err := mail.ParseAddress(id)
+		if err != nil || addr.Name != "" || addr.Address != id {
+			return "", ErrInvalidZelle
+		}
+		return strings.ToLower(addr.Address), nil
+	}
+	digits := nonDigits.ReplaceAllString(id, "")
+	if len(digits) == 11 && digits[0] == '1' {
+		digits = digits[1:]
+	}
+	if len(digits) != 10 || strings.Trim(id, "0123456789()-+. ") != "" {
+		return "", ErrInvalidZelle
+	}
+	return "+1" + digits, nil
+}
+
+// trimURLPrefix strips an optional scheme, "www." and any of the given host
+// prefixes from a pasted profile link.
+func trimURLPrefix(id string, prefixes ...string) string {
+	lower := strings.ToLower(id)
+	for _, scheme := range []string{"https://", "http://"} {
+		if strings.HasPrefix(lower, scheme) {
+			id, lower = id[len(scheme):], lower[len(scheme):]
+		}
+	}
+	if strings.HasPrefix(lower, "www.") {
+		id, lower = id[4:], lower[4:]
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(lower, p) {
+			return id[len(p):]
+		}
+	}
+	return id
+}
+
+// Links builds a prefilled pay link for each payment method that supports
+// one. Zelle has no public pay link, so its entry carries only the
+// identifier and amount for display. Methods that fail validation are skipped.
+func Links(methods []models.PaymentMethod, amount float64, note string) []models.PayLink {
+	if amount < 0.005 {
+		return nil
+	}
+	amountText := fmt.Sprintf("%.2f", amount)
+	escapedNote := strings.ReplaceAll(url.QueryEscape(note), "+", "%20")
+
+	var links []models.PayLink
+	seen := make(map[string]bool)
+	for _, m := range methods {
+		provider := Provider(m.Name)
+		if provider == "" || seen[provider] {
+			continue
+		}
+		normalized, err := NormalizeMethod(m)
+		if err != nil {
+			continue
+		}
+		seen[provider] = true
+
+		link := models.PayLink{Provider: provider, Identifier: normalized.Identifier, Amount: amount}
+		switch provider {
+		case MethodVenmo:
+			handle := strings.TrimPrefix(normalized.Identifier, "@")
+			query := fmt.Sprintf("txn=pay&recipients=%s&amount=%s&note=%s", handle, amountText, escapedNote)
+			link.URL = "https://account.venmo.com/pay?" + query
+			link.AppURL = "venmo://paycharge?" + query
+		case MethodCashApp:
+			link.URL = fmt.Sprintf("https://cash.app/%s/%s", normalized.Identifier, amountText)
+		case MethodPayPal:
+			link.URL = fmt.Sprintf("https://%s/%sUSD", normalized.Identifier, amountText)
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// AttachShareLinks fills PayLinks on each of the bill's shares that still has
+// something outstanding.
+func AttachShareLinks(bill *models.Bill) {
+	for i := range bill.PersonShares {
+		share := &bill.PersonShares[i]
+		share.PayLinks = Links(bill.PaymentMethods, share.Outstanding, bill.Name)
+	}
+}
+
+// AttachSettlementLinks fills PayLinks on each current-round settlement that
+// still has something outstanding. The payee's methods are those on the tab's
+// bills; the first valid one per provider is used.
+func AttachSettlementLinks(tab *models.Tab, settlements []models.TabSettlement) {
+	var methods []models.PaymentMethod
+	for _, bill := range tab.Bills {
+		methods = append(methods, bill.PaymentMethods...)
+	}
+	note := tab.Name + " settlement"
+	for i := range settlements {
+		if settlements[i].SupersededAt != nil {
+			continue
+		}
+		settlements[i].PayLinks = Links(methods, settlements[i].Outstanding, note)
+	}
+}
@@ -1 +1,193 @@
 package payments
+
+import (
+	"backend/internal/audit"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PaymentRepository interface {
+	GetByID(id uint) (*models.Payment, error)
+	GetByPublicID(publicID string) (*models.Payment, error)
+	GetSettlements(tabID uint) ([]models.TabSettlement, error)
+	ListByTab(tabID uint) ([]models.Payment, error)
+	ListByBill(billID uint) ([]models.Payment, error)
+	Create(payment *models.Payment, actor *models.TabMember) error
+	Receive(payment *models.Payment, at time.Time, actor *models.TabMember) error
+	Dispute(payment *models.Payment, at time.Time, reason string, actor *models.TabMember) error
+	Void(payment *models.Payment, at time.Time, actor *models.TabMember) error
+}
+
+type paymentRepository struct {
+	db *gorm.DB
+}
+
+func (r *paymentRepository) GetByID(id uint) (*models.Payment, error) {
+	payment := &models.Payment{}
+	if err := r.db.First(payment, id).Error; err != nil {
+		return payment, err
+	}
+	return r.linked(payment)
+}
+
+func (r *paymentRepository) GetByPublicID(publicID string) (*models.Payment, error) {
+	payment := &models.Payment{}
+	if err := r.db.Where("public_id = ?", publicID).First(payment).Error; err != nil {
+		return payment, err
+	}
+	return r.linked(payment)
+}
+
+// GetSettlements returns the tab's current settlement round.
+func (r *paymentRepository) GetSettlements(tabID uint) ([]models.TabSettlement, error) {
+	var settlements []models.TabSettlement
+	err := r.db.Where("tab_id = ? AND superseded_at IS NULL", tabID).Order("amount DESC").Find(&settlements).Error
+	return settlements, err
+}
+
+func (r *paymentRepository) ListByTab(tabID uint) ([]models.Payment, error) {
+	var payments []models.Payment
+	if err := r.db.Where("tab_id = ?", tabID).Order("paid_at DESC, id DESC").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	return payments, linkTargets(r.db, payments)
+}
+
+func (r *paymentRepository) ListByBill(billID uint) ([]models.Payment, error) {
+	var payments []models.Payment
+	if err := r.db.Where("bill_id = ?", billID).Order("paid_at DESC, id DESC").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	return payments, linkTargets(r.db, payments)
+}
+
+func (r *paymentRepository) linked(payment *models.Payment) (*models.Payment, error) {
+	payments := []models.Payment{*payment}
+	err := linkTargets(r.db, payments)
+	return &payments[0], err
+}
+
+// linkTargets fills in the public IDs of the settlements and person shares
+// the payments were recorded against.
+func linkTargets(db *gorm.DB, payments []models.Payment) error {
+	settlements, shares := map[uint]string{}, map[uint]string{}
+	for _, p := range payments {
+		if p.SettlementID != nil {
+			settlements[*p.SettlementID] = ""
+		}
+		if p.PersonShareID != nil {
+			shares[*p.PersonShareID] = ""
+		}
+	}
+	if err := lookupPublicIDs(db, &models.TabSettlement{}, settlements); err != nil {
+		return err
+	}
+	if err := lookupPublicIDs(db, &models.PersonShare{}, shares); err != nil {
+		return err
+	}
+	for i := range payments {
+		p := &payments[i]
+		if p.SettlementID != nil {
+			p.SettlementRef = security.Ref(settlements[*p.SettlementID])
+		}
+		if p.PersonShareID != nil {
+			p.ShareRef = security.Ref(shares[*p.PersonShareID])
+		}
+	}
+	return nil
+}
+
+// lookupPublicIDs fills in the public ID of each of model's rows keyed in ids.
+func lookupPublicIDs(db *gorm.DB, model interface{}, ids map[uint]string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]uint, 0, len(ids))
+	for id := range ids {
+		keys = append(keys, id)
+	}
+	var rows []struct {
+		ID       uint
+		PublicID string
+	}
+	if err := db.Model(model).Select("id", "public_id").Where("id IN ?", keys).Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		ids[row.ID] = row.PublicID
+	}
+	return nil
+}
+
+func (r *paymentRepository) Create(payment *models.Payment, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := Record(tx, payment); err != nil {
+			return err
+		}
+		return recordActivity(tx, payment, actor, "payment.recorded")
+	})
+}
+
+func (r *paymentRepository) Receive(payment *models.Payment, at time.Time, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := Receive(tx, payment, at); err != nil {
+			return err
+		}
+		return recordActivity(tx, payment, actor, "payment.received")
+	})
+}
+
+func (r *paymentRepository) Dispute(payment *models.Payment, at time.Time, reason string, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := Dispute(tx, payment, at, reason); err != nil {
+			return err
+		}
+		return recordActivity(tx, payment, actor, "payment.disputed")
+	})
+}
+
+func (r *paymentRepository) Void(payment *models.Payment, at time.Time, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := Void(tx, payment, at); err != nil {
+			return err
+		}
+		return recordActivity(tx, payment, actor, "payment.voided")
+	})
+}
+
+// recordActivity records action on the payment in its tab's activity. Voided
+// payments are recorded as they were; other changes as they now are. Payments
+// on bills outside a tab have no activity.
+func recordActivity(tx *gorm.DB, payment *models.Payment, actor *models.TabMember, action string) error {
+	tabID := payment.TabID
+	if tabID == nil {
+		bill := &models.Bill{}
+		if err := tx.Select("id", "tab_id").First(bill, *payment.BillID).Error; err != nil {
+			return err
+		}
+		if bill.TabID == nil {
+			return nil
+		}
+		tabID = bill.TabID
+	}
+	entry := audit.Entry{TabID: *tabID, Actor: actor, Action: action, Target: "payment:" + payment.PublicID}
+	if payment.VoidedAt != nil {
+		entry.Before = auditFields(payment)
+	} else {
+		entry.After = auditFields(payment)
+	}
+	return audit.Record(tx, entry)
+}
+
+// auditFields is the audited view of a payment.
+func auditFields(p *models.Payment) gin.H {
+	return gin.H{"payer": p.Payer, "payee": p.Payee, "amount": p.Amount, "method": p.Method, "status": p.Status}
+}
+
+func NewPaymentRepository(db *gorm.DB) PaymentRepository {
+	return &paymentRepository{db: db}
+}
@@ -1 +1,402 @@
 package payments
+
+import (
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"crypto/subtle"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Payment methods. MethodOther is used when none is given, including for
+// payments recorded by the plain paid toggle.
+const (
+	MethodVenmo    = "venmo"
+	MethodCashApp  = "cashapp"
+	MethodPayPal   = "paypal"
+	MethodZelle    = "zelle"
+	MethodApplePay = "apple_pay"
+	MethodCash     = "cash"
+	MethodOther    = "other"
+)
+
+var methods = map[string]bool{
+	MethodVenmo: true, MethodCashApp: true, MethodPayPal: true, MethodZelle: true,
+	MethodApplePay: true, MethodCash: true, MethodOther: true,
+}
+
+const (
+	maxNoteLength        = 280
+	maxExternalRefLength = 128
+	// Clocks on phones drift; allow paid_at slightly in the future.
+	maxClockSkew = 5 * time.Minute
+)
+
+var (
+	ErrInvalidAmount      = errors.New("amount must be greater than zero")
+	ErrOverpayment        = errors.New("amount exceeds what is still owed")
+	ErrInvalidMethod      = errors.New("unknown payment method")
+	ErrInvalidPaidAt      = errors.New("paid_at cannot be in the future")
+	ErrFieldTooLong       = errors.New("note or external_ref is too long")
+	ErrAlreadyVoided      = errors.New("payment is already voided")
+	ErrShareNotFound      = errors.New("share not found on this bill")
+	ErrSettlementNotFound = errors.New("settlement not found")
+	ErrInvalidStatus      = errors.New("status must be sent, received, disputed or unpaid")
+	ErrNotPending         = errors.New("no payment is awaiting confirmation")
+	ErrNotPayer           = errors.New("only the payer or the payee can do this")
+	ErrNotPayee           = errors.New("only the payee can confirm or dispute payments")
+)
+
+// Caller is who is acting on a settlement or share, as established from the
+// request's tokens.
+type Caller struct {
+	// MemberID is the caller's member on the tab, if they presented one.
+	MemberID *uint
+	// Actor is that member, whom the change is attributed to in the tab's
+	// activity. Nil for anonymous token holders and bill links.
+	Actor *models.TabMember
+	// Payee is set for whoever is owed: the tab creator, or the holder of a
+	// standalone bill's creator token. Tabs without members and bills created
+	// before creator tokens existed treat every token holder as the payee.
+	Payee bool
+}
+
+// TabCaller is the caller on a tab, given the member behind the request's
+// member token (nil if none).
+func TabCaller(tab *models.Tab, member *models.TabMember) Caller {
+	caller := Caller{Payee: len(tab.Members) == 0}
+	if member != nil && member.TabID == tab.ID {
+		caller.MemberID = &member.ID
+		caller.Actor = member
+		caller.Payee = caller.Payee || member.Role == "creator"
+	}
+	return caller
+}
+
+// BillCaller is the caller on a bill, given the request's creator token.
+// Bill links carry no member identity, so its changes are attributed to the
+// anonymous token holder.
+func BillCaller(bill *models.Bill, creatorToken string) Caller {
+	return Caller{
+		Payee: bill.CreatorToken == "" ||
+			subtle.ConstantTimeCompare([]byte(creatorToken), []byte(bill.CreatorToken)) == 1,
+	}
+}
+
+// IsPayer reports whether the caller may act for the payer. Settlements and
+// shares not linked to a member can be marked by any token holder.
+func (c Caller) IsPayer(payerMemberID *uint) bool {
+	if c.Payee || payerMemberID == nil {
+		return true
+	}
+	return c.MemberID != nil && *c.MemberID == *payerMemberID
+}
+
+// IsPayee reports whether the caller may confirm or dispute payments made to
+// payeeMemberID.
+func (c Caller) IsPayee(payeeMemberID *uint) bool {
+	if c.Payee {
+		return true
+	}
+	return c.MemberID != nil && payeeMemberID != nil && *c.MemberID == *payeeMemberID
+}
+
+// ActionFor maps a requested status for a settlement or share onto the action
+// that gets it there, if the caller is allowed to take it. The payer marks
+// sent; the payee marks received or disputed. Unpaid voids everything when
+// the payee asks and retracts unconfirmed payments when the payer does.
+func ActionFor(c Caller, status string, payerMemberID *uint) (Action, error) {
+	switch status {
+	case StatusSent:
+		if !c.IsPayer(payerMemberID) {
+			return "", ErrNotPayer
+		}
+		return ActionSend, nil
+	case StatusReceived, StatusDisputed:
+		if !c.Payee {
+			return "", ErrNotPayee
+		}
+		if status == StatusReceived {
+			return ActionReceive, nil
+		}
+		return ActionDispute, nil
+	case StatusUnpaid:
+		if c.Payee {
+			return ActionVoid, nil
+		}
+		if !c.IsPayer(payerMemberID) {
+			return "", ErrNotPayer
+		}
+		return ActionRetract, nil
+	}
+	return "", ErrInvalidStatus
+}
+
+// LegacyStatus maps the plain paid toggle onto a status: paid means received
+// when the payee sets it and sent when anyone else does.
+func LegacyStatus(c Caller, paid bool) string {
+	switch {
+	case !paid:
+		return StatusUnpaid
+	case c.Payee:
+		return StatusReceived
+	default:
+		return StatusSent
+	}
+}
+
+// Scope limits payment lookups by reference to one tab or bill.
+type Scope struct {
+	TabID  uint
+	BillID uint
+}
+
+func (s Scope) contains(p *models.Payment) bool {
+	if s.TabID != 0 {
+		return p.TabID != nil && *p.TabID == s.TabID
+	}
+	return p.BillID != nil && *p.BillID == s.BillID
+}
+
+// Input is a payment as reported by a client. Payer and what is being paid
+// come from the settlement or share it is recorded against.
+type Input struct {
+	Amount      float64
+	Method      string
+	Payee       string
+	Note        string
+	ExternalRef string
+	PaidAt      *time.Time
+}
+
+type PaymentService interface {
+	RecordForSettlement(tab *models.Tab, settlementRef string, in Input, caller Caller) (*models.Payment, error)
+	RecordForShare(bill *models.Bill, shareRef string, in Input, caller Caller) (*models.Payment, error)
+	ListForTab(tabID uint) ([]models.Payment, error)
+	ListForBill(billID uint) ([]models.Payment, error)
+	Confirm(scope Scope, ref string, caller Caller) (*models.Payment, error)
+	Dispute(scope Scope, ref string, reason string, caller Caller) (*models.Payment, error)
+	Void(scope Scope, ref string, caller Caller) (*models.Payment, error)
+}
+
+type paymentService struct {
+	repo PaymentRepository
+	now  func() time.Time
+}
+
+// RecordForSettlement records a (possibly partial) payment by the person a
+// current-round settlement belongs to. The payee defaults to the tab creator.
+// Payments the payee records are received; payments the payer records are
+// sent until the payee confirms them.
+func (s *paymentService) RecordForSettlement(tab *models.Tab, settlementRef string, in Input, caller Caller) (*models.Payment, error) {
+	settlements, err := s.repo.GetSettlements(tab.ID)
+	if err != nil {
+		return nil, err
+	}
+	var settlement *models.TabSettlement
+	for i := range settlements {
+		if security.MatchesRef(settlementRef, settlements[i].ID, settlements[i].PublicID) {
+			settlement = &settlements[i]
+			break
+		}
+	}
+	if settlement == nil {
+		return nil, ErrSettlementNotFound
+	}
+
+	payment, err := s.newPayment(in, caller, settlement.MemberID)
+	if err != nil {
+		return nil, err
+	}
+	payment.TabID = &tab.ID
+	payment.PayerKey = models.PayerKey(settlement.MemberID, settlement.PersonName)
+	payment.SettlementID = &settlement.ID
+	payment.SettlementRef = security.Ref(settlement.PublicID)
+	payment.PayerMemberID = settlement.MemberID
+	payment.Payer = settlement.PersonName
+	for _, m := range tab.Members {
+		if m.Role == "creator" {
+			payment.PayeeMemberID = &m.ID
+			if payment.Payee == "" {
+				payment.Payee = m.DisplayName
+			}
+			break
+		}
+	}
+
+	if err := s.repo.Create(payment, caller.Actor); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// RecordForShare records a (possibly partial) payment of a bill's person
+// share, received or sent as for RecordForSettlement.
+func (s *paymentService) RecordForShare(bill *models.Bill, shareRef string, in Input, caller Caller) (*models.Payment, error) {
+	var share *models.PersonShare
+	for i := range bill.PersonShares {
+		if security.MatchesRef(shareRef, bill.PersonShares[i].ID, bill.PersonShares[i].PublicID) {
+			share = &bill.PersonShares[i]
+			break
+		}
+	}
+	if share == nil {
+		return nil, ErrShareNotFound
+	}
+
+	payment, err := s.newPayment(in, caller, share.MemberID)
+	if err != nil {
+		return nil, err
+	}
+	payment.BillID = &bill.ID
+	payment.PersonShareID = &share.ID
+	payment.ShareRef = security.Ref(share.PublicID)
+	payment.PayerMemberID = share.MemberID
+	payment.Payer = share.PersonName
+
+	if err := s.repo.Create(payment, caller.Actor); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+func (s *paymentService) ListForTab(tabID uint) ([]models.Payment, error) {
+	return s.repo.ListByTab(tabID)
+}
+
+func (s *paymentService) ListForBill(billID uint) ([]models.Payment, error) {
+	return s.repo.ListByBill(billID)
+}
+
+// Confirm marks a sent or disputed payment as received. Only the payee can.
+func (s *paymentService) Confirm(scope Scope, ref string, caller Caller) (*models.Payment, error) {
+	payment, err := s.find(scope, ref)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.IsPayee(payment.PayeeMemberID) {
+		return nil, ErrNotPayee
+	}
+	if payment.VoidedAt != nil || (payment.Status != StatusSent && payment.Status != StatusDisputed) {
+		return nil, ErrNotPending
+	}
+	return payment, s.repo.Receive(payment, s.now(), caller.Actor)
+}
+
+// Dispute marks a sent payment as not received. Only the payee can.
+func (s *paymentService) Dispute(scope Scope, ref string, reason string, caller Caller) (*models.Payment, error) {
+	if len(reason) > maxNoteLength {
+		return nil, ErrFieldTooLong
+	}
+	payment, err := s.find(scope, ref)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.IsPayee(payment.PayeeMemberID) {
+		return nil, ErrNotPayee
+	}
+	if payment.VoidedAt != nil || payment.Status != StatusSent {
+		return nil, ErrNotPending
+	}
+	return payment, s.repo.Dispute(payment, s.now(), reason, caller.Actor)
+}
+
+// Void voids a payment. The payee can void any payment; the payer only ones
+// the payee has not confirmed.
+func (s *paymentService) Void(scope Scope, ref string, caller Caller) (*models.Payment, error) {
+	payment, err := s.find(scope, ref)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.IsPayee(payment.PayeeMemberID) &&
+		(payment.Status == StatusReceived || !caller.IsPayer(payment.PayerMemberID)) {
+		return nil, ErrNotPayer
+	}
+	if payment.VoidedAt != nil {
+		return nil, ErrAlreadyVoided
+	}
+	return payment, s.repo.Void(payment, s.now(), caller.Actor)
+}
+
+// find looks up a payment by reference within scope.
+func (s *paymentService) find(scope Scope, ref string) (*models.Payment, error) {
+	payment, err := s.get(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !scope.contains(payment) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return payment, nil
+}
+
+func (s *paymentService) get(ref string) (*models.Payment, error) {
+	id, publicID, err := security.ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if publicID != "" {
+		return s.repo.GetByPublicID(publicID)
+	}
+	return s.repo.GetByID(id)
+}
+
+// newPayment validates client input into a payment with no target yet. The
+// payee's payments are received; the payer's are sent.
+func (s *paymentService) newPayment(in Input, caller Caller, payerMemberID *uint) (*models.Payment, error) {
+	status := StatusReceived
+	if !caller.Payee {
+		if !caller.IsPayer(payerMemberID) {
+			return nil, ErrNotPayer
+		}
+		status = StatusSent
+	}
+
+	amount := math.Round(in.Amount*100) / 100
+	if amount <= 0 || math.IsNaN(in.Amount) || math.IsInf(in.Amount, 0) {
+		return nil, ErrInvalidAmount
+	}
+
+	method := strings.ToLower(strings.TrimSpace(in.Method))
+	if method == "" {
+		method = MethodOther
+	}
+	if !methods[method] {
+		return nil, ErrInvalidMethod
+	}
+
+	if len(in.Note) > maxNoteLength || len(in.ExternalRef) > maxExternalRefLength {
+		return nil, ErrFieldTooLong
+	}
+
+	now := s.now()
+	paidAt := now
+	if in.PaidAt != nil {
+		if in.PaidAt.After(now.Add(maxClockSkew)) {
+			return nil, ErrInvalidPaidAt
+		}
+		paidAt = *in.PaidAt
+	}
+
+	payment := &models.Payment{
+		Amount:      amount,
+		Method:      method,
+		Payee:       in.Payee,
+		Note:        in.Note,
+		ExternalRef: in.ExternalRef,
+		Status:      status,
+		PaidAt:      paidAt,
+	}
+	if status == StatusReceived {
+		payment.ReceivedAt = &now
+	}
+	return payment, nil
+}
+
+func NewPaymentService(repo PaymentRepository) PaymentService {
+	return &paymentService{repo: repo, now: time.Now}
+}
@@ -0,0 +1,516 @@
+package payments
+
+import (
+	"backend/pkg/models"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ── Mock PaymentRepository ──────────────────────────────────────
+
+type mockPaymentRepository struct {
+	payments    []models.Payment
+	settlements []models.TabSettlement
+	createErr   error
+	received    []uint
+	disputed    []uint
+	voided      []uint
+}
+
+func (m *mockPaymentRepository) GetByID(id uint) (*models.Payment, error) {
+	for i := range m.payments {
+		if m.payments[i].ID == id {
+			return &m.payments[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockPaymentRepository) GetByPublicID(publicID string) (*models.Payment, error) {
+	for i := range m.payments {
+		if m.payments[i].PublicID == publicID {
+			return &m.payments[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockPaymentRepository) GetSettlements(tabID uint) ([]models.TabSettlement, error) {
+	var result []models.TabSettlement
+	for _, s := range m.settlements {
+		if s.TabID == tabID {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockPaymentRepository) ListByTab(tabID uint) ([]models.Payment, error) {
+	return m.payments, nil
+}
+
+func (m *mockPaymentRepository) ListByBill(billID uint) ([]models.Payment, error) {
+	return m.payments, nil
+}
+
+func (m *mockPaymentRepository) Create(payment *models.Payment, actor *models.TabMember) error {
+	if m.createErr != nil {
+		return m.createErr
+	}
+	payment.ID = uint(len(m.payments) + 1)
+	m.payments = append(m.payments, *payment)
+	return nil
+}
+
+func (m *mockPaymentRepository) Receive(payment *models.Payment, at time.Time, actor *models.TabMember) error {
+	m.received = append(m.received, payment.ID)
+	return nil
+}
+
+func (m *mockPaymentRepository) Dispute(payment *models.Payment, at time.Time, reason string, actor *models.TabMember) error {
+	m.disputed = append(m.disputed, payment.ID)
+	return nil
+}
+
+func (m *mockPaymentRepository) Void(payment *models.Payment, at time.Time, actor *models.TabMember) error {
+	m.voided = append(m.voided, payment.ID)
+	return nil
+}
+
+var fixedNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+var payee = Caller{Payee: true}
+
+func memberCaller(id uint) Caller {
+	return Caller{MemberID: &id}
+}
+
+func newTestService(repo *mockPaymentRepository) *paymentService {
+	return &paymentService{repo: repo, now: func() time.Time { return fixedNow }}
+}
+
+// ── Tests ───────────────────────────────────────────────────────
+
+func TestNewPayment_Validation(t *testing.T) {
+	svc := newTestService(&mockPaymentRepository{})
+	future := fixedNow.Add(time.Hour)
+	skewed := fixedNow.Add(time.Minute)
+
+	tests := []struct {
+		name string
+		in   Input
+		want error
+	}{
+		{"zero amount", Input{Amount: 0}, ErrInvalidAmount},
+		{"negative amount", Input{Amount: -5}, ErrInvalidAmount},
+		{"rounds to zero", Input{Amount: 0.004}, ErrInvalidAmount},
+		{"unknown method", Input{Amount: 10, Method: "bitcoin"}, ErrInvalidMethod},
+		{"future paid_at", Input{Amount: 10, PaidAt: &future}, ErrInvalidPaidAt},
+		{"long note", Input{Amount: 10, Note: strings.Repeat("x", maxNoteLength+1)}, ErrFieldTooLong},
+		{"clock skew allowed", Input{Amount: 10, PaidAt: &skewed}, nil},
+		{"valid", Input{Amount: 10, Method: " Venmo "}, nil},
+	}
+	for _, tt := range tests {
+		_, err := svc.newPayment(tt.in, payee, nil)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	payment, _ := svc.newPayment(Input{Amount: 12.345}, payee, nil)
+	if payment.Amount != 12.35 || payment.Method != MethodOther || !payment.PaidAt.Equal(fixedNow) {
+		t.Errorf("expected 12.35 via other at now, got %.2f via %s at %v", payment.Amount, payment.Method, payment.PaidAt)
+	}
+}
+
+func TestRecordForSettlement_DerivesPayerAndPayee(t *testing.T) {
+	memberID := uint(4)
+	repo := &mockPaymentRepository{settlements: []models.TabSettlement{
+		{ID: 10, PublicID: "settlementpublicid0001", TabID: 1, MemberID: &memberID, PersonName: "Bob", Amount: 40},
+		{ID: 11, PublicID: "settlementpublicid0002", TabID: 1, PersonName: "Carol", Amount: 25},
+	}}
+	svc := newTestService(repo)
+	tab := &models.Tab{ID: 1, Members: []models.TabMember{
+		{ID: 3, DisplayName: "Alice", Role: "creator"},
+		{ID: 4, DisplayName: "Bob", Role: "member"},
+	}}
+
+	payment, err := svc.RecordForSettlement(tab, "settlementpublicid0001", Input{Amount: 15, Method: "venmo", Note: "half"}, memberCaller(4))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if payment.Payer != "Bob" || payment.Payee != "Alice" || payment.PayeeMemberID == nil || *payment.PayeeMemberID != 3 {
+		t.Errorf("expected Bob paying Alice, got %q paying %q", payment.Payer, payment.Payee)
+	}
+	if payment.Status != StatusSent || payment.ReceivedAt != nil {
+		t.Errorf("expected the payer's payment to await confirmation, got %s", payment.Status)
+	}
+	if payment.PayerKey != "member:4" || *payment.TabID != 1 || *payment.SettlementID != 10 || payment.SettlementRef != "settlementpublicid0001" {
+		t.Errorf("unexpected target: key=%s tab=%v settlement=%v %q", payment.PayerKey, payment.TabID, payment.SettlementID, payment.SettlementRef)
+	}
+
+	payment, err = svc.RecordForSettlement(tab, "11", Input{Amount: 5, Payee: "Dave"}, memberCaller(3))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if payment.PayerKey != "name:carol" || payment.Payee != "Dave" {
+		t.Errorf("expected name key and explicit payee, got %s / %s", payment.PayerKey, payment.Payee)
+	}
+
+	if _, err := svc.RecordForSettlement(tab, "10", Input{Amount: 5}, memberCaller(5)); !errors.Is(err, ErrNotPayer) {
+		t.Errorf("expected ErrNotPayer for another member, got %v", err)
+	}
+	if _, err := svc.RecordForSettlement(&models.Tab{ID: 2}, "10", Input{Amount: 5}, payee); !errors.Is(err, ErrSettlementNotFound) {
+		t.Errorf("expected ErrSettlementNotFound for another tab's settlement, got %v", err)
+	}
+}
+
+func TestRecordForSettlement_PropagatesOverpayment(t *testing.T) {
+	repo := &mockPaymentRepository{
+		settlements: []models.TabSettlement{{ID: 10, TabID: 1, PersonName: "Bob", Amount: 40}},
+		createErr:   ErrOverpayment,
+	}
+	svc := newTestService(repo)
+
+	_, err := svc.RecordForSettlement(&models.Tab{ID: 1}, "10", Input{Amount: 50}, payee)
+	if !errors.Is(err, ErrOverpayment) {
+		t.Errorf("expected ErrOverpayment, got %v", err)
+	}
+}
+
+func TestRecordForShare(t *testing.T) {
+	repo := &mockPaymentRepository{}
+	svc := newTestService(repo)
+	bill := &models.Bill{ID: 7, PersonShares: []models.PersonShare{{ID: 70, PublicID: "Sh3Vb8LmT2xYp9Rw5NcZa1", BillID: 7, PersonName: "Bob", Total: 20}}}
+
+	payment, err := svc.RecordForShare(bill, "Sh3Vb8LmT2xYp9Rw5NcZa1", Input{Amount: 20, Method: "cash"}, payee)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if *payment.BillID != 7 || *payment.PersonShareID != 70 || payment.ShareRef != "Sh3Vb8LmT2xYp9Rw5NcZa1" || payment.Payer != "Bob" || payment.TabID != nil {
+		t.Errorf("unexpected payment target: %+v", payment)
+	}
+	if payment.Status != StatusReceived || payment.ReceivedAt == nil {
+		t.Errorf("expected the creator's payment to be received, got %s", payment.Status)
+	}
+
+	bill.CreatorToken = "creator-secret"
+	// Legacy numeric share IDs still resolve
+	payment, err = svc.RecordForShare(bill, "70", Input{Amount: 5}, BillCaller(bill, ""))
+	if err != nil || payment.Status != StatusSent {
+		t.Errorf("expected a sent payment without the creator token, got %v / %v", payment, err)
+	}
+
+	if _, err := svc.RecordForShare(bill, "71", Input{Amount: 20}, payee); !errors.Is(err, ErrShareNotFound) {
+		t.Errorf("expected ErrShareNotFound, got %v", err)
+	}
+}
+
+func TestVoid_ScopedAndOnce(t *testing.T) {
+	tabID, billID := uint(1), uint(7)
+	voidedAt := fixedNow
+	repo := &mockPaymentRepository{payments: []models.Payment{
+		{ID: 1, PublicID: "paymentpublicid0000001", TabID: &tabID},
+		{ID: 2, BillID: &billID},
+		{ID: 3, TabID: &tabID, VoidedAt: &voidedAt},
+	}}
+	svc := newTestService(repo)
+
+	if _, err := svc.Void(Scope{TabID: 2}, "paymentpublicid0000001", payee); err != gorm.ErrRecordNotFound {
+		t.Errorf("expected not found for another tab, got %v", err)
+	}
+	if _, err := svc.Void(Scope{TabID: 1}, "2", payee); err != gorm.ErrRecordNotFound {
+		t.Errorf("expected not found for a bill payment, got %v", err)
+	}
+	if _, err := svc.Void(Scope{TabID: 1}, "3", payee); !errors.Is(err, ErrAlreadyVoided) {
+		t.Errorf("expected ErrAlreadyVoided, got %v", err)
+	}
+	if _, err := svc.Void(Scope{TabID: 1}, "paymentpublicid0000001", payee); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if _, err := svc.Void(Scope{BillID: 7}, "2", payee); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if len(repo.voided) != 2 || repo.voided[0] != 1 || repo.voided[1] != 2 {
+		t.Errorf("expected payments 1 and 2 voided, got %v", repo.voided)
+	}
+}
+
+func TestVoid_PayerOnlyBeforeConfirmation(t *testing.T) {
+	tabID, bob, alice := uint(1), uint(4), uint(3)
+	repo := &mockPaymentRepository{payments: []models.Payment{
+		{ID: 1, TabID: &tabID, PayerMemberID: &bob, PayeeMemberID: &alice, Status: StatusSent},
+		{ID: 2, TabID: &tabID, PayerMemberID: &bob, PayeeMemberID: &alice, Status: StatusReceived},
+	}}
+	svc := newTestService(repo)
+	scope := Scope{TabID: 1}
+
+	if _, err := svc.Void(scope, "1", memberCaller(5)); !errors.Is(err, ErrNotPayer) {
+		t.Errorf("expected ErrNotPayer for another member, got %v", err)
+	}
+	if _, err := svc.Void(scope, "2", memberCaller(bob)); !errors.Is(err, ErrNotPayer) {
+		t.Errorf("expected ErrNotPayer once received, got %v", err)
+	}
+	if _, err := svc.Void(scope, "1", memberCaller(bob)); err != nil {
+		t.Errorf("expected the payer to retract a sent payment, got %v", err)
+	}
+	if _, err := svc.Void(scope, "2", memberCaller(alice)); err != nil {
+		t.Errorf("expected the payee to void a received payment, got %v", err)
+	}
+}
+
+func TestConfirmAndDispute_PayeeOnly(t *testing.T) {
+	tabID, bob, alice := uint(1), uint(4), uint(3)
+	repo := &mockPaymentRepository{payments: []models.Payment{
+		{ID: 1, TabID: &tabID, PayerMemberID: &bob, PayeeMemberID: &alice, Status: StatusSent},
+		{ID: 2, TabID: &tabID, PayerMemberID: &bob, PayeeMemberID: &alice, Status: StatusReceived},
+		{ID: 3, TabID: &tabID, PayerMemberID: &bob, PayeeMemberID: &alice, Status: StatusDisputed},
+	}}
+	svc := newTestService(repo)
+	scope := Scope{TabID: 1}
+
+	if _, err := svc.Confirm(scope, "1", memberCaller(bob)); !errors.Is(err, ErrNotPayee) {
+		t.Errorf("expected the payer not to confirm their own payment, got %v", err)
+	}
+	if _, err := svc.Dispute(scope, "1", "", memberCaller(bob)); !errors.Is(err, ErrNotPayee) {
+		t.Errorf("expected the payer not to dispute, got %v", err)
+	}
+	if _, err := svc.Confirm(scope, "2", memberCaller(alice)); !errors.Is(err, ErrNotPending) {
+		t.Errorf("expected ErrNotPending for a received payment, got %v", err)
+	}
+	if _, err := svc.Dispute(scope, "3", "", memberCaller(alice)); !errors.Is(err, ErrNotPending) {
+		t.Errorf("expected ErrNotPending for a disputed payment, got %v", err)
+	}
+	if _, err := svc.Dispute(scope, "1", strings.Repeat("x", maxNoteLength+1), memberCaller(alice)); !errors.Is(err, ErrFieldTooLong) {
+		t.Errorf("expected ErrFieldTooLong, got %v", err)
+	}
+	if _, err := svc.Dispute(scope, "1", "not in my account", memberCaller(alice)); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if _, err := svc.Confirm(scope, "3", memberCaller(alice)); err != nil {
+		t.Errorf("expected a disputed payment to be confirmable, got %v", err)
+	}
+	if len(repo.disputed) != 1 || repo.disputed[0] != 1 || len(repo.received) != 1 || repo.received[0] != 3 {
+		t.Errorf("expected 1 disputed and 3 received, got %v / %v", repo.disputed, repo.received)
+	}
+}
+
+func TestTabCaller(t *testing.T) {
+	tab := &models.Tab{ID: 1, Members: []models.TabMember{{ID: 3, TabID: 1, Role: "creator"}, {ID: 4, TabID: 1, Role: "member"}}}
+
+	if c := TabCaller(tab, &tab.Members[0]); !c.Payee {
+		t.Error("expected the creator to be the payee")
+	}
+	if c := TabCaller(tab, &tab.Members[1]); c.Payee || c.MemberID == nil || *c.MemberID != 4 {
+		t.Errorf("expected a plain member caller, got %+v", c)
+	}
+	if c := TabCaller(tab, &models.TabMember{ID: 9, TabID: 2, Role: "creator"}); c.Payee || c.MemberID != nil {
+		t.Errorf("expected another tab's creator to count for nothing, got %+v", c)
+	}
+	if c := TabCaller(&models.Tab{ID: 5}, nil); !c.Payee {
+		t.Error("expected any token holder to be the payee on a tab without members")
+	}
+}
+
+func TestActionFor(t *testing.T) {
+	bob := uint(4)
+	tests := []struct {
+		name   string
+		caller Caller
+		status string
+		payer  *uint
+		want   Action
+		err    error
+	}{
+		{"payer sends", memberCaller(bob), StatusSent, &bob, ActionSend, nil},
+		{"other member cannot send", memberCaller(5), StatusSent, &bob, "", ErrNotPayer},
+		{"anyone sends for unlinked payer", Caller{}, StatusSent, nil, ActionSend, nil},
+		{"payer cannot confirm", memberCaller(bob), StatusReceived, &bob, "", ErrNotPayee},
+		{"payee confirms", payee, StatusReceived, &bob, ActionReceive, nil},
+		{"payee disputes", payee, StatusDisputed, &bob, ActionDispute, nil},
+		{"payer cannot dispute", Caller{}, StatusDisputed, nil, "", ErrNotPayee},
+		{"payee voids", payee, StatusUnpaid, &bob, ActionVoid, nil},
+		{"payer retracts", memberCaller(bob), StatusUnpaid, &bob, ActionRetract, nil},
+		{"other member cannot retract", memberCaller(5), StatusUnpaid, &bob, "", ErrNotPayer},
+		{"unknown status", payee, "partial", &bob, "", ErrInvalidStatus},
+	}
+	for _, tt := range tests {
+		got, err := ActionFor(tt.caller, tt.status, tt.payer)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %q/%v, got %q/%v", tt.name, tt.want, tt.err, got, err)
+		}
+	}
+
+	paid, unpaid := true, false
+	if action, _ := (StatusRequest{Paid: &paid}).Action(memberCaller(bob), &bob); action != ActionSend {
+		t.Errorf("expected paid=true from the payer to mean sent, got %q", action)
+	}
+	if action, _ := (StatusRequest{Paid: &paid}).Action(payee, &bob); action != ActionReceive {
+		t.Errorf("expected paid=true from the payee to mean received, got %q", action)
+	}
+	if action, _ := (StatusRequest{Paid: &unpaid}).Action(payee, &bob); action != ActionVoid {
+		t.Errorf("expected paid=false from the payee to void, got %q", action)
+	}
+	if _, err := (StatusRequest{}).Action(payee, &bob); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("expected ErrInvalidStatus without status or paid, got %v", err)
+	}
+}
+
+func TestDerive(t *testing.T) {
+	earlier, later := fixedNow.Add(-time.Hour), fixedNow
+	tests := []struct {
+		name    string
+		totals  []ledgerTotal
+		status  string
+		paid    float64
+		pending float64
+	}{
+		{"nothing", nil, StatusUnpaid, 0, 0},
+		{"partly received", []ledgerTotal{{Status: StatusReceived, Amount: 15, ReceivedAt: &earlier}}, StatusPartial, 15, 0},
+		{"sent awaits payee", []ledgerTotal{{Status: StatusSent, Amount: 40, SentAt: &later}}, StatusSent, 0, 40},
+		{"disputed", []ledgerTotal{{Status: StatusDisputed, Amount: 40, DisputedAt: &later}}, StatusDisputed, 0, 0},
+		{"rest sent after partial", []ledgerTotal{
+			{Status: StatusReceived, Amount: 15},
+			{Status: StatusSent, Amount: 25},
+		}, StatusSent, 15, 25},
+		{"received in full", []ledgerTotal{
+			{Status: StatusReceived, Amount: 10.1, ReceivedAt: &earlier},
+			{Status: StatusReceived, Amount: 29.9, ReceivedAt: &later},
+		}, StatusReceived, 40, 0},
+	}
+	for _, tt := range tests {
+		state := derive(40, tt.totals)
+		if state.Status != tt.status || state.AmountPaid != tt.paid || state.AmountPending != tt.pending {
+			t.Errorf("%s: expected %s paid=%.2f pending=%.2f, got %s paid=%.2f pending=%.2f",
+				tt.name, tt.status, tt.paid, tt.pending, state.Status, state.AmountPaid, state.AmountPending)
+		}
+		if state.Paid != (tt.status == StatusReceived) {
+			t.Errorf("%s: expected paid=%v", tt.name, tt.status == StatusReceived)
+		}
+	}
+
+	state := derive(40, []ledgerTotal{{Status: StatusReceived, Amount: 40, ReceivedAt: &earlier}, {Status: StatusReceived, ReceivedAt: &later}})
+	if state.ReceivedAt == nil || !state.ReceivedAt.Equal(later) {
+		t.Errorf("expected received_at to be the latest confirmation, got %v", state.ReceivedAt)
+	}
+	if state := derive(40, []ledgerTotal{{Status: StatusDisputed, Amount: 40, DisputedAt: &later}, {Status: StatusSent, Amount: 40}}); state.DisputedAt != nil {
+		t.Errorf("expected disputed_at cleared once a new payment is sent, got %v", state.DisputedAt)
+	}
+}
+
+func TestIsSettled(t *testing.T) {
+	tests := []struct {
+		amount, paid float64
+		want         bool
+	}{
+		{40, 0, false},
+		{40, 39.99, false},
+		{40, 40, true},
+		{33.33, 33.33, true},
+		{0.1 + 0.2, 0.3, true},
+		{0, 0, true},
+	}
+	for _, tt := range tests {
+		if got := isSettled(tt.amount, roundCents(tt.paid)); got != tt.want {
+			t.Errorf("isSettled(%v, %v) = %v, want %v", tt.amount, tt.paid, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeMethod(t *testing.T) {
+	tests := []struct {
+		name, identifier string
+		want             string
+		err              error
+	}{
+		{"Venmo", "@alice-w", "@alice-w", nil},
+		{"venmo", "alice_w", "@alice_w", nil},
+		{"Venmo", "@bob", "", ErrInvalidVenmo},
+		{"Venmo", "@alice w", "", ErrInvalidVenmo},
+		{"Cash App", "$alice", "$alice", nil},
+		{"CashApp", "https://cash.app/$Alice99", "$Alice99", nil},
+		{"Cash App", "$12345", "", ErrInvalidCashtag},
+		{"Cash App", "$way_too_long_for_a_cashtag", "", ErrInvalidCashtag},
+		{"PayPal", "https://www.paypal.me/alicew/", "paypal.me/alicew", nil},
+		{"PayPal", "paypal.com/paypalme/alicew", "paypal.me/alicew", nil},
+		{"PayPal", "alicew", "paypal.me/alicew", nil},
+		{"PayPal", "paypal.me/alice.w", "", ErrInvalidPayPal},
+		{"Zelle", "Alice@Example.com", "alice@example.com", nil},
+		{"Zelle", "(555) 123-4567", "+15551234567", nil},
+		{"Zelle", "+1 555.123.4567", "+15551234567", nil},
+		{"Zelle", "555-1234", "", ErrInvalidZelle},
+		{"Zelle", "Alice <alice@example.com>", "", ErrInvalidZelle},
+		{"Apple Pay", "anything goes", "anything goes", nil},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeMethod(models.PaymentMethod{Name: tt.name, Identifier: tt.identifier})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s %q: expected error %v, got %v", tt.name, tt.identifier, tt.err, err)
+			continue
+		}
+		if err == nil && got.Identifier != tt.want {
+			t.Errorf("%s %q: expected %q, got %q", tt.name, tt.identifier, tt.want, got.Identifier)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	methods := []models.PaymentMethod{
+		{Name: "Venmo", Identifier: "@bad"},
+		{Name: "Venmo", Identifier: "@alice-w"},
+		{Name: "Cash App", Identifier: "alice"},
+		{Name: "PayPal", Identifier: "paypal.me/alicew"},
+		{Name: "Zelle", Identifier: "alice@example.com"},
+		{Name: "Apple Pay", Identifier: "555-1234"},
+	}
+
+	links := Links(methods, 12.5, "Dinner & drinks")
+	if len(links) != 4 {
+		t.Fatalf("expected 4 links (invalid venmo and apple pay skipped), got %+v", links)
+	}
+	want := map[string]string{
+		MethodVenmo:   "https://account.venmo.com/pay?txn=pay&recipients=alice-w&amount=12.50&note=Dinner%20%26%20drinks",
+		MethodCashApp: "https://cash.app/$alice/12.50",
+		MethodPayPal:  "https://paypal.me/alicew/12.50USD",
+		MethodZelle:   "",
+	}
+	for _, link := range links {
+		if link.URL != want[link.Provider] {
+			t.Errorf("%s: expected %q, got %q", link.Provider, want[link.Provider], link.URL)
+		}
+		if link.Amount != 12.5 {
+			t.Errorf("%s: expected amount 12.5, got %v", link.Provider, link.Amount)
+		}
+	}
+	if links[0].AppURL != "venmo://paycharge?txn=pay&recipients=alice-w&amount=12.50&note=Dinner%20%26%20drinks" {
+		t.Errorf("unexpected venmo app url %q", links[0].AppURL)
+	}
+
+	if links := Links(methods, 0, "paid up"); links != nil {
+		t.Errorf("expected no links when nothing is owed, got %+v", links)
+	}
+}
+
+func TestAttachSettlementLinks_SkipsPaidAndSuperseded(t *testing.T) {
+	superseded := fixedNow
+	tab := &models.Tab{Name: "Ski trip", Bills: []models.Bill{
+		{PaymentMethods: []models.PaymentMethod{{Name: "Cash App", Identifier: "$alice"}}},
+	}}
+	settlements := []models.TabSettlement{
+		{PersonName: "Bob", Amount: 40, Outstanding: 15},
+		{PersonName: "Carol", Amount: 25, PaymentState: models.PaymentState{AmountPaid: 25, Paid: true}},
+		{PersonName: "Dave", Amount: 30, Outstanding: 30, SupersededAt: &superseded},
+	}
+
+	AttachSettlementLinks(tab, settlements)
+	if len(settlements[0].PayLinks) != 1 || settlements[0].PayLinks[0].URL != "https://cash.app/$alice/15.00" {
+		t.Errorf("expected a link for Bob's outstanding 15.00, got %+v", settlements[0].PayLinks)
+	}
+	if settlements[1].PayLinks != nil || settlements[2].PayLinks != nil {
+		t.Error("expected no links for paid or superseded settlements")
+	}
+}
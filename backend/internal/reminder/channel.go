@@ -0,0 +1,158 @@
+package reminder
+
+import (
+	"backend/pkg/models"
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+	"time"
+)
+
+// Channel names, as used in a policy's channels.
+const (
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+)
+
+var channelNames = map[string]bool{ChannelWebhook: true, ChannelEmail: true}
+
+// Reminder is one settlement.reminder event, resolved for delivery.
+type Reminder struct {
+	Event      models.Event
+	Tab        *models.Tab
+	Settlement *models.TabSettlement // with PayLinks attached
+	Count      int
+	// Email is the person's reminder address from the policy, if any.
+	Email string
+}
+
+// Channel delivers reminders one way. Send is retried with the event when it
+// fails; once it succeeds, the channel is not sent the event again.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, r Reminder) error
+}
+
+// EventDeliverer queues an event to a tab's webhooks. Satisfied by
+// webhook.WebhookService.
+type EventDeliverer interface {
+	Deliver(ctx context.Context, event models.Event) error
+}
+
+type webhookChannel struct {
+	webhooks EventDeliverer
+}
+
+// NewWebhookChannel delivers reminders to the tab's webhooks whose event
+// filter matches settlement.reminder, through the regular signed delivery
+// queue.
+func NewWebhookChannel(webhooks EventDeliverer) Channel {
+	return &webhookChannel{webhooks: webhooks}
+}
+
+func (c *webhookChannel) Name() string { return ChannelWebhook }
+
+func (c *webhookChannel) Send(ctx context.Context, r Reminder) error {
+	return c.webhooks.Deliver(ctx, r.Event)
+}
+
+// SMTPConfig is where and as whom reminder emails are sent.
+type SMTPConfig struct {
+	Addr     string // host:port
+	From     string
+	Username string
+	Password string
+}
+
+// SMTPFromEnv reads SMTP_ADDR, SMTP_FROM, SMTP_USERNAME and SMTP_PASSWORD.
+// Returns nil when SMTP_ADDR is unset, leaving the email channel disabled.
+func SMTPFromEnv() *SMTPConfig {
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		return nil
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "reminders@billington.app"
+	}
+	return &SMTPConfig{
+		Addr:     addr,
+		From:     from,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+}
+
+// EmailQueue queues reminder emails for the EmailWorker. Satisfied by
+// ReminderRepository.
+type EmailQueue interface {
+	QueueEmail(email *models.ReminderEmail) error
+}
+
+type emailChannel struct {
+	config SMTPConfig
+	queue  EmailQueue
+	now    func() time.Time
+}
+
+// NewEmailChannel emails reminders to the recipients in the tab's policy.
+// Reminders for people without an address are skipped. Send only queues the
+// email: it runs inside the dispatcher's transaction, so the SMTP exchange is
+// left to an EmailWorker.
+func NewEmailChannel(config SMTPConfig, queue EmailQueue) Channel {
+	return &emailChannel{config: config, queue: queue, now: time.Now}
+}
+
+func (c *emailChannel) Name() string { return ChannelEmail }
+
+func (c *emailChannel) Send(ctx context.Context, r Reminder) error {
+	if r.Email == "" {
+		return nil
+	}
+	return c.queue.QueueEmail(&models.ReminderEmail{
+		EventID:       r.Event.ID,
+		TabID:         r.Tab.ID,
+		Recipient:     r.Email,
+		Message:       c.compose(r),
+		Status:        EmailPending,
+		NextAttemptAt: c.now(),
+	})
+}
+
+// compose builds the reminder email. Names come from users, so header values
+// are stripped of line breaks and encoded.
+func (c *emailChannel) compose(r Reminder) []byte {
+	tabName := headerSafe(r.Tab.Name)
+	subject := fmt.Sprintf("Reminder: you owe $%.2f for %s", r.Settlement.Outstanding, tabName)
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", r.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", c.now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <reminder-%d@billington.app>\r\n", r.Event.ID)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&msg, "Hi %s,\r\n\r\n", headerSafe(r.Settlement.PersonName))
+	fmt.Fprintf(&msg, "You still owe $%.2f for %s.\r\n", r.Settlement.Outstanding, tabName)
+	if len(r.Settlement.PayLinks) > 0 {
+		msg.WriteString("\r\nPay with:\r\n")
+		for _, link := range r.Settlement.PayLinks {
+			target := link.URL
+			if target == "" {
+				target = link.Identifier
+			}
+			fmt.Fprintf(&msg, "  %s: %s\r\n", link.Provider, target)
+		}
+	}
+	msg.WriteString("\r\nAlready paid? Mark it as sent in the tab so it can be confirmed.\r\n")
+	return msg.Bytes()
+}
+
+func headerSafe(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,87 @@
+package reminder
+
+import (
+	"backend/internal/tabauth"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TabAccess resolves tabs and members for authorization. Satisfied by tab.TabService.
+type TabAccess interface {
+	GetTabByRef(ref string) (*models.Tab, error)
+	GetMemberByToken(token string) (*models.TabMember, error)
+}
+
+type ReminderHandler struct {
+	service ReminderService
+	tabs    TabAccess
+}
+
+func NewReminderHandler(service ReminderService, tabs TabAccess) *ReminderHandler {
+	return &ReminderHandler{service: service, tabs: tabs}
+}
+
+// GetReminders handles GET /api/tabs/:id/reminders
+func (h *ReminderHandler) GetReminders(c *gin.Context) {
+	t, _ := tabauth.ValidateCreator(c, h.tabs, "manage reminders")
+	if t == nil {
+		return
+	}
+
+	policy, err := h.service.GetPolicy(t.ID)
+	if err != nil {
+		log.Printf("internal error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// UpdateReminders handles PUT /api/tabs/:id/reminders
+func (h *ReminderHandler) UpdateReminders(c *gin.Context) {
+	t, member := tabauth.ValidateCreator(c, h.tabs, "manage reminders")
+	if t == nil {
+		return
+	}
+
+	var body struct {
+		Active        *bool                      `json:"active"`
+		IntervalHours int                        `json:"interval_hours"`
+		MaxReminders  int                        `json:"max_reminders"`
+		Channels      []string                   `json:"channels"`
+		Recipients    []models.ReminderRecipient `json:"recipients"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"})
+		return
+	}
+	for i := range body.Recipients {
+		body.Recipients[i].PersonName = security.SanitizeString(body.Recipients[i].PersonName)
+	}
+
+	policy, err := h.service.UpdatePolicy(t.ID, Settings{
+		Active:        body.Active,
+		IntervalHours: body.IntervalHours,
+		MaxReminders:  body.MaxReminders,
+		Channels:      body.Channels,
+		Recipients:    body.Recipients,
+	}, member)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidInterval), errors.Is(err, ErrInvalidMaxReminders), errors.Is(err, ErrUnknownChannel),
+			errors.Is(err, ErrInvalidRecipient), errors.Is(err, ErrTooManyRecipients):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Printf("internal error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
@@ -0,0 +1,124 @@
+package reminder
+
+import (
+	"backend/pkg/models"
+	"context"
+	"log"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// Reminder email statuses
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+)
+
+const (
+	maxEmailAttempts = 8
+	baseEmailDelay   = time.Minute
+	maxEmailDelay    = 2 * time.Hour
+	emailLease       = 2 * time.Minute
+	emailsPerTick    = 50
+)
+
+// EmailWorker sends queued reminder emails and retries failures with
+// exponential backoff.
+type EmailWorker struct {
+	repo     ReminderRepository
+	config   SMTPConfig
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewEmailWorker(repo ReminderRepository, config SMTPConfig) *EmailWorker {
+	return &EmailWorker{repo: repo, config: config, interval: 5 * time.Second, now: time.Now}
+}
+
+// Run sends due emails until ctx is cancelled.
+func (w *EmailWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.SendDue(ctx); err != nil {
+			log.Printf("reminder email: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue attempts every due email once and returns how many went out.
+func (w *EmailWorker) SendDue(ctx context.Context) (int, error) {
+	emails, err := w.repo.ClaimDueEmails(w.now(), emailLease, emailsPerTick)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for i := range emails {
+		if ctx.Err() != nil {
+			break
+		}
+		e := &emails[i]
+		w.attempt(e)
+		if err := w.repo.UpdateEmail(e); err != nil {
+			log.Printf("reminder email %d: %v", e.ID, err)
+			continue
+		}
+		if e.Status == EmailSent {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// attempt sends the email once and updates its status in place.
+func (w *EmailWorker) attempt(e *models.ReminderEmail) {
+	e.Attempts++
+	err := w.sendSMTP(e.Recipient, e.Message)
+	now := w.now()
+	if err == nil {
+		e.Status = EmailSent
+		e.LastError = ""
+		e.SentAt = &now
+		return
+	}
+
+	e.LastError = err.Error()
+	if e.Attempts >= maxEmailAttempts {
+		log.Printf("reminder email %d failed after %d attempts, giving up: %v", e.ID, e.Attempts, err)
+		e.Status = EmailFailed
+		return
+	}
+	e.NextAttemptAt = now.Add(emailDelay(e.Attempts))
+}
+
+func (w *EmailWorker) sendSMTP(to string, msg []byte) error {
+	var auth smtp.Auth
+	if w.config.Username != "" {
+		host, _, err := net.SplitHostPort(w.config.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", w.config.Username, w.config.Password, host)
+	}
+	return smtp.SendMail(w.config.Addr, auth, w.config.From, []string{to}, msg)
+}
+
+// emailDelay doubles from baseEmailDelay with each attempt, capped at
+// maxEmailDelay.
+func emailDelay(attempts int) time.Duration {
+	delay := baseEmailDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxEmailDelay {
+			return maxEmailDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,201 @@
+package reminder
+
+import (
+	"backend/internal/audit"
+	"backend/internal/events"
+	"backend/internal/payments"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReminderRepository interface {
+	GetPolicy(tabID uint) (*models.ReminderPolicy, error)
+	SavePolicy(policy *models.ReminderPolicy, actor *models.TabMember) error
+	GetSettlement(tabID uint, ref string) (*models.TabSettlement, error)
+	GetTab(id uint) (*models.Tab, error)
+	EmitDue(now time.Time, limit int) (int, error)
+	QueueEmail(email *models.ReminderEmail) error
+	ClaimDueEmails(now time.Time, lease time.Duration, limit int) ([]models.ReminderEmail, error)
+	UpdateEmail(email *models.ReminderEmail) error
+	SentChannels(eventID uint) ([]string, error)
+	MarkSent(eventID uint, channel string) error
+}
+
+type reminderRepository struct {
+	db *gorm.DB
+}
+
+func (r *reminderRepository) GetPolicy(tabID uint) (*models.ReminderPolicy, error) {
+	policy := &models.ReminderPolicy{}
+	err := r.db.Where("tab_id = ?", tabID).First(policy).Error
+	return policy, err
+}
+
+// SavePolicy creates the tab's policy or replaces the existing one, recording
+// the change in the tab's activity. A tab without a policy had the defaults.
+func (r *reminderRepository) SavePolicy(policy *models.ReminderPolicy, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing []models.ReminderPolicy
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("tab_id = ?", policy.TabID).Limit(1).Find(&existing).Error
+		if err != nil {
+			return err
+		}
+		before := defaultPolicy(policy.TabID)
+		if len(existing) > 0 {
+			before = &existing[0]
+		}
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tab_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"active", "interval_hours", "max_reminders", "channels", "recipients", "updated_at"}),
+		}).Create(policy).Error
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  policy.TabID,
+			Actor:  actor,
+			Action: "reminders.updated",
+			Before: auditFields(before),
+			After:  auditFields(policy),
+		})
+	})
+}
+
+// auditFields is the audited view of a policy. Recipient addresses are
+// personal data, so only their count is recorded.
+func auditFields(p *models.ReminderPolicy) gin.H {
+	return gin.H{
+		"active":         p.Active,
+		"interval_hours": p.IntervalHours,
+		"max_reminders":  p.MaxReminders,
+		"channels":       p.Channels,
+		"recipients":     len(p.Recipients),
+	}
+}
+
+// GetSettlement finds the tab's settlement by public ID, or by numeric ID for
+// reminders logged before events carried public IDs.
+func (r *reminderRepository) GetSettlement(tabID uint, ref string) (*models.TabSettlement, error) {
+	id, publicID, err := security.ParseRef(ref)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	settlement := &models.TabSettlement{}
+	query := r.db.Where("tab_id = ?", tabID)
+	if publicID != "" {
+		query = query.Where("public_id = ?", publicID)
+	} else {
+		query = query.Where("id = ?", id)
+	}
+	err = query.First(settlement).Error
+	return settlement, err
+}
+
+// GetTab loads the tab with its members and its bills' payment methods, for
+// composing reminders.
+func (r *reminderRepository) GetTab(id uint) (*models.Tab, error) {
+	tab := &models.Tab{}
+	err := r.db.Preload("Bills").Preload("Members").First(tab, id).Error
+	return tab, err
+}
+
+// EmitDue records a settlement.reminder event for up to limit settlements
+// whose next reminder is due, and counts it against them. Reminders leave the
+// tab's version alone, so they don't fail clients' If-Match writes. A settlement is due
+// when its tab is finalized (or recurring) and has an active policy, it is unpaid and not
+// waiting on the payee, it has had fewer than the policy's maximum reminders,
+// and the interval has passed since it was created or last reminded.
+func (r *reminderRepository) EmitDue(now time.Time, limit int) (int, error) {
+	var settlements []models.TabSettlement
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "tab_settlements"}, Options: "SKIP LOCKED"}).
+			Joins("JOIN reminder_policies ON reminder_policies.tab_id = tab_settlements.tab_id AND reminder_policies.active").
+			Joins("JOIN tabs ON tabs.id = tab_settlements.tab_id AND (tabs.finalized OR tabs.recurring)").
+			Where("tab_settlements.superseded_at IS NULL AND NOT tab_settlements.paid AND tab_settlements.status <> ?", payments.StatusSent).
+			Where("tab_settlements.reminders_sent < reminder_policies.max_reminders").
+			Where("COALESCE(tab_settlements.last_reminded_at, tab_settlements.created_at) + make_interval(hours => reminder_policies.interval_hours) <= ?", now).
+			Order("tab_settlements.id").Limit(limit).
+			Find(&settlements).Error
+		if err != nil {
+			return err
+		}
+		for _, s := range settlements {
+			sent := s.RemindersSent + 1
+			err := tx.Model(&models.TabSettlement{}).Where("id = ?", s.ID).Updates(map[string]interface{}{
+				"reminders_sent":   sent,
+				"last_reminded_at": now,
+			}).Error
+			if err != nil {
+				return err
+			}
+			err = events.RecordUnversioned(tx, s.TabID, events.SettlementReminder{
+				SettlementID: security.Ref(s.PublicID),
+				PersonName:   s.PersonName,
+				Outstanding:  s.Outstanding,
+				Reminder:     sent,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(settlements), nil
+}
+
+// QueueEmail inserts a pending email, skipping it if the reminder's email is
+// already queued so a redelivered event is not emailed twice.
+func (r *reminderRepository) QueueEmail(email *models.ReminderEmail) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(email).Error
+}
+
+// ClaimDueEmails locks up to limit due pending emails, pushes their next
+// attempt out by lease so other workers leave them alone, and returns them.
+func (r *reminderRepository) ClaimDueEmails(now time.Time, lease time.Duration, limit int) ([]models.ReminderEmail, error) {
+	var emails []models.ReminderEmail
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", EmailPending, now).
+			Order("next_attempt_at ASC").Limit(limit).
+			Find(&emails).Error
+		if err != nil || len(emails) == 0 {
+			return err
+		}
+		ids := make([]uint, len(emails))
+		for i, e := range emails {
+			ids[i] = e.ID
+		}
+		return tx.Model(&models.ReminderEmail{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return emails, err
+}
+
+func (r *reminderRepository) UpdateEmail(email *models.ReminderEmail) error {
+	return r.db.Model(email).
+		Select("status", "attempts", "last_error", "next_attempt_at", "sent_at").
+		Updates(email).Error
+}
+
+// SentChannels returns the channels the reminder event already went out
+// through.
+func (r *reminderRepository) SentChannels(eventID uint) ([]string, error) {
+	var channels []string
+	err := r.db.Model(&models.ReminderSend{}).Where("event_id = ?", eventID).Pluck("channel", &channels).Error
+	return channels, err
+}
+
+func (r *reminderRepository) MarkSent(eventID uint, channel string) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ReminderSend{EventID: eventID, Channel: channel}).Error
+}
+
+func NewReminderRepository(db *gorm.DB) ReminderRepository {
+	return &reminderRepository{db: db}
+}
@@ -0,0 +1,52 @@
+package reminder
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const remindersPerTick = 100
+
+// Scheduler periodically emits settlement.reminder events for due
+// settlements. Delivery happens later, when the dispatcher hands the events
+// to the reminder service, so reminders share the outbox's ordering and
+// retries. Several schedulers can run at once; each due settlement is locked
+// by one of them.
+type Scheduler struct {
+	repo     ReminderRepository
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewScheduler(repo ReminderRepository) *Scheduler {
+	return &Scheduler{repo: repo, interval: time.Minute, now: time.Now}
+}
+
+// Run emits due reminders until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.EmitDue(); err != nil {
+			log.Printf("reminder scheduler: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EmitDue emits every reminder that is due and returns how many it emitted.
+func (s *Scheduler) EmitDue() (int, error) {
+	total := 0
+	for {
+		n, err := s.repo.EmitDue(s.now(), remindersPerTick)
+		total += n
+		if err != nil || n < remindersPerTick {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package reminder
+
+import (
+	"backend/internal/events"
+	"backend/internal/payments"
+	"backend/pkg/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultIntervalHours = 72
+	minIntervalHours     = 24
+	maxIntervalHours     = 30 * 24
+	defaultMaxReminders  = 3
+	maxMaxReminders      = 10
+	maxRecipients        = 50
+)
+
+var (
+	ErrInvalidInterval     = errors.New("interval_hours must be between 24 and 720")
+	ErrInvalidMaxReminders = errors.New("max_reminders must be between 1 and 10")
+	ErrUnknownChannel      = errors.New("channels must be webhook or email")
+	ErrInvalidRecipient    = errors.New("each recipient needs a person_name and a valid email")
+	ErrTooManyRecipients   = errors.New("too many recipients")
+)
+
+// Settings is a policy as submitted by the tab creator. Zero values take the
+// defaults; Active defaults to true.
+type Settings struct {
+	Active        *bool
+	IntervalHours int
+	MaxReminders  int
+	Channels      []string
+	Recipients    []models.ReminderRecipient
+}
+
+type ReminderService interface {
+	GetPolicy(tabID uint) (*models.ReminderPolicy, error)
+	UpdatePolicy(tabID uint, settings Settings, actor *models.TabMember) (*models.ReminderPolicy, error)
+	Handle(ctx context.Context, event models.Event) error
+}
+
+type reminderService struct {
+	repo     ReminderRepository
+	channels map[string]Channel
+}
+
+// GetPolicy returns the tab's policy, or the inactive defaults if the creator
+// has never set one.
+func (s *reminderService) GetPolicy(tabID uint) (*models.ReminderPolicy, error) {
+	policy, err := s.repo.GetPolicy(tabID)
+	if err == gorm.ErrRecordNotFound {
+		return defaultPolicy(tabID), nil
+	}
+	return policy, err
+}
+
+func defaultPolicy(tabID uint) *models.ReminderPolicy {
+	return &models.ReminderPolicy{
+		TabID:         tabID,
+		IntervalHours: defaultIntervalHours,
+		MaxReminders:  defaultMaxReminders,
+		Channels:      []string{ChannelWebhook},
+		Recipients:    []models.ReminderRecipient{},
+	}
+}
+
+func (s *reminderService) UpdatePolicy(tabID uint, settings Settings, actor *models.TabMember) (*models.ReminderPolicy, error) {
+	policy, err := newPolicy(tabID, settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SavePolicy(policy, actor); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// newPolicy validates settings into a policy for the tab.
+func newPolicy(tabID uint, settings Settings) (*models.ReminderPolicy, error) {
+	policy := &models.ReminderPolicy{
+		TabID:         tabID,
+		Active:        settings.Active == nil || *settings.Active,
+		IntervalHours: settings.IntervalHours,
+		MaxReminders:  settings.MaxReminders,
+		Channels:      []string{},
+		Recipients:    []models.ReminderRecipient{},
+	}
+	if policy.IntervalHours == 0 {
+		policy.IntervalHours = defaultIntervalHours
+	}
+	if policy.IntervalHours < minIntervalHours || policy.IntervalHours > maxIntervalHours {
+		return nil, ErrInvalidInterval
+	}
+	if policy.MaxReminders == 0 {
+		policy.MaxReminders = defaultMaxReminders
+	}
+	if policy.MaxReminders < 1 || policy.MaxReminders > maxMaxReminders {
+		return nil, ErrInvalidMaxReminders
+	}
+
+	channels := settings.Channels
+	if channels == nil {
+		channels = []string{ChannelWebhook}
+	}
+	seen := make(map[string]bool)
+	for _, c := range channels {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if !channelNames[c] {
+			return nil, ErrUnknownChannel
+		}
+		if !seen[c] {
+			seen[c] = true
+			policy.Channels = append(policy.Channels, c)
+		}
+	}
+
+	if len(settings.Recipients) > maxRecipients {
+		return nil, ErrTooManyRecipients
+	}
+	for _, r := range settings.Recipients {
+		name := strings.TrimSpace(r.PersonName)
+		addr, err := mail.ParseAddress(strings.TrimSpace(r.Email))
+		if name == "" || err != nil || addr.Name != "" {
+			return nil, ErrInvalidRecipient
+		}
+		policy.Recipients = append(policy.Recipients, models.ReminderRecipient{
+			PersonName: name,
+			Email:      strings.ToLower(addr.Address),
+		})
+	}
+	return policy, nil
+}
+
+// Handle sends settlement.reminder events through the tab's configured
+// channels. It runs as an event-service dispatcher handler. A reminder whose
+// settlement has since been paid, sent or superseded, or whose policy has
+// been switched off, is dropped.
+func (s *reminderService) Handle(ctx context.Context, event models.Event) error {
+	if event.Type != (events.SettlementReminder{}).EventType() || event.TabID == nil {
+		return nil
+	}
+	var payload events.SettlementReminder
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return err
+	}
+
+	policy, err := s.repo.GetPolicy(*event.TabID)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !policy.Active {
+		return nil
+	}
+
+	settlement, err := s.repo.GetSettlement(*event.TabID, string(payload.SettlementID))
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if settlement.Paid || settlement.Status == payments.StatusSent || settlement.SupersededAt != nil {
+		return nil
+	}
+
+	tab, err := s.repo.GetTab(*event.TabID)
+	if err != nil {
+		return err
+	}
+	settlements := []models.TabSettlement{*settlement}
+	payments.AttachSettlementLinks(tab, settlements)
+
+	reminder := Reminder{
+		Event:      event,
+		Tab:        tab,
+		Settlement: &settlements[0],
+		Count:      payload.Reminder,
+		Email:      policy.EmailFor(settlement.PersonName),
+	}
+	// A retry after one channel failed skips those that already sent it
+	sent, err := s.repo.SentChannels(event.ID)
+	if err != nil {
+		return err
+	}
+	done := make(map[string]bool, len(sent))
+	for _, name := range sent {
+		done[name] = true
+	}
+	for _, name := range policy.Channels {
+		if done[name] {
+			continue
+		}
+		channel, ok := s.channels[name]
+		if !ok {
+			log.Printf("reminder %d: %s channel is not configured", event.ID, name)
+			continue
+		}
+		if err := channel.Send(ctx, reminder); err != nil {
+			return fmt.Errorf("%s reminder: %w", name, err)
+		}
+		if err := s.repo.MarkSent(event.ID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewReminderService returns the service with the given delivery channels.
+// The bill service, which only manages policies, passes none.
+func NewReminderService(repo ReminderRepository, channels ...Channel) ReminderService {
+	byName := make(map[string]Channel, len(channels))
+	for _, c := range channels {
+		byName[c.Name()] = c
+	}
+	return &reminderService{repo: repo, channels: byName}
+}
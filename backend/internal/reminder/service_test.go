@@ -0,0 +1,461 @@
+package reminder
+
+import (
+	"backend/internal/events"
+	"backend/internal/payments"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ── Mock ReminderRepository ─────────────────────────────────────
+
+type mockReminderRepository struct {
+	policy      *models.ReminderPolicy
+	settlements map[uint]*models.TabSettlement
+	tab         *models.Tab
+	saved       *models.ReminderPolicy
+	due         int
+	emitLimits  []int
+	emails      []models.ReminderEmail
+	sends       map[uint][]string
+}
+
+func (m *mockReminderRepository) GetPolicy(tabID uint) (*models.ReminderPolicy, error) {
+	if m.policy == nil || m.policy.TabID != tabID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return m.policy, nil
+}
+
+func (m *mockReminderRepository) SavePolicy(policy *models.ReminderPolicy, actor *models.TabMember) error {
+	m.saved = policy
+	return nil
+}
+
+func (m *mockReminderRepository) GetSettlement(tabID uint, ref string) (*models.TabSettlement, error) {
+	for _, s := range m.settlements {
+		if s.TabID == tabID && security.MatchesRef(ref, s.ID, s.PublicID) {
+			return s, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockReminderRepository) GetTab(id uint) (*models.Tab, error) {
+	return m.tab, nil
+}
+
+func (m *mockReminderRepository) EmitDue(now time.Time, limit int) (int, error) {
+	m.emitLimits = append(m.emitLimits, limit)
+	n := m.due
+	if n > limit {
+		n = limit
+	}
+	m.due -= n
+	return n, nil
+}
+
+func (m *mockReminderRepository) QueueEmail(email *models.ReminderEmail) error {
+	for _, e := range m.emails {
+		if e.EventID == email.EventID {
+			return nil
+		}
+	}
+	email.ID = uint(len(m.emails) + 1)
+	m.emails = append(m.emails, *email)
+	return nil
+}
+
+func (m *mockReminderRepository) ClaimDueEmails(now time.Time, lease time.Duration, limit int) ([]models.ReminderEmail, error) {
+	var due []models.ReminderEmail
+	for i := range m.emails {
+		e := &m.emails[i]
+		if e.Status == EmailPending && !e.NextAttemptAt.After(now) && len(due) < limit {
+			e.NextAttemptAt = now.Add(lease)
+			due = append(due, *e)
+		}
+	}
+	return due, nil
+}
+
+func (m *mockReminderRepository) UpdateEmail(email *models.ReminderEmail) error {
+	m.emails[email.ID-1] = *email
+	return nil
+}
+
+func (m *mockReminderRepository) SentChannels(eventID uint) ([]string, error) {
+	return m.sends[eventID], nil
+}
+
+func (m *mockReminderRepository) MarkSent(eventID uint, channel string) error {
+	if m.sends == nil {
+		m.sends = make(map[uint][]string)
+	}
+	m.sends[eventID] = append(m.sends[eventID], channel)
+	return nil
+}
+
+// ── Fake Channel ────────────────────────────────────────────────
+
+type fakeChannel struct {
+	name string
+	sent []Reminder
+	err  error
+}
+
+func (c *fakeChannel) Name() string { return c.name }
+
+func (c *fakeChannel) Send(ctx context.Context, r Reminder) error {
+	c.sent = append(c.sent, r)
+	return c.err
+}
+
+func reminderEvent(t *testing.T, tabID uint, settlementID string) models.Event {
+	t.Helper()
+	payload, err := json.Marshal(events.SettlementReminder{SettlementID: security.Ref(settlementID), PersonName: "Alice", Outstanding: 40, Reminder: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.Event{ID: 77, TabID: &tabID, Type: "settlement.reminder", Payload: payload}
+}
+
+func newTestRepo() *mockReminderRepository {
+	return &mockReminderRepository{
+		policy: &models.ReminderPolicy{
+			TabID:      1,
+			Active:     true,
+			Channels:   []string{ChannelWebhook, ChannelEmail},
+			Recipients: []models.ReminderRecipient{{PersonName: "alice", Email: "alice@example.com"}},
+		},
+		settlements: map[uint]*models.TabSettlement{
+			5: {ID: 5, PublicID: "StPv7Lm2Qx9Rw4Hb8NcZa3", TabID: 1, PersonName: "Alice", Amount: 40, Outstanding: 40},
+		},
+		tab: &models.Tab{ID: 1, Name: "Ski Trip", Bills: []models.Bill{
+			{PaymentMethods: []models.PaymentMethod{{Name: "Venmo", Identifier: "@bob-smith"}}},
+		}},
+	}
+}
+
+func TestNewPolicy_Validation(t *testing.T) {
+	off := false
+	cases := []struct {
+		name     string
+		settings Settings
+		want     error
+	}{
+		{"defaults", Settings{}, nil},
+		{"interval too short", Settings{IntervalHours: 12}, ErrInvalidInterval},
+		{"interval too long", Settings{IntervalHours: 721}, ErrInvalidInterval},
+		{"too many reminders", Settings{MaxReminders: 11}, ErrInvalidMaxReminders},
+		{"negative reminders", Settings{MaxReminders: -1}, ErrInvalidMaxReminders},
+		{"unknown channel", Settings{Channels: []string{"sms"}}, ErrUnknownChannel},
+		{"missing name", Settings{Recipients: []models.ReminderRecipient{{Email: "a@example.com"}}}, ErrInvalidRecipient},
+		{"bad email", Settings{Recipients: []models.ReminderRecipient{{PersonName: "A", Email: "nope"}}}, ErrInvalidRecipient},
+		{"display name in email", Settings{Recipients: []models.ReminderRecipient{{PersonName: "A", Email: "A <a@example.com>"}}}, ErrInvalidRecipient},
+		{"inactive", Settings{Active: &off}, nil},
+	}
+	for _, tc := range cases {
+		if _, err := newPolicy(1, tc.settings); !errors.Is(err, tc.want) {
+			t.Errorf("%s: error = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	tooMany := make([]models.ReminderRecipient, maxRecipients+1)
+	if _, err := newPolicy(1, Settings{Recipients: tooMany}); !errors.Is(err, ErrTooManyRecipients) {
+		t.Errorf("expected ErrTooManyRecipients, got %v", err)
+	}
+}
+
+func TestNewPolicy_Normalizes(t *testing.T) {
+	policy, err := newPolicy(1, Settings{
+		Channels:   []string{" Email ", "webhook", "email"},
+		Recipients: []models.ReminderRecipient{{PersonName: " Alice ", Email: "Alice@Example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !policy.Active || policy.IntervalHours != defaultIntervalHours || policy.MaxReminders != defaultMaxReminders {
+		t.Errorf("expected active defaults, got %+v", policy)
+	}
+	if strings.Join(policy.Channels, ",") != "email,webhook" {
+		t.Errorf("expected deduped channels, got %v", policy.Channels)
+	}
+	if r := policy.Recipients[0]; r.PersonName != "Alice" || r.Email != "alice@example.com" {
+		t.Errorf("unexpected recipient %+v", r)
+	}
+}
+
+func TestGetPolicy_DefaultsToInactive(t *testing.T) {
+	policy, err := NewReminderService(&mockReminderRepository{}).GetPolicy(3)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if policy.Active || policy.TabID != 3 || policy.IntervalHours != defaultIntervalHours {
+		t.Errorf("unexpected default policy %+v", policy)
+	}
+}
+
+func TestHandle_SendsThroughPolicyChannels(t *testing.T) {
+	repo := newTestRepo()
+	webhook := &fakeChannel{name: ChannelWebhook}
+	email := &fakeChannel{name: ChannelEmail}
+	svc := NewReminderService(repo, webhook, email)
+
+	if err := svc.Handle(context.Background(), reminderEvent(t, 1, "StPv7Lm2Qx9Rw4Hb8NcZa3")); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(webhook.sent) != 1 || len(email.sent) != 1 {
+		t.Fatalf("expected one reminder per channel, got %d webhook, %d email", len(webhook.sent), len(email.sent))
+	}
+	r := email.sent[0]
+	if r.Email != "alice@example.com" || r.Count != 2 || r.Event.ID != 77 {
+		t.Errorf("unexpected reminder %+v", r)
+	}
+	if len(r.Settlement.PayLinks) != 1 || r.Settlement.PayLinks[0].Provider != payments.MethodVenmo {
+		t.Errorf("expected a venmo pay link, got %+v", r.Settlement.PayLinks)
+	}
+}
+
+func TestHandle_ResolvesLegacyNumericSettlementID(t *testing.T) {
+	repo := newTestRepo()
+	channel := &fakeChannel{name: ChannelWebhook}
+	tabID := uint(1)
+	event := models.Event{ID: 77, TabID: &tabID, Type: "settlement.reminder", Payload: json.RawMessage(`{"settlement_id":5,"reminder":1}`)}
+
+	if err := NewReminderService(repo, channel).Handle(context.Background(), event); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(channel.sent) != 1 || channel.sent[0].Settlement.ID != 5 {
+		t.Errorf("expected the reminder for settlement 5, got %+v", channel.sent)
+	}
+}
+
+func TestHandle_DropsStaleReminders(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name   string
+		change func(repo *mockReminderRepository)
+	}{
+		{"no policy", func(repo *mockReminderRepository) { repo.policy = nil }},
+		{"inactive", func(repo *mockReminderRepository) { repo.policy.Active = false }},
+		{"paid", func(repo *mockReminderRepository) { repo.settlements[5].Paid = true }},
+		{"sent", func(repo *mockReminderRepository) { repo.settlements[5].Status = payments.StatusSent }},
+		{"superseded", func(repo *mockReminderRepository) { repo.settlements[5].SupersededAt = &now }},
+		{"deleted", func(repo *mockReminderRepository) { delete(repo.settlements, 5) }},
+	}
+	for _, tc := range cases {
+		repo := newTestRepo()
+		tc.change(repo)
+		channel := &fakeChannel{name: ChannelWebhook}
+		if err := NewReminderService(repo, channel).Handle(context.Background(), reminderEvent(t, 1, "StPv7Lm2Qx9Rw4Hb8NcZa3")); err != nil {
+			t.Errorf("%s: expected no error, got %v", tc.name, err)
+		}
+		if len(channel.sent) != 0 {
+			t.Errorf("%s: expected reminder to be dropped", tc.name)
+		}
+	}
+}
+
+func TestHandle_ReturnsChannelErrorsForRetry(t *testing.T) {
+	repo := newTestRepo()
+	repo.policy.Channels = []string{ChannelWebhook}
+	channel := &fakeChannel{name: ChannelWebhook, err: errors.New("boom")}
+	err := NewReminderService(repo, channel).Handle(context.Background(), reminderEvent(t, 1, "StPv7Lm2Qx9Rw4Hb8NcZa3"))
+	if err == nil || !strings.Contains(err.Error(), "webhook reminder") {
+		t.Errorf("expected wrapped channel error, got %v", err)
+	}
+}
+
+func TestHandle_RetrySkipsChannelsThatSent(t *testing.T) {
+	repo := newTestRepo()
+	webhook := &fakeChannel{name: ChannelWebhook}
+	email := &fakeChannel{name: ChannelEmail, err: errors.New("boom")}
+	svc := NewReminderService(repo, webhook, email)
+	event := reminderEvent(t, 1, "StPv7Lm2Qx9Rw4Hb8NcZa3")
+
+	if err := svc.Handle(context.Background(), event); err == nil {
+		t.Fatal("expected the email channel's error")
+	}
+	email.err = nil
+	if err := svc.Handle(context.Background(), event); err != nil {
+		t.Fatalf("expected no error on retry, got %v", err)
+	}
+	if len(webhook.sent) != 1 || len(email.sent) != 2 {
+		t.Errorf("expected the webhook sent once and the email retried, got %d webhook, %d email", len(webhook.sent), len(email.sent))
+	}
+}
+
+func TestHandle_IgnoresOtherEvents(t *testing.T) {
+	repo := newTestRepo()
+	channel := &fakeChannel{name: ChannelWebhook}
+	tabID := uint(1)
+	err := NewReminderService(repo, channel).Handle(context.Background(), models.Event{TabID: &tabID, Type: "settlement.paid"})
+	if err != nil || len(channel.sent) != 0 {
+		t.Errorf("expected other events to be ignored, got %v, %v", channel.sent, err)
+	}
+}
+
+func TestScheduler_EmitsInBatches(t *testing.T) {
+	repo := &mockReminderRepository{due: 2*remindersPerTick + 5}
+	n, err := NewScheduler(repo).EmitDue()
+	if err != nil || n != 2*remindersPerTick+5 {
+		t.Errorf("expected %d emitted, got %d, %v", 2*remindersPerTick+5, n, err)
+	}
+	if len(repo.emitLimits) != 3 {
+		t.Errorf("expected 3 batches, got %d", len(repo.emitLimits))
+	}
+}
+
+// smtpSink accepts one message on a local port, the way a dev SMTP sink
+// such as Mailpit would, and sends the recipient and body on the channel.
+func smtpSink(t *testing.T) (string, <-chan [2]string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan [2]string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		reply("220 sink")
+		var rcpt string
+		var body strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 sink")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				rcpt = strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>")
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if data == ".\r\n" {
+						break
+					}
+					body.WriteString(data)
+				}
+				reply("250 queued")
+				received <- [2]string{rcpt, body.String()}
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestEmailChannel_QueuesForWorker(t *testing.T) {
+	addr, received := smtpSink(t)
+	config := SMTPConfig{Addr: addr, From: "reminders@billington.app"}
+	repo := &mockReminderRepository{}
+	channel := NewEmailChannel(config, repo)
+
+	r := Reminder{
+		Event: models.Event{ID: 77},
+		Tab:   &models.Tab{ID: 1, Name: "Ski\r\nBcc: x@evil.test Trip"},
+		Settlement: &models.TabSettlement{
+			PersonName:  "Alice",
+			Outstanding: 40,
+			PayLinks:    []models.PayLink{{Provider: "venmo", URL: "https://account.venmo.com/pay?x=1"}},
+		},
+		Email: "alice@example.com",
+	}
+	// A redelivered event queues nothing more, and nothing is sent yet
+	for i := 0; i < 2; i++ {
+		if err := channel.Send(context.Background(), r); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if len(repo.emails) != 1 || repo.emails[0].Status != EmailPending || repo.emails[0].Recipient != "alice@example.com" {
+		t.Fatalf("expected one pending email queued, got %+v", repo.emails)
+	}
+
+	sent, err := NewEmailWorker(repo, config).SendDue(context.Background())
+	if err != nil || sent != 1 {
+		t.Fatalf("expected one email sent, got %d, %v", sent, err)
+	}
+	if repo.emails[0].Status != EmailSent || repo.emails[0].SentAt == nil {
+		t.Errorf("expected the email marked sent, got %+v", repo.emails[0])
+	}
+
+	select {
+	case msg := <-received:
+		if msg[0] != "alice@example.com" {
+			t.Errorf("expected recipient alice@example.com, got %q", msg[0])
+		}
+		if !strings.Contains(msg[1], "You still owe $40.00") || !strings.Contains(msg[1], "venmo: https://account.venmo.com/pay?x=1") {
+			t.Errorf("unexpected body:\n%s", msg[1])
+		}
+		if strings.Contains(msg[1], "\r\nBcc:") {
+			t.Errorf("expected header injection to be stripped:\n%s", msg[1])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestEmailChannel_SkipsPeopleWithoutAddress(t *testing.T) {
+	repo := &mockReminderRepository{}
+	channel := NewEmailChannel(SMTPConfig{Addr: "127.0.0.1:1", From: "reminders@billington.app"}, repo)
+	if err := channel.Send(context.Background(), Reminder{}); err != nil || len(repo.emails) != 0 {
+		t.Errorf("expected reminder without email to be skipped, got %v, %+v", err, repo.emails)
+	}
+}
+
+func TestEmailWorker_RetriesThenGivesUp(t *testing.T) {
+	// Nothing listens on the address, so every attempt fails
+	now := time.Now()
+	repo := &mockReminderRepository{emails: []models.ReminderEmail{
+		{ID: 1, EventID: 77, Recipient: "alice@example.com", Message: []byte("hi"), Status: EmailPending, NextAttemptAt: now},
+	}}
+	worker := NewEmailWorker(repo, SMTPConfig{Addr: "127.0.0.1:1", From: "reminders@billington.app"})
+	worker.now = func() time.Time { return now }
+
+	if sent, err := worker.SendDue(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("expected nothing sent, got %d, %v", sent, err)
+	}
+	e := repo.emails[0]
+	if e.Status != EmailPending || e.Attempts != 1 || e.LastError == "" || !e.NextAttemptAt.Equal(now.Add(baseEmailDelay)) {
+		t.Fatalf("expected a retry after %s, got %+v", baseEmailDelay, e)
+	}
+
+	for i := 1; i < maxEmailAttempts; i++ {
+		now = repo.emails[0].NextAttemptAt
+		worker.SendDue(context.Background())
+	}
+	if e := repo.emails[0]; e.Status != EmailFailed || e.Attempts != maxEmailAttempts {
+		t.Errorf("expected the email to fail after %d attempts, got %+v", maxEmailAttempts, e)
+	}
+}
@@ -1,13 +1,15 @@
 package tab
 
 import (
+	"backend/internal/payments"
+	"backend/pkg/etag"
 	"backend/pkg/models"
 	"backend/pkg/security"
 	"crypto/subtle"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -29,7 +31,7 @@ func NewTabHandler(service TabService) *TabHandler {
 	return &TabHandler{service: service}
 }
 
-// getTabAndValidate parses the ID, fetches the tab, and validates the token.
+// getTabAndValidate resolves the tab by public or legacy numeric ID and validates the token.
 // Returns the tab on success or writes an error and returns nil.
 func (h *TabHandler) getTabAndValidate(c *gin.Context) *models.Tab {
 	id := c.Param("id")
@@ -49,14 +51,12 @@ func (h *TabHandler) getTabAndValidate(c *gin.Context) *models.Tab {
 		return nil
 	}
 
-	idUint, err := strconv.ParseUint(id, 10, 32)
-	if err != nil {
-		c.JSON(400, gin.H{"error": "invalid id format"})
-		return nil
-	}
-
-	tab, err := h.service.GetTab(uint(idUint))
+	tab, err := h.service.GetTabByRef(id)
 	if err != nil {
+		if errors.Is(err, security.ErrInvalidRef) {
+			c.JSON(400, gin.H{"error": "invalid id format"})
+			return nil
+		}
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(404, gin.H{"error": "tab not found"})
 			return nil
@@ -90,11 +90,26 @@ func (h *TabHandler) getMemberFromQuery(c *gin.Context) *models.TabMember {
 	return member
 }
 
+// requireCreator enforces that, once a tab has members, only its creator may
+// perform the given action. Writes a 403 and returns false otherwise.
+func (h *TabHandler) requireCreator(c *gin.Context, tab *models.Tab, action string) bool {
+	if len(tab.Members) == 0 {
+		return true
+	}
+	member := h.getMemberFromQuery(c)
+	if member == nil || member.TabID != tab.ID || member.Role != "creator" {
+		c.JSON(403, gin.H{"error": "only the tab creator can " + action})
+		return false
+	}
+	return true
+}
+
 func (h *TabHandler) CreateTab(c *gin.Context) {
 	var body struct {
 		Name                string `json:"name"`
 		Description         string `json:"description"`
 		CreatorDisplayName  string `json:"creator_display_name"`
+		Recurring           bool   `json:"recurring"`
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -105,6 +120,7 @@ func (h *TabHandler) CreateTab(c *gin.Context) {
 	tab := models.Tab{
 		Name:        security.SanitizeString(body.Name),
 		Description: security.SanitizeString(body.Description),
+		Recurring:   body.Recurring,
 	}
 
 	token, err := security.GenerateSecureToken()
@@ -115,7 +131,7 @@ func (h *TabHandler) CreateTab(c *gin.Context) {
 	}
 	tab.AccessToken = token
 
-	err = h.service.CreateTab(&tab)
+	creator, err := h.service.CreateTab(&tab, security.SanitizeString(body.CreatorDisplayName))
 	if err != nil {
 		log.Printf("internal error: %v", err)
 		c.JSON(500, gin.H{"error": "an internal error occurred"})
@@ -123,18 +139,13 @@ func (h *TabHandler) CreateTab(c *gin.Context) {
 	}
 
 	resp := gin.H{
-		"tab_id":       tab.ID,
+		"public_id":    tab.PublicID,
 		"access_token": token,
-		"share_url":    fmt.Sprintf("%s/t/%d?t=%s", appDomain(), tab.ID, token),
+		"share_url":    fmt.Sprintf("%s/t/%s?t=%s", appDomain(), tab.PublicID, token),
 	}
-
-	creatorName := security.SanitizeString(body.CreatorDisplayName)
-	if creatorName != "" {
-		member, err := h.service.JoinTabAsCreator(tab.ID, creatorName)
-		if err == nil {
-			resp["member_token"] = member.MemberToken
-			resp["member_id"] = member.ID
-		}
+	if creator != nil {
+		resp["member_token"] = creator.MemberToken
+		resp["member_id"] = creator.PublicID
 	}
 
 	c.JSON(201, resp)
@@ -147,6 +158,12 @@ func (h *TabHandler) GetTab(c *gin.Context) {
 	}
 
 	tab.AccessToken = ""
+	tag := etag.For(tab.Version)
+	c.Header("ETag", tag)
+	if etag.NoneMatch(c.GetHeader("If-None-Match"), tag) {
+		c.Status(304)
+		return
+	}
 	c.JSON(200, tab)
 }
 
@@ -162,38 +179,130 @@ func (h *TabHandler) AddBillToTab(c *gin.Context) {
 	}
 
 	var body struct {
-		BillID uint `json:"bill_id"`
+		BillID    security.Ref `json:"bill_id"`
+		BillToken string       `json:"bill_token"`
+		Move      bool         `json:"move"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(400, gin.H{"error": "bad request"})
+	if err := c.ShouldBindJSON(&body); err != nil || body.BillID == "" || body.BillToken == "" {
+		c.JSON(400, gin.H{"error": "bill_id and bill_token required"})
+		return
+	}
+
+	err := h.service.AddBillToTab(tab.ID, string(body.BillID), body.BillToken, h.getMemberFromQuery(c), body.Move)
+	if err != nil {
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			c.JSON(404, gin.H{"error": "bill not found"})
+		case errors.Is(err, security.ErrInvalidRef):
+			c.JSON(400, gin.H{"error": "invalid bill id"})
+		case errors.Is(err, ErrBillTokenMismatch):
+			c.JSON(403, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrBillInAnotherTab):
+			c.JSON(409, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrAlreadyFinalized):
+			c.JSON(400, gin.H{"error": "tab is finalized"})
+		case errors.Is(err, ErrTabFinalized):
+			c.JSON(400, gin.H{"error": "bill's current tab is finalized"})
+		case errors.Is(err, ErrBillPeriodClosed):
+			c.JSON(409, gin.H{"error": err.Error()})
+		default:
+			log.Printf("internal error: %v", err)
+			c.JSON(500, gin.H{"error": "an internal error occurred"})
+		}
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func (h *TabHandler) RemoveBillFromTab(c *gin.Context) {
+	tab := h.getTabAndValidate(c)
+	if tab == nil {
+		return
+	}
+
+	member := h.getMemberFromQuery(c)
+	err := h.service.RemoveBillFromTab(tab.ID, c.Param("billId"), member)
+	if err != nil {
+		h.respondBillChangeError(c, err)
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func (h *TabHandler) MoveBill(c *gin.Context) {
+	tab := h.getTabAndValidate(c)
+	if tab == nil {
 		return
 	}
 
-	var memberID *uint
-	if member := h.getMemberFromQuery(c); member != nil {
-		memberID = &member.ID
+	var body struct {
+		TargetTabID security.Ref `json:"target_tab_id"`
+		TargetToken string       `json:"target_token"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.TargetTabID == "" {
+		c.JSON(400, gin.H{"error": "target_tab_id and target_token required"})
+		return
 	}
 
-	err := h.service.AddBillToTab(tab.ID, body.BillID, memberID)
+	// The caller must hold the destination tab's token as well
+	target, err := h.service.GetTabByRef(string(body.TargetTabID))
 	if err != nil {
+		if errors.Is(err, security.ErrInvalidRef) {
+			c.JSON(400, gin.H{"error": "invalid target tab id"})
+			return
+		}
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(404, gin.H{"error": "bill not found"})
+			c.JSON(404, gin.H{"error": "target tab not found"})
 			return
 		}
 		log.Printf("internal error: %v", err)
 		c.JSON(500, gin.H{"error": "an internal error occurred"})
 		return
 	}
+	if subtle.ConstantTimeCompare([]byte(body.TargetToken), []byte(target.AccessToken)) != 1 {
+		c.JSON(403, gin.H{"error": "target token mismatch"})
+		return
+	}
+
+	member := h.getMemberFromQuery(c)
+	err = h.service.MoveBill(tab.ID, c.Param("billId"), target.ID, member)
+	if err != nil {
+		h.respondBillChangeError(c, err)
+		return
+	}
 
 	c.JSON(200, gin.H{"status": "ok"})
 }
 
+func (h *TabHandler) respondBillChangeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrBillNotInTab), errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(404, gin.H{"error": ErrBillNotInTab.Error()})
+	case errors.Is(err, ErrNotBillOwner):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTabFinalized), errors.Is(err, ErrAlreadyFinalized):
+		c.JSON(400, gin.H{"error": ErrTabFinalized.Error()})
+	case errors.Is(err, ErrBillPeriodClosed):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		log.Printf("internal error: %v", err)
+		c.JSON(500, gin.H{"error": "an internal error occurred"})
+	}
+}
+
 func (h *TabHandler) UpdateTab(c *gin.Context) {
 	tab := h.getTabAndValidate(c)
 	if tab == nil {
 		return
 	}
 
+	version, ok := etag.IfMatch(c, tab.Version, "tab")
+	if !ok {
+		return
+	}
+
 	if tab.Finalized {
 		c.JSON(400, gin.H{"error": "tab is finalized"})
 		return
@@ -218,7 +327,11 @@ func (h *TabHandler) UpdateTab(c *gin.Context) {
 		update.Description = sanitized
 	}
 
-	err := h.service.UpdateTab(update)
+	err := h.service.UpdateTab(update, version, h.getMemberFromQuery(c))
+	if errors.Is(err, etag.ErrChanged) {
+		etag.Changed(c, "tab")
+		return
+	}
 	if err != nil {
 		log.Printf("internal error: %v", err)
 		c.JSON(500, gin.H{"error": "an internal error occurred"})
@@ -228,44 +341,151 @@ func (h *TabHandler) UpdateTab(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok"})
 }
 
+const maxIdempotencyKeyLength = 255
+
+// FinalizeTab handles POST /api/tabs/:id/finalize. With dry_run=true it
+// returns what finalizing would do instead. Retries carrying the
+// Idempotency-Key of the request that finalized the tab get its settlements
+// back.
 func (h *TabHandler) FinalizeTab(c *gin.Context) {
 	tab := h.getTabAndValidate(c)
 	if tab == nil {
 		return
 	}
 
-	// If tab has members, only the creator can finalize
-	if len(tab.Members) > 0 {
-		member := h.getMemberFromQuery(c)
-		if member == nil || member.Role != "creator" {
-			c.JSON(403, gin.H{"error": "only the tab creator can finalize"})
+	if !h.requireCreator(c, tab, "finalize") {
+		return
+	}
+
+	if c.Query("dry_run") == "true" {
+		preview, err := h.service.PreviewFinalize(tab.ID)
+		if err != nil {
+			log.Printf("internal error: %v", err)
+			c.JSON(500, gin.H{"error": "an internal error occurred"})
+			return
+		}
+		models.NewMemberRefs(tab.Members).Settlements(preview.Settlements)
+		c.JSON(200, preview)
+		return
+	}
+
+	key := c.GetHeader("Idempotency-Key")
+	if len(key) > maxIdempotencyKeyLength {
+		c.JSON(400, gin.H{"error": "Idempotency-Key is too long"})
+		return
+	}
+
+	// A retry of the request that finalized the tab sends the ETag it saw
+	// before finalizing, so it is replayed rather than refused
+	var version *uint
+	if !isReplay(tab, key) {
+		var ok bool
+		if version, ok = etag.IfMatch(c, tab.Version, "tab"); !ok {
 			return
 		}
 	}
 
-	settlements, err := h.service.FinalizeTab(tab.ID)
+	settlements, replayed, err := h.service.FinalizeTab(tab.ID, key, version, h.getMemberFromQuery(c))
+	if errors.Is(err, etag.ErrChanged) {
+		etag.Changed(c, "tab")
+		return
+	}
 	if err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if replayed {
+		c.Header("Idempotent-Replayed", "true")
+		respondSettlements(c, tab, settlements)
+		return
+	}
+
+	respondSettlements(c, tab, settlements)
+}
 
+// respondSettlements writes the tab's settlements with the links to pay them
+// and the members they are owed by.
+func respondSettlements(c *gin.Context, tab *models.Tab, settlements []models.TabSettlement) {
+	payments.AttachSettlementLinks(tab, settlements)
+	models.NewMemberRefs(tab.Members).Settlements(settlements)
 	c.JSON(200, settlements)
 }
 
+func (h *TabHandler) ReopenTab(c *gin.Context) {
+	tab := h.getTabAndValidate(c)
+	if tab == nil {
+		return
+	}
+
+	if !h.requireCreator(c, tab, "reopen") {
+		return
+	}
+
+	if err := h.service.ReopenTab(tab.ID, h.getMemberFromQuery(c)); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// ClosePeriod handles POST /api/tabs/:id/periods/close
+func (h *TabHandler) ClosePeriod(c *gin.Context) {
+	tab := h.getTabAndValidate(c)
+	if tab == nil {
+		return
+	}
+
+	if !h.requireCreator(c, tab, "close periods") {
+		return
+	}
+
+	settlements, err := h.service.ClosePeriod(tab.ID, h.getMemberFromQuery(c))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondSettlements(c, tab, settlements)
+}
+
+// GetBalances handles GET /api/tabs/:id/balances
+func (h *TabHandler) GetBalances(c *gin.Context) {
+	tab := h.getTabAndValidate(c)
+	if tab == nil {
+		return
+	}
+
+	balances, err := h.service.GetBalances(tab.ID)
+	if err != nil {
+		log.Printf("internal error: %v", err)
+		c.JSON(500, gin.H{"error": "an internal error occurred"})
+		return
+	}
+
+	c.JSON(200, balances)
+}
+
 func (h *TabHandler) GetSettlements(c *gin.Context) {
 	tab := h.getTabAndValidate(c)
 	if tab == nil {
 		return
 	}
 
-	settlements, err := h.service.GetSettlements(tab.ID)
+	var settlements []models.TabSettlement
+	var err error
+	if c.Query("history") == "true" {
+		settlements, err = h.service.GetSettlementHistory(tab.ID)
+	} else {
+		settlements, err = h.service.GetSettlements(tab.ID)
+	}
 	if err != nil {
 		log.Printf("internal error: %v", err)
 		c.JSON(500, gin.H{"error": "an internal error occurred"})
 		return
 	}
 
-	c.JSON(200, settlements)
+	respondSettlements(c, tab, settlements)
 }
 
 func (h *TabHandler) UpdateSettlement(c *gin.Context) {
@@ -274,21 +494,45 @@ func (h *TabHandler) UpdateSettlement(c *gin.Context) {
 		return
 	}
 
-	settlementID, err := strconv.ParseUint(c.Param("settlementId"), 10, 32)
-	if err != nil {
-		c.JSON(400, gin.H{"error": "invalid settlement id"})
+	version, ok := etag.IfMatch(c, tab.Version, "tab")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		Paid *bool `json:"paid"`
+	settlement, err := h.service.GetSettlementByRef(tab.ID, c.Param("settlementId"))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(404, gin.H{"error": "settlement not found on this tab"})
+			return
+		}
+		log.Printf("internal error: %v", err)
+		c.JSON(500, gin.H{"error": "an internal error occurred"})
+		return
 	}
-	if err := c.ShouldBindJSON(&body); err != nil || body.Paid == nil {
-		c.JSON(400, gin.H{"error": "paid field required"})
+
+	var body payments.StatusRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{"error": "status field required"})
 		return
 	}
 
-	if err := h.service.UpdateSettlementPaid(uint(settlementID), *body.Paid); err != nil {
+	// The payer marks a settlement sent; the payee (the tab creator) confirms
+	// it received or disputes it.
+	member := h.getMemberFromQuery(c)
+	action, err := body.Action(payments.TabCaller(tab, member), settlement.MemberID)
+	if err == nil {
+		body.Reason = security.SanitizeString(body.Reason)
+		err = h.service.MarkSettlement(tab.ID, settlement.ID, action, body.Reason, version, member)
+	}
+	if errors.Is(err, etag.ErrChanged) {
+		etag.Changed(c, "tab")
+		return
+	}
+	if err != nil {
+		if code := payments.StatusCode(err); code != 0 {
+			c.JSON(code, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("internal error: %v", err)
 		c.JSON(500, gin.H{"error": "an internal error occurred"})
 		return
@@ -325,7 +569,7 @@ func (h *TabHandler) JoinTab(c *gin.Context) {
 	}
 
 	c.JSON(201, gin.H{
-		"member_id":    member.ID,
+		"member_id":    member.PublicID,
 		"member_token": member.MemberToken,
 		"display_name": member.DisplayName,
 		"role":         member.Role,
@@ -347,3 +591,82 @@ func (h *TabHandler) GetMembers(c *gin.Context) {
 
 	c.JSON(200, members)
 }
+
+func (h *TabHandler) AddMemberAlias(c *gin.Context) {
+	tab := h.getTabAndValidate(c)
+	if tab == nil {
+		return
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{"error": "bad request"})
+		return
+	}
+
+	name := security.SanitizeString(body.Name)
+	if name == "" || len(name) > 30 {
+		c.JSON(400, gin.H{"error": "name must be 1-30 characters"})
+		return
+	}
+
+	actor := h.getMemberFromQuery(c)
+	alias, err := h.service.AddMemberAlias(tab.ID, c.Param("memberId"), name, actor)
+	if err != nil {
+		h.respondAliasError(c, err)
+		return
+	}
+
+	c.JSON(201, alias)
+}
+
+func (h *TabHandler) RemoveMemberAlias(c *gin.Context) {
+	tab := h.getTabAndValidate(c)
+	if tab == nil {
+		return
+	}
+
+	ref := c.Param("aliasId")
+	if _, _, err := security.ParseRef(ref); err != nil {
+		c.JSON(400, gin.H{"error": "invalid alias id"})
+		return
+	}
+	var alias *models.TabMemberAlias
+	for i := range tab.Aliases {
+		if security.MatchesRef(ref, tab.Aliases[i].ID, tab.Aliases[i].PublicID) {
+			alias = &tab.Aliases[i]
+		}
+	}
+	if alias == nil {
+		c.JSON(404, gin.H{"error": "alias not found"})
+		return
+	}
+
+	actor := h.getMemberFromQuery(c)
+	if err := h.service.RemoveMemberAlias(tab.ID, alias.ID, actor); err != nil {
+		h.respondAliasError(c, err)
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func (h *TabHandler) respondAliasError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrMemberNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case err == gorm.ErrRecordNotFound:
+		c.JSON(404, gin.H{"error": "alias not found"})
+	case errors.Is(err, ErrNotAliasOwner):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrAliasTaken):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTabFinalized):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		log.Printf("internal error: %v", err)
+		c.JSON(500, gin.H{"error": "an internal error occurred"})
+	}
+}
@@ -1,96 +1,526 @@
 package tab
 
 import (
+	"backend/internal/audit"
+	"backend/internal/events"
+	"backend/internal/payments"
 	"backend/pkg/models"
+	"backend/pkg/security"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TabRepository interface {
-	Create(tab *models.Tab) error
+	Create(tab *models.Tab, creator *models.TabMember) error
 	GetById(id uint) (tab *models.Tab, err error)
-	Update(tab *models.Tab) error
+	GetByPublicID(publicID string) (tab *models.Tab, err error)
+	Update(tab *models.Tab, ifVersion *uint, actor *models.TabMember) error
 	Delete(id uint) error
-	AddBill(tabID uint, billID uint, memberID *uint) error
-	Finalize(id uint) error
+	GetBill(billID uint) (*models.Bill, error)
+	GetBillByPublicID(publicID string) (*models.Bill, error)
+	AddBill(tabID uint, billID uint, member *models.TabMember) error
+	RemoveBill(tabID uint, billID uint, actor *models.TabMember) error
+	MoveBill(fromTabID uint, billID uint, toTabID uint, actor *models.TabMember) error
+	Finalize(id uint, idempotencyKey string, ifVersion *uint, plan FinalizePlan, actor *models.TabMember) error
 	GetSettlements(tabID uint) ([]models.TabSettlement, error)
-	CreateSettlements(settlements []models.TabSettlement) error
-	UpdateSettlementPaid(id uint, paid bool) error
+	GetSettlementHistory(tabID uint) ([]models.TabSettlement, error)
+	Reopen(id uint, actor *models.TabMember) error
+	ClosePeriod(tabID uint, plan ClosePlan, actor *models.TabMember) error
+	MarkSettlement(tabID uint, id uint, action payments.Action, reason string, ifVersion *uint, actor *models.TabMember) error
 	CreateMember(member *models.TabMember) error
 	GetMemberByToken(token string) (*models.TabMember, error)
 	GetMembersByTabID(tabID uint) ([]models.TabMember, error)
+	CreateAlias(alias *models.TabMemberAlias, actor *models.TabMember) error
+	DeleteAlias(tabID uint, aliasID uint, actor *models.TabMember) error
 }
 
+// FinalizePlan computes the settlements of a tab being finalized, or returns
+// an error to stop it. It is given the tab as loaded under its row lock.
+type FinalizePlan func(tab *models.Tab) ([]models.TabSettlement, error)
+
+// ClosePlan computes the settlements that replace previous when the tab's
+// open period is closed, or returns an error to stop it. Like FinalizePlan,
+// it is given the tab as loaded under its row lock.
+type ClosePlan func(tab *models.Tab, previous []models.TabSettlement) ([]models.TabSettlement, error)
+
 type tabRepository struct {
 	db *gorm.DB
 }
 
-func (r *tabRepository) Create(tab *models.Tab) error {
-	return r.db.Create(tab).Error
+// Create creates the tab and, if given, its creator's membership, recording
+// the creator as the actor.
+func (r *tabRepository) Create(tab *models.Tab, creator *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(tab).Error; err != nil {
+			return err
+		}
+		if creator != nil {
+			creator.TabID = tab.ID
+			if err := createMember(tx, creator); err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  tab.ID,
+			Actor:  creator,
+			Action: "tab.created",
+			After:  gin.H{"name": tab.Name, "description": tab.Description, "recurring": tab.Recurring},
+		})
+	})
 }
 
 func (r *tabRepository) GetById(id uint) (tab *models.Tab, err error) {
 	tab = &models.Tab{}
-	err = r.db.
+	err = r.preloaded().First(tab, id).Error
+	tab.FillMemberRefs()
+	return tab, err
+}
+
+func (r *tabRepository) GetByPublicID(publicID string) (tab *models.Tab, err error) {
+	tab = &models.Tab{}
+	err = r.preloaded().Where("public_id = ?", publicID).First(tab).Error
+	tab.FillMemberRefs()
+	return tab, err
+}
+
+func (r *tabRepository) preloaded() *gorm.DB {
+	return preload(r.db)
+}
+
+func preload(db *gorm.DB) *gorm.DB {
+	return db.
 		Preload("Bills.Items.Assignments").
 		Preload("Bills.Participants").
 		Preload("Bills.PersonShares").
 		Preload("Members").
-		First(tab, id).Error
-	return tab, err
+		Preload("Aliases").
+		Preload("Periods", func(db *gorm.DB) *gorm.DB { return db.Order("number") })
 }
 
-func (r *tabRepository) Update(tab *models.Tab) error {
-	return r.db.Model(tab).Updates(models.Tab{
-		Name:        tab.Name,
-		Description: tab.Description,
-	}).Error
+// Update saves the tab's name and description, if the tab is still at
+// ifVersion when one is given. Empty fields are left as they are.
+func (r *tabRepository) Update(tab *models.Tab, ifVersion *uint, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := events.RequireVersion(tx, &models.Tab{}, tab.ID, ifVersion); err != nil {
+			return err
+		}
+		current := &models.Tab{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "name", "description").First(current, tab.ID).Error; err != nil {
+			return err
+		}
+		before := gin.H{"name": current.Name, "description": current.Description}
+		err := tx.Model(tab).Updates(models.Tab{
+			Name:        tab.Name,
+			Description: tab.Description,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := events.Record(tx, tab.ID, events.TabUpdated{Name: tab.Name, Description: tab.Description}); err != nil {
+			return err
+		}
+		if tab.Name != "" {
+			current.Name = tab.Name
+		}
+		if tab.Description != "" {
+			current.Description = tab.Description
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  tab.ID,
+			Actor:  actor,
+			Action: "tab.updated",
+			Before: before,
+			After:  gin.H{"name": current.Name, "description": current.Description},
+		})
+	})
 }
 
 func (r *tabRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Tab{}, id).Error
 }
 
-func (r *tabRepository) AddBill(tabID uint, billID uint, memberID *uint) error {
-	updates := map[string]interface{}{"tab_id": tabID}
-	if memberID != nil {
-		updates["added_by_member_id"] = *memberID
+// GetBill loads just the fields needed to authorize attaching a bill.
+func (r *tabRepository) GetBill(billID uint) (*models.Bill, error) {
+	bill := &models.Bill{}
+	err := r.db.Select("id", "public_id", "tab_id", "access_token").First(bill, billID).Error
+	return bill, err
+}
+
+func (r *tabRepository) GetBillByPublicID(publicID string) (*models.Bill, error) {
+	bill := &models.Bill{}
+	err := r.db.Select("id", "public_id", "tab_id", "access_token").Where("public_id = ?", publicID).First(bill).Error
+	return bill, err
+}
+
+// AddBill attaches a bill to a tab, in the tab's open period if it is
+// recurring, attributed to member if given.
+func (r *tabRepository) AddBill(tabID uint, billID uint, member *models.TabMember) error {
+	updates := map[string]interface{}{"tab_id": tabID, "tab_period": openPeriod(tabID), "version": gorm.Expr("version + 1")}
+	if member != nil {
+		updates["added_by_member_id"] = member.ID
 	}
-	result := r.db.Model(&models.Bill{}).Where("id = ?", billID).Updates(updates)
-	if result.Error != nil {
-		return result.Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		tab, err := lockOpenTab(tx, tabID)
+		if err != nil {
+			return err
+		}
+		result := tx.Model(&models.Bill{}).Where("id = ?", billID).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		added := events.BillAdded{}
+		if added.BillID, err = events.PublicRef(tx, &models.Bill{}, billID); err != nil {
+			return err
+		}
+		if member != nil {
+			added.MemberID = security.Ref(member.PublicID)
+		}
+		if err := events.Record(tx, tabID, added); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  tabID,
+			Actor:  member,
+			Action: "bill.added",
+			Target: "bill:" + string(added.BillID),
+			After:  gin.H{"tab_id": tab.PublicID},
+		})
+	})
+}
+
+// lockOpenTab locks the tab's row for a bill joining it, failing with
+// ErrAlreadyFinalized if the tab is finalized.
+func lockOpenTab(tx *gorm.DB, tabID uint) (*models.Tab, error) {
+	tab := &models.Tab{}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "public_id", "finalized").First(tab, tabID).Error; err != nil {
+		return nil, err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if tab.Finalized {
+		return nil, ErrAlreadyFinalized
 	}
-	return nil
+	return tab, nil
+}
+
+// RemoveBill detaches a bill from a tab. The bill itself is kept.
+func (r *tabRepository) RemoveBill(tabID uint, billID uint, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Bill{}).Where("id = ? AND tab_id = ?", billID, tabID).Updates(map[string]interface{}{
+			"tab_id":             nil,
+			"added_by_member_id": nil,
+			"tab_period":         0,
+			"version":            gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		ref, err := events.PublicRef(tx, &models.Bill{}, billID)
+		if err != nil {
+			return err
+		}
+		if err := events.Record(tx, tabID, events.BillRemoved{BillID: ref}); err != nil {
+			return err
+		}
+		tabRef, err := events.PublicRef(tx, &models.Tab{}, tabID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  tabID,
+			Actor:  actor,
+			Action: "bill.removed",
+			Target: "bill:" + string(ref),
+			Before: gin.H{"tab_id": tabRef},
+		})
+	})
 }
 
-func (r *tabRepository) Finalize(id uint) error {
-	now := time.Now()
-	return r.db.Model(&models.Tab{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"finalized":    true,
-		"finalized_at": now,
-	}).Error
+// MoveBill reassigns a bill from one tab to another. Member attribution is
+// cleared since members are scoped to the original tab. The move is recorded
+// in both tabs' activity; the actor only counts as such on their own tab.
+func (r *tabRepository) MoveBill(fromTabID uint, billID uint, toTabID uint, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		to, err := lockOpenTab(tx, toTabID)
+		if err != nil {
+			return err
+		}
+		result := tx.Model(&models.Bill{}).Where("id = ? AND tab_id = ?", billID, fromTabID).Updates(map[string]interface{}{
+			"tab_id":             toTabID,
+			"added_by_member_id": nil,
+			"tab_period":         openPeriod(toTabID),
+			"version":            gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		ref, err := events.PublicRef(tx, &models.Bill{}, billID)
+		if err != nil {
+			return err
+		}
+		if err := events.Record(tx, fromTabID, events.BillRemoved{BillID: ref}); err != nil {
+			return err
+		}
+		if err := events.Record(tx, toTabID, events.BillAdded{BillID: ref}); err != nil {
+			return err
+		}
+		fromRef, err := events.PublicRef(tx, &models.Tab{}, fromTabID)
+		if err != nil {
+			return err
+		}
+		for _, tabID := range []uint{fromTabID, toTabID} {
+			err := audit.Record(tx, audit.Entry{
+				TabID:  tabID,
+				Actor:  actor,
+				Action: "bill.moved",
+				Target: "bill:" + string(ref),
+				Before: gin.H{"tab_id": fromRef},
+				After:  gin.H{"tab_id": to.PublicID},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
+// Finalize locks the tab, plans its settlement round from the locked tab,
+// creates the round and derives its paid state from payments already made on
+// the tab, in a single transaction. Bills are added under the same lock, so
+// none can land on the tab between the plan and the commit. Concurrent
+// requests create one round between them; the others fail with
+// ErrAlreadyFinalized. With ifVersion set, the tab must still be at that
+// version.
+func (r *tabRepository) Finalize(id uint, idempotencyKey string, ifVersion *uint, plan FinalizePlan, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Tab{}, id).Error; err != nil {
+			return err
+		}
+		tab := &models.Tab{}
+		if err := preload(tx).First(tab, id).Error; err != nil {
+			return err
+		}
+		if tab.Finalized {
+			return ErrAlreadyFinalized
+		}
+		if err := events.RequireVersion(tx, &models.Tab{}, id, ifVersion); err != nil {
+			return err
+		}
+		settlements, err := plan(tab)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		err = tx.Model(&models.Tab{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"finalized":    true,
+			"finalized_at": now,
+			"finalize_key": idempotencyKey,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if len(settlements) > 0 {
+			if err := tx.Create(&settlements).Error; err != nil {
+				return err
+			}
+			if err := payments.SyncSettlements(tx, id); err != nil {
+				return err
+			}
+		}
+		if err := events.Record(tx, id, events.TabFinalized{FinalizedAt: now}); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  id,
+			Actor:  actor,
+			Action: "tab.finalized",
+			Before: gin.H{"finalized": false},
+			After:  gin.H{"finalized": true, "settlements": len(settlements)},
+		})
+	})
+}
+
+// GetSettlements returns the current round of settlements, excluding rounds
+// superseded by a reopen.
 func (r *tabRepository) GetSettlements(tabID uint) ([]models.TabSettlement, error) {
 	var settlements []models.TabSettlement
-	err := r.db.Where("tab_id = ?", tabID).Order("amount DESC").Find(&settlements).Error
+	err := r.db.Where("tab_id = ? AND superseded_at IS NULL", tabID).Order("amount DESC").Find(&settlements).Error
+	return settlements, err
+}
+
+// GetSettlementHistory returns every settlement round, newest first.
+func (r *tabRepository) GetSettlementHistory(tabID uint) ([]models.TabSettlement, error) {
+	var settlements []models.TabSettlement
+	err := r.db.Where("tab_id = ?", tabID).Order("round DESC, amount DESC").Find(&settlements).Error
 	return settlements, err
 }
 
-func (r *tabRepository) CreateSettlements(settlements []models.TabSettlement) error {
-	return r.db.Create(&settlements).Error
+// Reopen clears the finalized flag and archives the current settlement round
+// in a single transaction. Fails with ErrNotFinalized unless the tab is
+// finalized, so concurrent reopens archive the round only once.
+func (r *tabRepository) Reopen(id uint, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Tab{}).Where("id = ? AND finalized", id).Updates(map[string]interface{}{
+			"finalized":    false,
+			"finalized_at": nil,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFinalized
+		}
+		err := tx.Model(&models.TabSettlement{}).
+			Where("tab_id = ? AND superseded_at IS NULL", id).
+			Update("superseded_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		if err := events.Record(tx, id, events.TabReopened{}); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  id,
+			Actor:  actor,
+			Action: "tab.reopened",
+			Before: gin.H{"finalized": true},
+			After:  gin.H{"finalized": false},
+		})
+	})
+}
+
+// ClosePeriod replaces the current settlements with the closed period's,
+// derives their paid state from payments already made on the tab and opens
+// the next period, in a single transaction. The settlements are planned
+// under the tab's row lock.
+func (r *tabRepository) ClosePeriod(tabID uint, plan ClosePlan, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Tab{}, tabID).Error; err != nil {
+			return err
+		}
+		tab := &models.Tab{}
+		if err := preload(tx).First(tab, tabID).Error; err != nil {
+			return err
+		}
+		var previous []models.TabSettlement
+		if err := tx.Where("tab_id = ? AND superseded_at IS NULL", tabID).Find(&previous).Error; err != nil {
+			return err
+		}
+		settlements, err := plan(tab, previous)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		period := tab.CurrentPeriod
+		err = tx.Model(&models.Tab{}).Where("id = ?", tabID).Update("current_period", period+1).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.TabPeriod{}).Where("tab_id = ? AND number = ?", tabID, period).Update("closed_at", now).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&models.TabPeriod{TabID: tabID, Number: period + 1, StartedAt: now}).Error; err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.TabSettlement{}).
+			Where("tab_id = ? AND superseded_at IS NULL", tabID).
+			Update("superseded_at", now).Error
+		if err != nil {
+			return err
+		}
+		if len(settlements) > 0 {
+			if err := tx.Create(&settlements).Error; err != nil {
+				return err
+			}
+			if err := payments.SyncSettlements(tx, tabID); err != nil {
+				return err
+			}
+		}
+		if err := events.Record(tx, tabID, events.PeriodClosed{Period: period, Settlements: len(settlements)}); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  tabID,
+			Actor:  actor,
+			Action: "tab.period_closed",
+			Before: gin.H{"current_period": period},
+			After:  gin.H{"current_period": period + 1, "settlements": len(settlements)},
+		})
+	})
+}
+
+// openPeriod is the period a bill joins when added to the tab: the open one
+// on recurring tabs, 0 on the rest.
+func openPeriod(tabID uint) clause.Expr {
+	return gorm.Expr("(SELECT current_period FROM tabs WHERE id = ?)", tabID)
 }
 
-func (r *tabRepository) UpdateSettlementPaid(id uint, paid bool) error {
-	return r.db.Model(&models.TabSettlement{}).Where("id = ?", id).Update("paid", paid).Error
+// MarkSettlement records, confirms, disputes or voids the settlement payer's
+// payments as action requires; its derived paid state follows. With ifVersion
+// set, the tab must still be at that version.
+func (r *tabRepository) MarkSettlement(tabID uint, id uint, action payments.Action, reason string, ifVersion *uint, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := events.RequireVersion(tx, &models.Tab{}, tabID, ifVersion); err != nil {
+			return err
+		}
+		settlement := &models.TabSettlement{}
+		if err := tx.First(settlement, id).Error; err != nil {
+			return err
+		}
+		if err := payments.Apply(tx, payments.Target{SettlementID: id}, action, reason); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  tabID,
+			Actor:  actor,
+			Action: "settlement." + string(action),
+			Target: "settlement:" + settlement.PublicID,
+			Before: gin.H{"person_name": settlement.PersonName, "status": settlement.Status, "amount_paid": settlement.AmountPaid},
+			After:  gin.H{"person_name": settlement.PersonName, "reason": reason},
+		})
+	})
 }
 
+// CreateMember adds a member who joined the tab, recording them as the actor.
 func (r *tabRepository) CreateMember(member *models.TabMember) error {
-	return r.db.Create(member).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := createMember(tx, member); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  member.TabID,
+			Actor:  member,
+			Action: "member.joined",
+			Target: "member:" + member.PublicID,
+			After:  gin.H{"display_name": member.DisplayName, "role": member.Role},
+		})
+	})
+}
+
+func createMember(tx *gorm.DB, member *models.TabMember) error {
+	if err := tx.Create(member).Error; err != nil {
+		return err
+	}
+	return events.Record(tx, member.TabID, events.MemberJoined{
+		MemberID:    security.Ref(member.PublicID),
+		DisplayName: member.DisplayName,
+		Role:        member.Role,
+	})
 }
 
 func (r *tabRepository) GetMemberByToken(token string) (*models.TabMember, error) {
@@ -105,6 +535,59 @@ func (r *tabRepository) GetMembersByTabID(tabID uint) ([]models.TabMember, error
 	return members, err
 }
 
+func (r *tabRepository) CreateAlias(alias *models.TabMemberAlias, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(alias).Error; err != nil {
+			return err
+		}
+		memberRef, err := events.PublicRef(tx, &models.TabMember{}, alias.MemberID)
+		if err != nil {
+			return err
+		}
+		err = events.Record(tx, alias.TabID, events.MemberAliasAdded{AliasID: security.Ref(alias.PublicID), MemberID: memberRef, Name: alias.Name})
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  alias.TabID,
+			Actor:  actor,
+			Action: "member.alias_added",
+			Target: "alias:" + alias.PublicID,
+			After:  gin.H{"member_id": memberRef, "name": alias.Name},
+		})
+	})
+}
+
+func (r *tabRepository) DeleteAlias(tabID uint, aliasID uint, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		alias := &models.TabMemberAlias{}
+		if err := tx.Where("id = ? AND tab_id = ?", aliasID, tabID).First(alias).Error; err != nil {
+			return err
+		}
+		memberRef, err := events.PublicRef(tx, &models.TabMember{}, alias.MemberID)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("id = ? AND tab_id = ?", aliasID, tabID).Delete(&models.TabMemberAlias{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := events.Record(tx, tabID, events.MemberAliasRemoved{AliasID: security.Ref(alias.PublicID)}); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  tabID,
+			Actor:  actor,
+			Action: "member.alias_removed",
+			Target: "alias:" + alias.PublicID,
+			Before: gin.H{"member_id": memberRef, "name": alias.Name},
+		})
+	})
+}
+
 func NewTabRepository(db *gorm.DB) TabRepository {
 	return &tabRepository{db: db}
 }
@@ -1,31 +1,86 @@
 package tab
 
 import (
+	"backend/internal/payments"
 	"backend/pkg/models"
 	"backend/pkg/security"
+	"crypto/subtle"
 	"errors"
 	"fmt"
-	"strings"
+	"math"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTabFinalized      = errors.New("tab is finalized")
+	ErrBillNotInTab      = errors.New("bill not found on this tab")
+	ErrNotBillOwner      = errors.New("only the tab creator or the member who added the bill can change it")
+	ErrBillTokenMismatch = errors.New("bill token mismatch")
+	ErrBillInAnotherTab  = errors.New("bill already belongs to another tab")
+	ErrMemberNotFound    = errors.New("member not found on this tab")
+	ErrNotAliasOwner     = errors.New("only the tab creator or the member themself can change member aliases")
+	ErrAliasTaken        = errors.New("name is already linked to another member")
+	ErrRecurringTab      = errors.New("recurring tabs close periods instead of finalizing")
+	ErrNotRecurring      = errors.New("tab is not recurring")
+	ErrEmptyPeriod       = errors.New("period has no bills")
+	ErrBillPeriodClosed  = errors.New("bill belongs to a closed period")
+	ErrAlreadyFinalized  = errors.New("tab is already finalized")
+	ErrNotFinalized      = errors.New("tab is not finalized")
+	ErrNoBills           = errors.New("tab has no bills")
 )
 
+// FinalizePreview is what finalizing a tab would do: the settlements it
+// would create and the issues found. Any blocker stops finalization;
+// warnings don't.
+type FinalizePreview struct {
+	Settlements []models.TabSettlement `json:"settlements"`
+	Blockers    []Issue                `json:"blockers"`
+	Warnings    []Issue                `json:"warnings"`
+}
+
+// Issue is a problem found while planning finalization.
+type Issue struct {
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Count   int      `json:"count,omitempty"`
+	Names   []string `json:"names,omitempty"`
+	err     error
+}
+
+func (p *FinalizePreview) block(code string, err error) *Issue {
+	p.Blockers = append(p.Blockers, Issue{Code: code, Message: err.Error(), err: err})
+	return &p.Blockers[len(p.Blockers)-1]
+}
+
 // ImageQuerier provides read access to tab images without importing the image package.
 type ImageQuerier interface {
 	GetByTabID(tabID uint) ([]models.TabImage, error)
 }
 
 type TabService interface {
-	CreateTab(tab *models.Tab) error
+	CreateTab(tab *models.Tab, creatorName string) (*models.TabMember, error)
 	GetTab(id uint) (tab *models.Tab, err error)
-	UpdateTab(tab *models.Tab) error
-	AddBillToTab(tabID uint, billID uint, memberID *uint) error
-	FinalizeTab(id uint) ([]models.TabSettlement, error)
+	GetTabByRef(ref string) (tab *models.Tab, err error)
+	UpdateTab(tab *models.Tab, ifVersion *uint, actor *models.TabMember) error
+	AddBillToTab(tabID uint, billRef string, billToken string, member *models.TabMember, move bool) error
+	RemoveBillFromTab(tabID uint, billRef string, member *models.TabMember) error
+	MoveBill(fromTabID uint, billRef string, toTabID uint, member *models.TabMember) error
+	FinalizeTab(id uint, idempotencyKey string, ifVersion *uint, actor *models.TabMember) ([]models.TabSettlement, bool, error)
+	PreviewFinalize(id uint) (*FinalizePreview, error)
+	ReopenTab(id uint, actor *models.TabMember) error
+	ClosePeriod(id uint, actor *models.TabMember) ([]models.TabSettlement, error)
+	GetBalances(tabID uint) ([]models.TabBalance, error)
 	GetSettlements(tabID uint) ([]models.TabSettlement, error)
-	UpdateSettlementPaid(id uint, paid bool) error
+	GetSettlementHistory(tabID uint) ([]models.TabSettlement, error)
+	GetSettlementByRef(tabID uint, ref string) (*models.TabSettlement, error)
+	MarkSettlement(tabID uint, id uint, action payments.Action, reason string, ifVersion *uint, actor *models.TabMember) error
 	JoinTab(tabID uint, displayName string) (*models.TabMember, error)
-	JoinTabAsCreator(tabID uint, displayName string) (*models.TabMember, error)
 	GetMemberByToken(token string) (*models.TabMember, error)
 	GetMembers(tabID uint) ([]models.TabMember, error)
+	AddMemberAlias(tabID uint, memberRef string, name string, actor *models.TabMember) (*models.TabMemberAlias, error)
+	RemoveMemberAlias(tabID uint, aliasID uint, actor *models.TabMember) error
 }
 
 type tabService struct {
@@ -33,8 +88,25 @@ type tabService struct {
 	imgQuerier ImageQuerier
 }
 
-func (s *tabService) CreateTab(tab *models.Tab) error {
-	return s.repo.Create(tab)
+// CreateTab creates the tab; recurring tabs start with their first period
+// open. Given a creator name, the creator joins it in the same step and is
+// returned.
+func (s *tabService) CreateTab(tab *models.Tab, creatorName string) (*models.TabMember, error) {
+	if tab.Recurring {
+		tab.CurrentPeriod = 1
+		tab.Periods = []models.TabPeriod{{Number: 1, StartedAt: time.Now()}}
+	}
+	var creator *models.TabMember
+	if creatorName != "" {
+		var err error
+		if creator, err = newMember(0, creatorName, "creator"); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.Create(tab, creator); err != nil {
+		return nil, err
+	}
+	return creator, nil
 }
 
 func (s *tabService) GetTab(id uint) (tab *models.Tab, err error) {
@@ -42,120 +114,458 @@ func (s *tabService) GetTab(id uint) (tab *models.Tab, err error) {
 	if err != nil {
 		return nil, err
 	}
+	return withTotals(tab), nil
+}
+
+// GetTabByRef looks a tab up by public ID, or by numeric ID for legacy links.
+func (s *tabService) GetTabByRef(ref string) (tab *models.Tab, err error) {
+	id, publicID, err := security.ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if publicID == "" {
+		return s.GetTab(id)
+	}
+	tab, err = s.repo.GetByPublicID(publicID)
+	if err != nil {
+		return nil, err
+	}
+	return withTotals(tab), nil
+}
+
+func withTotals(tab *models.Tab) *models.Tab {
 	// Recalculate total from bills and strip bill access tokens
 	var total float64
+	periods := make(map[int]*models.TabPeriod, len(tab.Periods))
+	for i := range tab.Periods {
+		periods[tab.Periods[i].Number] = &tab.Periods[i]
+	}
 	for i := range tab.Bills {
 		total += tab.Bills[i].Total
 		tab.Bills[i].AccessToken = ""
+		if p, ok := periods[tab.Bills[i].TabPeriod]; ok {
+			p.Total += tab.Bills[i].Total
+			p.BillCount++
+		}
 	}
 	tab.TotalAmount = total
-	return tab, nil
+	return tab
+}
+
+// UpdateTab saves the tab's name and description. With ifVersion set, it
+// fails with etag.ErrChanged unless the tab is still at that version.
+func (s *tabService) UpdateTab(tab *models.Tab, ifVersion *uint, actor *models.TabMember) error {
+	return s.repo.Update(tab, ifVersion, actor)
+}
+
+// AddBillToTab attaches a bill to a tab. The caller must prove ownership of the
+// bill with its access token, and a bill already on another tab is only taken
+// over when move is set and that tab is not finalized. The bill is attributed
+// to member if they belong to the tab.
+func (s *tabService) AddBillToTab(tabID uint, billRef string, billToken string, member *models.TabMember, move bool) error {
+	billID, publicID, err := security.ParseRef(billRef)
+	if err != nil {
+		return err
+	}
+	var bill *models.Bill
+	if publicID != "" {
+		bill, err = s.repo.GetBillByPublicID(publicID)
+	} else {
+		bill, err = s.repo.GetBill(billID)
+	}
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(billToken), []byte(bill.AccessToken)) != 1 {
+		return ErrBillTokenMismatch
+	}
+	if bill.TabID != nil && *bill.TabID != tabID {
+		if !move {
+			return ErrBillInAnotherTab
+		}
+		current, err := s.repo.GetById(*bill.TabID)
+		if err != nil {
+			return err
+		}
+		if current.Finalized {
+			return ErrTabFinalized
+		}
+		if inClosedPeriod(current, bill) {
+			return ErrBillPeriodClosed
+		}
+	}
+	if member != nil && member.TabID != tabID {
+		member = nil
+	}
+	return s.repo.AddBill(tabID, bill.ID, member)
+}
+
+func (s *tabService) RemoveBillFromTab(tabID uint, billRef string, member *models.TabMember) error {
+	tab, err := s.repo.GetById(tabID)
+	if err != nil {
+		return err
+	}
+	bill, err := checkBillChange(tab, billRef, member)
+	if err != nil {
+		return err
+	}
+	return s.repo.RemoveBill(tabID, bill.ID, member)
+}
+
+func (s *tabService) MoveBill(fromTabID uint, billRef string, toTabID uint, member *models.TabMember) error {
+	if fromTabID == toTabID {
+		return errors.New("bill is already on this tab")
+	}
+	from, err := s.repo.GetById(fromTabID)
+	if err != nil {
+		return err
+	}
+	bill, err := checkBillChange(from, billRef, member)
+	if err != nil {
+		return err
+	}
+	to, err := s.repo.GetById(toTabID)
+	if err != nil {
+		return err
+	}
+	if to.Finalized {
+		return ErrTabFinalized
+	}
+	return s.repo.MoveBill(fromTabID, bill.ID, toTabID, member)
 }
 
-func (s *tabService) UpdateTab(tab *models.Tab) error {
-	return s.repo.Update(tab)
+// checkBillChange finds the bill on the tab and verifies the given member may
+// detach it. Tabs without members fall back to access-token authorization alone.
+func checkBillChange(tab *models.Tab, billRef string, member *models.TabMember) (*models.Bill, error) {
+	if tab.Finalized {
+		return nil, ErrTabFinalized
+	}
+	var bill *models.Bill
+	for i := range tab.Bills {
+		if security.MatchesRef(billRef, tab.Bills[i].ID, tab.Bills[i].PublicID) {
+			bill = &tab.Bills[i]
+			break
+		}
+	}
+	if bill == nil {
+		return nil, ErrBillNotInTab
+	}
+	if inClosedPeriod(tab, bill) {
+		return nil, ErrBillPeriodClosed
+	}
+	if len(tab.Members) == 0 {
+		return bill, nil
+	}
+	if member == nil || member.TabID != tab.ID {
+		return nil, ErrNotBillOwner
+	}
+	if member.Role == "creator" {
+		return bill, nil
+	}
+	if bill.AddedByMemberID != nil && *bill.AddedByMemberID == member.ID {
+		return bill, nil
+	}
+	return nil, ErrNotBillOwner
+}
+
+// inClosedPeriod reports whether the bill was settled with an earlier period
+// of a recurring tab, which fixes it to the tab.
+func inClosedPeriod(tab *models.Tab, bill *models.Bill) bool {
+	return tab.Recurring && bill.TabPeriod < tab.CurrentPeriod
+}
+
+// FinalizeTab settles the tab: it creates each person's settlement, as
+// PreviewFinalize plans it, and locks the tab. A request repeating the
+// idempotency key that finalized the tab gets the current settlements back,
+// reported as replayed, instead of an error.
+func (s *tabService) FinalizeTab(id uint, idempotencyKey string, ifVersion *uint, actor *models.TabMember) ([]models.TabSettlement, bool, error) {
+	tab, err := s.GetTab(id)
+	if err != nil {
+		return nil, false, err
+	}
+	if isReplay(tab, idempotencyKey) {
+		settlements, err := s.repo.GetSettlements(id)
+		return settlements, true, err
+	}
+
+	// The plan is made under the tab's lock, so it covers every bill
+	err = s.repo.Finalize(id, idempotencyKey, ifVersion, func(tab *models.Tab) ([]models.TabSettlement, error) {
+		plan, err := s.planFinalize(withTotals(tab))
+		if err != nil {
+			return nil, err
+		}
+		if len(plan.Blockers) > 0 {
+			return nil, plan.Blockers[0].err
+		}
+		return plan.Settlements, nil
+	}, actor)
+	if errors.Is(err, ErrAlreadyFinalized) && idempotencyKey != "" {
+		// A concurrent retry may have finalized it first
+		if tab, err = s.GetTab(id); err != nil {
+			return nil, false, err
+		}
+		if isReplay(tab, idempotencyKey) {
+			settlements, err := s.repo.GetSettlements(id)
+			return settlements, true, err
+		}
+		return nil, false, ErrAlreadyFinalized
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Return the created settlements (now with IDs)
+	settlements, err := s.repo.GetSettlements(id)
+	return settlements, false, err
 }
 
-func (s *tabService) AddBillToTab(tabID uint, billID uint, memberID *uint) error {
-	return s.repo.AddBill(tabID, billID, memberID)
+// isReplay reports whether the tab was finalized by a request with the same
+// idempotency key.
+func isReplay(tab *models.Tab, idempotencyKey string) bool {
+	return idempotencyKey != "" && tab.Finalized && tab.FinalizeKey == idempotencyKey
 }
 
-func (s *tabService) FinalizeTab(id uint) ([]models.TabSettlement, error) {
+// PreviewFinalize returns the settlements finalizing the tab would create,
+// and anything stopping it, without changing anything.
+func (s *tabService) PreviewFinalize(id uint) (*FinalizePreview, error) {
 	tab, err := s.GetTab(id)
 	if err != nil {
 		return nil, err
 	}
+	return s.planFinalize(tab)
+}
+
+// planFinalize computes the tab's settlements and checks it can be finalized.
+func (s *tabService) planFinalize(tab *models.Tab) (*FinalizePreview, error) {
+	plan := &FinalizePreview{Settlements: []models.TabSettlement{}, Blockers: []Issue{}, Warnings: []Issue{}}
 
+	if tab.Recurring {
+		plan.block("recurring_tab", ErrRecurringTab)
+	}
 	if tab.Finalized {
-		return nil, errors.New("tab is already finalized")
+		plan.block("already_finalized", ErrAlreadyFinalized)
 	}
-
 	if len(tab.Bills) == 0 {
-		return nil, errors.New("tab has no bills")
+		plan.block("no_bills", ErrNoBills)
 	}
 
-	// Check all images are processed
-	images, err := s.imgQuerier.GetByTabID(id)
+	unprocessed, err := s.unprocessedImages(tab.ID)
 	if err != nil {
 		return nil, err
 	}
-	for _, img := range images {
-		if !img.Processed {
-			return nil, errors.New("all images must be marked as processed before finalizing")
-		}
+	if unprocessed > 0 {
+		plan.block("unprocessed_images", imagesUnprocessed("finalizing")).Count = unprocessed
 	}
 
 	// Compute per-person totals from bill person_shares
-	personTotals := make(map[string]float64)
-	personDisplayNames := make(map[string]string) // preserve original casing
+	totals := aggregateShares(tab)
+
+	// If the tab was reopened, start a new round. Payments made in earlier
+	// rounds still count: the repository derives each settlement's paid
+	// state from the payer's payments on the tab.
+	history, err := s.repo.GetSettlementHistory(tab.ID)
+	if err != nil {
+		return nil, err
+	}
+	round := 1
+	if len(history) > 0 {
+		round = history[0].Round + 1
+	}
+
+	var unmapped []string
+	for _, total := range totals {
+		plan.Settlements = append(plan.Settlements, models.TabSettlement{
+			TabID:      tab.ID,
+			MemberID:   total.MemberID,
+			PersonName: total.Name,
+			Amount:     total.Amount,
+			Round:      round,
+		})
+		if total.MemberID == nil {
+			unmapped = append(unmapped, total.Name)
+		}
+	}
+	// Names that match no member are settled under the name alone, which
+	// is usually a typo or a missing alias once people have joined
+	if len(tab.Members) > 0 && len(unmapped) > 0 {
+		plan.Warnings = append(plan.Warnings, Issue{
+			Code:    "unmapped_names",
+			Message: "some names on bills match no member; add aliases to link them",
+			Names:   unmapped,
+		})
+	}
+	return plan, nil
+}
+
+// ClosePeriod settles a recurring tab's open period and opens the next one.
+// The new settlements replace the previous period's: each covers the
+// person's bills since the tab started, so payments towards earlier periods
+// still count and whatever was left unpaid carries over.
+func (s *tabService) ClosePeriod(id uint, actor *models.TabMember) ([]models.TabSettlement, error) {
+	// The plan is made under the tab's lock, so no bill or payment slips past it
+	err := s.repo.ClosePeriod(id, func(tab *models.Tab, previous []models.TabSettlement) ([]models.TabSettlement, error) {
+		return s.planClose(withTotals(tab), previous)
+	}, actor)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetSettlements(id)
+}
+
+// planClose computes the settlements that replace previous when the tab's
+// open period closes.
+func (s *tabService) planClose(tab *models.Tab, previous []models.TabSettlement) ([]models.TabSettlement, error) {
+	if !tab.Recurring {
+		return nil, ErrNotRecurring
+	}
+	period := tab.CurrentPeriod
+
+	var inPeriod []models.Bill
 	for _, bill := range tab.Bills {
-		for _, share := range bill.PersonShares {
-			key := strings.ToLower(share.PersonName)
-			personTotals[key] += share.Total
-			// Prefer a capitalized variant over all-lowercase
-			if existing, ok := personDisplayNames[key]; !ok {
-				personDisplayNames[key] = share.PersonName
-			} else if existing == key && share.PersonName != key {
-				personDisplayNames[key] = share.PersonName
-			}
+		if bill.TabPeriod == period {
+			inPeriod = append(inPeriod, bill)
 		}
 	}
+	if len(inPeriod) == 0 {
+		return nil, ErrEmptyPeriod
+	}
+	if err := s.checkImagesProcessed(tab.ID, "closing a period"); err != nil {
+		return nil, err
+	}
+
+	carried := make(map[string]float64, len(previous))
+	for _, p := range previous {
+		carried[models.PayerKey(p.MemberID, p.PersonName)] = p.Amount - p.AmountPaid
+	}
+	periodTotals := make(map[string]float64)
+	for _, total := range aggregateShares(&models.Tab{Bills: inPeriod, Members: tab.Members, Aliases: tab.Aliases}) {
+		periodTotals[total.Key] = total.Amount
+	}
 
-	// Create settlement records
 	var settlements []models.TabSettlement
-	for key, amount := range personTotals {
+	for _, total := range aggregateShares(tab) {
+		periodAmount := roundCents(periodTotals[total.Key])
+		carriedOver := roundCents(carried[total.Key])
+		if periodAmount == 0 && carriedOver <= 0 {
+			// Settled up and nothing new
+			continue
+		}
 		settlements = append(settlements, models.TabSettlement{
-			TabID:      id,
-			PersonName: personDisplayNames[key],
-			Amount:     amount,
-			Paid:       false,
+			TabID:        tab.ID,
+			MemberID:     total.MemberID,
+			PersonName:   total.Name,
+			Amount:       total.Amount,
+			Round:        period,
+			PeriodAmount: &periodAmount,
+			CarriedOver:  &carriedOver,
 		})
 	}
 
-	if err := s.repo.CreateSettlements(settlements); err != nil {
-		return nil, err
+	return settlements, nil
+}
+
+// checkImagesProcessed fails unless every receipt image on the tab has been
+// processed, so no bill is missed when settling.
+func (s *tabService) checkImagesProcessed(tabID uint, action string) error {
+	unprocessed, err := s.unprocessedImages(tabID)
+	if err != nil {
+		return err
+	}
+	if unprocessed > 0 {
+		return imagesUnprocessed(action)
 	}
+	return nil
+}
 
-	if err := s.repo.Finalize(id); err != nil {
-		return nil, err
+func (s *tabService) unprocessedImages(tabID uint) (int, error) {
+	images, err := s.imgQuerier.GetByTabID(tabID)
+	if err != nil {
+		return 0, err
 	}
+	unprocessed := 0
+	for _, img := range images {
+		if !img.Processed {
+			unprocessed++
+		}
+	}
+	return unprocessed, nil
+}
 
-	// Return the created settlements (now with IDs)
-	return s.repo.GetSettlements(id)
+func imagesUnprocessed(action string) error {
+	return fmt.Errorf("all images must be marked as processed before %s", action)
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// ReopenTab unfinalizes a tab so bills can be changed again. The current
+// settlements are kept as a superseded round rather than deleted.
+func (s *tabService) ReopenTab(id uint, actor *models.TabMember) error {
+	return s.repo.Reopen(id, actor)
+}
+
+// GetBalances returns each person's running balance on the tab, finalized
+// or not.
+func (s *tabService) GetBalances(tabID uint) ([]models.TabBalance, error) {
+	tab, err := s.GetTab(tabID)
+	if err != nil {
+		return nil, err
+	}
+	result := balances(tab)
+	models.NewMemberRefs(tab.Members).Balances(result)
+	return result, nil
 }
 
 func (s *tabService) GetSettlements(tabID uint) ([]models.TabSettlement, error) {
 	return s.repo.GetSettlements(tabID)
 }
 
-func (s *tabService) UpdateSettlementPaid(id uint, paid bool) error {
-	return s.repo.UpdateSettlementPaid(id, paid)
+func (s *tabService) GetSettlementHistory(tabID uint) ([]models.TabSettlement, error) {
+	return s.repo.GetSettlementHistory(tabID)
 }
 
-func (s *tabService) JoinTab(tabID uint, displayName string) (*models.TabMember, error) {
-	return s.createMember(tabID, displayName, "member")
+// GetSettlementByRef finds a current-round settlement on the tab by public or numeric ID.
+func (s *tabService) GetSettlementByRef(tabID uint, ref string) (*models.TabSettlement, error) {
+	settlements, err := s.repo.GetSettlements(tabID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range settlements {
+		if security.MatchesRef(ref, settlements[i].ID, settlements[i].PublicID) {
+			return &settlements[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
 }
 
-func (s *tabService) JoinTabAsCreator(tabID uint, displayName string) (*models.TabMember, error) {
-	return s.createMember(tabID, displayName, "creator")
+func (s *tabService) MarkSettlement(tabID uint, id uint, action payments.Action, reason string, ifVersion *uint, actor *models.TabMember) error {
+	return s.repo.MarkSettlement(tabID, id, action, reason, ifVersion, actor)
 }
 
-func (s *tabService) createMember(tabID uint, displayName string, role string) (*models.TabMember, error) {
+func (s *tabService) JoinTab(tabID uint, displayName string) (*models.TabMember, error) {
+	member, err := newMember(tabID, displayName, "member")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateMember(member); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func newMember(tabID uint, displayName string, role string) (*models.TabMember, error) {
 	memberToken, err := security.GenerateSecureToken()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate member token: %w", err)
 	}
-	member := &models.TabMember{
+	return &models.TabMember{
 		TabID:       tabID,
 		DisplayName: displayName,
 		MemberToken: memberToken,
 		Role:        role,
 		JoinedAt:    time.Now(),
-	}
-	if err := s.repo.CreateMember(member); err != nil {
-		return nil, err
-	}
-	return member, nil
+	}, nil
 }
 
 func (s *tabService) GetMemberByToken(token string) (*models.TabMember, error) {
@@ -166,6 +576,75 @@ func (s *tabService) GetMembers(tabID uint) ([]models.TabMember, error) {
 	return s.repo.GetMembersByTabID(tabID)
 }
 
+// AddMemberAlias links a person name used on the tab's bills to a member so
+// finalization settles both under the member. Linking a name that is already
+// linked to the same member is a no-op.
+func (s *tabService) AddMemberAlias(tabID uint, memberRef string, name string, actor *models.TabMember) (*models.TabMemberAlias, error) {
+	tab, err := s.repo.GetById(tabID)
+	if err != nil {
+		return nil, err
+	}
+	if tab.Finalized {
+		return nil, ErrTabFinalized
+	}
+	var member *models.TabMember
+	for i := range tab.Members {
+		if security.MatchesRef(memberRef, tab.Members[i].ID, tab.Members[i].PublicID) {
+			member = &tab.Members[i]
+			break
+		}
+	}
+	if member == nil {
+		return nil, ErrMemberNotFound
+	}
+	if !canManageAliases(tab, member.ID, actor) {
+		return nil, ErrNotAliasOwner
+	}
+
+	key := normalizeName(name)
+	for i := range tab.Aliases {
+		if tab.Aliases[i].Name == key {
+			if tab.Aliases[i].MemberID == member.ID {
+				return &tab.Aliases[i], nil
+			}
+			return nil, ErrAliasTaken
+		}
+	}
+
+	alias := &models.TabMemberAlias{TabID: tabID, MemberID: member.ID, MemberRef: security.Ref(member.PublicID), Name: key}
+	if err := s.repo.CreateAlias(alias, actor); err != nil {
+		return nil, err
+	}
+	return alias, nil
+}
+
+func (s *tabService) RemoveMemberAlias(tabID uint, aliasID uint, actor *models.TabMember) error {
+	tab, err := s.repo.GetById(tabID)
+	if err != nil {
+		return err
+	}
+	if tab.Finalized {
+		return ErrTabFinalized
+	}
+	for _, alias := range tab.Aliases {
+		if alias.ID == aliasID {
+			if !canManageAliases(tab, alias.MemberID, actor) {
+				return ErrNotAliasOwner
+			}
+			return s.repo.DeleteAlias(tabID, aliasID, actor)
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+// canManageAliases reports whether actor may change the aliases of the given member.
+func canManageAliases(tab *models.Tab, memberID uint, actor *models.TabMember) bool {
+	if actor == nil || actor.TabID != tab.ID {
+		return false
+	}
+	return actor.Role == "creator" || actor.ID == memberID
+}
+
 func NewTabService(repo TabRepository, imgQuerier ImageQuerier) TabService {
 	return &tabService{repo: repo, imgQuerier: imgQuerier}
 }
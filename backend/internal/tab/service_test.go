@@ -1,18 +1,25 @@
 package tab
 
 import (
+	"backend/internal/payments"
+	"backend/pkg/etag"
 	"backend/pkg/models"
+	"backend/pkg/security"
 	"errors"
 	"testing"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // ── Mock TabRepository ──────────────────────────────────────────
 
 type mockTabRepository struct {
 	tabs        map[uint]*models.Tab
+	bills       map[uint]*models.Bill
 	members     []models.TabMember
 	settlements []models.TabSettlement
+	history     []models.TabSettlement
 
 	createErr            error
 	getByIdErr           error
@@ -21,7 +28,6 @@ type mockTabRepository struct {
 	addBillErr           error
 	finalizeErr          error
 	getSettlementsErr    error
-	createSettlementsErr error
 	updatePaidErr        error
 	createMemberErr      error
 	getMemberByTokenErr  error
@@ -30,23 +36,38 @@ type mockTabRepository struct {
 	// Capture calls
 	addBillTabID    uint
 	addBillBillID   uint
-	addBillMemberID *uint
+	addBillMember   *models.TabMember
+	removedBillID   uint
+	movedBillID     uint
+	movedToTabID    uint
 	finalizedID     uint
+	reopenedID      uint
 	createdSettlements []models.TabSettlement
+	createdAlias       *models.TabMemberAlias
+	deletedAliasID     uint
+	closedPeriod       int
+
+	concurrentFinalizeKey string
+	addedBeforeLock       *models.Bill
 }
 
 func newMockRepo() *mockTabRepository {
 	return &mockTabRepository{
-		tabs: make(map[uint]*models.Tab),
+		tabs:  make(map[uint]*models.Tab),
+		bills: make(map[uint]*models.Bill),
 	}
 }
 
-func (m *mockTabRepository) Create(tab *models.Tab) error {
+func (m *mockTabRepository) Create(tab *models.Tab, creator *models.TabMember) error {
 	if m.createErr != nil {
 		return m.createErr
 	}
 	tab.ID = uint(len(m.tabs) + 1)
 	m.tabs[tab.ID] = tab
+	if creator != nil {
+		creator.TabID = tab.ID
+		return m.CreateMember(creator)
+	}
 	return nil
 }
 
@@ -61,19 +82,88 @@ func (m *mockTabRepository) GetById(id uint) (*models.Tab, error) {
 	return tab, nil
 }
 
-func (m *mockTabRepository) Update(tab *models.Tab) error  { return m.updateErr }
+func (m *mockTabRepository) GetByPublicID(publicID string) (*models.Tab, error) {
+	for _, tab := range m.tabs {
+		if tab.PublicID == publicID {
+			return tab, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (m *mockTabRepository) Update(tab *models.Tab, ifVersion *uint, actor *models.TabMember) error { return m.updateErr }
 func (m *mockTabRepository) Delete(id uint) error          { return m.deleteErr }
 
-func (m *mockTabRepository) AddBill(tabID uint, billID uint, memberID *uint) error {
+func (m *mockTabRepository) GetBill(billID uint) (*models.Bill, error) {
+	bill, ok := m.bills[billID]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return bill, nil
+}
+
+func (m *mockTabRepository) GetBillByPublicID(publicID string) (*models.Bill, error) {
+	for _, bill := range m.bills {
+		if bill.PublicID == publicID {
+			return bill, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (m *mockTabRepository) AddBill(tabID uint, billID uint, member *models.TabMember) error {
 	m.addBillTabID = tabID
 	m.addBillBillID = billID
-	m.addBillMemberID = memberID
+	m.addBillMember = member
 	return m.addBillErr
 }
 
-func (m *mockTabRepository) Finalize(id uint) error {
+func (m *mockTabRepository) RemoveBill(tabID uint, billID uint, actor *models.TabMember) error {
+	m.removedBillID = billID
+	return nil
+}
+
+func (m *mockTabRepository) MoveBill(fromTabID uint, billID uint, toTabID uint, actor *models.TabMember) error {
+	m.movedBillID = billID
+	m.movedToTabID = toTabID
+	return nil
+}
+
+func (m *mockTabRepository) Finalize(id uint, idempotencyKey string, ifVersion *uint, plan FinalizePlan, actor *models.TabMember) error {
+	if m.finalizeErr != nil {
+		return m.finalizeErr
+	}
+	if m.concurrentFinalizeKey != "" {
+		// Another request finalized the tab first
+		m.tabs[id].Finalized = true
+		m.tabs[id].FinalizeKey = m.concurrentFinalizeKey
+		return ErrAlreadyFinalized
+	}
+	tab, ok := m.tabs[id]
+	if !ok {
+		return errors.New("record not found")
+	}
+	if m.addedBeforeLock != nil {
+		// Another request added a bill before the lock was taken
+		tab.Bills = append(tab.Bills, *m.addedBeforeLock)
+	}
+	if tab.Finalized {
+		return ErrAlreadyFinalized
+	}
+	if ifVersion != nil && *ifVersion != tab.Version {
+		return etag.ErrChanged
+	}
+	settlements, err := plan(tab)
+	if err != nil {
+		return err
+	}
 	m.finalizedID = id
-	return m.finalizeErr
+	m.createdSettlements = settlements
+	// Copy to settlements so GetSettlements returns them
+	m.settlements = settlements
+	tab.Finalized = true
+	tab.FinalizeKey = idempotencyKey
+	return nil
 }
 
 func (m *mockTabRepository) GetSettlements(tabID uint) ([]models.TabSettlement, error) {
@@ -83,17 +173,41 @@ func (m *mockTabRepository) GetSettlements(tabID uint) ([]models.TabSettlement,
 	return m.settlements, nil
 }
 
-func (m *mockTabRepository) CreateSettlements(settlements []models.TabSettlement) error {
-	if m.createSettlementsErr != nil {
-		return m.createSettlementsErr
+func (m *mockTabRepository) GetSettlementHistory(tabID uint) ([]models.TabSettlement, error) {
+	return m.history, nil
+}
+
+func (m *mockTabRepository) Reopen(id uint, actor *models.TabMember) error {
+	tab, ok := m.tabs[id]
+	if !ok || !tab.Finalized {
+		return ErrNotFinalized
+	}
+	tab.Finalized = false
+	m.reopenedID = id
+	return nil
+}
+
+func (m *mockTabRepository) ClosePeriod(tabID uint, plan ClosePlan, actor *models.TabMember) error {
+	tab, ok := m.tabs[tabID]
+	if !ok {
+		return errors.New("record not found")
+	}
+	if m.addedBeforeLock != nil {
+		// Another request added a bill before the lock was taken
+		tab.Bills = append(tab.Bills, *m.addedBeforeLock)
+	}
+	settlements, err := plan(tab, m.settlements)
+	if err != nil {
+		return err
 	}
+	m.closedPeriod = tab.CurrentPeriod
 	m.createdSettlements = settlements
-	// Copy to settlements so GetSettlements returns them
 	m.settlements = settlements
+	tab.CurrentPeriod++
 	return nil
 }
 
-func (m *mockTabRepository) UpdateSettlementPaid(id uint, paid bool) error {
+func (m *mockTabRepository) MarkSettlement(tabID uint, id uint, action payments.Action, reason string, ifVersion *uint, actor *models.TabMember) error {
 	return m.updatePaidErr
 }
 
@@ -131,6 +245,17 @@ func (m *mockTabRepository) GetMembersByTabID(tabID uint) ([]models.TabMember, e
 	return result, nil
 }
 
+func (m *mockTabRepository) CreateAlias(alias *models.TabMemberAlias, actor *models.TabMember) error {
+	alias.ID = 1
+	m.createdAlias = alias
+	return nil
+}
+
+func (m *mockTabRepository) DeleteAlias(tabID uint, aliasID uint, actor *models.TabMember) error {
+	m.deletedAliasID = aliasID
+	return nil
+}
+
 // ── Mock ImageQuerier ───────────────────────────────────────────
 
 type mockImageQuerier struct {
@@ -170,7 +295,7 @@ func TestFinalizeTab_Success(t *testing.T) {
 	}
 
 	svc := NewTabService(repo, imgQ)
-	settlements, err := svc.FinalizeTab(1)
+	settlements, _, err := svc.FinalizeTab(1, "", nil, nil)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -198,6 +323,50 @@ func TestFinalizeTab_Success(t *testing.T) {
 	}
 }
 
+func TestFinalizeTab_PlansUnderLock(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{
+		ID:    1,
+		Bills: []models.Bill{{ID: 1, Total: 60, PersonShares: []models.PersonShare{{PersonName: "Alice", Total: 60}}}},
+	}
+	// Added after the tab was first read, before the finalize locked it
+	repo.addedBeforeLock = &models.Bill{ID: 2, Total: 40, PersonShares: []models.PersonShare{{PersonName: "Bob", Total: 40}}}
+
+	settlements, _, err := NewTabService(repo, &mockImageQuerier{}).FinalizeTab(1, "", nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	totals := make(map[string]float64)
+	for _, s := range settlements {
+		totals[s.PersonName] = s.Amount
+	}
+	if totals["Alice"] != 60 || totals["Bob"] != 40 {
+		t.Errorf("expected settlements for both bills, got %v", totals)
+	}
+}
+
+func TestFinalizeTab_StaleVersion(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{
+		ID:      1,
+		Version: 4,
+		Bills:   []models.Bill{{ID: 1, Total: 60, PersonShares: []models.PersonShare{{PersonName: "Alice", Total: 60}}}},
+	}
+	svc := NewTabService(repo, &mockImageQuerier{})
+
+	stale := uint(3)
+	if _, _, err := svc.FinalizeTab(1, "", &stale, nil); !errors.Is(err, etag.ErrChanged) {
+		t.Fatalf("expected etag.ErrChanged, got %v", err)
+	}
+	if repo.tabs[1].Finalized || repo.createdSettlements != nil {
+		t.Error("expected nothing written for a stale version")
+	}
+	current := uint(4)
+	if _, _, err := svc.FinalizeTab(1, "", &current, nil); err != nil {
+		t.Fatalf("expected no error at the current version, got %v", err)
+	}
+}
+
 func TestFinalizeTab_AlreadyFinalized(t *testing.T) {
 	repo := newMockRepo()
 	imgQ := &mockImageQuerier{}
@@ -209,7 +378,7 @@ func TestFinalizeTab_AlreadyFinalized(t *testing.T) {
 	}
 
 	svc := NewTabService(repo, imgQ)
-	_, err := svc.FinalizeTab(1)
+	_, _, err := svc.FinalizeTab(1, "", nil, nil)
 	if err == nil {
 		t.Fatal("expected error for already finalized tab")
 	}
@@ -229,7 +398,7 @@ func TestFinalizeTab_NoBills(t *testing.T) {
 	}
 
 	svc := NewTabService(repo, imgQ)
-	_, err := svc.FinalizeTab(1)
+	_, _, err := svc.FinalizeTab(1, "", nil, nil)
 	if err == nil {
 		t.Fatal("expected error for tab with no bills")
 	}
@@ -254,7 +423,7 @@ func TestFinalizeTab_UnprocessedImages(t *testing.T) {
 	}
 
 	svc := NewTabService(repo, imgQ)
-	_, err := svc.FinalizeTab(1)
+	_, _, err := svc.FinalizeTab(1, "", nil, nil)
 	if err == nil {
 		t.Fatal("expected error for unprocessed images")
 	}
@@ -263,6 +432,659 @@ func TestFinalizeTab_UnprocessedImages(t *testing.T) {
 	}
 }
 
+func TestFinalizeTab_StartsNewRoundWithoutPaidFlags(t *testing.T) {
+	repo := newMockRepo()
+	imgQ := &mockImageQuerier{}
+
+	repo.tabs[1] = &models.Tab{
+		ID: 1,
+		Bills: []models.Bill{
+			{
+				ID: 1, Total: 100,
+				PersonShares: []models.PersonShare{
+					{PersonName: "Alice", Total: 60},
+					{PersonName: "Bob", Total: 40},
+				},
+			},
+		},
+	}
+	superseded := time.Now()
+	repo.history = []models.TabSettlement{
+		{TabID: 1, PersonName: "alice", Amount: 60, PaymentState: models.PaymentState{AmountPaid: 60, Paid: true}, Round: 2, SupersededAt: &superseded},
+		{TabID: 1, PersonName: "Bob", Amount: 35, PaymentState: models.PaymentState{AmountPaid: 35, Paid: true}, Round: 2, SupersededAt: &superseded},
+	}
+
+	svc := NewTabService(repo, imgQ)
+	settlements, _, err := svc.FinalizeTab(1, "", nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Paid state is derived from payments by the repository, not copied
+	// from the previous round
+	for _, s := range settlements {
+		if s.Round != 3 {
+			t.Errorf("expected round 3 for %s, got %d", s.PersonName, s.Round)
+		}
+		if s.Paid || s.AmountPaid != 0 {
+			t.Errorf("expected %s to be created unpaid, got paid=%v amount_paid=%.2f", s.PersonName, s.Paid, s.AmountPaid)
+		}
+	}
+}
+
+func TestFinalizeTab_KeysSettlementsByMember(t *testing.T) {
+	repo := newMockRepo()
+	imgQ := &mockImageQuerier{}
+
+	jonID := uint(7)
+	repo.tabs[1] = &models.Tab{
+		ID: 1,
+		Members: []models.TabMember{
+			{ID: 7, TabID: 1, DisplayName: "Jonathan"},
+			{ID: 8, TabID: 1, DisplayName: "Priya"},
+		},
+		Aliases: []models.TabMemberAlias{
+			{ID: 1, TabID: 1, MemberID: 7, Name: "jon"},
+		},
+		Bills: []models.Bill{
+			{
+				ID: 1,
+				PersonShares: []models.PersonShare{
+					{PersonName: "Jon", Total: 20},
+					{PersonName: "priya", Total: 15},
+				},
+			},
+			{
+				ID: 2,
+				PersonShares: []models.PersonShare{
+					{PersonName: "J", MemberID: &jonID, Total: 10},
+					{PersonName: "Jonathan", Total: 5},
+					{PersonName: "Sam", Total: 12},
+				},
+			},
+		},
+	}
+
+	svc := NewTabService(repo, imgQ)
+	settlements, _, err := svc.FinalizeTab(1, "", nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(settlements) != 3 {
+		t.Fatalf("expected 3 settlements, got %d", len(settlements))
+	}
+
+	byName := make(map[string]models.TabSettlement)
+	for _, s := range settlements {
+		byName[s.PersonName] = s
+	}
+
+	jon := byName["Jonathan"]
+	if jon.Amount != 35 {
+		t.Errorf("expected Jonathan total 35, got %f", jon.Amount)
+	}
+	if jon.MemberID == nil || *jon.MemberID != 7 {
+		t.Errorf("expected Jonathan settlement linked to member 7, got %v", jon.MemberID)
+	}
+	priya := byName["Priya"]
+	if priya.Amount != 15 || priya.MemberID == nil || *priya.MemberID != 8 {
+		t.Errorf("expected Priya linked to member 8 with 15, got %+v", priya)
+	}
+	if sam := byName["Sam"]; sam.Amount != 12 || sam.MemberID != nil {
+		t.Errorf("expected unlinked Sam with 12, got %+v", sam)
+	}
+}
+
+func TestGetBalances_MatchesFinalization(t *testing.T) {
+	repo := newMockRepo()
+	aliceID, bobID, carolID := uint(1), uint(2), uint(3)
+	repo.tabs[1] = &models.Tab{
+		ID: 1,
+		Members: []models.TabMember{
+			{ID: aliceID, TabID: 1, DisplayName: "Alice", Role: "creator"},
+			{ID: bobID, PublicID: "bobPublicID00000000000", TabID: 1, DisplayName: "Bob", Role: "member"},
+			{ID: carolID, TabID: 1, DisplayName: "Carol", Role: "member"},
+		},
+		Bills: []models.Bill{
+			{
+				// Added without a member, so the creator paid
+				ID: 1, Total: 90,
+				PersonShares: []models.PersonShare{
+					{PersonName: "alice", Total: 30},
+					{PersonName: "Bob", Total: 30},
+					{PersonName: "Dan", Total: 30},
+				},
+			},
+			{
+				ID: 2, Total: 60, AddedByMemberID: &bobID,
+				PersonShares: []models.PersonShare{
+					{PersonName: "Alice", Total: 20},
+					{PersonName: "Bob", MemberID: &bobID, Total: 40},
+				},
+			},
+			{
+				ID: 3, Total: 15, AddedByMemberID: &carolID,
+				PersonShares: []models.PersonShare{
+					{PersonName: "Dan", Total: 15},
+				},
+			},
+		},
+	}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	balances, err := svc.GetBalances(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := map[string][3]float64{
+		// paid, owed, net
+		"Alice": {90, 50, 40},
+		"Bob":   {60, 70, -10},
+		"Dan":   {0, 45, -45},
+		"Carol": {15, 0, 15},
+	}
+	if len(balances) != len(want) {
+		t.Fatalf("expected %d balances, got %+v", len(want), balances)
+	}
+	var net float64
+	owed := make(map[string]float64)
+	for _, b := range balances {
+		w, ok := want[b.PersonName]
+		if !ok || b.Paid != w[0] || b.Owed != w[1] || b.Net != w[2] {
+			t.Errorf("%s: got paid=%.2f owed=%.2f net=%.2f, want %v", b.PersonName, b.Paid, b.Owed, b.Net, w)
+		}
+		net += b.Net
+		owed[b.PersonName] = b.Owed
+		if b.PersonName == "Bob" && b.MemberRef != "bobPublicID00000000000" {
+			t.Errorf("expected Bob's balance to refer to his public ID, got %q", b.MemberRef)
+		}
+	}
+	if net != 0 {
+		t.Errorf("expected balances to net to zero, got %.2f", net)
+	}
+
+	// The preview owes exactly what finalization settles
+	settlements, _, err := svc.FinalizeTab(1, "", nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, s := range settlements {
+		if owed[s.PersonName] != s.Amount {
+			t.Errorf("%s: balance owes %.2f, settlement %.2f", s.PersonName, owed[s.PersonName], s.Amount)
+		}
+	}
+}
+
+func TestPreviewFinalize_MatchesFinalizeWithoutChanges(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{
+		ID:      1,
+		Members: []models.TabMember{{ID: 1, TabID: 1, DisplayName: "Alice", Role: "creator"}},
+		Bills: []models.Bill{
+			{
+				ID: 1, Total: 100,
+				PersonShares: []models.PersonShare{
+					{PersonName: "alice", Total: 60},
+					{PersonName: "Bobby", Total: 40},
+				},
+			},
+		},
+	}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	preview, err := svc.PreviewFinalize(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.createdSettlements != nil || repo.finalizedID != 0 {
+		t.Fatal("expected the preview to change nothing")
+	}
+	if len(preview.Blockers) != 0 {
+		t.Errorf("expected no blockers, got %+v", preview.Blockers)
+	}
+	if len(preview.Warnings) != 1 || preview.Warnings[0].Code != "unmapped_names" || len(preview.Warnings[0].Names) != 1 || preview.Warnings[0].Names[0] != "Bobby" {
+		t.Errorf("expected Bobby reported as unmapped, got %+v", preview.Warnings)
+	}
+
+	settlements, _, err := svc.FinalizeTab(1, "", nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(settlements) != len(preview.Settlements) {
+		t.Fatalf("expected %d settlements, got %d", len(preview.Settlements), len(settlements))
+	}
+	for i := range settlements {
+		got, want := settlements[i], preview.Settlements[i]
+		if got.PersonName != want.PersonName || got.Amount != want.Amount || got.Round != want.Round {
+			t.Errorf("settlement %d: finalized %+v, previewed %+v", i, got, want)
+		}
+	}
+}
+
+func TestPreviewFinalize_ReportsBlockers(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{ID: 1, Finalized: true}
+	imgQ := &mockImageQuerier{images: []models.TabImage{{ID: 1}, {ID: 2, Processed: true}, {ID: 3}}}
+
+	svc := NewTabService(repo, imgQ)
+	preview, err := svc.PreviewFinalize(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var codes []string
+	for _, b := range preview.Blockers {
+		codes = append(codes, b.Code)
+	}
+	if len(codes) != 3 || codes[0] != "already_finalized" || codes[1] != "no_bills" || codes[2] != "unprocessed_images" {
+		t.Fatalf("unexpected blockers %v", codes)
+	}
+	if preview.Blockers[2].Count != 2 {
+		t.Errorf("expected 2 unprocessed images, got %d", preview.Blockers[2].Count)
+	}
+
+	// Finalizing fails with the first blocker
+	if _, _, err := svc.FinalizeTab(1, "", nil, nil); !errors.Is(err, ErrAlreadyFinalized) {
+		t.Errorf("expected ErrAlreadyFinalized, got %v", err)
+	}
+}
+
+func recurringTab() *models.Tab {
+	return &models.Tab{
+		ID:            1,
+		Recurring:     true,
+		CurrentPeriod: 2,
+		Periods:       []models.TabPeriod{{TabID: 1, Number: 1}, {TabID: 1, Number: 2}},
+		Bills: []models.Bill{
+			{
+				ID: 1, Total: 100, TabPeriod: 1,
+				PersonShares: []models.PersonShare{
+					{PersonName: "Alice", Total: 60},
+					{PersonName: "Bob", Total: 40},
+				},
+			},
+			{
+				ID: 2, Total: 30, TabPeriod: 2,
+				PersonShares: []models.PersonShare{
+					{PersonName: "Alice", Total: 20},
+					{PersonName: "Bob", Total: 10},
+				},
+			},
+		},
+	}
+}
+
+func TestClosePeriod_CarriesOverUnpaidBalances(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = recurringTab()
+	// Alice paid period 1 in full; Bob paid 15 of 40
+	repo.settlements = []models.TabSettlement{
+		{TabID: 1, PersonName: "Alice", Amount: 60, PaymentState: models.PaymentState{AmountPaid: 60, Paid: true}, Round: 1},
+		{TabID: 1, PersonName: "Bob", Amount: 40, PaymentState: models.PaymentState{AmountPaid: 15}, Round: 1},
+	}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	settlements, err := svc.ClosePeriod(1, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.closedPeriod != 2 {
+		t.Errorf("expected period 2 to close, got %d", repo.closedPeriod)
+	}
+	if len(settlements) != 2 {
+		t.Fatalf("expected 2 settlements, got %d", len(settlements))
+	}
+
+	want := map[string][3]float64{
+		// amount (since the tab began), period amount, carried over
+		"Alice": {80, 20, 0},
+		"Bob":   {50, 10, 25},
+	}
+	for _, s := range settlements {
+		w := want[s.PersonName]
+		if s.Amount != w[0] || s.PeriodAmount == nil || *s.PeriodAmount != w[1] || s.CarriedOver == nil || *s.CarriedOver != w[2] {
+			t.Errorf("%s: got amount=%.2f period=%v carried=%v, want %v", s.PersonName, s.Amount, s.PeriodAmount, s.CarriedOver, w)
+		}
+		if s.Round != 2 {
+			t.Errorf("expected round 2 for %s, got %d", s.PersonName, s.Round)
+		}
+	}
+}
+
+func TestClosePeriod_SkipsSettledPeopleWithNothingNew(t *testing.T) {
+	repo := newMockRepo()
+	tab := recurringTab()
+	tab.Bills[1].PersonShares = []models.PersonShare{{PersonName: "Bob", Total: 30}}
+	repo.tabs[1] = tab
+	repo.settlements = []models.TabSettlement{
+		{TabID: 1, PersonName: "Alice", Amount: 60, PaymentState: models.PaymentState{AmountPaid: 60, Paid: true}, Round: 1},
+		{TabID: 1, PersonName: "Bob", Amount: 40, PaymentState: models.PaymentState{AmountPaid: 40, Paid: true}, Round: 1},
+	}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	settlements, err := svc.ClosePeriod(1, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(settlements) != 1 || settlements[0].PersonName != "Bob" {
+		t.Fatalf("expected only Bob to settle, got %+v", settlements)
+	}
+}
+
+func TestClosePeriod_PlansUnderLock(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = recurringTab()
+	repo.addedBeforeLock = &models.Bill{
+		ID: 3, Total: 12, TabPeriod: 2,
+		PersonShares: []models.PersonShare{{PersonName: "Carol", Total: 12}},
+	}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	settlements, err := svc.ClosePeriod(1, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, s := range settlements {
+		if s.PersonName == "Carol" {
+			return
+		}
+	}
+	t.Errorf("expected the bill added before the lock to be settled, got %+v", settlements)
+}
+
+func TestClosePeriod_Rules(t *testing.T) {
+	tests := []struct {
+		name string
+		tab  func() *models.Tab
+		want error
+	}{
+		{"not recurring", func() *models.Tab { return &models.Tab{ID: 1} }, ErrNotRecurring},
+		{"empty period", func() *models.Tab {
+			tab := recurringTab()
+			tab.Bills = tab.Bills[:1]
+			return tab
+		}, ErrEmptyPeriod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockRepo()
+			repo.tabs[1] = tt.tab()
+			svc := NewTabService(repo, &mockImageQuerier{})
+			if _, err := svc.ClosePeriod(1, nil); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestFinalizeTab_RejectsRecurringTab(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = recurringTab()
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	if _, _, err := svc.FinalizeTab(1, "", nil, nil); !errors.Is(err, ErrRecurringTab) {
+		t.Errorf("expected ErrRecurringTab, got %v", err)
+	}
+}
+
+func TestGetTab_PeriodTotals(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = recurringTab()
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	tab, err := svc.GetTab(1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if tab.Periods[0].Total != 100 || tab.Periods[0].BillCount != 1 {
+		t.Errorf("period 1: got total=%.2f bills=%d", tab.Periods[0].Total, tab.Periods[0].BillCount)
+	}
+	if tab.Periods[1].Total != 30 || tab.Periods[1].BillCount != 1 {
+		t.Errorf("period 2: got total=%.2f bills=%d", tab.Periods[1].Total, tab.Periods[1].BillCount)
+	}
+}
+
+func TestRemoveBill_ClosedPeriod(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = recurringTab()
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	if err := svc.RemoveBillFromTab(1, "1", nil); !errors.Is(err, ErrBillPeriodClosed) {
+		t.Errorf("expected ErrBillPeriodClosed, got %v", err)
+	}
+}
+
+func TestFinalizeTab_ReplaysIdempotencyKey(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{
+		ID:    1,
+		Bills: []models.Bill{{ID: 1, Total: 10, PersonShares: []models.PersonShare{{PersonName: "Alice", Total: 10}}}},
+	}
+	svc := NewTabService(repo, &mockImageQuerier{})
+
+	settlements, replayed, err := svc.FinalizeTab(1, "key-1", nil, nil)
+	if err != nil || replayed {
+		t.Fatalf("expected a fresh finalize, got replayed=%v err=%v", replayed, err)
+	}
+	repo.finalizedID = 0
+
+	again, replayed, err := svc.FinalizeTab(1, "key-1", nil, nil)
+	if err != nil || !replayed {
+		t.Fatalf("expected a replay, got replayed=%v err=%v", replayed, err)
+	}
+	if repo.finalizedID != 0 {
+		t.Error("expected the replay not to finalize again")
+	}
+	if len(again) != len(settlements) || again[0].Amount != settlements[0].Amount {
+		t.Errorf("expected the original settlements, got %+v", again)
+	}
+
+	if _, _, err := svc.FinalizeTab(1, "key-2", nil, nil); !errors.Is(err, ErrAlreadyFinalized) {
+		t.Errorf("expected ErrAlreadyFinalized for another key, got %v", err)
+	}
+	if _, _, err := svc.FinalizeTab(1, "", nil, nil); !errors.Is(err, ErrAlreadyFinalized) {
+		t.Errorf("expected ErrAlreadyFinalized without a key, got %v", err)
+	}
+}
+
+func TestFinalizeTab_ConcurrentRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		winner       string
+		wantReplayed bool
+		wantErr      error
+	}{
+		{"same key", "key-1", true, nil},
+		{"other key", "key-2", false, ErrAlreadyFinalized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockRepo()
+			repo.tabs[1] = &models.Tab{
+				ID:    1,
+				Bills: []models.Bill{{ID: 1, Total: 10, PersonShares: []models.PersonShare{{PersonName: "Alice", Total: 10}}}},
+			}
+			repo.concurrentFinalizeKey = tt.winner
+			svc := NewTabService(repo, &mockImageQuerier{})
+
+			_, replayed, err := svc.FinalizeTab(1, "key-1", nil, nil)
+			if replayed != tt.wantReplayed || !errors.Is(err, tt.wantErr) {
+				t.Errorf("got replayed=%v err=%v, want replayed=%v err=%v", replayed, err, tt.wantReplayed, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddMemberAlias_Success(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{
+		ID:      1,
+		Members: []models.TabMember{{ID: 7, TabID: 1, Role: "member", PublicID: "m7publicid0000000000ab"}},
+	}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	actor := &models.TabMember{ID: 7, TabID: 1, Role: "member"}
+	alias, err := svc.AddMemberAlias(1, "m7publicid0000000000ab", "  Jon ", actor)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if alias.MemberID != 7 || alias.MemberRef != "m7publicid0000000000ab" || alias.Name != "jon" {
+		t.Errorf("unexpected alias: %+v", alias)
+	}
+	if repo.createdAlias == nil {
+		t.Error("expected CreateAlias to be called")
+	}
+}
+
+func TestAddMemberAlias_Rules(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{
+		ID: 1,
+		Members: []models.TabMember{
+			{ID: 7, TabID: 1, Role: "creator"},
+			{ID: 8, TabID: 1, Role: "member"},
+		},
+		Aliases: []models.TabMemberAlias{{ID: 3, TabID: 1, MemberID: 7, Name: "jon"}},
+	}
+	svc := NewTabService(repo, &mockImageQuerier{})
+	creator := &models.TabMember{ID: 7, TabID: 1, Role: "creator"}
+	other := &models.TabMember{ID: 8, TabID: 1, Role: "member"}
+
+	if _, err := svc.AddMemberAlias(1, "7", "Johnny", other); !errors.Is(err, ErrNotAliasOwner) {
+		t.Errorf("expected ErrNotAliasOwner for another member, got %v", err)
+	}
+	if _, err := svc.AddMemberAlias(1, "7", "Johnny", nil); !errors.Is(err, ErrNotAliasOwner) {
+		t.Errorf("expected ErrNotAliasOwner without a member, got %v", err)
+	}
+	if _, err := svc.AddMemberAlias(1, "8", "JON", creator); !errors.Is(err, ErrAliasTaken) {
+		t.Errorf("expected ErrAliasTaken, got %v", err)
+	}
+	if _, err := svc.AddMemberAlias(1, "99", "Sam", creator); !errors.Is(err, ErrMemberNotFound) {
+		t.Errorf("expected ErrMemberNotFound, got %v", err)
+	}
+	alias, err := svc.AddMemberAlias(1, "7", "Jon", creator)
+	if err != nil || alias.ID != 3 || repo.createdAlias != nil {
+		t.Errorf("expected existing alias to be returned unchanged, got %+v, %v", alias, err)
+	}
+
+	repo.tabs[1].Finalized = true
+	if _, err := svc.AddMemberAlias(1, "8", "P", other); !errors.Is(err, ErrTabFinalized) {
+		t.Errorf("expected ErrTabFinalized, got %v", err)
+	}
+}
+
+func TestRemoveMemberAlias(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{
+		ID:      1,
+		Aliases: []models.TabMemberAlias{{ID: 3, TabID: 1, MemberID: 7, Name: "jon"}},
+	}
+	svc := NewTabService(repo, &mockImageQuerier{})
+
+	if err := svc.RemoveMemberAlias(1, 3, &models.TabMember{ID: 8, TabID: 1, Role: "member"}); !errors.Is(err, ErrNotAliasOwner) {
+		t.Errorf("expected ErrNotAliasOwner, got %v", err)
+	}
+	if err := svc.RemoveMemberAlias(1, 4, &models.TabMember{ID: 7, TabID: 1}); err != gorm.ErrRecordNotFound {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+	if err := svc.RemoveMemberAlias(1, 3, &models.TabMember{ID: 7, TabID: 1, Role: "member"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.deletedAliasID != 3 {
+		t.Errorf("expected alias 3 deleted, got %d", repo.deletedAliasID)
+	}
+}
+
+func TestReopenTab_Success(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{ID: 1, Finalized: true}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	if err := svc.ReopenTab(1, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.reopenedID != 1 {
+		t.Errorf("expected Reopen called with id 1, got %d", repo.reopenedID)
+	}
+}
+
+func TestReopenTab_OnlyOnce(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{ID: 1, Finalized: true}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	if err := svc.ReopenTab(1, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := svc.ReopenTab(1, nil); !errors.Is(err, ErrNotFinalized) {
+		t.Errorf("expected ErrNotFinalized for a repeated reopen, got %v", err)
+	}
+}
+
+func TestReopenTab_NotFinalized(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{ID: 1, Finalized: false}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	err := svc.ReopenTab(1, nil)
+	if err == nil {
+		t.Fatal("expected error for tab that is not finalized")
+	}
+	if err.Error() != "tab is not finalized" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if repo.reopenedID != 0 {
+		t.Error("expected Reopen not to be called")
+	}
+}
+
+func TestGetTabByRef(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[3] = &models.Tab{ID: 3, PublicID: "4zX9kQ2mN8pL1vR7tY3wBc", Bills: []models.Bill{{Total: 10, AccessToken: "x"}, {Total: 5}}}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	for _, ref := range []string{"3", "4zX9kQ2mN8pL1vR7tY3wBc"} {
+		tab, err := svc.GetTabByRef(ref)
+		if err != nil {
+			t.Fatalf("expected no error for %q, got %v", ref, err)
+		}
+		if tab.ID != 3 {
+			t.Errorf("expected tab 3 for %q, got %d", ref, tab.ID)
+		}
+		if tab.TotalAmount != 15 {
+			t.Errorf("expected total 15, got %f", tab.TotalAmount)
+		}
+		if tab.Bills[0].AccessToken != "" {
+			t.Error("expected bill access tokens to be stripped")
+		}
+	}
+
+	if _, err := svc.GetTabByRef("not-an-id"); !errors.Is(err, security.ErrInvalidRef) {
+		t.Errorf("expected ErrInvalidRef, got %v", err)
+	}
+}
+
+func TestGetSettlementByRef(t *testing.T) {
+	repo := newMockRepo()
+	repo.settlements = []models.TabSettlement{
+		{ID: 4, TabID: 1, PublicID: "4zX9kQ2mN8pL1vR7tY3wBc", PersonName: "Alice"},
+		{ID: 5, TabID: 1, PublicID: "7hT2kQ2mN8pL1vR7tY3wBc", PersonName: "Bob"},
+	}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	settlement, err := svc.GetSettlementByRef(1, "7hT2kQ2mN8pL1vR7tY3wBc")
+	if err != nil || settlement.ID != 5 {
+		t.Fatalf("expected settlement 5, got %v, %v", settlement, err)
+	}
+	settlement, err = svc.GetSettlementByRef(1, "4")
+	if err != nil || settlement.ID != 4 {
+		t.Fatalf("expected settlement 4, got %v, %v", settlement, err)
+	}
+	if _, err := svc.GetSettlementByRef(1, "99"); err != gorm.ErrRecordNotFound {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+}
+
 func TestJoinTab_Success(t *testing.T) {
 	repo := newMockRepo()
 	imgQ := &mockImageQuerier{}
@@ -290,16 +1112,21 @@ func TestJoinTab_Success(t *testing.T) {
 	}
 }
 
-func TestJoinTabAsCreator_Success(t *testing.T) {
+func TestCreateTab_WithCreator(t *testing.T) {
 	repo := newMockRepo()
 	imgQ := &mockImageQuerier{}
 	svc := NewTabService(repo, imgQ)
 
-	member, err := svc.JoinTabAsCreator(1, "Alice")
+	tab := &models.Tab{Name: "Trip"}
+	member, err := svc.CreateTab(tab, "Alice")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
 
+	if member.TabID != tab.ID || len(repo.members) != 1 {
+		t.Errorf("expected the creator to join tab %d, got %+v", tab.ID, repo.members)
+	}
+
 	if member.Role != "creator" {
 		t.Errorf("expected role creator, got %s", member.Role)
 	}
@@ -316,10 +1143,10 @@ func TestAddBillToTab_WithMember(t *testing.T) {
 	imgQ := &mockImageQuerier{}
 
 	repo.tabs[1] = &models.Tab{ID: 1}
+	repo.bills[99] = &models.Bill{ID: 99, AccessToken: "billtok"}
 
 	svc := NewTabService(repo, imgQ)
-	memberID := uint(42)
-	err := svc.AddBillToTab(1, 99, &memberID)
+	err := svc.AddBillToTab(1, "99", "billtok", &models.TabMember{ID: 42, TabID: 1}, false)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -330,8 +1157,199 @@ func TestAddBillToTab_WithMember(t *testing.T) {
 	if repo.addBillBillID != 99 {
 		t.Errorf("expected billID 99, got %d", repo.addBillBillID)
 	}
-	if repo.addBillMemberID == nil || *repo.addBillMemberID != 42 {
-		t.Error("expected memberID 42 to be passed through")
+	if repo.addBillMember == nil || repo.addBillMember.ID != 42 {
+		t.Error("expected member 42 to be passed through")
+	}
+}
+
+func TestAddBillToTab_MemberOfAnotherTab(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{ID: 1}
+	repo.bills[99] = &models.Bill{ID: 99, AccessToken: "billtok"}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	if err := svc.AddBillToTab(1, "99", "billtok", &models.TabMember{ID: 42, TabID: 2}, false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.addBillMember != nil {
+		t.Error("expected a member of another tab not to be credited")
+	}
+}
+
+func TestAddBillToTab_ByPublicID(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{ID: 1}
+	repo.bills[99] = &models.Bill{ID: 99, PublicID: "4zX9kQ2mN8pL1vR7tY3wBc", AccessToken: "billtok"}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	if err := svc.AddBillToTab(1, "4zX9kQ2mN8pL1vR7tY3wBc", "billtok", nil, false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.addBillBillID != 99 {
+		t.Errorf("expected billID 99, got %d", repo.addBillBillID)
+	}
+}
+
+func TestAddBillToTab_TokenMismatch(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{ID: 1}
+	repo.bills[99] = &models.Bill{ID: 99, AccessToken: "billtok"}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	for _, token := range []string{"", "wrong"} {
+		err := svc.AddBillToTab(1, "99", token, nil, false)
+		if !errors.Is(err, ErrBillTokenMismatch) {
+			t.Errorf("expected ErrBillTokenMismatch for token %q, got %v", token, err)
+		}
+	}
+	if repo.addBillBillID != 0 {
+		t.Error("expected AddBill not to be called")
+	}
+}
+
+func TestAddBillToTab_AlreadyInAnotherTab(t *testing.T) {
+	repo := newMockRepo()
+	otherTab := uint(2)
+	repo.tabs[1] = &models.Tab{ID: 1}
+	repo.tabs[2] = &models.Tab{ID: 2}
+	repo.bills[99] = &models.Bill{ID: 99, TabID: &otherTab, AccessToken: "billtok"}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	err := svc.AddBillToTab(1, "99", "billtok", nil, false)
+	if !errors.Is(err, ErrBillInAnotherTab) {
+		t.Fatalf("expected ErrBillInAnotherTab, got %v", err)
+	}
+
+	// An explicit move is allowed
+	if err := svc.AddBillToTab(1, "99", "billtok", nil, true); err != nil {
+		t.Fatalf("expected explicit move to succeed, got %v", err)
+	}
+	if repo.addBillTabID != 1 || repo.addBillBillID != 99 {
+		t.Error("expected bill 99 attached to tab 1")
+	}
+}
+
+func TestAddBillToTab_MoveFromFinalizedTab(t *testing.T) {
+	repo := newMockRepo()
+	otherTab := uint(2)
+	repo.tabs[1] = &models.Tab{ID: 1}
+	repo.tabs[2] = &models.Tab{ID: 2, Finalized: true}
+	repo.bills[99] = &models.Bill{ID: 99, TabID: &otherTab, AccessToken: "billtok"}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	err := svc.AddBillToTab(1, "99", "billtok", nil, true)
+	if !errors.Is(err, ErrTabFinalized) {
+		t.Fatalf("expected ErrTabFinalized, got %v", err)
+	}
+}
+
+func TestRemoveBillFromTab_Creator(t *testing.T) {
+	repo := newMockRepo()
+	addedBy := uint(2)
+	repo.tabs[1] = &models.Tab{
+		ID:      1,
+		Bills:   []models.Bill{{ID: 7, AddedByMemberID: &addedBy}},
+		Members: []models.TabMember{{ID: 1, TabID: 1, Role: "creator"}, {ID: 2, TabID: 1, Role: "member"}},
+	}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	err := svc.RemoveBillFromTab(1, "7", &models.TabMember{ID: 1, TabID: 1, Role: "creator"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.removedBillID != 7 {
+		t.Errorf("expected bill 7 removed, got %d", repo.removedBillID)
+	}
+}
+
+func TestRemoveBillFromTab_AddingMember(t *testing.T) {
+	repo := newMockRepo()
+	addedBy := uint(2)
+	repo.tabs[1] = &models.Tab{
+		ID:      1,
+		Bills:   []models.Bill{{ID: 7, AddedByMemberID: &addedBy}},
+		Members: []models.TabMember{{ID: 1, TabID: 1, Role: "creator"}, {ID: 2, TabID: 1, Role: "member"}},
+	}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	if err := svc.RemoveBillFromTab(1, "7", &models.TabMember{ID: 2, TabID: 1, Role: "member"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestRemoveBillFromTab_OtherMemberForbidden(t *testing.T) {
+	repo := newMockRepo()
+	addedBy := uint(2)
+	repo.tabs[1] = &models.Tab{
+		ID:      1,
+		Bills:   []models.Bill{{ID: 7, AddedByMemberID: &addedBy}},
+		Members: []models.TabMember{{ID: 1, TabID: 1, Role: "creator"}, {ID: 2, TabID: 1, Role: "member"}, {ID: 3, TabID: 1, Role: "member"}},
+	}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	for _, member := range []*models.TabMember{nil, {ID: 3, TabID: 1, Role: "member"}, {ID: 9, TabID: 4, Role: "creator"}} {
+		err := svc.RemoveBillFromTab(1, "7", member)
+		if !errors.Is(err, ErrNotBillOwner) {
+			t.Errorf("expected ErrNotBillOwner for member %+v, got %v", member, err)
+		}
+	}
+	if repo.removedBillID != 0 {
+		t.Error("expected RemoveBill not to be called")
+	}
+}
+
+func TestRemoveBillFromTab_FinalizedOrMissing(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{ID: 1, Finalized: true, Bills: []models.Bill{{ID: 7}}}
+	repo.tabs[2] = &models.Tab{ID: 2, Bills: []models.Bill{{ID: 8}}}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	if err := svc.RemoveBillFromTab(1, "7", nil); !errors.Is(err, ErrTabFinalized) {
+		t.Errorf("expected ErrTabFinalized, got %v", err)
+	}
+	if err := svc.RemoveBillFromTab(2, "7", nil); !errors.Is(err, ErrBillNotInTab) {
+		t.Errorf("expected ErrBillNotInTab, got %v", err)
+	}
+}
+
+func TestRemoveBillFromTab_ByPublicID(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{ID: 1, Bills: []models.Bill{{ID: 7, PublicID: "4zX9kQ2mN8pL1vR7tY3wBc"}}}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	if err := svc.RemoveBillFromTab(1, "4zX9kQ2mN8pL1vR7tY3wBc", nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.removedBillID != 7 {
+		t.Errorf("expected bill 7 removed, got %d", repo.removedBillID)
+	}
+}
+
+func TestMoveBill_Success(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{ID: 1, Bills: []models.Bill{{ID: 7}}}
+	repo.tabs[2] = &models.Tab{ID: 2}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	if err := svc.MoveBill(1, "7", 2, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.movedBillID != 7 || repo.movedToTabID != 2 {
+		t.Errorf("expected bill 7 moved to tab 2, got bill %d to tab %d", repo.movedBillID, repo.movedToTabID)
+	}
+}
+
+func TestMoveBill_TargetFinalized(t *testing.T) {
+	repo := newMockRepo()
+	repo.tabs[1] = &models.Tab{ID: 1, Bills: []models.Bill{{ID: 7}}}
+	repo.tabs[2] = &models.Tab{ID: 2, Finalized: true}
+
+	svc := NewTabService(repo, &mockImageQuerier{})
+	if err := svc.MoveBill(1, "7", 2, nil); !errors.Is(err, ErrTabFinalized) {
+		t.Errorf("expected ErrTabFinalized, got %v", err)
+	}
+	if repo.movedBillID != 0 {
+		t.Error("expected MoveBill not to be called")
 	}
 }
 
@@ -0,0 +1,146 @@
+package tab
+
+import (
+	"backend/pkg/models"
+	"sort"
+	"strings"
+)
+
+// personTotal is one person's aggregated share across a tab's bills.
+type personTotal struct {
+	Key      string
+	MemberID *uint
+	Name     string
+	Amount   float64
+}
+
+// personResolver maps free-text person names on bills to tab members.
+type personResolver struct {
+	members map[uint]*models.TabMember
+	byName  map[string]*models.TabMember
+}
+
+func newPersonResolver(tab *models.Tab) *personResolver {
+	r := &personResolver{
+		members: make(map[uint]*models.TabMember),
+		byName:  make(map[string]*models.TabMember),
+	}
+	for i := range tab.Members {
+		m := &tab.Members[i]
+		r.members[m.ID] = m
+		r.byName[normalizeName(m.DisplayName)] = m
+	}
+	// Explicit aliases win over display-name matches
+	for _, alias := range tab.Aliases {
+		if m, ok := r.members[alias.MemberID]; ok {
+			r.byName[normalizeName(alias.Name)] = m
+		}
+	}
+	return r
+}
+
+// resolve returns the member a share belongs to, if any. An explicit member ID
+// on the share takes precedence, then aliases, then a display-name match.
+func (r *personResolver) resolve(memberID *uint, name string) *models.TabMember {
+	if memberID != nil {
+		if m, ok := r.members[*memberID]; ok {
+			return m
+		}
+	}
+	return r.byName[normalizeName(name)]
+}
+
+// aggregateShares totals every person share on the tab. Shares linked to a
+// member are merged under that member; the rest merge by case-insensitive name.
+// The result is sorted by key so callers see a stable order.
+func aggregateShares(tab *models.Tab) []personTotal {
+	resolver := newPersonResolver(tab)
+	totals := make(map[string]*personTotal)
+	for _, bill := range tab.Bills {
+		for _, share := range bill.PersonShares {
+			member := resolver.resolve(share.MemberID, share.PersonName)
+			key := personKey(member, share.PersonName)
+			t, ok := totals[key]
+			if !ok {
+				t = &personTotal{Key: key, Name: share.PersonName}
+				if member != nil {
+					id := member.ID
+					t.MemberID = &id
+					t.Name = member.DisplayName
+				}
+				totals[key] = t
+			} else if member == nil && t.Name == strings.ToLower(t.Name) && share.PersonName != t.Name {
+				// Prefer a capitalized variant over all-lowercase
+				t.Name = share.PersonName
+			}
+			t.Amount += share.Total
+		}
+	}
+
+	result := make([]personTotal, 0, len(totals))
+	for _, t := range totals {
+		result = append(result, *t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// balances nets what each person paid for the tab's bills against their
+// share from aggregateShares, so the preview matches finalization. A bill is
+// paid for by the member who added it, or else by the tab creator, whom
+// settlements are owed to; bills on tabs without members have no payer.
+func balances(tab *models.Tab) []models.TabBalance {
+	resolver := newPersonResolver(tab)
+	var creator *models.TabMember
+	for i := range tab.Members {
+		if tab.Members[i].Role == "creator" {
+			creator = &tab.Members[i]
+			break
+		}
+	}
+	paid := make(map[uint]float64)
+	for _, bill := range tab.Bills {
+		payer := creator
+		if bill.AddedByMemberID != nil {
+			if m, ok := resolver.members[*bill.AddedByMemberID]; ok {
+				payer = m
+			}
+		}
+		if payer != nil {
+			paid[payer.ID] += bill.Total
+		}
+	}
+
+	result := []models.TabBalance{}
+	for _, t := range aggregateShares(tab) {
+		balance := models.TabBalance{MemberID: t.MemberID, PersonName: t.Name, Owed: roundCents(t.Amount)}
+		if t.MemberID != nil {
+			balance.Paid = roundCents(paid[*t.MemberID])
+			delete(paid, *t.MemberID)
+		}
+		balance.Net = roundCents(balance.Paid - balance.Owed)
+		result = append(result, balance)
+	}
+	// Members who paid for bills without having a share of any
+	for i := range tab.Members {
+		m := &tab.Members[i]
+		if amount, ok := paid[m.ID]; ok {
+			id := m.ID
+			result = append(result, models.TabBalance{MemberID: &id, PersonName: m.DisplayName, Paid: roundCents(amount), Net: roundCents(amount)})
+		}
+	}
+	return result
+}
+
+// personKey matches the payer keys that tab payments are recorded under, so
+// a settlement picks up payments from earlier rounds.
+func personKey(member *models.TabMember, name string) string {
+	if member != nil {
+		return models.PayerKey(&member.ID, name)
+	}
+	return models.PayerKey(nil, name)
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,98 @@
+// Package tabauth checks the tab access and member tokens presented with a
+// request. Handlers outside the tab package share it; tab.TabService
+// satisfies its interfaces.
+package tabauth
+
+import (
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TabResolver looks up tabs for token validation.
+type TabResolver interface {
+	GetTabByRef(ref string) (*models.Tab, error)
+}
+
+// TabAccess looks up tabs and members for authorization.
+type TabAccess interface {
+	TabResolver
+	GetMemberByToken(token string) (*models.TabMember, error)
+}
+
+// RequestToken returns the access token presented with the request, from the
+// Authorization header or else the t query parameter.
+func RequestToken(c *gin.Context) string {
+	authHeader := c.GetHeader("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	return c.Query("t")
+}
+
+// ValidateTab resolves the tab named by the id path parameter and checks the
+// access token. Returns the tab on success or writes an error and returns nil.
+func ValidateTab(c *gin.Context, tabs TabResolver) *models.Tab {
+	t, err := tabs.GetTabByRef(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, security.ErrInvalidRef) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			return nil
+		}
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "tab not found"})
+			return nil
+		}
+		log.Printf("internal error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
+		return nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(RequestToken(c)), []byte(t.AccessToken)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "token mismatch"})
+		return nil
+	}
+	return t
+}
+
+// RequestMember returns the member whose token (X-Member-Token header or m
+// query parameter) was presented, or nil if none or an unknown one was.
+func RequestMember(c *gin.Context, tabs TabAccess) *models.TabMember {
+	memberToken := c.GetHeader("X-Member-Token")
+	if memberToken == "" {
+		memberToken = c.Query("m")
+	}
+	if memberToken == "" {
+		return nil
+	}
+	member, err := tabs.GetMemberByToken(memberToken)
+	if err != nil {
+		return nil
+	}
+	return member
+}
+
+// ValidateCreator validates the tab like ValidateTab and, once the tab has
+// members, requires the creator's member token for action. Returns the tab
+// and the calling member (nil on tabs without members), or writes an error
+// and returns a nil tab.
+func ValidateCreator(c *gin.Context, tabs TabAccess, action string) (*models.Tab, *models.TabMember) {
+	t := ValidateTab(c, tabs)
+	if t == nil {
+		return nil, nil
+	}
+
+	member := RequestMember(c, tabs)
+	if len(t.Members) > 0 && (member == nil || member.TabID != t.ID || member.Role != "creator") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the tab creator can " + action})
+		return nil, nil
+	}
+	return t, member
+}
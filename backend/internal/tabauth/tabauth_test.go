@@ -0,0 +1,139 @@
+package tabauth
+
+import (
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ── Mock TabAccess ──────────────────────────────────────────────
+
+type mockTabAccess struct {
+	tab     *models.Tab
+	members map[string]*models.TabMember
+}
+
+func (m *mockTabAccess) GetTabByRef(ref string) (*models.Tab, error) {
+	if _, _, err := security.ParseRef(ref); err != nil {
+		return nil, err
+	}
+	if m.tab == nil || ref != m.tab.PublicID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return m.tab, nil
+}
+
+func (m *mockTabAccess) GetMemberByToken(token string) (*models.TabMember, error) {
+	if member, ok := m.members[token]; ok {
+		return member, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// ── Tests ───────────────────────────────────────────────────────
+
+// request runs check on a request for the tab with the given ref, query and
+// headers, and returns the recorded response.
+func request(ref, query string, header http.Header, check func(c *gin.Context)) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/tabs/"+ref+query, nil)
+	for k, v := range header {
+		c.Request.Header[k] = v
+	}
+	c.Params = gin.Params{{Key: "id", Value: ref}}
+	check(c)
+	return w
+}
+
+const tabRef = "Kq3Vb8LmT2xYp9Rw5NcZa1"
+
+func TestValidateTab(t *testing.T) {
+	tabs := &mockTabAccess{tab: &models.Tab{ID: 1, PublicID: tabRef, AccessToken: "secret"}}
+	tests := []struct {
+		name   string
+		ref    string
+		query  string
+		header http.Header
+		want   int
+	}{
+		{"query token", tabRef, "?t=secret", nil, http.StatusOK},
+		{"bearer token", tabRef, "", http.Header{"Authorization": {"Bearer secret"}}, http.StatusOK},
+		{"bearer token wins", tabRef, "?t=secret", http.Header{"Authorization": {"Bearer wrong"}}, http.StatusForbidden},
+		{"wrong token", tabRef, "?t=wrong", nil, http.StatusForbidden},
+		{"no token", tabRef, "", nil, http.StatusForbidden},
+		{"unknown tab", "Zz3Vb8LmT2xYp9Rw5NcZa1", "?t=secret", nil, http.StatusNotFound},
+		{"invalid ref", "not-a-ref", "?t=secret", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		var got *models.Tab
+		w := request(tt.ref, tt.query, tt.header, func(c *gin.Context) {
+			if got = ValidateTab(c, tabs); got != nil {
+				c.Status(http.StatusOK)
+			}
+		})
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+		if (got != nil) != (tt.want == http.StatusOK) {
+			t.Errorf("%s: unexpected tab %+v", tt.name, got)
+		}
+	}
+}
+
+func TestValidateCreator(t *testing.T) {
+	creator := &models.TabMember{ID: 1, TabID: 1, Role: "creator"}
+	member := &models.TabMember{ID: 2, TabID: 1, Role: "member"}
+	outsider := &models.TabMember{ID: 3, TabID: 2, Role: "creator"}
+	tabs := &mockTabAccess{
+		tab:     &models.Tab{ID: 1, PublicID: tabRef, AccessToken: "secret", Members: []models.TabMember{*creator, *member}},
+		members: map[string]*models.TabMember{"c": creator, "m": member, "o": outsider},
+	}
+	tests := []struct {
+		name   string
+		query  string
+		header http.Header
+		want   *models.TabMember
+	}{
+		{"creator", "?t=secret&m=c", nil, creator},
+		{"creator header", "?t=secret", http.Header{"X-Member-Token": {"c"}}, creator},
+		{"member", "?t=secret&m=m", nil, nil},
+		{"other tab's creator", "?t=secret&m=o", nil, nil},
+		{"no member token", "?t=secret", nil, nil},
+	}
+	for _, tt := range tests {
+		var got *models.TabMember
+		w := request(tabRef, tt.query, tt.header, func(c *gin.Context) {
+			if tab, m := ValidateCreator(c, tabs, "manage webhooks"); tab != nil {
+				got = m
+				c.Status(http.StatusOK)
+			}
+		})
+		if tt.want == nil {
+			if w.Code != http.StatusForbidden || w.Body.String() != `{"error":"only the tab creator can manage webhooks"}` {
+				t.Errorf("%s: expected 403, got %d: %s", tt.name, w.Code, w.Body.String())
+			}
+			continue
+		}
+		if w.Code != http.StatusOK || got != tt.want {
+			t.Errorf("%s: expected the creator, got %d %+v", tt.name, w.Code, got)
+		}
+	}
+
+	// Until someone joins, the access token alone is enough
+	tabs.tab.Members = nil
+	w := request(tabRef, "?t=secret", nil, func(c *gin.Context) {
+		if tab, _ := ValidateCreator(c, tabs, "manage webhooks"); tab != nil {
+			c.Status(http.StatusOK)
+		}
+	})
+	if w.Code != http.StatusOK {
+		t.Errorf("expected a tab without members to allow the token holder, got %d", w.Code)
+	}
+}
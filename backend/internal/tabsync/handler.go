@@ -0,0 +1,97 @@
+package tabsync
+
+import (
+	"backend/internal/tabauth"
+	"backend/pkg/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TabAccess resolves tabs and members for authorization. Satisfied by tab.TabService.
+type TabAccess interface {
+	GetTabByRef(ref string) (*models.Tab, error)
+	GetMemberByToken(token string) (*models.TabMember, error)
+}
+
+type SyncHandler struct {
+	service SyncService
+	tabs    TabAccess
+}
+
+func NewSyncHandler(service SyncService, tabs TabAccess) *SyncHandler {
+	return &SyncHandler{service: service, tabs: tabs}
+}
+
+// GetChanges handles GET /api/tabs/:id/changes?since=cursor
+func (h *SyncHandler) GetChanges(c *gin.Context) {
+	t := tabauth.ValidateTab(c, h.tabs)
+	if t == nil {
+		return
+	}
+
+	var since uint64
+	if v := c.Query("since"); v != "" {
+		var err error
+		if since, err = strconv.ParseUint(v, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since cursor"})
+			return
+		}
+	}
+
+	changes, err := h.service.Changes(t.ID, uint(since))
+	if err != nil {
+		log.Printf("internal error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// PushChanges handles POST /api/tabs/:id/changes
+// Creates bills made offline and reports what became of each.
+func (h *SyncHandler) PushChanges(c *gin.Context) {
+	t := tabauth.ValidateTab(c, h.tabs)
+	if t == nil {
+		return
+	}
+
+	var body struct {
+		Bills []BillPush `json:"bills"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"})
+		return
+	}
+	if len(body.Bills) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bills required"})
+		return
+	}
+	if len(body.Bills) > maxPushBills {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at most 50 bills per push"})
+		return
+	}
+
+	var member *models.TabMember
+	memberToken := c.GetHeader("X-Member-Token")
+	if memberToken == "" {
+		memberToken = c.Query("m")
+	}
+	if memberToken != "" {
+		if m, err := h.tabs.GetMemberByToken(memberToken); err == nil && m.TabID == t.ID {
+			member = m
+		}
+	}
+
+	results, err := h.service.PushBills(t.ID, member, body.Bills)
+	if err != nil {
+		log.Printf("internal error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
@@ -0,0 +1,183 @@
+package tabsync
+
+import (
+	"backend/internal/audit"
+	"backend/internal/events"
+	"backend/pkg/models"
+	"backend/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SyncRepository interface {
+	LatestEventID(tabID uint) (uint, error)
+	EventsSince(tabID uint, afterID uint, limit int) ([]models.Event, error)
+	GetTab(tabID uint) (*models.Tab, error)
+	GetBills(tabID uint, refs []string) ([]models.Bill, error)
+	GetMembers(tabID uint, refs []string) ([]models.TabMember, error)
+	GetImages(tabID uint, refs []string) ([]models.TabImage, error)
+	GetSettlements(tabID uint) ([]models.TabSettlement, error)
+	GetSupersededSettlementIDs(tabID uint) ([]string, error)
+	GetBillPublicIDs(ids []uint) ([]string, error)
+	CreateBill(bill *models.Bill, actor *models.TabMember) (*models.Bill, bool, error)
+}
+
+type syncRepository struct {
+	db *gorm.DB
+}
+
+func (r *syncRepository) LatestEventID(tabID uint) (uint, error) {
+	var id uint
+	err := r.db.Model(&models.Event{}).Where("tab_id = ?", tabID).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+func (r *syncRepository) EventsSince(tabID uint, afterID uint, limit int) ([]models.Event, error) {
+	var evs []models.Event
+	err := r.db.Where("tab_id = ? AND id > ?", tabID, afterID).Order("id ASC").Limit(limit).Find(&evs).Error
+	return evs, err
+}
+
+func (r *syncRepository) GetTab(tabID uint) (*models.Tab, error) {
+	tab := &models.Tab{}
+	err := r.db.First(tab, tabID).Error
+	return tab, err
+}
+
+// scoped limits a query to the tab and, unless refs is nil, to the records
+// with those public or numeric IDs.
+func scoped(db *gorm.DB, tabID uint, refs []string) *gorm.DB {
+	db = db.Where("tab_id = ?", tabID)
+	if refs != nil {
+		publicIDs, ids := splitRefs(refs)
+		db = db.Where("public_id IN ? OR id IN ?", publicIDs, ids)
+	}
+	return db.Order("id ASC")
+}
+
+// GetBills returns the tab's bills with the given refs, or all of them if
+// refs is nil. Bills no longer on the tab are skipped.
+func (r *syncRepository) GetBills(tabID uint, refs []string) ([]models.Bill, error) {
+	var bills []models.Bill
+	err := scoped(r.db, tabID, refs).
+		Preload("Items.Assignments").
+		Preload("Participants").
+		Preload("Splits", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("PersonShares").
+		Find(&bills).Error
+	return bills, err
+}
+
+func (r *syncRepository) GetMembers(tabID uint, refs []string) ([]models.TabMember, error) {
+	var members []models.TabMember
+	err := scoped(r.db, tabID, refs).Find(&members).Error
+	return members, err
+}
+
+func (r *syncRepository) GetImages(tabID uint, refs []string) ([]models.TabImage, error) {
+	var images []models.TabImage
+	err := scoped(r.db, tabID, refs).Find(&images).Error
+	return images, err
+}
+
+// GetSettlements returns the current round of settlements.
+func (r *syncRepository) GetSettlements(tabID uint) ([]models.TabSettlement, error) {
+	var settlements []models.TabSettlement
+	err := r.db.Where("tab_id = ? AND superseded_at IS NULL", tabID).Order("amount DESC").Find(&settlements).Error
+	return settlements, err
+}
+
+// GetSupersededSettlementIDs returns the public IDs of the settlements
+// earlier rounds replaced.
+func (r *syncRepository) GetSupersededSettlementIDs(tabID uint) ([]string, error) {
+	ids := []string{}
+	err := r.db.Model(&models.TabSettlement{}).Where("tab_id = ? AND superseded_at IS NOT NULL", tabID).Order("id ASC").Pluck("public_id", &ids).Error
+	return ids, err
+}
+
+// GetBillPublicIDs returns the public IDs of the bills with the given IDs,
+// wherever they are now. Bills that no longer exist are skipped.
+func (r *syncRepository) GetBillPublicIDs(ids []uint) ([]string, error) {
+	publicIDs := []string{}
+	if len(ids) == 0 {
+		return publicIDs, nil
+	}
+	err := r.db.Model(&models.Bill{}).Where("id IN ?", ids).Order("id ASC").Pluck("public_id", &publicIDs).Error
+	return publicIDs, err
+}
+
+// CreateBill creates a pushed bill in its tab's open period, with its
+// bill.created event and activity entry. If the tab already has a bill with the same client ID
+// added by the same member, that bill is returned instead, with true; one
+// added by anyone else is ErrClientIDTaken, so a client ID never hands out
+// another member's bill tokens. The tab stays locked meanwhile,
+// so a bill never lands on a tab that is being finalized or closing a period.
+func (r *syncRepository) CreateBill(bill *models.Bill, actor *models.TabMember) (*models.Bill, bool, error) {
+	stored, existed := bill, false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		tab := &models.Tab{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(tab, *bill.TabID).Error; err != nil {
+			return err
+		}
+
+		existing := &models.Bill{}
+		err := tx.Where("client_id = ?", bill.ClientID).First(existing).Error
+		if err == nil {
+			if existing.TabID == nil || *existing.TabID != tab.ID || !sameMember(existing.AddedByMemberID, bill.AddedByMemberID) {
+				return ErrClientIDTaken
+			}
+			stored, existed = existing, true
+			return nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		if tab.Finalized {
+			return ErrTabFinalized
+		}
+		// A bill made offline in a period that has since closed can't join it
+		if tab.Recurring && bill.TabPeriod != 0 && bill.TabPeriod != tab.CurrentPeriod {
+			return ErrPeriodClosed
+		}
+		bill.TabPeriod = tab.CurrentPeriod
+
+		if err := tx.Create(bill).Error; err != nil {
+			return err
+		}
+		err = events.RecordBill(tx, bill.ID, bill.TabID, events.BillCreated{
+			BillID: security.Ref(bill.PublicID),
+			Name:   bill.Name,
+			Total:  bill.Total,
+		})
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  tab.ID,
+			Actor:  actor,
+			Action: "bill.added",
+			Target: "bill:" + bill.PublicID,
+			After:  gin.H{"tab_id": tab.PublicID, "client_id": bill.ClientID},
+		})
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return stored, existed, nil
+}
+
+// sameMember reports whether a and b attribute to the same member, or both
+// to none.
+func sameMember(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func NewSyncRepository(db *gorm.DB) SyncRepository {
+	return &syncRepository{db: db}
+}
@@ -0,0 +1,471 @@
+package tabsync
+
+import (
+	"backend/internal/bill"
+	"backend/internal/events"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	// eventsPerPage bounds how many events one changes request reads
+	eventsPerPage     = 500
+	maxPushBills      = 50
+	maxClientIDLength = 64
+)
+
+var (
+	ErrTabFinalized    = errors.New("tab has been finalized")
+	ErrPeriodClosed    = errors.New("the bill's period has been closed")
+	ErrClientIDTaken   = errors.New("client_id belongs to another bill")
+	ErrClientIDInvalid = errors.New("client_id is required and at most 64 characters")
+)
+
+// Push statuses
+const (
+	PushCreated  = "created"
+	PushExisting = "existing"
+	PushConflict = "conflict"
+	PushInvalid  = "invalid"
+)
+
+// TabState is a tab's own fields. Its bills, members, images and settlements
+// sync separately.
+type TabState struct {
+	PublicID      string     `json:"public_id"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	Finalized     bool       `json:"finalized"`
+	FinalizedAt   *time.Time `json:"finalized_at"`
+	Recurring     bool       `json:"recurring"`
+	CurrentPeriod int        `json:"current_period,omitempty"`
+	Version       uint       `json:"version"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Changes is what changed on a tab after a cursor: the current state of each
+// bill, member, image and settlement that changed, and the public IDs of
+// those no longer on the tab. Cursor is the ID of the last event covered.
+type Changes struct {
+	Cursor               uint                   `json:"cursor"`
+	HasMore              bool                   `json:"has_more"`
+	Tab                  TabState               `json:"tab"`
+	Bills                []models.Bill          `json:"bills"`
+	DeletedBillIDs       []string               `json:"deleted_bill_ids"`
+	Members              []models.TabMember     `json:"members"`
+	Images               []models.TabImage      `json:"images"`
+	DeletedImageIDs      []string               `json:"deleted_image_ids"`
+	Settlements          []models.TabSettlement `json:"settlements"`
+	DeletedSettlementIDs []string               `json:"deleted_settlement_ids"`
+}
+
+// BillPush is a bill created offline. ClientID identifies it across retries.
+type BillPush struct {
+	ClientID string `json:"client_id"`
+	models.Bill
+}
+
+// PushResult is what became of one pushed bill. Conflicts carry a Reason
+// code; conflicts and invalid bills an Error.
+type PushResult struct {
+	ClientID     string `json:"client_id"`
+	Status       string `json:"status"`
+	Reason       string `json:"reason,omitempty"`
+	Error        string `json:"error,omitempty"`
+	PublicID     string `json:"public_id,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	CreatorToken string `json:"creator_token,omitempty"`
+}
+
+type SyncService interface {
+	Changes(tabID uint, since uint) (*Changes, error)
+	PushBills(tabID uint, actor *models.TabMember, pushes []BillPush) ([]PushResult, error)
+}
+
+type syncService struct {
+	repo SyncRepository
+}
+
+// Changes returns what changed on the tab after the since cursor, reading at
+// most one page of events. A zero cursor returns everything on the tab.
+// Entities are read after the events, so they may already include changes
+// the next page reports again.
+func (s *syncService) Changes(tabID uint, since uint) (*Changes, error) {
+	if since == 0 {
+		return s.snapshot(tabID)
+	}
+
+	evs, err := s.repo.EventsSince(tabID, since, eventsPerPage)
+	if err != nil {
+		return nil, err
+	}
+	t, err := touchedBy(evs)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := newChanges(since)
+	if len(evs) > 0 {
+		changes.Cursor = evs[len(evs)-1].ID
+		changes.HasMore = len(evs) == eventsPerPage
+	}
+	if err := s.loadTab(tabID, changes); err != nil {
+		return nil, err
+	}
+
+	if len(t.bills.refs) > 0 {
+		bills, err := s.repo.GetBills(tabID, t.bills.refs)
+		if err != nil {
+			return nil, err
+		}
+		changes.Bills = stripTokens(bills)
+		gone := missing(t.bills.refs, func(ref string) bool {
+			for _, b := range bills {
+				if security.MatchesRef(ref, b.ID, b.PublicID) {
+					return true
+				}
+			}
+			return false
+		})
+		// Bills named by numeric ID in older events are looked up wherever
+		// they are now
+		publicIDs, ids := splitRefs(gone)
+		moved, err := s.repo.GetBillPublicIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		// The same bill may be named both ways
+		var deleted refSet
+		for _, publicID := range append(publicIDs, moved...) {
+			deleted.add(publicID)
+		}
+		if len(deleted.refs) > 0 {
+			changes.DeletedBillIDs = deleted.refs
+		}
+	}
+	if len(t.members.refs) > 0 {
+		if changes.Members, err = s.repo.GetMembers(tabID, t.members.refs); err != nil {
+			return nil, err
+		}
+	}
+	if len(t.images.refs) > 0 {
+		images, err := s.repo.GetImages(tabID, t.images.refs)
+		if err != nil {
+			return nil, err
+		}
+		changes.Images = images
+		gone := missing(t.images.refs, func(ref string) bool {
+			for _, img := range images {
+				if security.MatchesRef(ref, img.ID, img.PublicID) {
+					return true
+				}
+			}
+			return false
+		})
+		// Deleted images can't be looked up, so only those named by public ID
+		// are reported
+		changes.DeletedImageIDs, _ = splitRefs(gone)
+	}
+	if t.settlements {
+		if changes.Settlements, err = s.repo.GetSettlements(tabID); err != nil {
+			return nil, err
+		}
+	}
+	if t.superseded {
+		if changes.DeletedSettlementIDs, err = s.repo.GetSupersededSettlementIDs(tabID); err != nil {
+			return nil, err
+		}
+	}
+	members, err := s.repo.GetMembers(tabID, nil)
+	if err != nil {
+		return nil, err
+	}
+	changes.linkMembers(models.NewMemberRefs(members))
+	return changes, nil
+}
+
+// snapshot returns everything on the tab. The cursor is read first, so
+// nothing committed while the rest is read is missed.
+func (s *syncService) snapshot(tabID uint) (*Changes, error) {
+	cursor, err := s.repo.LatestEventID(tabID)
+	if err != nil {
+		return nil, err
+	}
+	changes := newChanges(cursor)
+	if err := s.loadTab(tabID, changes); err != nil {
+		return nil, err
+	}
+	bills, err := s.repo.GetBills(tabID, nil)
+	if err != nil {
+		return nil, err
+	}
+	changes.Bills = stripTokens(bills)
+	if changes.Members, err = s.repo.GetMembers(tabID, nil); err != nil {
+		return nil, err
+	}
+	if changes.Images, err = s.repo.GetImages(tabID, nil); err != nil {
+		return nil, err
+	}
+	if changes.Settlements, err = s.repo.GetSettlements(tabID); err != nil {
+		return nil, err
+	}
+	changes.linkMembers(models.NewMemberRefs(changes.Members))
+	return changes, nil
+}
+
+// linkMembers fills in the member references on the changed bills and
+// settlements.
+func (c *Changes) linkMembers(members models.MemberRefs) {
+	for i := range c.Bills {
+		members.Bill(&c.Bills[i])
+	}
+	members.Settlements(c.Settlements)
+}
+
+func (s *syncService) loadTab(tabID uint, changes *Changes) error {
+	tab, err := s.repo.GetTab(tabID)
+	if err != nil {
+		return err
+	}
+	changes.Tab = TabState{
+		PublicID:      tab.PublicID,
+		Name:          tab.Name,
+		Description:   tab.Description,
+		Finalized:     tab.Finalized,
+		FinalizedAt:   tab.FinalizedAt,
+		Recurring:     tab.Recurring,
+		CurrentPeriod: tab.CurrentPeriod,
+		Version:       tab.Version,
+		UpdatedAt:     tab.UpdatedAt,
+	}
+	return nil
+}
+
+func newChanges(cursor uint) *Changes {
+	return &Changes{
+		Cursor:               cursor,
+		Bills:                []models.Bill{},
+		DeletedBillIDs:       []string{},
+		Members:              []models.TabMember{},
+		Images:               []models.TabImage{},
+		DeletedImageIDs:      []string{},
+		Settlements:          []models.TabSettlement{},
+		DeletedSettlementIDs: []string{},
+	}
+}
+
+// touched is what a run of events changed, by public ID or, for events
+// logged before payloads carried public IDs, numeric ID. Settlements are
+// reloaded as a whole; superseded means a reopen or period close replaced
+// them.
+type touched struct {
+	bills, members, images refSet
+	settlements            bool
+	superseded             bool
+}
+
+// refSet collects refs in the order first seen.
+type refSet struct {
+	seen map[string]bool
+	refs []string
+}
+
+func (s *refSet) add(ref string) {
+	if ref == "" || ref == "0" || s.seen[ref] {
+		return
+	}
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	s.seen[ref] = true
+	s.refs = append(s.refs, ref)
+}
+
+func touchedBy(evs []models.Event) (*touched, error) {
+	t := &touched{}
+	for _, ev := range evs {
+		if ev.BillID != nil {
+			t.bills.add(strconv.FormatUint(uint64(*ev.BillID), 10))
+		}
+		// Older bill and image events carried the public ID alongside the
+		// numeric one
+		var payload struct {
+			BillID   security.Ref `json:"bill_id"`
+			MemberID security.Ref `json:"member_id"`
+			ImageID  security.Ref `json:"image_id"`
+			PublicID string       `json:"public_id"`
+		}
+		if err := json.Unmarshal(ev.Payload, &payload); err != nil {
+			return nil, err
+		}
+
+		switch ev.Type {
+		case events.BillCreated{}.EventType(), events.BillAdded{}.EventType(), events.BillRemoved{}.EventType():
+			t.bills.add(orPublicID(payload.BillID, payload.PublicID))
+		case events.MemberJoined{}.EventType():
+			t.members.add(string(payload.MemberID))
+		case events.ImageUploaded{}.EventType(), events.ImageProcessed{}.EventType(), events.ImageDeleted{}.EventType():
+			t.images.add(orPublicID(payload.ImageID, payload.PublicID))
+		case events.TabFinalized{}.EventType(), events.SettlementPaid{}.EventType(), events.SettlementReminder{}.EventType():
+			t.settlements = true
+		case events.TabReopened{}.EventType(), events.PeriodClosed{}.EventType():
+			t.settlements = true
+			t.superseded = true
+		case events.PaymentRecorded{}.EventType(), events.PaymentVoided{}.EventType(),
+			events.PaymentReceived{}.EventType(), events.PaymentDisputed{}.EventType():
+			// Payments on person shares are bill events; the rest are on settlements
+			if ev.BillID == nil {
+				t.settlements = true
+			}
+		}
+	}
+	return t, nil
+}
+
+func orPublicID(ref security.Ref, publicID string) string {
+	if publicID != "" {
+		return publicID
+	}
+	return string(ref)
+}
+
+// missing returns the refs found reports false for.
+func missing(refs []string, found func(ref string) bool) []string {
+	result := []string{}
+	for _, ref := range refs {
+		if !found(ref) {
+			result = append(result, ref)
+		}
+	}
+	return result
+}
+
+// splitRefs separates public IDs from numeric IDs. Refs that are neither are
+// dropped.
+func splitRefs(refs []string) ([]string, []uint) {
+	publicIDs, ids := []string{}, []uint{}
+	for _, ref := range refs {
+		id, publicID, err := security.ParseRef(ref)
+		switch {
+		case err != nil:
+		case publicID != "":
+			publicIDs = append(publicIDs, publicID)
+		default:
+			ids = append(ids, id)
+		}
+	}
+	return publicIDs, ids
+}
+
+func stripTokens(bills []models.Bill) []models.Bill {
+	for i := range bills {
+		bills[i].AccessToken = ""
+	}
+	return bills
+}
+
+// PushBills creates bills made offline on the tab, in order, attributed to
+// actor (nil for an anonymous token holder). Each bill gets its own result: a bill whose client ID is already
+// on the tab is reported as existing rather than created twice, so a batch
+// can be retried whole. An error stops the batch; bills before it are kept.
+func (s *syncService) PushBills(tabID uint, actor *models.TabMember, pushes []BillPush) ([]PushResult, error) {
+	results := make([]PushResult, 0, len(pushes))
+	tabMembers, err := s.repo.GetMembers(tabID, nil)
+	if err != nil {
+		return results, err
+	}
+	members := models.NewMemberRefs(tabMembers)
+	for i := range pushes {
+		result, err := s.push(tabID, actor, members, &pushes[i])
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (s *syncService) push(tabID uint, actor *models.TabMember, members models.MemberRefs, p *BillPush) (PushResult, error) {
+	result := PushResult{ClientID: p.ClientID}
+	invalid := func(err error) (PushResult, error) {
+		result.Status = PushInvalid
+		result.Error = err.Error()
+		return result, nil
+	}
+	if p.ClientID == "" || len(p.ClientID) > maxClientIDLength {
+		return invalid(ErrClientIDInvalid)
+	}
+
+	b := &p.Bill
+	// The period the bill was made in is kept to detect one closed meanwhile
+	period := b.TabPeriod
+	if err := bill.SanitizeBill(b); err != nil {
+		return invalid(err)
+	}
+	b.TabID = &tabID
+	b.AddedByMemberID = nil
+	if actor != nil {
+		b.AddedByMemberID = &actor.ID
+	}
+	b.TabPeriod = period
+	b.ClientID = p.ClientID
+	if err := bill.ResolveMembers(b, members); err != nil {
+		return invalid(err)
+	}
+	if err := bill.ApplySplit(b); err != nil {
+		if bill.IsSplitError(err) {
+			return invalid(err)
+		}
+		return result, err
+	}
+
+	var err error
+	if b.AccessToken, err = security.GenerateSecureToken(); err != nil {
+		return result, err
+	}
+	if b.CreatorToken, err = security.GenerateSecureToken(); err != nil {
+		return result, err
+	}
+
+	stored, existed, err := s.repo.CreateBill(b, actor)
+	if reason := conflictReason(err); reason != "" {
+		result.Status = PushConflict
+		result.Reason = reason
+		result.Error = err.Error()
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	result.Status = PushCreated
+	if existed {
+		result.Status = PushExisting
+	}
+	result.PublicID = stored.PublicID
+	result.AccessToken = stored.AccessToken
+	result.CreatorToken = stored.CreatorToken
+	return result, nil
+}
+
+// conflictReason is the reason code of a push conflict, or "" if err isn't
+// one.
+func conflictReason(err error) string {
+	switch {
+	case errors.Is(err, ErrTabFinalized):
+		return "tab_finalized"
+	case errors.Is(err, ErrPeriodClosed):
+		return "period_closed"
+	case errors.Is(err, ErrClientIDTaken):
+		return "client_id_taken"
+	}
+	return ""
+}
+
+func NewSyncService(repo SyncRepository) SyncService {
+	return &syncService{repo: repo}
+}
@@ -0,0 +1,407 @@
+package tabsync
+
+import (
+	"backend/internal/events"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// ── Mock SyncRepository ─────────────────────────────────────────
+
+type mockSyncRepository struct {
+	tab         models.Tab
+	events      []models.Event
+	bills       []models.Bill
+	members     []models.TabMember
+	images      []models.TabImage
+	settlements []models.TabSettlement
+	superseded  []string
+	created     []models.Bill
+	createErr   error
+}
+
+func (m *mockSyncRepository) LatestEventID(tabID uint) (uint, error) {
+	if len(m.events) == 0 {
+		return 0, nil
+	}
+	return m.events[len(m.events)-1].ID, nil
+}
+
+func (m *mockSyncRepository) EventsSince(tabID uint, afterID uint, limit int) ([]models.Event, error) {
+	var result []models.Event
+	for _, ev := range m.events {
+		if ev.ID > afterID && len(result) < limit {
+			result = append(result, ev)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockSyncRepository) GetTab(tabID uint) (*models.Tab, error) {
+	tab := m.tab
+	return &tab, nil
+}
+
+func wanted(refs []string, id uint, publicID string) bool {
+	if refs == nil {
+		return true
+	}
+	for _, ref := range refs {
+		if security.MatchesRef(ref, id, publicID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mockSyncRepository) GetBills(tabID uint, refs []string) ([]models.Bill, error) {
+	var result []models.Bill
+	for _, b := range m.bills {
+		if b.TabID != nil && *b.TabID == tabID && wanted(refs, b.ID, b.PublicID) {
+			result = append(result, b)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockSyncRepository) GetMembers(tabID uint, refs []string) ([]models.TabMember, error) {
+	var result []models.TabMember
+	for _, member := range m.members {
+		if member.TabID == tabID && wanted(refs, member.ID, member.PublicID) {
+			result = append(result, member)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockSyncRepository) GetImages(tabID uint, refs []string) ([]models.TabImage, error) {
+	var result []models.TabImage
+	for _, img := range m.images {
+		if img.TabID == tabID && wanted(refs, img.ID, img.PublicID) {
+			result = append(result, img)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockSyncRepository) GetSettlements(tabID uint) ([]models.TabSettlement, error) {
+	return m.settlements, nil
+}
+
+func (m *mockSyncRepository) GetSupersededSettlementIDs(tabID uint) ([]string, error) {
+	return m.superseded, nil
+}
+
+func (m *mockSyncRepository) GetBillPublicIDs(ids []uint) ([]string, error) {
+	result := []string{}
+	for _, b := range m.bills {
+		for _, id := range ids {
+			if id == b.ID {
+				result = append(result, b.PublicID)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (m *mockSyncRepository) CreateBill(bill *models.Bill, actor *models.TabMember) (*models.Bill, bool, error) {
+	if m.createErr != nil {
+		return nil, false, m.createErr
+	}
+	for i := range m.created {
+		if m.created[i].ClientID == bill.ClientID {
+			if !sameMember(m.created[i].AddedByMemberID, bill.AddedByMemberID) {
+				return nil, false, ErrClientIDTaken
+			}
+			return &m.created[i], true, nil
+		}
+	}
+	bill.ID = uint(len(m.created) + 1)
+	bill.PublicID = "pub" + bill.ClientID
+	m.created = append(m.created, *bill)
+	return bill, false, nil
+}
+
+// ── Tests ───────────────────────────────────────────────────────
+
+func event(id uint, ev events.DomainEvent, billID *uint) models.Event {
+	payload, _ := json.Marshal(ev)
+	tabID := uint(1)
+	return models.Event{ID: id, TabID: &tabID, BillID: billID, Type: ev.EventType(), Payload: payload}
+}
+
+// publicID pads name out to a well-formed public ID.
+func publicID(name string) string {
+	return name + strings.Repeat("x", security.PublicIDLength-len(name))
+}
+
+func onTab(id uint) models.Bill {
+	tabID := uint(1)
+	return models.Bill{ID: id, PublicID: publicID(fmt.Sprintf("bill%d", id)), TabID: &tabID, AccessToken: "secret"}
+}
+
+func sharedWith(b models.Bill, memberID uint) models.Bill {
+	b.PersonShares = []models.PersonShare{{MemberID: &memberID}}
+	return b
+}
+
+func TestChanges_Snapshot(t *testing.T) {
+	repo := &mockSyncRepository{
+		tab:         models.Tab{ID: 1, Name: "Trip", Version: 7},
+		events:      []models.Event{event(3, events.BillCreated{BillID: security.Ref(publicID("bill1"))}, nil), event(9, events.TabUpdated{Name: "Trip"}, nil)},
+		bills:       []models.Bill{onTab(1), onTab(2)},
+		members:     []models.TabMember{{ID: 1, TabID: 1}},
+		images:      []models.TabImage{{ID: 1, TabID: 1}},
+		settlements: []models.TabSettlement{{ID: 1, TabID: 1}},
+	}
+	changes, err := NewSyncService(repo).Changes(1, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if changes.Cursor != 9 || changes.HasMore {
+		t.Errorf("expected cursor 9 with nothing more, got %d %v", changes.Cursor, changes.HasMore)
+	}
+	if changes.Tab.Name != "Trip" || changes.Tab.Version != 7 {
+		t.Errorf("unexpected tab state %+v", changes.Tab)
+	}
+	if len(changes.Bills) != 2 || len(changes.Members) != 1 || len(changes.Images) != 1 || len(changes.Settlements) != 1 {
+		t.Errorf("expected everything on the tab, got %+v", changes)
+	}
+	if changes.Bills[0].AccessToken != "" {
+		t.Errorf("expected bill tokens stripped")
+	}
+}
+
+func TestChanges_Delta(t *testing.T) {
+	billOne, billTwo := uint(1), uint(2)
+	repo := &mockSyncRepository{
+		tab: models.Tab{ID: 1},
+		events: []models.Event{
+			event(10, events.BillCreated{BillID: security.Ref(publicID("bill1"))}, &billOne),
+			event(11, events.BillAdded{BillID: security.Ref(publicID("bill2")), MemberID: security.Ref(publicID("member2"))}, nil),
+			event(12, events.SharePaid{ShareID: security.Ref(publicID("share5")), Paid: true}, &billOne),
+			event(13, events.BillRemoved{BillID: security.Ref(publicID("bill2"))}, nil),
+			event(14, events.MemberJoined{MemberID: security.Ref(publicID("member3"))}, nil),
+			event(15, events.ImageUploaded{ImageID: security.Ref(publicID("img4"))}, nil),
+			event(16, events.ImageDeleted{ImageID: security.Ref(publicID("img5"))}, nil),
+			event(17, events.PaymentRecorded{PaymentID: security.Ref(publicID("payment1"))}, &billTwo),
+		},
+		bills:   []models.Bill{sharedWith(onTab(1), 2), {ID: 2, PublicID: publicID("bill2")}},
+		members: []models.TabMember{{ID: 2, PublicID: publicID("member2"), TabID: 1}, {ID: 3, PublicID: publicID("member3"), TabID: 1}},
+		images:  []models.TabImage{{ID: 4, PublicID: publicID("img4"), TabID: 1}},
+	}
+	changes, err := NewSyncService(repo).Changes(1, 9)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if changes.Cursor != 17 {
+		t.Errorf("expected cursor 17, got %d", changes.Cursor)
+	}
+	if len(changes.Bills) != 1 || changes.Bills[0].ID != 1 {
+		t.Errorf("expected bill 1 changed, got %+v", changes.Bills)
+	}
+	if ref := changes.Bills[0].PersonShares[0].MemberRef; string(ref) != publicID("member2") {
+		t.Errorf("expected the share to refer to member2, got %q", ref)
+	}
+	if len(changes.DeletedBillIDs) != 1 || changes.DeletedBillIDs[0] != publicID("bill2") {
+		t.Errorf("expected bill 2 deleted, got %v", changes.DeletedBillIDs)
+	}
+	if len(changes.Members) != 1 || changes.Members[0].ID != 3 {
+		t.Errorf("expected only the joined member, got %+v", changes.Members)
+	}
+	if len(changes.Images) != 1 || changes.Images[0].ID != 4 || len(changes.DeletedImageIDs) != 1 || changes.DeletedImageIDs[0] != publicID("img5") {
+		t.Errorf("expected image 4 changed and 5 deleted, got %+v %v", changes.Images, changes.DeletedImageIDs)
+	}
+	if len(changes.Settlements) != 0 || len(changes.DeletedSettlementIDs) != 0 {
+		t.Errorf("expected settlements untouched by share payments")
+	}
+}
+
+func TestChanges_LegacyNumericPayloads(t *testing.T) {
+	tabID := uint(1)
+	legacy := func(id uint, typ string, payload string) models.Event {
+		return models.Event{ID: id, TabID: &tabID, Type: typ, Payload: json.RawMessage(payload)}
+	}
+	repo := &mockSyncRepository{
+		tab: models.Tab{ID: 1},
+		events: []models.Event{
+			legacy(10, "bill.added", `{"bill_id":1}`),
+			legacy(11, "bill.removed", `{"bill_id":2}`),
+			legacy(12, "member.joined", `{"member_id":3}`),
+			legacy(13, "image.deleted", `{"image_id":5,"public_id":"img5xxxxxxxxxxxxxxxxxx"}`),
+			legacy(14, "image.processed", `{"image_id":6,"processed":true}`),
+		},
+		bills:   []models.Bill{onTab(1), {ID: 2, PublicID: publicID("bill2")}},
+		members: []models.TabMember{{ID: 3, PublicID: publicID("member3"), TabID: 1}},
+	}
+	changes, err := NewSyncService(repo).Changes(1, 9)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(changes.Bills) != 1 || changes.Bills[0].ID != 1 {
+		t.Errorf("expected bill 1 changed, got %+v", changes.Bills)
+	}
+	if len(changes.DeletedBillIDs) != 1 || changes.DeletedBillIDs[0] != publicID("bill2") {
+		t.Errorf("expected bill 2 deleted, got %v", changes.DeletedBillIDs)
+	}
+	if len(changes.Members) != 1 || changes.Members[0].ID != 3 {
+		t.Errorf("expected member 3 joined, got %+v", changes.Members)
+	}
+	if len(changes.DeletedImageIDs) != 1 || changes.DeletedImageIDs[0] != publicID("img5") {
+		t.Errorf("expected only image 5 reported deleted, got %v", changes.DeletedImageIDs)
+	}
+}
+
+func TestChanges_Settlements(t *testing.T) {
+	repo := &mockSyncRepository{
+		events:      []models.Event{event(20, events.TabReopened{}, nil), event(21, events.TabFinalized{}, nil)},
+		settlements: []models.TabSettlement{{ID: 8}},
+		superseded:  []string{"settlement4", "settlement5"},
+	}
+	changes, err := NewSyncService(repo).Changes(1, 19)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(changes.Settlements) != 1 || len(changes.DeletedSettlementIDs) != 2 {
+		t.Errorf("expected the new round and the superseded IDs, got %+v %v", changes.Settlements, changes.DeletedSettlementIDs)
+	}
+}
+
+func TestChanges_Pages(t *testing.T) {
+	repo := &mockSyncRepository{}
+	last := uint(eventsPerPage + 10)
+	for id := uint(1); id <= last; id++ {
+		repo.events = append(repo.events, event(id, events.TabUpdated{}, nil))
+	}
+	svc := NewSyncService(repo)
+
+	page, err := svc.Changes(1, 5)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if page.Cursor != eventsPerPage+5 || !page.HasMore {
+		t.Errorf("expected a full page ending at %d, got cursor %d more %v", eventsPerPage+5, page.Cursor, page.HasMore)
+	}
+	page, _ = svc.Changes(1, page.Cursor)
+	if page.Cursor != last || page.HasMore {
+		t.Errorf("expected the last page ending at %d, got cursor %d more %v", last, page.Cursor, page.HasMore)
+	}
+	page, _ = svc.Changes(1, last)
+	if page.Cursor != last || page.HasMore || page.Bills == nil {
+		t.Errorf("expected an empty page at the same cursor, got %+v", page)
+	}
+}
+
+func TestPushBills(t *testing.T) {
+	repo := &mockSyncRepository{}
+	member := &models.TabMember{ID: 3, TabID: 1}
+	pushes := []BillPush{
+		{ClientID: "a", Bill: models.Bill{Name: " Lunch ", Subtotal: 20, SplitMode: "equal", Splits: []models.BillSplit{{PersonName: "Al"}, {PersonName: "Bo"}}, Version: 9}},
+		{ClientID: "", Bill: models.Bill{Name: "No ID"}},
+		{ClientID: "b", Bill: models.Bill{Name: "Bad", Subtotal: 10, SplitMode: "percentage", Splits: []models.BillSplit{{PersonName: "Al", Value: 30}}}},
+		{ClientID: "a", Bill: models.Bill{Name: "Lunch retried"}},
+	}
+	results, err := NewSyncService(repo).PushBills(1, member, pushes)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	wantStatus := []string{PushCreated, PushInvalid, PushInvalid, PushExisting}
+	for i, want := range wantStatus {
+		if results[i].Status != want {
+			t.Errorf("bill %d: status %q, want %q (%s)", i, results[i].Status, want, results[i].Error)
+		}
+	}
+	if len(repo.created) != 1 {
+		t.Fatalf("expected one bill created, got %d", len(repo.created))
+	}
+	b := repo.created[0]
+	if b.TabID == nil || *b.TabID != 1 || b.AddedByMemberID == nil || *b.AddedByMemberID != 3 || b.ClientID != "a" || b.Version != 0 {
+		t.Errorf("unexpected bill %+v", b)
+	}
+	if len(b.PersonShares) != 2 || b.AccessToken == "" || b.CreatorToken == "" {
+		t.Errorf("expected shares and tokens, got %+v", b)
+	}
+	if results[3].PublicID != results[0].PublicID || results[3].AccessToken != results[0].AccessToken {
+		t.Errorf("expected the retry to return the original bill, got %+v", results[3])
+	}
+}
+
+func TestPushBills_Members(t *testing.T) {
+	repo := &mockSyncRepository{members: []models.TabMember{{ID: 4, PublicID: "Kq3Vb8LmT2xYp9Rw5NcZa1", TabID: 1}}}
+	split := func(ref security.Ref) []models.BillSplit {
+		return []models.BillSplit{{PersonName: "Al", MemberRef: ref}, {PersonName: "Bo"}}
+	}
+	pushes := []BillPush{
+		{ClientID: "a", Bill: models.Bill{Name: "Cab", Subtotal: 10, SplitMode: "equal", Splits: split("Kq3Vb8LmT2xYp9Rw5NcZa1")}},
+		{ClientID: "b", Bill: models.Bill{Name: "Bus", Subtotal: 10, SplitMode: "equal", Splits: split("Zz3Vb8LmT2xYp9Rw5NcZa1")}},
+	}
+	results, err := NewSyncService(repo).PushBills(1, nil, pushes)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if results[0].Status != PushCreated || results[1].Status != PushInvalid {
+		t.Fatalf("expected the bill with a stranger's member_id refused, got %+v", results)
+	}
+	share := repo.created[0].PersonShares[0]
+	if share.MemberID == nil || *share.MemberID != 4 {
+		t.Errorf("expected the share linked to member 4, got %+v", share)
+	}
+}
+
+func TestPushBills_OtherMembersClientID(t *testing.T) {
+	repo := &mockSyncRepository{}
+	svc := NewSyncService(repo)
+	alice, bob := &models.TabMember{ID: 3, TabID: 1}, &models.TabMember{ID: 4, TabID: 1}
+	push := func(member *models.TabMember) PushResult {
+		results, err := svc.PushBills(1, member, []BillPush{{ClientID: "a", Bill: models.Bill{Name: "Cab", Subtotal: 10}}})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return results[0]
+	}
+
+	if r := push(alice); r.Status != PushCreated {
+		t.Fatalf("expected the bill created, got %+v", r)
+	}
+	for _, member := range []*models.TabMember{bob, nil} {
+		r := push(member)
+		if r.Status != PushConflict || r.Reason != "client_id_taken" || r.AccessToken != "" || r.CreatorToken != "" {
+			t.Errorf("expected a client_id_taken conflict without tokens, got %+v", r)
+		}
+	}
+	if r := push(alice); r.Status != PushExisting || r.CreatorToken == "" {
+		t.Errorf("expected Alice's retry to return her bill, got %+v", r)
+	}
+}
+
+func TestPushBills_Conflicts(t *testing.T) {
+	cases := []struct {
+		err    error
+		reason string
+	}{
+		{ErrTabFinalized, "tab_finalized"},
+		{ErrPeriodClosed, "period_closed"},
+		{ErrClientIDTaken, "client_id_taken"},
+	}
+	for _, tc := range cases {
+		repo := &mockSyncRepository{createErr: tc.err}
+		results, err := NewSyncService(repo).PushBills(1, nil, []BillPush{{ClientID: "a", Bill: models.Bill{Name: "Cab"}}})
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tc.reason, err)
+		}
+		if results[0].Status != PushConflict || results[0].Reason != tc.reason {
+			t.Errorf("expected a %s conflict, got %+v", tc.reason, results[0])
+		}
+	}
+
+	failure := errors.New("connection reset")
+	repo := &mockSyncRepository{createErr: failure}
+	if _, err := NewSyncService(repo).PushBills(1, nil, []BillPush{{ClientID: "a"}}); !errors.Is(err, failure) {
+		t.Errorf("expected the repository error, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package template
+
+import (
+	"backend/internal/payments"
+	"backend/internal/tabauth"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TabAccess resolves tabs and members for authorization. Satisfied by tab.TabService.
+type TabAccess interface {
+	GetTabByRef(ref string) (*models.Tab, error)
+	GetMemberByToken(token string) (*models.TabMember, error)
+}
+
+type TemplateHandler struct {
+	service TemplateService
+	tabs    TabAccess
+}
+
+func NewTemplateHandler(service TemplateService, tabs TabAccess) *TemplateHandler {
+	return &TemplateHandler{service: service, tabs: tabs}
+}
+
+// bindTemplate reads a template from the request body. Active defaults to
+// true. Writes an error and returns nil if the body is invalid.
+func bindTemplate(c *gin.Context) *models.BillTemplate {
+	var body struct {
+		models.BillTemplate
+		Active *bool `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"})
+		return nil
+	}
+
+	t := body.BillTemplate
+	t.Active = body.Active == nil || *body.Active
+	t.Name = security.SanitizeString(t.Name)
+	for i := range t.Items {
+		t.Items[i].Name = security.SanitizeString(t.Items[i].Name)
+		for j := range t.Items[i].Assignments {
+			t.Items[i].Assignments[j].PersonName = security.SanitizeString(t.Items[i].Assignments[j].PersonName)
+		}
+	}
+	for i := range t.Splits {
+		t.Splits[i].PersonName = security.SanitizeString(t.Splits[i].PersonName)
+	}
+	for i := range t.TipExcluded {
+		t.TipExcluded[i] = security.SanitizeString(t.TipExcluded[i])
+	}
+	for i, method := range t.PaymentMethods {
+		normalized, err := payments.NormalizeMethod(method)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil
+		}
+		t.PaymentMethods[i] = normalized
+	}
+	return &t
+}
+
+// CreateTemplate handles POST /api/tabs/:id/templates
+func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
+	t, member := tabauth.ValidateCreator(c, h.tabs, "manage templates")
+	if t == nil {
+		return
+	}
+	template := bindTemplate(c)
+	if template == nil {
+		return
+	}
+
+	template.TabID = t.ID
+	if member != nil {
+		template.CreatedByMemberID = &member.ID
+	}
+	members := models.NewMemberRefs(t.Members)
+	if err := h.service.Create(template, members, member); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	members.Template(template)
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// ListTemplates handles GET /api/tabs/:id/templates
+func (h *TemplateHandler) ListTemplates(c *gin.Context) {
+	t, _ := tabauth.ValidateCreator(c, h.tabs, "manage templates")
+	if t == nil {
+		return
+	}
+
+	templates, err := h.service.List(t.ID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	members := models.NewMemberRefs(t.Members)
+	for i := range templates {
+		members.Template(&templates[i])
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// UpdateTemplate handles PUT /api/tabs/:id/templates/:templateId
+func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
+	t, member := tabauth.ValidateCreator(c, h.tabs, "manage templates")
+	if t == nil {
+		return
+	}
+	changes := bindTemplate(c)
+	if changes == nil {
+		return
+	}
+
+	members := models.NewMemberRefs(t.Members)
+	template, err := h.service.Update(t.ID, c.Param("templateId"), changes, members, member)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	members.Template(template)
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate handles DELETE /api/tabs/:id/templates/:templateId
+// Bills the template already created are kept.
+func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
+	t, member := tabauth.ValidateCreator(c, h.tabs, "manage templates")
+	if t == nil {
+		return
+	}
+
+	if err := h.service.Delete(t.ID, c.Param("templateId"), member); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h *TemplateHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, security.ErrInvalidRef):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template id"})
+	case err == gorm.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+	case IsValidationError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTooManyTemplates):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("internal error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
+	}
+}
@@ -0,0 +1,252 @@
+package template
+
+import (
+	"backend/internal/audit"
+	"backend/internal/events"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TemplateRepository interface {
+	Create(template *models.BillTemplate, actor *models.TabMember) error
+	GetByID(id uint) (*models.BillTemplate, error)
+	GetByPublicID(publicID string) (*models.BillTemplate, error)
+	ListByTab(tabID uint) ([]models.BillTemplate, error)
+	Update(template *models.BillTemplate, actor *models.TabMember) error
+	Delete(template *models.BillTemplate, actor *models.TabMember) error
+	RunDue(now time.Time, limit int) (int, error)
+}
+
+// retryDelay is how long a template whose run failed waits before it is
+// tried again.
+const retryDelay = 15 * time.Minute
+
+type templateRepository struct {
+	db *gorm.DB
+}
+
+func (r *templateRepository) Create(template *models.BillTemplate, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(template).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  template.TabID,
+			Actor:  actor,
+			Action: "template.created",
+			Target: "template:" + template.PublicID,
+			After:  auditFields(template),
+		})
+	})
+}
+
+func (r *templateRepository) GetByID(id uint) (*models.BillTemplate, error) {
+	template := &models.BillTemplate{}
+	err := r.db.First(template, id).Error
+	return template, err
+}
+
+func (r *templateRepository) GetByPublicID(publicID string) (*models.BillTemplate, error) {
+	template := &models.BillTemplate{}
+	err := r.db.Where("public_id = ?", publicID).First(template).Error
+	return template, err
+}
+
+func (r *templateRepository) ListByTab(tabID uint) ([]models.BillTemplate, error) {
+	var templates []models.BillTemplate
+	err := r.db.Where("tab_id = ?", tabID).Order("id ASC").Find(&templates).Error
+	return templates, err
+}
+
+// Update saves every field but the template's identity and last run.
+func (r *templateRepository) Update(template *models.BillTemplate, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		before := &models.BillTemplate{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(before, template.ID).Error; err != nil {
+			return err
+		}
+		err := tx.Model(template).Select("*").Omit("id", "public_id", "tab_id", "created_by_member_id", "last_run_at", "created_at").Updates(template).Error
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  template.TabID,
+			Actor:  actor,
+			Action: "template.updated",
+			Target: "template:" + template.PublicID,
+			Before: auditFields(before),
+			After:  auditFields(template),
+		})
+	})
+}
+
+func (r *templateRepository) Delete(template *models.BillTemplate, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.BillTemplate{}, template.ID).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  template.TabID,
+			Actor:  actor,
+			Action: "template.deleted",
+			Target: "template:" + template.PublicID,
+			Before: auditFields(template),
+		})
+	})
+}
+
+// auditFields is the audited view of a template.
+func auditFields(t *models.BillTemplate) gin.H {
+	return gin.H{
+		"name":         t.Name,
+		"total":        t.Total,
+		"frequency":    t.Frequency,
+		"day_of_month": t.DayOfMonth,
+		"weekday":      t.Weekday,
+		"active":       t.Active,
+	}
+}
+
+// RunDue creates the bill for the next run of up to limit active templates
+// that are due, and moves each on to its following run. Both commit together,
+// and bills are unique per template and run, so a restart never creates a run
+// twice. Runs that fall while the tab is finalized are skipped rather than
+// added to a settled tab; bills on recurring tabs go into the open period. A
+// template that fell behind catches up one run per call. Each template runs in
+// its own savepoint, so one that fails is held back for retryDelay without
+// holding back the rest. It returns the number of runs made.
+func (r *templateRepository) RunDue(now time.Time, limit int) (int, error) {
+	var templates []models.BillTemplate
+	runs := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("active AND next_run_at <= ? AND (retry_at IS NULL OR retry_at <= ?)", now, now).
+			Order("next_run_at ASC").Limit(limit).
+			Find(&templates).Error
+		if err != nil || len(templates) == 0 {
+			return err
+		}
+
+		tabIDs := make([]uint, len(templates))
+		for i, t := range templates {
+			tabIDs[i] = t.TabID
+		}
+		// The tabs stay locked, so none is finalized while its bills are made
+		var tabs []models.Tab
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "finalized").
+			Where("id IN ?", tabIDs).Order("id ASC").Find(&tabs).Error
+		if err != nil {
+			return err
+		}
+		finalized := make(map[uint]bool, len(tabs))
+		for _, tab := range tabs {
+			finalized[tab.ID] = tab.Finalized
+		}
+
+		for i := range templates {
+			t := &templates[i]
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				return runTemplate(tx, t, finalized[t.TabID])
+			})
+			if err == nil {
+				runs++
+				continue
+			}
+			log.Printf("template %d: %v", t.ID, err)
+			err = tx.Model(&models.BillTemplate{}).Where("id = ?", t.ID).Update("retry_at", now.Add(retryDelay)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return runs, nil
+}
+
+// runTemplate creates the template's bill for its next run, unless the tab
+// is finalized, and moves it on to the following run.
+func runTemplate(tx *gorm.DB, t *models.BillTemplate, finalized bool) error {
+	run := t.NextRunAt.UTC()
+	updates := map[string]interface{}{"next_run_at": followingRun(t, run), "retry_at": nil}
+	if !finalized {
+		created, err := createRun(tx, t, run)
+		if err != nil {
+			return err
+		}
+		if created {
+			updates["last_run_at"] = run
+		} else {
+			// The template no longer makes a valid bill
+			updates["active"] = false
+		}
+	}
+	return tx.Model(&models.BillTemplate{}).Where("id = ?", t.ID).Updates(updates).Error
+}
+
+// createRun creates the template's bill for the run on date and records its
+// bill.created event. It reports false, without creating anything, if the
+// template doesn't make a valid bill. A bill already holding the run counts
+// as created.
+func createRun(tx *gorm.DB, template *models.BillTemplate, date time.Time) (bool, error) {
+	var members []models.TabMember
+	if err := tx.Select("id", "public_id").Where("tab_id = ?", template.TabID).Find(&members).Error; err != nil {
+		return false, err
+	}
+	bill, err := newBill(template, date, models.NewMemberRefs(members))
+	if err != nil {
+		log.Printf("template %d: %v", template.ID, err)
+		return false, nil
+	}
+	if bill.AccessToken, err = security.GenerateSecureToken(); err != nil {
+		return false, err
+	}
+	if bill.CreatorToken, err = security.GenerateSecureToken(); err != nil {
+		return false, err
+	}
+	bill.TemplateID = &template.ID
+	bill.ScheduledFor = &date
+
+	// A savepoint of its own keeps the transaction usable if the run is taken
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(bill).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.Bill{}).Where("id = ?", bill.ID).
+			Update("tab_period", gorm.Expr("(SELECT current_period FROM tabs WHERE id = ?)", template.TabID)).Error
+		if err != nil {
+			return err
+		}
+		return events.RecordBill(tx, bill.ID, bill.TabID, events.BillCreated{
+			BillID: security.Ref(bill.PublicID),
+			Name:   bill.Name,
+			Total:  bill.Total,
+		})
+	})
+	if isRunTaken(err) {
+		log.Printf("template %d: run %s already has a bill", template.ID, date.Format(time.RFC3339))
+		return true, nil
+	}
+	return err == nil, err
+}
+
+// isRunTaken reports whether err is a violation of the one bill per template
+// and run constraint.
+func isRunTaken(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_bill_template_run"
+}
+
+func NewTemplateRepository(db *gorm.DB) TemplateRepository {
+	return &templateRepository{db: db}
+}
@@ -0,0 +1,54 @@
+package template
+
+import (
+	"backend/pkg/models"
+	"strings"
+	"time"
+)
+
+// Frequencies a template can run at.
+const (
+	FrequencyMonthly = "monthly"
+	FrequencyWeekly  = "weekly"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// nextRun returns the template's first run on or after from's date. Runs are
+// at midnight UTC.
+func nextRun(t *models.BillTemplate, from time.Time) time.Time {
+	from = from.UTC()
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	if t.Frequency == FrequencyWeekly {
+		wait := (int(weekdays[strings.ToLower(t.Weekday)]) - int(day.Weekday()) + 7) % 7
+		return day.AddDate(0, 0, wait)
+	}
+	run := monthDay(day.Year(), day.Month(), t.DayOfMonth)
+	if run.Before(day) {
+		run = monthDay(day.Year(), day.Month()+1, t.DayOfMonth)
+	}
+	return run
+}
+
+// followingRun returns the run after the one on date.
+func followingRun(t *models.BillTemplate, date time.Time) time.Time {
+	return nextRun(t, date.AddDate(0, 0, 1))
+}
+
+// monthDay returns the given day of the month, or the month's last day if it
+// is shorter.
+func monthDay(year int, month time.Month, day int) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,49 @@
+package template
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const runsPerTick = 100
+
+// Scheduler periodically creates the bills of due templates. Several
+// schedulers can run at once; each due template is locked by one of them.
+type Scheduler struct {
+	repo     TemplateRepository
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewScheduler(repo TemplateRepository) *Scheduler {
+	return &Scheduler{repo: repo, interval: time.Minute, now: time.Now}
+}
+
+// Run creates due bills until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunDue(); err != nil {
+			log.Printf("template scheduler: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue runs every template that is due and returns how many runs it made.
+func (s *Scheduler) RunDue() (int, error) {
+	total := 0
+	for {
+		n, err := s.repo.RunDue(s.now(), runsPerTick)
+		total += n
+		if err != nil || n < runsPerTick {
+			return total, err
+		}
+	}
+}
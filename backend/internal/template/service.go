@@ -0,0 +1,247 @@
+package template
+
+import (
+	"backend/internal/bill"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const maxTemplatesPerTab = 20
+
+var (
+	ErrNameRequired      = errors.New("name is required")
+	ErrUnknownFrequency  = errors.New("frequency must be monthly or weekly")
+	ErrInvalidDayOfMonth = errors.New("day_of_month must be between 1 and 31")
+	ErrInvalidWeekday    = errors.New("weekday must be a day of the week, such as monday")
+	ErrNothingToSplit    = errors.New("templates need items or splits")
+	ErrTooManyTemplates  = errors.New("tab already has the maximum number of templates")
+)
+
+type TemplateService interface {
+	Create(template *models.BillTemplate, members models.MemberRefs, actor *models.TabMember) error
+	List(tabID uint) ([]models.BillTemplate, error)
+	Get(tabID uint, ref string) (*models.BillTemplate, error)
+	Update(tabID uint, ref string, changes *models.BillTemplate, members models.MemberRefs, actor *models.TabMember) (*models.BillTemplate, error)
+	Delete(tabID uint, ref string, actor *models.TabMember) error
+}
+
+type templateService struct {
+	repo TemplateRepository
+	now  func() time.Time
+}
+
+// Create validates the template against the tab's members and schedules its
+// first run, which may be today.
+func (s *templateService) Create(template *models.BillTemplate, members models.MemberRefs, actor *models.TabMember) error {
+	if err := validate(template, members); err != nil {
+		return err
+	}
+	existing, err := s.repo.ListByTab(template.TabID)
+	if err != nil {
+		return err
+	}
+	if len(existing) >= maxTemplatesPerTab {
+		return ErrTooManyTemplates
+	}
+	template.NextRunAt = nextRun(template, s.now())
+	return s.repo.Create(template, actor)
+}
+
+func (s *templateService) List(tabID uint) ([]models.BillTemplate, error) {
+	return s.repo.ListByTab(tabID)
+}
+
+// Get resolves a template by public or numeric ID, scoped to the tab.
+func (s *templateService) Get(tabID uint, ref string) (*models.BillTemplate, error) {
+	id, publicID, err := security.ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	var template *models.BillTemplate
+	if publicID != "" {
+		template, err = s.repo.GetByPublicID(publicID)
+	} else {
+		template, err = s.repo.GetByID(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if template.TabID != tabID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return template, nil
+}
+
+// Update replaces the template's bill and schedule and reschedules its next
+// run. A run that already created a bill is not repeated.
+func (s *templateService) Update(tabID uint, ref string, changes *models.BillTemplate, members models.MemberRefs, actor *models.TabMember) (*models.BillTemplate, error) {
+	template, err := s.Get(tabID, ref)
+	if err != nil {
+		return nil, err
+	}
+	changes.ID = template.ID
+	changes.PublicID = template.PublicID
+	changes.TabID = template.TabID
+	changes.CreatedByMemberID = template.CreatedByMemberID
+	changes.LastRunAt = template.LastRunAt
+	changes.CreatedAt = template.CreatedAt
+	if err := validate(changes, members); err != nil {
+		return nil, err
+	}
+
+	from := s.now()
+	if changes.LastRunAt != nil && from.Before(changes.LastRunAt.AddDate(0, 0, 1)) {
+		from = changes.LastRunAt.AddDate(0, 0, 1)
+	}
+	changes.NextRunAt = nextRun(changes, from)
+	if err := s.repo.Update(changes, actor); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (s *templateService) Delete(tabID uint, ref string, actor *models.TabMember) error {
+	template, err := s.Get(tabID, ref)
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(template, actor)
+}
+
+// validate checks the schedule and that the template makes a valid bill,
+// normalizing both the way bills are. Member references are stored as the
+// members' public IDs.
+func validate(template *models.BillTemplate, members models.MemberRefs) error {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return ErrNameRequired
+	}
+
+	template.Frequency = strings.ToLower(strings.TrimSpace(template.Frequency))
+	switch template.Frequency {
+	case FrequencyMonthly:
+		if template.DayOfMonth < 1 || template.DayOfMonth > 31 {
+			return ErrInvalidDayOfMonth
+		}
+		template.Weekday = ""
+	case FrequencyWeekly:
+		template.Weekday = strings.ToLower(strings.TrimSpace(template.Weekday))
+		if _, ok := weekdays[template.Weekday]; !ok {
+			return ErrInvalidWeekday
+		}
+		template.DayOfMonth = 0
+	default:
+		return ErrUnknownFrequency
+	}
+
+	if err := linkMembers(template, members); err != nil {
+		return err
+	}
+	b, err := newBill(template, time.Now(), members)
+	if err != nil {
+		return err
+	}
+	if len(b.PersonShares) == 0 {
+		return ErrNothingToSplit
+	}
+	template.SplitMode = b.SplitMode
+	template.TaxPolicy = b.TaxPolicy
+	template.TipPolicy = b.TipPolicy
+	template.TipBase = b.TipBase
+	template.TipExcluded = b.TipExcluded
+	template.TipAmount = b.TipAmount
+	template.Total = b.Total
+	return nil
+}
+
+// linkMembers checks that each member the template refers to is one of
+// members and stores the reference as the member's public ID.
+func linkMembers(template *models.BillTemplate, members models.MemberRefs) error {
+	link := func(ref *security.Ref) error {
+		id, ok := members.Resolve(*ref)
+		if !ok {
+			return bill.ErrUnknownMember
+		}
+		*ref = members.Ref(id)
+		return nil
+	}
+	for i := range template.Items {
+		for j := range template.Items[i].Assignments {
+			if err := link(&template.Items[i].Assignments[j].MemberRef); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range template.Splits {
+		if err := link(&template.Splits[i].MemberRef); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newBill builds the bill a template creates for its run on date, with its
+// person shares computed and linked to the tab's members.
+func newBill(template *models.BillTemplate, date time.Time, members models.MemberRefs) (*models.Bill, error) {
+	tabID := template.TabID
+	b := &models.Bill{
+		TabID:           &tabID,
+		AddedByMemberID: template.CreatedByMemberID,
+		Name:            template.Name,
+		Subtotal:        template.Subtotal,
+		Tax:             template.Tax,
+		TipAmount:       template.TipAmount,
+		TipPercentage:   template.TipPercentage,
+		ServiceCharge:   template.ServiceCharge,
+		DeliveryFee:     template.DeliveryFee,
+		Total:           template.Total,
+		Date:            date,
+		PaymentMethods:  template.PaymentMethods,
+		TaxPolicy:       template.TaxPolicy,
+		TipPolicy:       template.TipPolicy,
+		TipBase:         template.TipBase,
+		TipExcluded:     append([]string(nil), template.TipExcluded...),
+		SplitMode:       template.SplitMode,
+	}
+	for _, item := range template.Items {
+		billItem := models.BillItem{Name: item.Name, Price: item.Price, TaxExempt: item.TaxExempt}
+		for _, a := range item.Assignments {
+			billItem.Assignments = append(billItem.Assignments, models.ItemAssignment{
+				PersonName: a.PersonName,
+				MemberRef:  a.MemberRef,
+				Percentage: a.Percentage,
+			})
+		}
+		b.Items = append(b.Items, billItem)
+	}
+	for _, split := range template.Splits {
+		b.Splits = append(b.Splits, models.BillSplit{PersonName: split.PersonName, MemberRef: split.MemberRef, Value: split.Value})
+	}
+	if err := bill.ResolveMembers(b, members); err != nil {
+		return nil, err
+	}
+	if err := bill.ApplySplit(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// IsValidationError reports whether err means the submitted template was
+// invalid.
+func IsValidationError(err error) bool {
+	for _, target := range []error{ErrNameRequired, ErrUnknownFrequency, ErrInvalidDayOfMonth, ErrInvalidWeekday, ErrNothingToSplit} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return bill.IsSplitError(err)
+}
+
+func NewTemplateService(repo TemplateRepository) TemplateService {
+	return &templateService{repo: repo, now: time.Now}
+}
@@ -0,0 +1,299 @@
+package template
+
+import (
+	"backend/internal/bill"
+	"backend/pkg/models"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ── Mock TemplateRepository ─────────────────────────────────────
+
+type mockTemplateRepository struct {
+	templates []models.BillTemplate
+	updated   *models.BillTemplate
+	deleted   uint
+	due       int
+	runLimits []int
+}
+
+func (m *mockTemplateRepository) Create(template *models.BillTemplate, actor *models.TabMember) error {
+	template.ID = uint(len(m.templates) + 1)
+	m.templates = append(m.templates, *template)
+	return nil
+}
+
+func (m *mockTemplateRepository) GetByID(id uint) (*models.BillTemplate, error) {
+	for i := range m.templates {
+		if m.templates[i].ID == id {
+			return &m.templates[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockTemplateRepository) GetByPublicID(publicID string) (*models.BillTemplate, error) {
+	for i := range m.templates {
+		if m.templates[i].PublicID == publicID {
+			return &m.templates[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockTemplateRepository) ListByTab(tabID uint) ([]models.BillTemplate, error) {
+	var result []models.BillTemplate
+	for _, t := range m.templates {
+		if t.TabID == tabID {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockTemplateRepository) Update(template *models.BillTemplate, actor *models.TabMember) error {
+	m.updated = template
+	return nil
+}
+
+func (m *mockTemplateRepository) Delete(template *models.BillTemplate, actor *models.TabMember) error {
+	m.deleted = template.ID
+	return nil
+}
+
+func (m *mockTemplateRepository) RunDue(now time.Time, limit int) (int, error) {
+	m.runLimits = append(m.runLimits, limit)
+	n := m.due
+	if n > limit {
+		n = limit
+	}
+	m.due -= n
+	return n, nil
+}
+
+// ── Tests ───────────────────────────────────────────────────────
+
+func rent() *models.BillTemplate {
+	return &models.BillTemplate{
+		TabID:      1,
+		Name:       "Rent",
+		Subtotal:   2000,
+		SplitMode:  "percentage",
+		Splits:     []models.TemplateSplit{{PersonName: "Alice", Value: 60}, {PersonName: "Bob", Value: 40}},
+		Frequency:  "Monthly",
+		DayOfMonth: 1,
+		Active:     true,
+	}
+}
+
+func newTestService(repo *mockTemplateRepository, now time.Time) *templateService {
+	return &templateService{repo: repo, now: func() time.Time { return now }}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestNextRun(t *testing.T) {
+	cases := []struct {
+		name     string
+		template models.BillTemplate
+		from     time.Time
+		want     time.Time
+	}{
+		{"monthly later this month", models.BillTemplate{Frequency: FrequencyMonthly, DayOfMonth: 15}, date(2026, 3, 10), date(2026, 3, 15)},
+		{"monthly today", models.BillTemplate{Frequency: FrequencyMonthly, DayOfMonth: 15}, date(2026, 3, 15).Add(9 * time.Hour), date(2026, 3, 15)},
+		{"monthly next month", models.BillTemplate{Frequency: FrequencyMonthly, DayOfMonth: 1}, date(2026, 3, 2), date(2026, 4, 1)},
+		{"monthly short month", models.BillTemplate{Frequency: FrequencyMonthly, DayOfMonth: 31}, date(2026, 2, 1), date(2026, 2, 28)},
+		{"monthly across year", models.BillTemplate{Frequency: FrequencyMonthly, DayOfMonth: 31}, date(2026, 12, 31).AddDate(0, 0, 1), date(2027, 1, 31)},
+		{"weekly", models.BillTemplate{Frequency: FrequencyWeekly, Weekday: "friday"}, date(2026, 10, 18), date(2026, 10, 23)},
+		{"weekly today", models.BillTemplate{Frequency: FrequencyWeekly, Weekday: "sunday"}, date(2026, 10, 18), date(2026, 10, 18)},
+	}
+	for _, tc := range cases {
+		if got := nextRun(&tc.template, tc.from); !got.Equal(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	monthly := &models.BillTemplate{Frequency: FrequencyMonthly, DayOfMonth: 31}
+	if got := followingRun(monthly, date(2026, 1, 31)); !got.Equal(date(2026, 2, 28)) {
+		t.Errorf("expected the run after Jan 31 on Feb 28, got %v", got)
+	}
+	if got := followingRun(monthly, date(2026, 2, 28)); !got.Equal(date(2026, 3, 31)) {
+		t.Errorf("expected the run after Feb 28 on Mar 31, got %v", got)
+	}
+}
+
+func TestCreate_Validation(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(*models.BillTemplate)
+		want   error
+	}{
+		{"valid", func(*models.BillTemplate) {}, nil},
+		{"missing name", func(t *models.BillTemplate) { t.Name = " " }, ErrNameRequired},
+		{"unknown frequency", func(t *models.BillTemplate) { t.Frequency = "daily" }, ErrUnknownFrequency},
+		{"day out of range", func(t *models.BillTemplate) { t.DayOfMonth = 32 }, ErrInvalidDayOfMonth},
+		{"missing day", func(t *models.BillTemplate) { t.DayOfMonth = 0 }, ErrInvalidDayOfMonth},
+		{"bad weekday", func(t *models.BillTemplate) { t.Frequency = "weekly"; t.Weekday = "someday" }, ErrInvalidWeekday},
+		{"nothing to split", func(t *models.BillTemplate) { t.SplitMode = ""; t.Splits = nil }, ErrNothingToSplit},
+		{"bad percentages", func(t *models.BillTemplate) { t.Splits[1].Value = 50 }, bill.ErrPercentMismatch},
+	}
+	for _, tc := range cases {
+		template := rent()
+		tc.modify(template)
+		err := newTestService(&mockTemplateRepository{}, date(2026, 10, 18)).Create(template, nil, nil)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: error = %v, want %v", tc.name, err, tc.want)
+		}
+		if err != nil && !IsValidationError(err) {
+			t.Errorf("%s: expected a validation error, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestCreate_SchedulesAndNormalizes(t *testing.T) {
+	repo := &mockTemplateRepository{}
+	template := rent()
+	template.TipPercentage = 10
+	if err := newTestService(repo, date(2026, 10, 18).Add(15*time.Hour)).Create(template, nil, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !template.NextRunAt.Equal(date(2026, 11, 1)) {
+		t.Errorf("expected first run on Nov 1, got %v", template.NextRunAt)
+	}
+	if template.Frequency != FrequencyMonthly || template.TaxPolicy != bill.PolicyProportional {
+		t.Errorf("expected normalized frequency and policies, got %q %q", template.Frequency, template.TaxPolicy)
+	}
+	if template.TipAmount != 200 || template.Total != 2200 {
+		t.Errorf("expected tip and total filled in, got %.2f %.2f", template.TipAmount, template.Total)
+	}
+}
+
+func TestCreate_EnforcesLimit(t *testing.T) {
+	repo := &mockTemplateRepository{}
+	svc := newTestService(repo, date(2026, 10, 18))
+	for i := 0; i < maxTemplatesPerTab; i++ {
+		if err := svc.Create(rent(), nil, nil); err != nil {
+			t.Fatalf("template %d: %v", i, err)
+		}
+	}
+	if err := svc.Create(rent(), nil, nil); !errors.Is(err, ErrTooManyTemplates) {
+		t.Errorf("expected ErrTooManyTemplates, got %v", err)
+	}
+}
+
+func TestUpdate_DoesNotRepeatTodaysRun(t *testing.T) {
+	ran := date(2026, 10, 18)
+	repo := &mockTemplateRepository{}
+	existing := rent()
+	existing.PublicID = "7hT2kQ9mN8pL1vR7tY3wBd"
+	existing.Frequency = FrequencyWeekly
+	existing.Weekday = "sunday"
+	existing.LastRunAt = &ran
+	repo.templates = []models.BillTemplate{*existing}
+	repo.templates[0].ID = 1
+
+	changes := rent()
+	changes.TabID = 0
+	changes.Frequency = FrequencyWeekly
+	changes.Weekday = "Sunday"
+	changes.Subtotal = 2100
+	updated, err := newTestService(repo, ran.Add(8*time.Hour)).Update(1, "7hT2kQ9mN8pL1vR7tY3wBd", changes, nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if updated.TabID != 1 || updated.ID != 1 || updated.LastRunAt == nil {
+		t.Errorf("expected identity and last run kept, got %+v", updated)
+	}
+	if !updated.NextRunAt.Equal(date(2026, 10, 25)) {
+		t.Errorf("expected next run a week on, got %v", updated.NextRunAt)
+	}
+	if repo.updated == nil || repo.updated.Total != 2100 {
+		t.Errorf("expected the new total saved, got %+v", repo.updated)
+	}
+}
+
+func TestGet_ScopedToTab(t *testing.T) {
+	repo := &mockTemplateRepository{templates: []models.BillTemplate{{ID: 1, TabID: 2}}}
+	svc := newTestService(repo, time.Now())
+	if _, err := svc.Get(1, "1"); err != gorm.ErrRecordNotFound {
+		t.Errorf("expected not found for another tab's template, got %v", err)
+	}
+	if err := svc.Delete(1, "1", nil); err != gorm.ErrRecordNotFound || repo.deleted != 0 {
+		t.Errorf("expected delete refused, got %v", err)
+	}
+}
+
+func TestNewBill_ComputesShares(t *testing.T) {
+	member := uint(4)
+	template := &models.BillTemplate{
+		TabID:             1,
+		CreatedByMemberID: &member,
+		Name:              "Utilities",
+		Subtotal:          90,
+		Tax:               9,
+		Items: []models.TemplateItem{
+			{Name: "Power", Price: 60, Assignments: []models.TemplateAssignment{{PersonName: "Alice", MemberRef: "4", Percentage: 50}, {PersonName: "Bob", Percentage: 50}}},
+			{Name: "Internet", Price: 30, Assignments: []models.TemplateAssignment{{PersonName: "Bob", Percentage: 100}}},
+		},
+		Frequency:  FrequencyMonthly,
+		DayOfMonth: 5,
+	}
+	members := models.MemberRefs{4: "Kq3Vb8LmT2xYp9Rw5NcZa1"}
+	b, err := newBill(template, date(2026, 11, 5), members)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if b.TabID == nil || *b.TabID != 1 || b.AddedByMemberID != &member || !b.Date.Equal(date(2026, 11, 5)) {
+		t.Errorf("unexpected bill %+v", b)
+	}
+	if b.Total != 99 || len(b.PersonShares) != 2 {
+		t.Fatalf("expected total 99 over 2 shares, got %.2f over %d", b.Total, len(b.PersonShares))
+	}
+	if b.PersonShares[0].Total != 33 || b.PersonShares[1].Total != 66 {
+		t.Errorf("expected 33/66, got %.2f/%.2f", b.PersonShares[0].Total, b.PersonShares[1].Total)
+	}
+	if len(b.Items) != 2 || len(b.Items[0].Assignments) != 2 {
+		t.Errorf("expected items and assignments copied, got %+v", b.Items)
+	}
+	if id := b.PersonShares[0].MemberID; id == nil || *id != 4 {
+		t.Errorf("expected Alice's share linked to member 4, got %v", id)
+	}
+}
+
+func TestLinkMembers(t *testing.T) {
+	members := models.MemberRefs{4: "Kq3Vb8LmT2xYp9Rw5NcZa1"}
+	template := &models.BillTemplate{
+		Items:  []models.TemplateItem{{Assignments: []models.TemplateAssignment{{PersonName: "Alice", MemberRef: "4"}}}},
+		Splits: []models.TemplateSplit{{PersonName: "Alice", MemberRef: "Kq3Vb8LmT2xYp9Rw5NcZa1"}, {PersonName: "Bob"}},
+	}
+	if err := linkMembers(template, members); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if ref := template.Items[0].Assignments[0].MemberRef; ref != "Kq3Vb8LmT2xYp9Rw5NcZa1" {
+		t.Errorf("expected the numeric reference stored as the public ID, got %q", ref)
+	}
+	if template.Splits[1].MemberRef != "" {
+		t.Errorf("expected no member on Bob's split, got %q", template.Splits[1].MemberRef)
+	}
+
+	template.Splits[0].MemberRef = "5"
+	if err := linkMembers(template, members); !errors.Is(err, bill.ErrUnknownMember) {
+		t.Errorf("expected ErrUnknownMember, got %v", err)
+	}
+}
+
+func TestScheduler_RunsInBatches(t *testing.T) {
+	repo := &mockTemplateRepository{due: runsPerTick + 3}
+	n, err := NewScheduler(repo).RunDue()
+	if err != nil || n != runsPerTick+3 {
+		t.Errorf("expected %d runs, got %d, %v", runsPerTick+3, n, err)
+	}
+	if len(repo.runLimits) != 2 {
+		t.Errorf("expected 2 batches, got %d", len(repo.runLimits))
+	}
+}
@@ -2,9 +2,10 @@ package web
 
 import (
 	"backend/internal/bill"
+	"backend/pkg/security"
 	"crypto/subtle"
+	"errors"
 	"log"
-	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -36,14 +37,12 @@ func (h *WebpageHandler) CreateHTML(c *gin.Context) {
 		return
 	}
 
-	idUint, err := strconv.ParseUint(id, 10, 32)
-	if err != nil {
-		c.JSON(400, gin.H{"error": "invalid id format"})
-		return
-	}
-
-	bill, err := h.service.GetBill(uint(idUint))
+	bill, err := h.service.GetBillByRef(id)
 	if err != nil {
+		if errors.Is(err, security.ErrInvalidRef) {
+			c.JSON(400, gin.H{"error": "invalid id format"})
+			return
+		}
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(404, gin.H{"error": "bill not found"})
 			return
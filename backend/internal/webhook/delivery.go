@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"backend/pkg/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	maxDeliveryAttempts = 8
+	baseDeliveryDelay   = 30 * time.Second
+	maxDeliveryDelay    = time.Hour
+	deliveryTimeout     = 10 * time.Second
+	deliveriesPerTick   = 10
+	// deliveryLease outlasts a tick's deliveries timing out one after another,
+	// so a claimed delivery is never claimed again while it is being sent
+	deliveryLease = deliveriesPerTick*deliveryTimeout + time.Minute
+)
+
+var errBlockedTarget = errors.New("webhook target resolves to a private address")
+
+// Sign returns the signature header value for a delivery: an HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook's secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Worker sends queued webhook deliveries and retries failures with
+// exponential backoff.
+type Worker struct {
+	repo     WebhookRepository
+	client   *http.Client
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewWorker(repo WebhookRepository, allowPrivate bool) *Worker {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = rejectPrivate
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Worker{
+		repo: repo,
+		client: &http.Client{
+			Timeout:   deliveryTimeout,
+			Transport: transport,
+			// Redirects are treated as failures so a target can't bounce us elsewhere
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		interval: 2 * time.Second,
+		now:      time.Now,
+	}
+}
+
+// rejectPrivate refuses connections to loopback, private and link-local
+// addresses, checked after DNS resolution.
+func rejectPrivate(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errBlockedTarget
+	}
+	return nil
+}
+
+// Run sends due deliveries until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.DeliverDue(ctx); err != nil {
+			log.Printf("webhook delivery: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue attempts every due delivery once and returns how many succeeded.
+func (w *Worker) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := w.repo.ClaimDue(w.now(), deliveryLease, deliveriesPerTick)
+	if err != nil {
+		return 0, err
+	}
+	succeeded := 0
+	for i := range deliveries {
+		d := &deliveries[i]
+		w.attempt(ctx, d)
+		if err := w.repo.UpdateDelivery(d); err != nil {
+			log.Printf("webhook delivery %d: %v", d.ID, err)
+			continue
+		}
+		if d.Status == StatusSucceeded {
+			succeeded++
+		}
+	}
+	return succeeded, nil
+}
+
+// attempt sends the delivery once and updates its status in place.
+func (w *Worker) attempt(ctx context.Context, d *models.WebhookDelivery) {
+	if d.Webhook == nil || !d.Webhook.Active {
+		d.Status = StatusSkipped
+		return
+	}
+
+	d.Attempts++
+	status, err := w.send(ctx, d)
+	d.ResponseStatus = status
+	now := w.now()
+	if err == nil {
+		d.Status = StatusSucceeded
+		d.LastError = ""
+		d.DeliveredAt = &now
+		return
+	}
+
+	d.LastError = err.Error()
+	if d.Attempts >= maxDeliveryAttempts {
+		d.Status = StatusFailed
+		return
+	}
+	d.NextAttemptAt = now.Add(deliveryDelay(d.Attempts))
+}
+
+func (w *Worker) send(ctx context.Context, d *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Webhook.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := w.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Billington-Webhooks/1.0")
+	req.Header.Set("X-Billington-Event", d.EventType)
+	req.Header.Set("X-Billington-Delivery", strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set("X-Billington-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Billington-Signature", Sign(d.Webhook.Secret, timestamp, d.Body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// deliveryDelay doubles from baseDeliveryDelay with each attempt, capped at maxDeliveryDelay.
+func deliveryDelay(attempts int) time.Duration {
+	delay := baseDeliveryDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDeliveryDelay {
+			return maxDeliveryDelay
+		}
+	}
+	return delay
+}
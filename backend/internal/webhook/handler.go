@@ -0,0 +1,180 @@
+package webhook
+
+import (
+	"backend/internal/tabauth"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TabAccess resolves tabs and members for authorization. Satisfied by tab.TabService.
+type TabAccess interface {
+	GetTabByRef(ref string) (*models.Tab, error)
+	GetMemberByToken(token string) (*models.TabMember, error)
+}
+
+type WebhookHandler struct {
+	service WebhookService
+	tabs    TabAccess
+}
+
+func NewWebhookHandler(service WebhookService, tabs TabAccess) *WebhookHandler {
+	return &WebhookHandler{service: service, tabs: tabs}
+}
+
+// CreateWebhook handles POST /api/tabs/:id/webhooks
+// The signing secret is only returned in this response.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	t, member := tabauth.ValidateCreator(c, h.tabs, "manage webhooks")
+	if t == nil {
+		return
+	}
+
+	var body struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"})
+		return
+	}
+
+	webhook, err := h.service.Create(t.ID, strings.TrimSpace(body.URL), body.Events, member)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"public_id":  webhook.PublicID,
+		"url":        webhook.URL,
+		"events":     webhook.Events,
+		"active":     webhook.Active,
+		"secret":     webhook.Secret,
+		"created_at": webhook.CreatedAt,
+	})
+}
+
+// ListWebhooks handles GET /api/tabs/:id/webhooks
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	t, _ := tabauth.ValidateCreator(c, h.tabs, "manage webhooks")
+	if t == nil {
+		return
+	}
+
+	webhooks, err := h.service.List(t.ID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// UpdateWebhook handles PATCH /api/tabs/:id/webhooks/:webhookId
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	t, member := tabauth.ValidateCreator(c, h.tabs, "manage webhooks")
+	if t == nil {
+		return
+	}
+
+	var body struct {
+		URL    *string   `json:"url"`
+		Events *[]string `json:"events"`
+		Active *bool     `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"})
+		return
+	}
+	if body.URL != nil {
+		trimmed := strings.TrimSpace(*body.URL)
+		body.URL = &trimmed
+	}
+
+	webhook, err := h.service.Update(t.ID, c.Param("webhookId"), Changes{URL: body.URL, Events: body.Events, Active: body.Active}, member)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook handles DELETE /api/tabs/:id/webhooks/:webhookId
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	t, member := tabauth.ValidateCreator(c, h.tabs, "manage webhooks")
+	if t == nil {
+		return
+	}
+
+	if err := h.service.Delete(t.ID, c.Param("webhookId"), member); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ListDeliveries handles GET /api/tabs/:id/webhooks/:webhookId/deliveries?before=&limit=
+// Returns deliveries newest first; pass next_before as ?before= for the next page.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	t, _ := tabauth.ValidateCreator(c, h.tabs, "manage webhooks")
+	if t == nil {
+		return
+	}
+
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = parsed
+	}
+	var before uint
+	if raw := c.Query("before"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before cursor"})
+			return
+		}
+		before = uint(parsed)
+	}
+
+	deliveries, err := h.service.Deliveries(t.ID, c.Param("webhookId"), before, limit)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	var nextBefore *uint
+	if len(deliveries) == limit {
+		nextBefore = &deliveries[len(deliveries)-1].ID
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries, "next_before": nextBefore})
+}
+
+func (h *WebhookHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, security.ErrInvalidRef):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+	case err == gorm.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+	case errors.Is(err, ErrInvalidURL), errors.Is(err, ErrUnknownEventType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTooManyWebhooks):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("internal error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "an internal error occurred"})
+	}
+}
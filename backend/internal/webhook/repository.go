@@ -0,0 +1,173 @@
+package webhook
+
+import (
+	"backend/internal/audit"
+	"backend/pkg/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository interface {
+	Create(webhook *models.Webhook, actor *models.TabMember) error
+	GetByID(id uint) (*models.Webhook, error)
+	GetByPublicID(publicID string) (*models.Webhook, error)
+	ListByTab(tabID uint) ([]models.Webhook, error)
+	GetTabPublicID(tabID uint) (string, error)
+	Update(webhook *models.Webhook, actor *models.TabMember) error
+	Delete(webhook *models.Webhook, actor *models.TabMember) error
+	QueueDeliveries(deliveries []models.WebhookDelivery) error
+	ClaimDue(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+	UpdateDelivery(delivery *models.WebhookDelivery) error
+	ListDeliveries(webhookID uint, beforeID uint, limit int) ([]models.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func (r *webhookRepository) Create(webhook *models.Webhook, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(webhook).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  webhook.TabID,
+			Actor:  actor,
+			Action: "webhook.created",
+			Target: "webhook:" + webhook.PublicID,
+			After:  auditFields(webhook),
+		})
+	})
+}
+
+func (r *webhookRepository) GetByID(id uint) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	err := r.db.First(webhook, id).Error
+	return webhook, err
+}
+
+func (r *webhookRepository) GetByPublicID(publicID string) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	err := r.db.Where("public_id = ?", publicID).First(webhook).Error
+	return webhook, err
+}
+
+func (r *webhookRepository) ListByTab(tabID uint) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := r.db.Where("tab_id = ?", tabID).Order("id ASC").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// GetTabPublicID returns the public ID deliveries name the tab by.
+func (r *webhookRepository) GetTabPublicID(tabID uint) (string, error) {
+	tab := &models.Tab{}
+	err := r.db.Select("id", "public_id").First(tab, tabID).Error
+	return tab.PublicID, err
+}
+
+// Update saves the webhook's settings, recording what they were in the same
+// transaction.
+func (r *webhookRepository) Update(webhook *models.Webhook, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		before := &models.Webhook{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(before, webhook.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(webhook).Select("url", "events", "active").Updates(webhook).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  webhook.TabID,
+			Actor:  actor,
+			Action: "webhook.updated",
+			Target: "webhook:" + webhook.PublicID,
+			Before: auditFields(before),
+			After:  auditFields(webhook),
+		})
+	})
+}
+
+func (r *webhookRepository) Delete(webhook *models.Webhook, actor *models.TabMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Webhook{}, webhook.ID).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			TabID:  webhook.TabID,
+			Actor:  actor,
+			Action: "webhook.deleted",
+			Target: "webhook:" + webhook.PublicID,
+			Before: auditFields(webhook),
+		})
+	})
+}
+
+// auditFields is the audited view of a webhook; the secret is never recorded.
+func auditFields(w *models.Webhook) gin.H {
+	return gin.H{"url": w.URL, "events": w.Events, "active": w.Active}
+}
+
+// QueueDeliveries inserts pending deliveries, skipping any already queued for
+// the same webhook and event so a redelivered event is not sent twice.
+func (r *webhookRepository) QueueDeliveries(deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+// ClaimDue locks up to limit due pending deliveries, pushes their next attempt
+// out by lease so other workers leave them alone, and returns them with their
+// webhook loaded.
+func (r *webhookRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+			Order("next_attempt_at ASC").Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", idsOf(deliveries)).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil || len(deliveries) == 0 {
+		return deliveries, err
+	}
+	err = r.db.Preload("Webhook").Find(&deliveries, idsOf(deliveries)).Error
+	return deliveries, err
+}
+
+func idsOf(deliveries []models.WebhookDelivery) []uint {
+	ids := make([]uint, len(deliveries))
+	for i, d := range deliveries {
+		ids[i] = d.ID
+	}
+	return ids
+}
+
+func (r *webhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Model(delivery).
+		Select("status", "attempts", "response_status", "last_error", "next_attempt_at", "delivered_at").
+		Updates(delivery).Error
+}
+
+// ListDeliveries returns a webhook's deliveries newest first. Pass the last
+// ID of the previous page as beforeID, or 0 for the first page.
+func (r *webhookRepository) ListDeliveries(webhookID uint, beforeID uint, limit int) ([]models.WebhookDelivery, error) {
+	query := r.db.Where("webhook_id = ?", webhookID)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	var deliveries []models.WebhookDelivery
+	err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
@@ -0,0 +1,277 @@
+package webhook
+
+import (
+	"backend/internal/events"
+	"backend/pkg/models"
+	"backend/pkg/security"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+const maxWebhooksPerTab = 10
+
+var (
+	ErrInvalidURL       = errors.New("webhook url must be an absolute https url")
+	ErrUnknownEventType = errors.New("unknown event type in filter")
+	ErrTooManyWebhooks  = errors.New("tab already has the maximum number of webhooks")
+)
+
+// Changes holds the fields of a webhook that can be updated; nil means unchanged.
+type Changes struct {
+	URL    *string
+	Events *[]string
+	Active *bool
+}
+
+type WebhookService interface {
+	Create(tabID uint, rawURL string, filter []string, actor *models.TabMember) (*models.Webhook, error)
+	List(tabID uint) ([]models.Webhook, error)
+	Get(tabID uint, ref string) (*models.Webhook, error)
+	Update(tabID uint, ref string, changes Changes, actor *models.TabMember) (*models.Webhook, error)
+	Delete(tabID uint, ref string, actor *models.TabMember) error
+	Deliveries(tabID uint, ref string, beforeID uint, limit int) ([]models.WebhookDelivery, error)
+	Handle(ctx context.Context, event models.Event) error
+	Deliver(ctx context.Context, event models.Event) error
+}
+
+type webhookService struct {
+	repo         WebhookRepository
+	allowPrivate bool
+}
+
+// AllowPrivateFromEnv reports whether WEBHOOK_ALLOW_PRIVATE permits plain-http
+// and private-network webhook targets. Only enable it for local development.
+func AllowPrivateFromEnv() bool {
+	return os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"
+}
+
+// Create registers a webhook with a freshly generated signing secret.
+func (s *webhookService) Create(tabID uint, rawURL string, filter []string, actor *models.TabMember) (*models.Webhook, error) {
+	if err := s.validateURL(rawURL); err != nil {
+		return nil, err
+	}
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+	existing, err := s.repo.ListByTab(tabID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxWebhooksPerTab {
+		return nil, ErrTooManyWebhooks
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+	webhook := &models.Webhook{
+		TabID:  tabID,
+		URL:    rawURL,
+		Secret: secret,
+		Events: normalizeFilter(filter),
+		Active: true,
+	}
+	if err := s.repo.Create(webhook, actor); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) List(tabID uint) ([]models.Webhook, error) {
+	return s.repo.ListByTab(tabID)
+}
+
+// Get resolves a webhook by public or numeric ID, scoped to the tab.
+func (s *webhookService) Get(tabID uint, ref string) (*models.Webhook, error) {
+	id, publicID, err := security.ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	var webhook *models.Webhook
+	if publicID != "" {
+		webhook, err = s.repo.GetByPublicID(publicID)
+	} else {
+		webhook, err = s.repo.GetByID(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if webhook.TabID != tabID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) Update(tabID uint, ref string, changes Changes, actor *models.TabMember) (*models.Webhook, error) {
+	webhook, err := s.Get(tabID, ref)
+	if err != nil {
+		return nil, err
+	}
+	if changes.URL != nil {
+		if err := s.validateURL(*changes.URL); err != nil {
+			return nil, err
+		}
+		webhook.URL = *changes.URL
+	}
+	if changes.Events != nil {
+		if err := validateFilter(*changes.Events); err != nil {
+			return nil, err
+		}
+		webhook.Events = normalizeFilter(*changes.Events)
+	}
+	if changes.Active != nil {
+		webhook.Active = *changes.Active
+	}
+	if err := s.repo.Update(webhook, actor); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) Delete(tabID uint, ref string, actor *models.TabMember) error {
+	webhook, err := s.Get(tabID, ref)
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(webhook, actor)
+}
+
+func (s *webhookService) Deliveries(tabID uint, ref string, beforeID uint, limit int) ([]models.WebhookDelivery, error) {
+	webhook, err := s.Get(tabID, ref)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(webhook.ID, beforeID, limit)
+}
+
+// Handle queues a delivery of the event to every active webhook on its tab
+// whose filter matches. It runs as an event-service dispatcher handler; the
+// actual HTTP calls happen in the delivery worker so a slow endpoint never
+// holds up the tab's other events. Reminders are left to the reminder
+// service, which only Delivers them when the tab's reminder settings say so.
+func (s *webhookService) Handle(ctx context.Context, event models.Event) error {
+	if event.Type == (events.SettlementReminder{}).EventType() {
+		return nil
+	}
+	return s.Deliver(ctx, event)
+}
+
+// Deliver queues the event to the tab's matching webhooks, whatever its type.
+func (s *webhookService) Deliver(ctx context.Context, event models.Event) error {
+	if event.TabID == nil {
+		return nil
+	}
+	webhooks, err := s.repo.ListByTab(*event.TabID)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	var deliveries []models.WebhookDelivery
+	for _, w := range webhooks {
+		if !w.Active || !matches(w.Events, event.Type) {
+			continue
+		}
+		if body == nil {
+			tabPublicID, err := s.repo.GetTabPublicID(*event.TabID)
+			if err != nil {
+				return err
+			}
+			if body, err = json.Marshal(events.NewView(event, tabPublicID)); err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:     w.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Body:          body,
+			Status:        StatusPending,
+			NextAttemptAt: time.Now(),
+		})
+	}
+	return s.repo.QueueDeliveries(deliveries)
+}
+
+func (s *webhookService) validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+	if u.Scheme != "https" && !(s.allowPrivate && u.Scheme == "http") {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+func validateFilter(filter []string) error {
+	for _, f := range filter {
+		f = strings.TrimSpace(f)
+		known := false
+		for _, t := range events.Types {
+			if matches([]string{f}, t) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return ErrUnknownEventType
+		}
+	}
+	return nil
+}
+
+func normalizeFilter(filter []string) []string {
+	result := make([]string, 0, len(filter))
+	for _, f := range filter {
+		result = append(result, strings.TrimSpace(f))
+	}
+	return result
+}
+
+// matches reports whether an event type passes a filter of exact types and
+// "prefix.*" wildcards. An empty filter matches everything.
+func matches(filter []string, eventType string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		if f == eventType || f == "*" {
+			return true
+		}
+		if strings.HasSuffix(f, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(f, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func NewWebhookService(repo WebhookRepository, allowPrivate bool) WebhookService {
+	return &webhookService{repo: repo, allowPrivate: allowPrivate}
+}
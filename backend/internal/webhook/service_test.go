@@ -0,0 +1,376 @@
+package webhook
+
+import (
+	"backend/internal/events"
+	"backend/pkg/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ── Mock WebhookRepository ──────────────────────────────────────
+
+type mockWebhookRepository struct {
+	webhooks   []models.Webhook
+	queued     []models.WebhookDelivery
+	due        []models.WebhookDelivery
+	updated    []models.WebhookDelivery
+	deleted    uint
+	listBefore uint
+}
+
+func (m *mockWebhookRepository) Create(webhook *models.Webhook, actor *models.TabMember) error {
+	webhook.ID = uint(len(m.webhooks) + 1)
+	m.webhooks = append(m.webhooks, *webhook)
+	return nil
+}
+
+func (m *mockWebhookRepository) GetByID(id uint) (*models.Webhook, error) {
+	for i := range m.webhooks {
+		if m.webhooks[i].ID == id {
+			return &m.webhooks[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockWebhookRepository) GetByPublicID(publicID string) (*models.Webhook, error) {
+	for i := range m.webhooks {
+		if m.webhooks[i].PublicID == publicID {
+			return &m.webhooks[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockWebhookRepository) ListByTab(tabID uint) ([]models.Webhook, error) {
+	var result []models.Webhook
+	for _, w := range m.webhooks {
+		if w.TabID == tabID {
+			result = append(result, w)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockWebhookRepository) GetTabPublicID(tabID uint) (string, error) {
+	return fmt.Sprintf("tab%dpublicidxxxxxxxxxxx", tabID), nil
+}
+
+func (m *mockWebhookRepository) Update(webhook *models.Webhook, actor *models.TabMember) error {
+	return nil
+}
+
+func (m *mockWebhookRepository) Delete(webhook *models.Webhook, actor *models.TabMember) error {
+	m.deleted = webhook.ID
+	return nil
+}
+
+func (m *mockWebhookRepository) QueueDeliveries(deliveries []models.WebhookDelivery) error {
+	m.queued = append(m.queued, deliveries...)
+	return nil
+}
+
+func (m *mockWebhookRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	var result []models.WebhookDelivery
+	for _, d := range m.due {
+		if d.Status == StatusPending && !d.NextAttemptAt.After(now) {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockWebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	m.updated = append(m.updated, *delivery)
+	for i := range m.due {
+		if m.due[i].ID == delivery.ID {
+			m.due[i] = *delivery
+		}
+	}
+	return nil
+}
+
+func (m *mockWebhookRepository) ListDeliveries(webhookID uint, beforeID uint, limit int) ([]models.WebhookDelivery, error) {
+	m.listBefore = beforeID
+	return nil, nil
+}
+
+func TestCreate_Validation(t *testing.T) {
+	repo := &mockWebhookRepository{}
+	svc := NewWebhookService(repo, false)
+
+	cases := []struct {
+		url    string
+		filter []string
+		want   error
+	}{
+		{"http://example.com/hook", nil, ErrInvalidURL},
+		{"https://user:pw@example.com/hook", nil, ErrInvalidURL},
+		{"/relative", nil, ErrInvalidURL},
+		{"https://example.com/hook", []string{"bill.exploded"}, ErrUnknownEventType},
+		{"https://example.com/hook", []string{"nothing.*"}, ErrUnknownEventType},
+		{"https://example.com/hook", []string{"bill.*", "settlement.paid"}, nil},
+	}
+	for _, tc := range cases {
+		_, err := svc.Create(1, tc.url, tc.filter, nil)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("Create(%q, %v) error = %v, want %v", tc.url, tc.filter, err, tc.want)
+		}
+	}
+
+	// Plain http is allowed for local development
+	if _, err := NewWebhookService(repo, true).Create(1, "http://127.0.0.1:9000/hook", nil, nil); err != nil {
+		t.Errorf("expected http to be allowed with allowPrivate, got %v", err)
+	}
+}
+
+func TestCreate_GeneratesSecretAndEnforcesLimit(t *testing.T) {
+	repo := &mockWebhookRepository{}
+	svc := NewWebhookService(repo, false)
+
+	webhook, err := svc.Create(1, "https://example.com/hook", nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(webhook.Secret, "whsec_") || len(webhook.Secret) != 70 {
+		t.Errorf("unexpected secret %q", webhook.Secret)
+	}
+	if !webhook.Active {
+		t.Error("expected new webhook to be active")
+	}
+
+	for i := 1; i < maxWebhooksPerTab; i++ {
+		if _, err := svc.Create(1, "https://example.com/hook", nil, nil); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if _, err := svc.Create(1, "https://example.com/hook", nil, nil); !errors.Is(err, ErrTooManyWebhooks) {
+		t.Errorf("expected ErrTooManyWebhooks, got %v", err)
+	}
+}
+
+func TestGet_ScopedToTab(t *testing.T) {
+	repo := &mockWebhookRepository{webhooks: []models.Webhook{{ID: 4, TabID: 2}}}
+	svc := NewWebhookService(repo, false)
+
+	if _, err := svc.Get(1, "4"); err != gorm.ErrRecordNotFound {
+		t.Errorf("expected ErrRecordNotFound for another tab's webhook, got %v", err)
+	}
+	if w, err := svc.Get(2, "4"); err != nil || w.ID != 4 {
+		t.Errorf("expected webhook 4, got %v, %v", w, err)
+	}
+}
+
+func TestHandle_QueuesMatchingWebhooks(t *testing.T) {
+	repo := &mockWebhookRepository{webhooks: []models.Webhook{
+		{ID: 1, TabID: 1, Active: true},
+		{ID: 2, TabID: 1, Active: true, Events: []string{"bill.*"}},
+		{ID: 3, TabID: 1, Active: true, Events: []string{"settlement.paid"}},
+		{ID: 4, TabID: 1, Active: false},
+		{ID: 5, TabID: 2, Active: true},
+	}}
+	svc := NewWebhookService(repo, false)
+
+	tabID := uint(1)
+	event := models.Event{ID: 42, TabID: &tabID, Type: "bill.added", Payload: json.RawMessage(`{"bill_id":7}`)}
+	if err := svc.Handle(context.Background(), event); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(repo.queued) != 2 {
+		t.Fatalf("expected 2 deliveries queued, got %d", len(repo.queued))
+	}
+	for i, want := range []uint{1, 2} {
+		d := repo.queued[i]
+		if d.WebhookID != want || d.EventID != 42 || d.Status != StatusPending {
+			t.Errorf("unexpected delivery %+v", d)
+		}
+	}
+	var body events.View
+	if err := json.Unmarshal(repo.queued[0].Body, &body); err != nil || body.Type != "bill.added" {
+		t.Errorf("expected event body, got %s (%v)", repo.queued[0].Body, err)
+	}
+	if body.TabID != "tab1publicidxxxxxxxxxxx" {
+		t.Errorf("expected the tab named by public ID, got %q", body.TabID)
+	}
+}
+
+func TestHandle_IgnoresStandaloneBillEvents(t *testing.T) {
+	repo := &mockWebhookRepository{webhooks: []models.Webhook{{ID: 1, TabID: 1, Active: true}}}
+	billID := uint(3)
+	err := NewWebhookService(repo, false).Handle(context.Background(), models.Event{ID: 1, BillID: &billID, Type: "bill.created"})
+	if err != nil || len(repo.queued) != 0 {
+		t.Errorf("expected nothing queued, got %v, %v", repo.queued, err)
+	}
+}
+
+func TestHandle_LeavesRemindersToReminderChannel(t *testing.T) {
+	repo := &mockWebhookRepository{webhooks: []models.Webhook{{ID: 1, TabID: 1, Active: true}}}
+	svc := NewWebhookService(repo, false)
+
+	tabID := uint(1)
+	event := models.Event{ID: 9, TabID: &tabID, Type: "settlement.reminder", Payload: json.RawMessage(`{"settlement_id":2}`)}
+	if err := svc.Handle(context.Background(), event); err != nil || len(repo.queued) != 0 {
+		t.Fatalf("expected Handle to skip reminders, got %v, %v", repo.queued, err)
+	}
+	if err := svc.Deliver(context.Background(), event); err != nil || len(repo.queued) != 1 {
+		t.Fatalf("expected Deliver to queue the reminder, got %v, %v", repo.queued, err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	got := Sign("whsec_test", 1700000000, []byte(`{"id":1}`))
+	want := "sha256=2f441ba4b3b2d50d28a9ab9d9fd8880376ecd1eb5d0435401553f5d8d0a5dcf8"
+	if got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+}
+
+func TestWorker_DeliversSignedPayload(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{header: r.Header.Clone(), body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	body := json.RawMessage(`{"id":42,"type":"bill.added"}`)
+	repo := &mockWebhookRepository{due: []models.WebhookDelivery{{
+		ID: 9, WebhookID: 1, EventID: 42, EventType: "bill.added", Body: body, Status: StatusPending,
+		Webhook: &models.Webhook{ID: 1, URL: server.URL, Secret: "whsec_test", Active: true},
+	}}}
+	worker := NewWorker(repo, true)
+
+	n, err := worker.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 successful delivery, got %d", n)
+	}
+
+	req := <-got
+	if string(req.body) != string(body) {
+		t.Errorf("expected body %s, got %s", body, req.body)
+	}
+	ts, err := strconv.ParseInt(req.header.Get("X-Billington-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatalf("missing timestamp header: %v", err)
+	}
+	if sig := req.header.Get("X-Billington-Signature"); sig != Sign("whsec_test", ts, body) {
+		t.Errorf("signature %q does not verify", sig)
+	}
+	if req.header.Get("X-Billington-Event") != "bill.added" || req.header.Get("X-Billington-Delivery") != "9" {
+		t.Errorf("unexpected headers %v", req.header)
+	}
+
+	d := repo.updated[0]
+	if d.Status != StatusSucceeded || d.Attempts != 1 || d.ResponseStatus != 204 || d.DeliveredAt == nil {
+		t.Errorf("unexpected delivery state %+v", d)
+	}
+}
+
+func TestWorker_RetriesWithBackoffThenFails(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := &mockWebhookRepository{due: []models.WebhookDelivery{{
+		ID: 1, EventType: "bill.added", Body: json.RawMessage(`{}`), Status: StatusPending, NextAttemptAt: now,
+		Webhook: &models.Webhook{URL: server.URL, Secret: "s", Active: true},
+	}}}
+	worker := NewWorker(repo, true)
+	worker.now = func() time.Time { return now }
+
+	worker.DeliverDue(context.Background())
+	d := repo.due[0]
+	if d.Status != StatusPending || d.Attempts != 1 || d.ResponseStatus != 502 {
+		t.Fatalf("unexpected state after first failure %+v", d)
+	}
+	if !d.NextAttemptAt.Equal(now.Add(baseDeliveryDelay)) {
+		t.Errorf("expected retry at +%v, got %v", baseDeliveryDelay, d.NextAttemptAt)
+	}
+
+	// Not due yet
+	worker.DeliverDue(context.Background())
+	if calls != 1 {
+		t.Errorf("expected no attempt before backoff elapses, got %d calls", calls)
+	}
+
+	for i := 0; i < maxDeliveryAttempts; i++ {
+		now = now.Add(maxDeliveryDelay)
+		worker.DeliverDue(context.Background())
+	}
+	d = repo.due[0]
+	if d.Status != StatusFailed || d.Attempts != maxDeliveryAttempts {
+		t.Errorf("expected failed after %d attempts, got %+v", maxDeliveryAttempts, d)
+	}
+	if calls != maxDeliveryAttempts {
+		t.Errorf("expected %d calls, got %d", maxDeliveryAttempts, calls)
+	}
+}
+
+func TestWorker_SkipsInactiveWebhook(t *testing.T) {
+	repo := &mockWebhookRepository{due: []models.WebhookDelivery{{
+		ID: 1, Status: StatusPending, Webhook: &models.Webhook{URL: "http://127.0.0.1:1", Active: false},
+	}}}
+	NewWorker(repo, true).DeliverDue(context.Background())
+	if repo.updated[0].Status != StatusSkipped || repo.updated[0].Attempts != 0 {
+		t.Errorf("expected skipped without an attempt, got %+v", repo.updated[0])
+	}
+}
+
+func TestWorker_BlocksPrivateTargets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not reach a loopback target")
+	}))
+	defer server.Close()
+
+	repo := &mockWebhookRepository{due: []models.WebhookDelivery{{
+		ID: 1, Body: json.RawMessage(`{}`), Status: StatusPending,
+		Webhook: &models.Webhook{URL: server.URL, Secret: "s", Active: true},
+	}}}
+	NewWorker(repo, false).DeliverDue(context.Background())
+	if d := repo.updated[0]; d.Status != StatusPending || !strings.Contains(d.LastError, "private address") {
+		t.Errorf("expected blocked attempt to be retried later, got %+v", d)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	cases := []struct {
+		filter []string
+		typ    string
+		want   bool
+	}{
+		{nil, "bill.added", true},
+		{[]string{"bill.added"}, "bill.added", true},
+		{[]string{"bill.*"}, "bill.removed", true},
+		{[]string{"bill.*"}, "billing.added", false},
+		{[]string{"settlement.paid"}, "share.paid", false},
+	}
+	for _, tc := range cases {
+		if got := matches(tc.filter, tc.typ); got != tc.want {
+			t.Errorf("matches(%v, %q) = %v, want %v", tc.filter, tc.typ, got, tc.want)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package database
 
 import (
 	"backend/pkg/models"
+	"backend/pkg/security"
 	"fmt"
+	"log"
 	"os"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -33,10 +36,141 @@ func InitDB() (*gorm.DB, error) {
 	}
 
 	// Migrate parent tables first (Tab before Bill, since Bill has FK to Tab)
-	err = db.AutoMigrate(&models.Tab{}, &models.TabMember{}, &models.TabImage{}, &models.TabSettlement{}, &models.Bill{}, &models.Person{}, &models.BillItem{}, &models.ItemAssignment{}, &models.PersonShare{})
+	err = db.AutoMigrate(&models.Tab{}, &models.TabMember{}, &models.TabMemberAlias{}, &models.TabImage{}, &models.TabSettlement{}, &models.TabPeriod{}, &models.Bill{}, &models.Person{}, &models.BillItem{}, &models.ItemAssignment{}, &models.BillSplit{}, &models.PersonShare{}, &models.Event{}, &models.Webhook{}, &models.WebhookDelivery{}, &models.TabActivity{}, &models.Payment{}, &models.ReminderPolicy{}, &models.ReminderEmail{}, &models.ReminderSend{}, &models.BillTemplate{}, &models.IdempotencyKey{})
 	if err != nil {
 		return nil, err
 	}
 
+	if err := backfillPublicIDs(db); err != nil {
+		return nil, err
+	}
+
+	if err := backfillPayments(db); err != nil {
+		return nil, err
+	}
+
+	if err := protectAuditTrail(db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
+
+// backfillPublicIDs assigns public IDs to rows created before share URLs
+// switched away from numeric IDs. It is a no-op once every row has one.
+func backfillPublicIDs(db *gorm.DB) error {
+	for _, table := range []string{"tabs", "tab_members", "tab_images", "tab_settlements", "tab_member_aliases", "bills", "person_shares", "webhooks"} {
+		var ids []uint
+		if err := db.Table(table).Where("public_id IS NULL OR public_id = ''").Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			publicID, err := security.GeneratePublicID()
+			if err != nil {
+				return err
+			}
+			if err := db.Table(table).Where("id = ?", id).Update("public_id", publicID).Error; err != nil {
+				return err
+			}
+		}
+		if len(ids) > 0 {
+			log.Printf("backfilled public ids for %d %s", len(ids), table)
+		}
+	}
+	return nil
+}
+
+// backfillPayments turns paid flags set before payments were tracked into
+// payment records, so the derived paid state survives the next recompute, and
+// gives rows paid before payments needed confirming their status. It is a
+// no-op once every paid share and settlement has an amount paid and a status.
+func backfillPayments(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var shares []models.PersonShare
+		if err := tx.Where("paid = ? AND amount_paid = 0 AND total > 0", true).Find(&shares).Error; err != nil {
+			return err
+		}
+		for _, share := range shares {
+			payment := &models.Payment{
+				BillID:        &share.BillID,
+				PersonShareID: &share.ID,
+				PayerMemberID: share.MemberID,
+				Payer:         share.PersonName,
+				Amount:        share.Total,
+				Method:        "other",
+				Status:        "received",
+				PaidAt:        now,
+				ReceivedAt:    &now,
+			}
+			if err := tx.Create(payment).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.PersonShare{}).Where("id = ?", share.ID).Update("amount_paid", share.Total).Error; err != nil {
+				return err
+			}
+		}
+
+		var settlements []models.TabSettlement
+		if err := tx.Where("paid = ? AND amount_paid = 0 AND amount > 0 AND superseded_at IS NULL", true).Find(&settlements).Error; err != nil {
+			return err
+		}
+		for _, s := range settlements {
+			payment := &models.Payment{
+				TabID:         &s.TabID,
+				PayerKey:      models.PayerKey(s.MemberID, s.PersonName),
+				SettlementID:  &s.ID,
+				PayerMemberID: s.MemberID,
+				Payer:         s.PersonName,
+				Amount:        s.Amount,
+				Method:        "other",
+				Status:        "received",
+				PaidAt:        s.CreatedAt,
+				ReceivedAt:    &s.CreatedAt,
+			}
+			if err := tx.Create(payment).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.TabSettlement{}).Where("id = ?", s.ID).Update("amount_paid", s.Amount).Error; err != nil {
+				return err
+			}
+		}
+
+		if n := len(shares) + len(settlements); n > 0 {
+			log.Printf("backfilled %d payments from paid flags", n)
+		}
+
+		for _, model := range []interface{}{&models.PersonShare{}, &models.TabSettlement{}} {
+			err := tx.Model(model).Where("status = ? AND paid = ?", "unpaid", true).Update("status", "received").Error
+			if err != nil {
+				return err
+			}
+			err = tx.Model(model).Where("status = ? AND amount_paid > 0", "unpaid").Update("status", "partial").Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// protectAuditTrail installs a trigger that rejects updates and deletes on
+// tab_activities, so the audit trail can only grow. Safe to run on every start.
+func protectAuditTrail(db *gorm.DB) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION reject_tab_activity_change() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'tab_activities is append-only';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS tab_activities_immutable ON tab_activities`,
+		`CREATE TRIGGER tab_activities_immutable BEFORE UPDATE OR DELETE ON tab_activities
+		FOR EACH ROW EXECUTE FUNCTION reject_tab_activity_change()`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Package etag builds and compares the entity tags of versioned resources.
+package etag
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrChanged is returned by a conditional write that found the resource at a
+// different version than the request's If-Match named.
+var ErrChanged = errors.New("resource has changed since it was loaded")
+
+// For returns the strong entity tag of a resource at version.
+func For(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// Match reports whether an If-Match header lists tag or is "*". Weak tags
+// never match, as If-Match requires a strong comparison.
+func Match(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// NoneMatch reports whether an If-None-Match header lists tag or is "*",
+// comparing weakly so tags a proxy marked weak still match.
+func NoneMatch(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch honors the request's If-Match header against the resource's current
+// version. It returns the version a write must still find, nil if any will do.
+// If the header doesn't list the current ETag, it writes 412 with the current
+// ETag and returns false.
+func IfMatch(c *gin.Context, version uint, resource string) (*uint, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return nil, true
+	}
+	if !Match(header, For(version)) {
+		c.Header("ETag", For(version))
+		Changed(c, resource)
+		return nil, false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return nil, true
+	}
+	return &version, true
+}
+
+// Changed writes the 412 for a resource that changed since it was loaded.
+func Changed(c *gin.Context, resource string) {
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": resource + " has changed since it was loaded"})
+}
@@ -0,0 +1,81 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFor(t *testing.T) {
+	if got := For(42); got != `"42"` {
+		t.Errorf("expected \"42\" quoted, got %s", got)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		header string
+		want   bool
+	}{
+		{`"3"`, true},
+		{`"2", "3"`, true},
+		{`*`, true},
+		{`"2"`, false},
+		{`W/"3"`, false},
+		{``, false},
+	}
+	for _, tc := range cases {
+		if got := Match(tc.header, For(3)); got != tc.want {
+			t.Errorf("Match(%q) = %v, want %v", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestNoneMatch(t *testing.T) {
+	cases := []struct {
+		header string
+		want   bool
+	}{
+		{`"3"`, true},
+		{`W/"3"`, true},
+		{`"1",W/"3"`, true},
+		{`*`, true},
+		{`"2"`, false},
+		{``, false},
+	}
+	for _, tc := range cases {
+		if got := NoneMatch(tc.header, For(3)); got != tc.want {
+			t.Errorf("NoneMatch(%q) = %v, want %v", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		header  string
+		ok      bool
+		version *uint
+	}{
+		{``, true, nil},
+		{`*`, true, nil},
+		{`"2", "3"`, true, new(uint)},
+		{`"2"`, false, nil},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPatch, "/", nil)
+		c.Request.Header.Set("If-Match", tc.header)
+
+		version, ok := IfMatch(c, 3, "tab")
+		if ok != tc.ok || (version == nil) != (tc.version == nil) || (version != nil && *version != 3) {
+			t.Errorf("IfMatch(%q) = %v %v, want %v with a version %v", tc.header, version, ok, tc.ok, tc.version != nil)
+		}
+		if !ok && (w.Code != http.StatusPreconditionFailed || w.Header().Get("ETag") != `"3"`) {
+			t.Errorf("IfMatch(%q): expected 412 with the current ETag, got %d %q", tc.header, w.Code, w.Header().Get("ETag"))
+		}
+	}
+}
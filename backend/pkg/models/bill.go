@@ -1,6 +1,7 @@
 package models
 
 import (
+	"backend/pkg/security"
 	"time"
 
 	"gorm.io/gorm"
@@ -12,18 +13,29 @@ type PaymentMethod struct {
 	Identifier string `json:"identifier"`
 }
 
+// PayLink is a prefilled link for paying an outstanding amount with one of
+// the payee's payment methods. Computed per response, never stored.
+type PayLink struct {
+	Provider   string  `json:"provider"`
+	Identifier string  `json:"identifier"`
+	Amount     float64 `json:"amount"`
+	URL        string  `json:"url,omitempty"`
+	AppURL     string  `json:"app_url,omitempty"`
+}
+
 // Person represents a participant in a bill.
 type Person struct {
-	ID   uint   `gorm:"primaryKey" json:"id"`
+	ID   uint   `gorm:"primaryKey" json:"-"`
 	Name string `gorm:"not null" json:"name"`
 }
 
 // BillItem represents an item on a bill with its cost.
 type BillItem struct {
-	ID          uint             `gorm:"primaryKey" json:"id"`
-	BillID      uint             `gorm:"not null;index" json:"bill_id"`
+	ID          uint             `gorm:"primaryKey" json:"-"`
+	BillID      uint             `gorm:"not null;index" json:"-"`
 	Name        string           `gorm:"not null" json:"name"`
 	Price       float64          `gorm:"not null" json:"price"`
+	TaxExempt   bool             `gorm:"not null;default:false" json:"tax_exempt"`
 	Assignments []ItemAssignment `gorm:"constraint:OnDelete:CASCADE" json:"assignments"`
 	CreatedAt   time.Time        `json:"created_at"`
 	UpdatedAt   time.Time        `json:"updated_at"`
@@ -31,14 +43,29 @@ type BillItem struct {
 
 // ItemAssignment represents the percentage assignment of a bill item to a person.
 type ItemAssignment struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	BillItemID uint      `gorm:"not null;index" json:"bill_item_id"`
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	BillItemID uint      `gorm:"not null;index" json:"-"`
 	PersonName string    `gorm:"not null;index" json:"person_name"`
+	MemberID   *uint     `gorm:"index" json:"-"`
+	MemberRef  security.Ref `gorm:"-" json:"member_id,omitempty"`
 	Percentage float64   `gorm:"not null" json:"percentage"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// BillSplit is one person's part of a bill-level split. Value is read per the
+// bill's split mode: a weight for shares, a dollar amount for exact and a
+// percentage for percentage; equal splits ignore it.
+type BillSplit struct {
+	ID         uint         `gorm:"primaryKey" json:"-"`
+	BillID     uint         `gorm:"not null;index" json:"-"`
+	PersonName string       `gorm:"not null" json:"person_name"`
+	MemberID   *uint        `gorm:"index" json:"-"`
+	MemberRef  security.Ref `gorm:"-" json:"member_id,omitempty"`
+	Value      float64      `gorm:"not null;default:0" json:"value"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
 // ItemDetail represents an item in a person's share
 type ItemDetail struct {
 	Name     string  `json:"name"`
@@ -48,35 +75,80 @@ type ItemDetail struct {
 
 // PersonShare represents a person's calculated share of the bill.
 type PersonShare struct {
-	ID         uint         `gorm:"primaryKey" json:"id"`
-	BillID     uint         `gorm:"not null;index" json:"bill_id"`
+	ID         uint         `gorm:"primaryKey" json:"-"`
+	PublicID   string       `gorm:"type:varchar(22);uniqueIndex" json:"public_id"`
+	BillID     uint         `gorm:"not null;index" json:"-"`
 	PersonName string       `gorm:"not null" json:"person_name"`
+	MemberID   *uint        `gorm:"index" json:"-"`
+	MemberRef  security.Ref `gorm:"-" json:"member_id,omitempty"`
 	Items      []ItemDetail `gorm:"type:jsonb;serializer:json" json:"items"`
 	Subtotal   float64      `gorm:"not null" json:"subtotal"`
 	TaxShare   float64      `gorm:"not null" json:"tax_share"`
 	TipShare   float64      `gorm:"not null" json:"tip_share"`
 	Total      float64      `gorm:"not null" json:"total"`
-	Paid       bool         `gorm:"default:false" json:"paid"`
+	// Fixed fees, divided by the server
+	ServiceChargeShare float64 `gorm:"not null;default:0" json:"service_charge_share"`
+	DeliveryFeeShare   float64 `gorm:"not null;default:0" json:"delivery_fee_share"`
+	// Derived from the share's payments
+	PaymentState
+	Outstanding float64   `gorm:"-" json:"outstanding"`
+	PayLinks    []PayLink `gorm:"-" json:"pay_links,omitempty"`
+}
+
+// AfterFind computes what is still owed on the share.
+func (s *PersonShare) AfterFind(tx *gorm.DB) error {
+	s.Outstanding = outstanding(s.Total, s.AmountPaid)
+	return nil
+}
+
+// BeforeCreate assigns the share's public ID.
+func (s *PersonShare) BeforeCreate(tx *gorm.DB) error {
+	return assignPublicID(&s.PublicID)
 }
 
 type Bill struct {
-	ID               uint            `gorm:"primaryKey" json:"id"`
-	TabID            *uint           `gorm:"index" json:"tab_id,omitempty"`
-	AddedByMemberID  *uint           `gorm:"index" json:"added_by_member_id,omitempty"`
+	ID               uint            `gorm:"primaryKey" json:"-"`
+	PublicID         string          `gorm:"type:varchar(22);uniqueIndex" json:"public_id"`
+	TabID            *uint           `gorm:"index" json:"-"`
+	AddedByMemberID  *uint           `gorm:"index" json:"-"`
+	AddedByRef       security.Ref    `gorm:"-" json:"added_by_member_id,omitempty"`
 	Name           string          `gorm:"not null" json:"name"`
 	Subtotal       float64         `gorm:"not null" json:"subtotal"`
 	Tax            float64         `gorm:"not null" json:"tax"`
 	TipAmount      float64         `gorm:"not null" json:"tip_amount"`
 	TipPercentage  float64         `json:"tip_percentage"`
+	ServiceCharge  float64         `gorm:"not null;default:0" json:"service_charge"`
+	DeliveryFee    float64         `gorm:"not null;default:0" json:"delivery_fee"`
 	Total          float64         `gorm:"not null" json:"total"`
 	Date           time.Time       `gorm:"not null" json:"date"`
 	PaymentMethods []PaymentMethod `gorm:"type:jsonb;serializer:json" json:"payment_methods"` // Changed to array
 	Participants   []Person        `gorm:"many2many:bill_participants;constraint:OnDelete:SET NULL" json:"participants"`
 	Items          []BillItem      `gorm:"constraint:OnDelete:CASCADE" json:"items"`
+	// How tax and tip are divided when the server computes person shares
+	TaxPolicy   string   `gorm:"type:varchar(16);not null;default:'proportional'" json:"tax_policy"`
+	TipPolicy   string   `gorm:"type:varchar(16);not null;default:'proportional'" json:"tip_policy"`
+	TipBase     string   `gorm:"type:varchar(16);not null;default:'pre_tax'" json:"tip_base"`
+	TipExcluded []string `gorm:"type:jsonb;serializer:json" json:"tip_excluded,omitempty"`
+	// Period of a recurring tab the bill belongs to; 0 on other tabs
+	TabPeriod int `gorm:"not null;default:0" json:"tab_period,omitempty"`
+	// Set on bills a template created; one bill per template and run
+	TemplateID   *uint      `gorm:"uniqueIndex:idx_bill_template_run" json:"-"`
+	ScheduledFor *time.Time `gorm:"uniqueIndex:idx_bill_template_run" json:"scheduled_for,omitempty"`
+	// SplitMode is items (person shares follow the item assignments) or one
+	// of the bill-level modes, which divide the whole bill by Splits.
+	SplitMode      string          `gorm:"type:varchar(16);not null;default:'items'" json:"split_mode"`
+	Splits         []BillSplit     `gorm:"constraint:OnDelete:CASCADE" json:"splits,omitempty"`
 	PersonShares   []PersonShare   `gorm:"constraint:OnDelete:CASCADE" json:"person_shares"`
 	AccessToken    string          `gorm:"type:varchar(64);uniqueIndex" json:"access_token,omitempty"`
 	CreatedAt      time.Time       `json:"created_at"`
 	UpdatedAt      time.Time       `json:"updated_at"`
+	// CreatorToken is held only by whoever created a standalone bill; it lets
+	// them confirm payments as the payee.
+	CreatorToken string `gorm:"type:varchar(64);index" json:"-"`
+	// Counts the changes recorded for the bill; served as its ETag
+	Version uint `gorm:"not null;default:0" json:"version"`
+	// Set on bills an offline client pushed, to recognize retries
+	ClientID string `gorm:"type:varchar(64);uniqueIndex:idx_bill_client_id,where:client_id <> ''" json:"-"`
 }
 
 // BeforeCreate hook to set default values before creating a Bill.
@@ -84,5 +156,5 @@ func (b *Bill) BeforeCreate(tx *gorm.DB) error {
 	if b.Date.IsZero() {
 		b.Date = time.Now()
 	}
-	return nil
+	return assignPublicID(&b.PublicID)
 }
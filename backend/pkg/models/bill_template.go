@@ -0,0 +1,74 @@
+package models
+
+import (
+	"backend/pkg/security"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BillTemplate is a bill a tab repeats on a schedule, such as rent or a
+// subscription. The template scheduler creates a real bill on the tab from it
+// each time it comes due.
+type BillTemplate struct {
+	ID                uint            `gorm:"primaryKey" json:"-"`
+	PublicID          string          `gorm:"type:varchar(22);uniqueIndex" json:"public_id"`
+	TabID             uint            `gorm:"not null;index" json:"-"`
+	CreatedByMemberID *uint           `json:"-"`
+	CreatedByRef      security.Ref    `gorm:"-" json:"created_by_member_id,omitempty"`
+	Name              string          `gorm:"not null" json:"name"`
+	Subtotal          float64         `gorm:"not null" json:"subtotal"`
+	Tax               float64         `gorm:"not null" json:"tax"`
+	TipAmount         float64         `gorm:"not null" json:"tip_amount"`
+	TipPercentage     float64         `json:"tip_percentage"`
+	ServiceCharge     float64         `gorm:"not null;default:0" json:"service_charge"`
+	DeliveryFee       float64         `gorm:"not null;default:0" json:"delivery_fee"`
+	Total             float64         `gorm:"not null" json:"total"`
+	PaymentMethods    []PaymentMethod `gorm:"type:jsonb;serializer:json" json:"payment_methods"`
+	Items             []TemplateItem  `gorm:"type:jsonb;serializer:json" json:"items"`
+	SplitMode         string          `gorm:"type:varchar(16);not null;default:'items'" json:"split_mode"`
+	Splits            []TemplateSplit `gorm:"type:jsonb;serializer:json" json:"splits,omitempty"`
+	TaxPolicy         string          `gorm:"type:varchar(16);not null;default:'proportional'" json:"tax_policy"`
+	TipPolicy         string          `gorm:"type:varchar(16);not null;default:'proportional'" json:"tip_policy"`
+	TipBase           string          `gorm:"type:varchar(16);not null;default:'pre_tax'" json:"tip_base"`
+	TipExcluded       []string        `gorm:"type:jsonb;serializer:json" json:"tip_excluded,omitempty"`
+	// Schedule, in UTC: monthly on DayOfMonth (the last day of shorter
+	// months) or weekly on Weekday
+	Frequency  string     `gorm:"type:varchar(16);not null" json:"frequency"`
+	DayOfMonth int        `json:"day_of_month,omitempty"`
+	Weekday    string     `gorm:"type:varchar(9)" json:"weekday,omitempty"`
+	Active     bool       `gorm:"not null;default:true" json:"active"`
+	NextRunAt  time.Time  `gorm:"not null;index" json:"next_run_at"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	RetryAt    *time.Time `json:"-"` // A failed run waits until then to be retried
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate assigns the template's public ID.
+func (t *BillTemplate) BeforeCreate(tx *gorm.DB) error {
+	return assignPublicID(&t.PublicID)
+}
+
+// TemplateItem is an item copied onto every bill a template creates.
+type TemplateItem struct {
+	Name        string               `json:"name"`
+	Price       float64              `json:"price"`
+	TaxExempt   bool                 `json:"tax_exempt"`
+	Assignments []TemplateAssignment `json:"assignments"`
+}
+
+// TemplateAssignment assigns a percentage of a template item to a person.
+type TemplateAssignment struct {
+	PersonName string       `json:"person_name"`
+	MemberRef  security.Ref `json:"member_id,omitempty"`
+	Percentage float64      `json:"percentage"`
+}
+
+// TemplateSplit is one person's part of a template's bill-level split, read
+// per its split mode as for BillSplit.
+type TemplateSplit struct {
+	PersonName string       `json:"person_name"`
+	MemberRef  security.Ref `json:"member_id,omitempty"`
+	Value      float64      `json:"value"`
+}
@@ -1 +1,27 @@
 package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is an entry in the domain event log, which doubles as a transactional
+// outbox. Events are written in the same transaction as the change they
+// describe, so the log never reports a change that was rolled back, and the
+// event service dispatches them in order per PartitionKey.
+type Event struct {
+	ID           uint            `gorm:"primaryKey;index:idx_events_tab,priority:2;index:idx_events_pending,priority:2" json:"id"`
+	TabID        *uint           `gorm:"index:idx_events_tab,priority:1" json:"-"`
+	BillID       *uint           `gorm:"index" json:"-"`
+	Type         string          `gorm:"type:varchar(40);not null" json:"type"`
+	Payload      json.RawMessage `gorm:"type:jsonb" json:"payload"`
+	PartitionKey string          `gorm:"type:varchar(64);index:idx_events_pending,priority:1,where:dispatched_at IS NULL AND failed_at IS NULL" json:"-"`
+	CreatedAt    time.Time       `json:"created_at"`
+
+	// Dispatch bookkeeping
+	DispatchedAt  *time.Time `json:"-"`
+	Attempts      int        `gorm:"not null;default:0" json:"-"`
+	NextAttemptAt *time.Time `json:"-"`
+	FailedAt      *time.Time `json:"-"`
+	LastError     string     `json:"-"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotencyKey is a request sent with an Idempotency-Key header. It holds
+// a fingerprint of the request and, once the request succeeds, its response,
+// so a retry with the same key is answered without repeating the request.
+// Keys are unique per method and path.
+type IdempotencyKey struct {
+	ID          uint        `gorm:"primaryKey"`
+	Scope       string      `gorm:"type:varchar(512);not null;uniqueIndex:idx_idempotency_scope_key"`
+	Key         string      `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_scope_key"`
+	Fingerprint string      `gorm:"type:varchar(64);not null"`
+	Status      int         `gorm:"not null;default:0"` // 0 while the request is in progress
+	Header      http.Header `gorm:"type:jsonb;serializer:json"`
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
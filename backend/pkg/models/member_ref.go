@@ -0,0 +1,104 @@
+package models
+
+import "backend/pkg/security"
+
+// MemberRefs maps tab members' IDs to their public IDs. Responses refer to
+// members by public ID only, so member references are filled in from it
+// before a record is served.
+type MemberRefs map[uint]string
+
+// NewMemberRefs indexes members by ID.
+func NewMemberRefs(members []TabMember) MemberRefs {
+	refs := make(MemberRefs, len(members))
+	for _, m := range members {
+		refs[m.ID] = m.PublicID
+	}
+	return refs
+}
+
+// Ref returns the public ID of the member with the given ID, or "" if id is
+// nil or not one of the members.
+func (r MemberRefs) Ref(id *uint) security.Ref {
+	if id == nil {
+		return ""
+	}
+	return security.Ref(r[*id])
+}
+
+// Resolve returns the ID of the member ref names, by public ID or legacy
+// numeric ID. An empty ref resolves to nil; ok is false if ref names none of
+// the members.
+func (r MemberRefs) Resolve(ref security.Ref) (id *uint, ok bool) {
+	if ref == "" {
+		return nil, true
+	}
+	for memberID, publicID := range r {
+		if security.MatchesRef(string(ref), memberID, publicID) {
+			memberID := memberID
+			return &memberID, true
+		}
+	}
+	return nil, false
+}
+
+// Bill fills in the member references on the bill and its splits, item
+// assignments and person shares.
+func (r MemberRefs) Bill(b *Bill) {
+	b.AddedByRef = r.Ref(b.AddedByMemberID)
+	for i := range b.Items {
+		for j := range b.Items[i].Assignments {
+			a := &b.Items[i].Assignments[j]
+			a.MemberRef = r.Ref(a.MemberID)
+		}
+	}
+	for i := range b.Splits {
+		b.Splits[i].MemberRef = r.Ref(b.Splits[i].MemberID)
+	}
+	for i := range b.PersonShares {
+		b.PersonShares[i].MemberRef = r.Ref(b.PersonShares[i].MemberID)
+	}
+}
+
+// Settlements fills in the member each settlement is owed by.
+func (r MemberRefs) Settlements(settlements []TabSettlement) {
+	for i := range settlements {
+		settlements[i].MemberRef = r.Ref(settlements[i].MemberID)
+	}
+}
+
+// Balances fills in the member each balance belongs to.
+func (r MemberRefs) Balances(balances []TabBalance) {
+	for i := range balances {
+		balances[i].MemberRef = r.Ref(balances[i].MemberID)
+	}
+}
+
+// Payment fills in the payment's payer and payee members.
+func (r MemberRefs) Payment(p *Payment) {
+	p.PayerRef = r.Ref(p.PayerMemberID)
+	p.PayeeRef = r.Ref(p.PayeeMemberID)
+}
+
+// Activity fills in the member behind each audit entry.
+func (r MemberRefs) Activity(activity []TabActivity) {
+	for i := range activity {
+		activity[i].ActorRef = r.Ref(activity[i].ActorMemberID)
+	}
+}
+
+// Template fills in the member who created the template.
+func (r MemberRefs) Template(t *BillTemplate) {
+	t.CreatedByRef = r.Ref(t.CreatedByMemberID)
+}
+
+// FillMemberRefs fills in the member references on everything loaded with
+// the tab from its own members.
+func (t *Tab) FillMemberRefs() {
+	refs := NewMemberRefs(t.Members)
+	for i := range t.Bills {
+		refs.Bill(&t.Bills[i])
+	}
+	for i := range t.Aliases {
+		t.Aliases[i].MemberRef = refs.Ref(&t.Aliases[i].MemberID)
+	}
+}
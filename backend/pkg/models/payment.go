@@ -1 +1,84 @@
 package models
+
+import (
+	"backend/pkg/security"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Payment records money changing hands against a tab settlement or a bill's
+// person share. Partial payments are separate rows; a settlement's or share's
+// AmountPaid and Paid are derived from the payments that have not been voided.
+type Payment struct {
+	ID       uint   `gorm:"primaryKey" json:"-"`
+	PublicID string `gorm:"type:varchar(22);uniqueIndex" json:"public_id"`
+
+	// Tab payments count towards the payer's settlement in every round, so
+	// they are keyed by payer (PayerKey) rather than by settlement row.
+	TabID         *uint        `gorm:"index:idx_payments_tab_payer,priority:1" json:"-"`
+	PayerKey      string       `gorm:"type:varchar(64);index:idx_payments_tab_payer,priority:2" json:"-"`
+	SettlementID  *uint        `json:"-"`
+	SettlementRef security.Ref `gorm:"-" json:"settlement_id,omitempty"`
+
+	BillID        *uint        `gorm:"index" json:"-"`
+	PersonShareID *uint        `gorm:"index" json:"-"`
+	ShareRef      security.Ref `gorm:"-" json:"person_share_id,omitempty"`
+
+	PayerMemberID *uint        `json:"-"`
+	PayerRef      security.Ref `gorm:"-" json:"payer_member_id,omitempty"`
+	Payer         string       `gorm:"not null" json:"payer"`
+	PayeeMemberID *uint        `json:"-"`
+	PayeeRef      security.Ref `gorm:"-" json:"payee_member_id,omitempty"`
+	Payee         string       `json:"payee"`
+	Amount        float64      `gorm:"not null" json:"amount"`
+	Method        string       `gorm:"type:varchar(32);not null" json:"method"`
+	Note          string       `json:"note,omitempty"`
+	ExternalRef   string       `gorm:"type:varchar(128)" json:"external_ref,omitempty"`
+
+	// Status is "sent" until the payee confirms it as "received" or marks it
+	// "disputed". Only received payments count towards AmountPaid.
+	Status        string     `gorm:"type:varchar(16);not null;default:received" json:"status"`
+	PaidAt        time.Time  `gorm:"not null" json:"paid_at"`
+	ReceivedAt    *time.Time `json:"received_at,omitempty"`
+	DisputedAt    *time.Time `json:"disputed_at,omitempty"`
+	DisputeReason string     `json:"dispute_reason,omitempty"`
+	VoidedAt      *time.Time `json:"voided_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// PaymentState is the paid state of a settlement or person share. It is
+// derived from payments and kept in sync by the payments package; never write
+// it directly.
+type PaymentState struct {
+	AmountPaid    float64 `gorm:"not null;default:0" json:"amount_paid"`
+	AmountPending float64 `gorm:"not null;default:0" json:"amount_pending"`
+	Paid          bool    `gorm:"default:false" json:"paid"`
+	// Status is unpaid, partial, sent (awaiting the payee), received or disputed.
+	Status     string     `gorm:"type:varchar(16);not null;default:unpaid" json:"status"`
+	SentAt     *time.Time `json:"sent_at,omitempty"`
+	ReceivedAt *time.Time `json:"received_at,omitempty"`
+	DisputedAt *time.Time `json:"disputed_at,omitempty"`
+}
+
+// BeforeCreate assigns the payment's public ID.
+func (p *Payment) BeforeCreate(tx *gorm.DB) error {
+	return assignPublicID(&p.PublicID)
+}
+
+// PayerKey identifies who a settlement or tab payment belongs to: the linked
+// member if there is one, otherwise the normalized person name.
+func PayerKey(memberID *uint, name string) string {
+	if memberID != nil {
+		return fmt.Sprintf("member:%d", *memberID)
+	}
+	return "name:" + strings.ToLower(strings.TrimSpace(name))
+}
+
+// outstanding is what is still owed on amount once paid has been received.
+func outstanding(amount, paid float64) float64 {
+	return math.Max(0, math.Round((amount-paid)*100)/100)
+}
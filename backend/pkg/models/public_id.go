@@ -0,0 +1,15 @@
+package models
+
+import "backend/pkg/security"
+
+// assignPublicID sets a fresh random public ID, replacing anything a client
+// sent: public IDs are chosen by the server only. They are what share URLs
+// expose instead of sequential primary keys.
+func assignPublicID(id *string) error {
+	generated, err := security.GeneratePublicID()
+	if err != nil {
+		return err
+	}
+	*id = generated
+	return nil
+}
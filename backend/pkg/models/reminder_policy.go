@@ -0,0 +1,63 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ReminderPolicy is a tab creator's settings for reminding people with unpaid
+// settlements once the tab is finalized. One per tab.
+type ReminderPolicy struct {
+	ID            uint                `gorm:"primaryKey" json:"-"`
+	TabID         uint                `gorm:"not null;uniqueIndex" json:"-"`
+	Active        bool                `gorm:"not null;default:true" json:"active"`
+	IntervalHours int                 `gorm:"not null" json:"interval_hours"`
+	MaxReminders  int                 `gorm:"not null" json:"max_reminders"`
+	Channels      []string            `gorm:"type:jsonb;serializer:json" json:"channels"`
+	Recipients    []ReminderRecipient `gorm:"type:jsonb;serializer:json" json:"recipients"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+// ReminderRecipient is where email reminders for one person go.
+type ReminderRecipient struct {
+	PersonName string `json:"person_name"`
+	Email      string `json:"email"`
+}
+
+// EmailFor returns the reminder address for a settlement's person, matching
+// names case-insensitively, or "" if there is none.
+func (p *ReminderPolicy) EmailFor(personName string) string {
+	for _, r := range p.Recipients {
+		if strings.EqualFold(strings.TrimSpace(r.PersonName), strings.TrimSpace(personName)) {
+			return r.Email
+		}
+	}
+	return ""
+}
+
+// ReminderEmail is one reminder's email, queued so it is sent outside the
+// event dispatcher's transaction, and retried until it goes out. The message
+// is composed when the email is queued so every attempt sends identical bytes.
+type ReminderEmail struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	EventID       uint       `gorm:"not null;uniqueIndex" json:"event_id"`
+	TabID         uint       `gorm:"not null;index" json:"-"`
+	Recipient     string     `gorm:"not null" json:"recipient"`
+	Message       []byte     `gorm:"not null" json:"-"`
+	Status        string     `gorm:"type:varchar(20);not null;default:'pending';index:idx_reminder_emails_due,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `gorm:"index:idx_reminder_emails_due,priority:2" json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ReminderSend records that a reminder event went out through a channel, so
+// a retry of the event skips the channels that already sent it.
+type ReminderSend struct {
+	EventID   uint      `gorm:"primaryKey;autoIncrement:false" json:"event_id"`
+	Channel   string    `gorm:"type:varchar(16);primaryKey" json:"channel"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -2,18 +2,36 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Tab struct {
-	ID          uint       `gorm:"primaryKey" json:"id"`
+	ID          uint       `gorm:"primaryKey" json:"-"`
+	PublicID    string     `gorm:"type:varchar(22);uniqueIndex" json:"public_id"`
 	Name        string     `gorm:"not null" json:"name"`
 	Description string     `json:"description"`
 	Bills       []Bill      `gorm:"foreignKey:TabID" json:"bills"`
 	Members     []TabMember `gorm:"foreignKey:TabID" json:"members,omitempty"`
+	Aliases     []TabMemberAlias `gorm:"foreignKey:TabID" json:"aliases,omitempty"`
 	TotalAmount float64    `gorm:"-" json:"total_amount"`
 	Finalized   bool       `gorm:"default:false" json:"finalized"`
 	FinalizedAt *time.Time `json:"finalized_at"`
 	AccessToken string     `gorm:"type:varchar(64);uniqueIndex" json:"access_token,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
+	// Recurring tabs settle period by period instead of finalizing once
+	Recurring     bool        `gorm:"not null;default:false" json:"recurring"`
+	CurrentPeriod int         `gorm:"not null;default:0" json:"current_period,omitempty"`
+	Periods       []TabPeriod `gorm:"foreignKey:TabID" json:"periods,omitempty"`
+	// Idempotency-Key of the request that last finalized the tab
+	FinalizeKey string `gorm:"type:varchar(255)" json:"-"`
+	// Counts the changes recorded for the tab, its bills and members; served
+	// as its ETag
+	Version uint `gorm:"not null;default:0" json:"version"`
+}
+
+// BeforeCreate assigns the tab's public ID.
+func (t *Tab) BeforeCreate(tx *gorm.DB) error {
+	return assignPublicID(&t.PublicID)
 }
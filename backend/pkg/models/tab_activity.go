@@ -0,0 +1,22 @@
+package models
+
+import (
+	"backend/pkg/security"
+	"encoding/json"
+	"time"
+)
+
+// TabActivity is an immutable audit entry for one change to a tab. Rows are
+// insert-only; the database rejects updates and deletes.
+type TabActivity struct {
+	ID            uint            `gorm:"primaryKey;index:idx_tab_activities_feed,priority:2" json:"id"`
+	TabID         uint            `gorm:"not null;index:idx_tab_activities_feed,priority:1" json:"-"`
+	ActorMemberID *uint           `json:"-"`
+	ActorRef      security.Ref    `gorm:"-" json:"actor_member_id,omitempty"`
+	ActorName     string          `gorm:"not null" json:"actor_name"`
+	Action        string          `gorm:"type:varchar(40);not null" json:"action"`
+	Target        string          `gorm:"type:varchar(64)" json:"target,omitempty"`
+	Before        json.RawMessage `gorm:"type:jsonb" json:"before,omitempty"`
+	After         json.RawMessage `gorm:"type:jsonb" json:"after,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
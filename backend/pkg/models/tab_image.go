@@ -1,15 +1,28 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type TabImage struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	TabID      uint      `gorm:"not null;index" json:"tab_id"`
-	Filename   string    `gorm:"not null" json:"filename"`
-	URL        string    `gorm:"not null" json:"url"`
-	Size       int64     `gorm:"not null" json:"size"`
-	MimeType   string    `gorm:"not null" json:"mime_type"`
-	Processed  bool      `gorm:"default:false" json:"processed"`
-	UploadedBy string    `json:"uploaded_by"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID              uint      `gorm:"primaryKey" json:"-"`
+	PublicID        string    `gorm:"type:varchar(22);uniqueIndex" json:"public_id"`
+	TabID           uint      `gorm:"not null;index;index:idx_tab_images_content,priority:1" json:"-"`
+	Filename        string    `gorm:"not null" json:"filename"`
+	URL             string    `gorm:"not null" json:"url"`
+	Size            int64     `gorm:"not null" json:"size"`
+	MimeType        string    `gorm:"not null" json:"mime_type"`
+	Processed       bool      `gorm:"default:false" json:"processed"`
+	UploadedBy      string    `json:"uploaded_by"`
+	ContentHash     string    `gorm:"type:varchar(64);index:idx_tab_images_content,priority:2" json:"content_hash,omitempty"`
+	PerceptualHash  string    `gorm:"type:varchar(16)" json:"-"`
+	NearDuplicateOf string    `gorm:"-" json:"near_duplicate_of,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// BeforeCreate assigns the image's public ID.
+func (i *TabImage) BeforeCreate(tx *gorm.DB) error {
+	return assignPublicID(&i.PublicID)
 }
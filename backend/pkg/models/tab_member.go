@@ -1,12 +1,40 @@
 package models
 
-import "time"
+import (
+	"backend/pkg/security"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type TabMember struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	TabID       uint      `gorm:"not null;index" json:"tab_id"`
+	ID          uint      `gorm:"primaryKey" json:"-"`
+	PublicID    string    `gorm:"type:varchar(22);uniqueIndex" json:"public_id"`
+	TabID       uint      `gorm:"not null;index" json:"-"`
 	DisplayName string    `gorm:"not null" json:"display_name"`
 	MemberToken string    `gorm:"type:varchar(64);uniqueIndex" json:"-"`
 	Role        string    `gorm:"type:varchar(20);default:'member'" json:"role"`
 	JoinedAt    time.Time `json:"joined_at"`
 }
+
+// BeforeCreate assigns the member's public ID.
+func (m *TabMember) BeforeCreate(tx *gorm.DB) error {
+	return assignPublicID(&m.PublicID)
+}
+
+// TabMemberAlias maps a person name used on the tab's bills to a member, so
+// "Jon" and "Jonathan" settle as the same person.
+type TabMemberAlias struct {
+	ID        uint         `gorm:"primaryKey" json:"-"`
+	PublicID  string       `gorm:"type:varchar(22);uniqueIndex" json:"public_id"`
+	TabID     uint         `gorm:"not null;uniqueIndex:idx_tab_alias_name,priority:1" json:"-"`
+	MemberID  uint         `gorm:"not null;index" json:"-"`
+	MemberRef security.Ref `gorm:"-" json:"member_id"`
+	Name      string       `gorm:"not null;uniqueIndex:idx_tab_alias_name,priority:2" json:"name"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// BeforeCreate assigns the alias's public ID.
+func (a *TabMemberAlias) BeforeCreate(tx *gorm.DB) error {
+	return assignPublicID(&a.PublicID)
+}
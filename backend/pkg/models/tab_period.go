@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// TabPeriod is one settlement period of a recurring tab. The tab's current
+// period is open; closing it settles the tab up to that point.
+type TabPeriod struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	TabID     uint       `gorm:"not null;uniqueIndex:idx_tab_period" json:"-"`
+	Number    int        `gorm:"not null;uniqueIndex:idx_tab_period" json:"number"`
+	StartedAt time.Time  `gorm:"not null" json:"started_at"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+	// Computed from the period's bills
+	Total     float64 `gorm:"-" json:"total"`
+	BillCount int     `gorm:"-" json:"bill_count"`
+}
@@ -1,12 +1,56 @@
 package models
 
-import "time"
+import (
+	"backend/pkg/security"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type TabSettlement struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	TabID      uint      `gorm:"not null;index" json:"tab_id"`
-	PersonName string    `gorm:"not null" json:"person_name"`
-	Amount     float64   `gorm:"not null" json:"amount"`
-	Paid       bool      `gorm:"default:false" json:"paid"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID         uint         `gorm:"primaryKey" json:"-"`
+	PublicID   string       `gorm:"type:varchar(22);uniqueIndex" json:"public_id"`
+	TabID      uint         `gorm:"not null;index" json:"-"`
+	MemberID   *uint        `gorm:"index" json:"-"`
+	MemberRef  security.Ref `gorm:"-" json:"member_id,omitempty"`
+	PersonName string       `gorm:"not null" json:"person_name"`
+	Amount     float64      `gorm:"not null" json:"amount"`
+	// Derived from the payer's payments on the tab, in every round
+	PaymentState
+	Outstanding  float64    `gorm:"-" json:"outstanding"`
+	PayLinks     []PayLink  `gorm:"-" json:"pay_links,omitempty"`
+	Round        int        `gorm:"not null;default:1" json:"round"`
+	SupersededAt *time.Time `gorm:"index" json:"superseded_at,omitempty"`
+	// Recurring tabs only: Amount and AmountPaid run from the start of the
+	// tab, PeriodAmount is the closed period's part and CarriedOver what was
+	// still owed from earlier periods
+	PeriodAmount *float64 `json:"period_amount,omitempty"`
+	CarriedOver  *float64 `json:"carried_over,omitempty"`
+	// Set by the reminder scheduler
+	RemindersSent  int        `gorm:"not null;default:0" json:"reminders_sent"`
+	LastRemindedAt *time.Time `json:"last_reminded_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// AfterFind computes what is still owed on the settlement.
+func (s *TabSettlement) AfterFind(tx *gorm.DB) error {
+	s.Outstanding = outstanding(s.Amount, s.AmountPaid)
+	return nil
+}
+
+// BeforeCreate assigns the settlement's public ID.
+func (s *TabSettlement) BeforeCreate(tx *gorm.DB) error {
+	return assignPublicID(&s.PublicID)
+}
+
+// TabBalance is one person's running position on a tab: what they paid for
+// bills against their share of them. Computed per request, never stored.
+// Owed is what their settlement would be if the tab were finalized now.
+type TabBalance struct {
+	MemberID   *uint        `json:"-"`
+	MemberRef  security.Ref `json:"member_id,omitempty"`
+	PersonName string       `json:"person_name"`
+	Paid       float64      `json:"paid"`
+	Owed       float64      `json:"owed"`
+	Net        float64      `json:"net"`
 }
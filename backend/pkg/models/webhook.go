@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook is a tab's subscription to its domain events. Events is a filter of
+// event types ("bill.added") or type prefixes ("bill.*"); empty means all.
+type Webhook struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	PublicID  string    `gorm:"type:varchar(22);uniqueIndex" json:"public_id"`
+	TabID     uint      `gorm:"not null;index" json:"-"`
+	URL       string    `gorm:"not null" json:"url"`
+	Secret    string    `gorm:"type:varchar(80);not null" json:"-"`
+	Events    []string  `gorm:"type:jsonb;serializer:json" json:"events"`
+	Active    bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BeforeCreate assigns the webhook's public ID.
+func (w *Webhook) BeforeCreate(tx *gorm.DB) error {
+	return assignPublicID(&w.PublicID)
+}
+
+// WebhookDelivery is one event's delivery to one webhook, including retries.
+// The body is frozen when the delivery is queued so every attempt sends, and
+// signs, identical bytes.
+type WebhookDelivery struct {
+	ID             uint            `gorm:"primaryKey;index:idx_webhook_deliveries_log,priority:2" json:"id"`
+	WebhookID      uint            `gorm:"not null;uniqueIndex:idx_webhook_delivery_event,priority:1;index:idx_webhook_deliveries_log,priority:1" json:"-"`
+	Webhook        *Webhook        `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	EventID        uint            `gorm:"not null;uniqueIndex:idx_webhook_delivery_event,priority:2" json:"event_id"`
+	EventType      string          `gorm:"type:varchar(40);not null" json:"event_type"`
+	Body           json.RawMessage `gorm:"type:jsonb;not null" json:"-"`
+	Status         string          `gorm:"type:varchar(20);not null;default:'pending';index:idx_webhook_deliveries_due,priority:1" json:"status"`
+	Attempts       int             `gorm:"not null;default:0" json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `gorm:"index:idx_webhook_deliveries_due,priority:2" json:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
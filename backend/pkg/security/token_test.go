@@ -1,6 +1,7 @@
 package security
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 )
@@ -59,3 +60,67 @@ func TestBase62EncodeKnownValue(t *testing.T) {
 		t.Errorf("expected '47' for 0xFF, got %q", result)
 	}
 }
+
+func TestGeneratePublicID(t *testing.T) {
+	seen := make(map[string]struct{}, 1000)
+	for i := 0; i < 1000; i++ {
+		id, err := GeneratePublicID()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(id) != PublicIDLength {
+			t.Fatalf("expected length %d, got %d: %q", PublicIDLength, len(id), id)
+		}
+		if _, exists := seen[id]; exists {
+			t.Fatalf("duplicate public id generated: %q", id)
+		}
+		seen[id] = struct{}{}
+
+		n, pub, err := ParseRef(id)
+		if err != nil || n != 0 || pub != id {
+			t.Fatalf("expected %q to parse as a public id, got (%d, %q, %v)", id, n, pub, err)
+		}
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	n, pub, err := ParseRef("42")
+	if err != nil || n != 42 || pub != "" {
+		t.Errorf("expected legacy id 42, got (%d, %q, %v)", n, pub, err)
+	}
+
+	for _, bad := range []string{"", "0", "-1", "abc", "99999999999", "4zX9kQ2mN8pL1vR7tY3wB!"} {
+		if _, _, err := ParseRef(bad); err != ErrInvalidRef {
+			t.Errorf("expected ErrInvalidRef for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestMatchesRef(t *testing.T) {
+	pub := "4zX9kQ2mN8pL1vR7tY3wBc"
+	if !MatchesRef("7", 7, pub) {
+		t.Error("expected numeric ref to match")
+	}
+	if !MatchesRef(pub, 7, pub) {
+		t.Error("expected public ref to match")
+	}
+	if MatchesRef("8", 7, pub) || MatchesRef("4zX9kQ2mN8pL1vR7tY3wBd", 7, pub) {
+		t.Error("expected mismatched refs not to match")
+	}
+}
+
+func TestRefUnmarshalJSON(t *testing.T) {
+	var body struct {
+		A Ref `json:"a"`
+		B Ref `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a": 5, "b": "4zX9kQ2mN8pL1vR7tY3wBc"}`), &body); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body.A != "5" || body.B != "4zX9kQ2mN8pL1vR7tY3wBc" {
+		t.Errorf("unexpected refs: %q, %q", body.A, body.B)
+	}
+	if err := json.Unmarshal([]byte(`{"a": -5}`), &body); err == nil {
+		t.Error("expected error for negative id")
+	}
+}
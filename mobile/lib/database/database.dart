@@ -84,7 +84,7 @@ class Tabs extends Table {
   TextColumn get name => text()();
   TextColumn get description => text().withDefault(const Constant(''))();
   TextColumn get billIds => text().withDefault(const Constant(''))();
-  IntColumn get backendId => integer().nullable()();
+  TextColumn get backendId => text().nullable()();
   TextColumn get accessToken => text().nullable()();
   TextColumn get shareUrl => text().nullable()();
   BoolColumn get finalized => boolean().withDefault(const Constant(false))();
@@ -126,7 +126,7 @@ class AppDatabase extends _$AppDatabase {
   AppDatabase() : super(_openConnection());
 
   @override
-  int get schemaVersion => 7;
+  int get schemaVersion => 8;
 
   @override
   MigrationStrategy get migration => MigrationStrategy(
@@ -156,6 +156,13 @@ class AppDatabase extends _$AppDatabase {
         await migrator.createTable(peopleGroupMembers);
         await customStatement('CREATE UNIQUE INDEX IF NOT EXISTS idx_people_group_members_unique ON people_group_members(group_id, person_id)');
       }
+      if (from < 8) {
+        // Tabs are now addressed by their public ID; numeric IDs still resolve
+        await migrator.alterTable(TableMigration(
+          tabs,
+          columnTransformer: {tabs.backendId: tabs.backendId.cast<String>()},
+        ));
+      }
     },
     beforeOpen: (details) async {
       await customStatement('PRAGMA foreign_keys = ON');
@@ -1928,11 +1928,11 @@ class $TabsTable extends Tabs with TableInfo<$TabsTable, Tab> {
     'backendId',
   );
   @override
-  late final GeneratedColumn<int> backendId = GeneratedColumn<int>(
+  late final GeneratedColumn<String> backendId = GeneratedColumn<String>(
     'backend_id',
     aliasedName,
     true,
-    type: DriftSqlType.int,
+    type: DriftSqlType.string,
     requiredDuringInsert: false,
   );
   static const VerificationMeta _accessTokenMeta = const VerificationMeta(
@@ -2156,7 +2156,7 @@ class $TabsTable extends Tabs with TableInfo<$TabsTable, Tab> {
             data['${effectivePrefix}bill_ids'],
           )!,
       backendId: attachedDatabase.typeMapping.read(
-        DriftSqlType.int,
+        DriftSqlType.string,
         data['${effectivePrefix}backend_id'],
       ),
       accessToken: attachedDatabase.typeMapping.read(
@@ -2204,7 +2204,7 @@ class Tab extends DataClass implements Insertable<Tab> {
   final String name;
   final String description;
   final String billIds;
-  final int? backendId;
+  final String? backendId;
   final String? accessToken;
   final String? shareUrl;
   final bool finalized;
@@ -2234,7 +2234,7 @@ class Tab extends DataClass implements Insertable<Tab> {
     map['description'] = Variable<String>(description);
     map['bill_ids'] = Variable<String>(billIds);
     if (!nullToAbsent || backendId != null) {
-      map['backend_id'] = Variable<int>(backendId);
+      map['backend_id'] = Variable<String>(backendId);
     }
     if (!nullToAbsent || accessToken != null) {
       map['access_token'] = Variable<String>(accessToken);
@@ -2293,7 +2293,7 @@ class Tab extends DataClass implements Insertable<Tab> {
       name: serializer.fromJson<String>(json['name']),
       description: serializer.fromJson<String>(json['description']),
       billIds: serializer.fromJson<String>(json['billIds']),
-      backendId: serializer.fromJson<int?>(json['backendId']),
+      backendId: serializer.fromJson<String?>(json['backendId']),
       accessToken: serializer.fromJson<String?>(json['accessToken']),
       shareUrl: serializer.fromJson<String?>(json['shareUrl']),
       finalized: serializer.fromJson<bool>(json['finalized']),
@@ -2311,7 +2311,7 @@ class Tab extends DataClass implements Insertable<Tab> {
       'name': serializer.toJson<String>(name),
       'description': serializer.toJson<String>(description),
       'billIds': serializer.toJson<String>(billIds),
-      'backendId': serializer.toJson<int?>(backendId),
+      'backendId': serializer.toJson<String?>(backendId),
       'accessToken': serializer.toJson<String?>(accessToken),
       'shareUrl': serializer.toJson<String?>(shareUrl),
       'finalized': serializer.toJson<bool>(finalized),
@@ -2327,7 +2327,7 @@ class Tab extends DataClass implements Insertable<Tab> {
     String? name,
     String? description,
     String? billIds,
-    Value<int?> backendId = const Value.absent(),
+    Value<String?> backendId = const Value.absent(),
     Value<String?> accessToken = const Value.absent(),
     Value<String?> shareUrl = const Value.absent(),
     bool? finalized,
@@ -2426,7 +2426,7 @@ class TabsCompanion extends UpdateCompanion<Tab> {
   final Value<String> name;
   final Value<String> description;
   final Value<String> billIds;
-  final Value<int?> backendId;
+  final Value<String?> backendId;
   final Value<String?> accessToken;
   final Value<String?> shareUrl;
   final Value<bool> finalized;
@@ -2467,7 +2467,7 @@ class TabsCompanion extends UpdateCompanion<Tab> {
     Expression<String>? name,
     Expression<String>? description,
     Expression<String>? billIds,
-    Expression<int>? backendId,
+    Expression<String>? backendId,
     Expression<String>? accessToken,
     Expression<String>? shareUrl,
     Expression<bool>? finalized,
@@ -2497,7 +2497,7 @@ class TabsCompanion extends UpdateCompanion<Tab> {
     Value<String>? name,
     Value<String>? description,
     Value<String>? billIds,
-    Value<int?>? backendId,
+    Value<String?>? backendId,
     Value<String?>? accessToken,
     Value<String?>? shareUrl,
     Value<bool>? finalized,
@@ -2538,7 +2538,7 @@ class TabsCompanion extends UpdateCompanion<Tab> {
       map['bill_ids'] = Variable<String>(billIds.value);
     }
     if (backendId.present) {
-      map['backend_id'] = Variable<int>(backendId.value);
+      map['backend_id'] = Variable<String>(backendId.value);
     }
     if (accessToken.present) {
       map['access_token'] = Variable<String>(accessToken.value);
@@ -4408,7 +4408,7 @@ typedef $$TabsTableCreateCompanionBuilder =
       required String name,
       Value<String> description,
       Value<String> billIds,
-      Value<int?> backendId,
+      Value<String?> backendId,
       Value<String?> accessToken,
       Value<String?> shareUrl,
       Value<bool> finalized,
@@ -4423,7 +4423,7 @@ typedef $$TabsTableUpdateCompanionBuilder =
       Value<String> name,
       Value<String> description,
       Value<String> billIds,
-      Value<int?> backendId,
+      Value<String?> backendId,
       Value<String?> accessToken,
       Value<String?> shareUrl,
       Value<bool> finalized,
@@ -4461,7 +4461,7 @@ class $$TabsTableFilterComposer extends Composer<_$AppDatabase, $TabsTable> {
     builder: (column) => ColumnFilters(column),
   );
 
-  ColumnFilters<int> get backendId => $composableBuilder(
+  ColumnFilters<String> get backendId => $composableBuilder(
     column: $table.backendId,
     builder: (column) => ColumnFilters(column),
   );
@@ -4530,7 +4530,7 @@ class $$TabsTableOrderingComposer extends Composer<_$AppDatabase, $TabsTable> {
     builder: (column) => ColumnOrderings(column),
   );
 
-  ColumnOrderings<int> get backendId => $composableBuilder(
+  ColumnOrderings<String> get backendId => $composableBuilder(
     column: $table.backendId,
     builder: (column) => ColumnOrderings(column),
   );
@@ -4594,7 +4594,7 @@ class $$TabsTableAnnotationComposer
   GeneratedColumn<String> get billIds =>
       $composableBuilder(column: $table.billIds, builder: (column) => column);
 
-  GeneratedColumn<int> get backendId =>
+  GeneratedColumn<String> get backendId =>
       $composableBuilder(column: $table.backendId, builder: (column) => column);
 
   GeneratedColumn<String> get accessToken => $composableBuilder(
@@ -4655,7 +4655,7 @@ class $$TabsTableTableManager
                 Value<String> name = const Value.absent(),
                 Value<String> description = const Value.absent(),
                 Value<String> billIds = const Value.absent(),
-                Value<int?> backendId = const Value.absent(),
+                Value<String?> backendId = const Value.absent(),
                 Value<String?> accessToken = const Value.absent(),
                 Value<String?> shareUrl = const Value.absent(),
                 Value<bool> finalized = const Value.absent(),
@@ -4683,7 +4683,7 @@ class $$TabsTableTableManager
                 required String name,
                 Value<String> description = const Value.absent(),
                 Value<String> billIds = const Value.absent(),
-                Value<int?> backendId = const Value.absent(),
+                Value<String?> backendId = const Value.absent(),
                 Value<String?> accessToken = const Value.absent(),
                 Value<String?> shareUrl = const Value.absent(),
                 Value<bool> finalized = const Value.absent(),
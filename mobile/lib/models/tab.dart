@@ -6,7 +6,7 @@ class AppTab {
   final String description;
   final DateTime createdAt;
   final List<int> billIds; // References to RecentBill IDs
-  final int? backendId;
+  final String? backendId;
   final String? accessToken;
   final String? shareUrl;
   final bool finalized;
@@ -55,7 +55,7 @@ class AppTab {
     String? description,
     DateTime? createdAt,
     List<int>? billIds,
-    int? backendId,
+    String? backendId,
     String? accessToken,
     String? shareUrl,
     bool? finalized,
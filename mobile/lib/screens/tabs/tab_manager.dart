@@ -221,9 +221,9 @@ class TabManager extends ChangeNotifier {
       final pathSegments = uri.pathSegments;
       if (pathSegments.length < 2 || pathSegments[0] != 't') return null;
 
-      final tabId = int.tryParse(pathSegments[1]);
+      final tabId = pathSegments[1];
       final accessToken = uri.queryParameters['t'];
-      if (tabId == null || accessToken == null) return null;
+      if (tabId.isEmpty || accessToken == null) return null;
 
       final apiService = ApiService();
 
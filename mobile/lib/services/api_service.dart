@@ -77,7 +77,7 @@ class ApiService {
           throw ApiException('Failed to parse bill upload response');
         }
         return BillUploadResponse(
-          billId: data['bill_id'] as int? ?? 0,
+          billId: data['public_id'] as String? ?? '',
           accessToken: data['access_token'] as String? ?? '',
           shareUrl: data['share_url'] as String? ?? '',
         );
@@ -143,11 +143,11 @@ class ApiService {
           throw ApiException('Failed to parse tab creation response');
         }
         return TabCreateResponse(
-          tabId: data['tab_id'] as int? ?? 0,
+          tabId: data['public_id'] as String? ?? '',
           accessToken: data['access_token'] as String? ?? '',
           shareUrl: data['share_url'] as String? ?? '',
           memberToken: data['member_token'] as String?,
-          memberId: data['member_id'] as int?,
+          memberId: data['member_id'] as String?,
         );
       } else {
         throw ApiException(
@@ -168,7 +168,7 @@ class ApiService {
 
   /// Adds a bill to a tab on the backend
   Future<bool> addBillToTab(
-    int tabId,
+    String tabId,
     int billId,
     String accessToken, {
     String? memberToken,
@@ -209,7 +209,7 @@ class ApiService {
 
   /// Uploads an image to a tab
   Future<TabImageResponse> uploadTabImage(
-    int tabId,
+    String tabId,
     String accessToken,
     File imageFile, {
     String? memberToken,
@@ -255,7 +255,7 @@ class ApiService {
 
   /// Gets all images for a tab
   Future<List<TabImageResponse>> getTabImages(
-    int tabId,
+    String tabId,
     String accessToken,
   ) async {
     try {
@@ -286,8 +286,8 @@ class ApiService {
 
   /// Toggles the processed status of an image
   Future<bool> updateTabImage(
-    int tabId,
-    int imageId,
+    String tabId,
+    String imageId,
     String accessToken,
     bool processed,
   ) async {
@@ -321,7 +321,7 @@ class ApiService {
 
   /// Finalizes a tab, locking it from further edits and creating settlements
   Future<List<SettlementResponse>> finalizeTab(
-    int tabId,
+    String tabId,
     String accessToken, {
     String? memberToken,
   }) async {
@@ -360,7 +360,7 @@ class ApiService {
 
   /// Gets settlements for a finalized tab
   Future<List<SettlementResponse>> getSettlements(
-    int tabId,
+    String tabId,
     String accessToken,
   ) async {
     try {
@@ -391,8 +391,8 @@ class ApiService {
 
   /// Toggles the paid status of a settlement
   Future<bool> updateSettlement(
-    int tabId,
-    int settlementId,
+    String tabId,
+    String settlementId,
     String accessToken,
     bool paid,
   ) async {
@@ -426,8 +426,8 @@ class ApiService {
 
   /// Deletes an image from a tab
   Future<bool> deleteTabImage(
-    int tabId,
-    int imageId,
+    String tabId,
+    String imageId,
     String accessToken,
   ) async {
     try {
@@ -456,7 +456,7 @@ class ApiService {
 
   /// Joins a tab as a new member
   Future<TabJoinResponse> joinTab(
-    int tabId,
+    String tabId,
     String accessToken,
     String displayName,
   ) async {
@@ -476,7 +476,7 @@ class ApiService {
           throw ApiException('Failed to parse join tab response');
         }
         return TabJoinResponse(
-          memberId: data['member_id'] as int? ?? 0,
+          memberId: data['member_id'] as String? ?? '',
           memberToken: data['member_token'] as String? ?? '',
           displayName: data['display_name'] as String? ?? '',
           role: data['role'] as String? ?? 'member',
@@ -500,7 +500,7 @@ class ApiService {
 
   /// Gets members of a tab
   Future<List<TabMemberResponse>> getTabMembers(
-    int tabId,
+    String tabId,
     String accessToken,
   ) async {
     try {
@@ -531,7 +531,7 @@ class ApiService {
 
   /// Fetches full tab data from the backend
   Future<Map<String, dynamic>> getTabData(
-    int tabId,
+    String tabId,
     String accessToken,
   ) async {
     try {
@@ -614,11 +614,11 @@ class ApiService {
 
 /// Response object from tab creation
 class TabCreateResponse {
-  final int tabId;
+  final String tabId;
   final String accessToken;
   final String shareUrl;
   final String? memberToken;
-  final int? memberId;
+  final String? memberId;
 
   TabCreateResponse({
     required this.tabId,
@@ -631,7 +631,7 @@ class TabCreateResponse {
 
 /// Response object from joining a tab
 class TabJoinResponse {
-  final int memberId;
+  final String memberId;
   final String memberToken;
   final String displayName;
   final String role;
@@ -646,7 +646,7 @@ class TabJoinResponse {
 
 /// Response object for tab members
 class TabMemberResponse {
-  final int id;
+  final String id;
   final String displayName;
   final String role;
   final String joinedAt;
@@ -660,7 +660,7 @@ class TabMemberResponse {
 
   factory TabMemberResponse.fromJson(Map<String, dynamic> json) {
     return TabMemberResponse(
-      id: (json['id'] as int?) ?? 0,
+      id: json['public_id'] as String? ?? '',
       displayName: json['display_name'] ?? '',
       role: json['role'] ?? 'member',
       joinedAt: json['joined_at'] ?? '',
@@ -670,7 +670,7 @@ class TabMemberResponse {
 
 /// Response object from bill upload
 class BillUploadResponse {
-  final int billId;
+  final String billId;
   final String accessToken;
   final String shareUrl;
 
@@ -683,8 +683,7 @@ class BillUploadResponse {
 
 /// Response object for tab images
 class TabImageResponse {
-  final int id;
-  final int tabId;
+  final String id;
   final String filename;
   final String url;
   final int size;
@@ -695,7 +694,6 @@ class TabImageResponse {
 
   TabImageResponse({
     required this.id,
-    required this.tabId,
     required this.filename,
     required this.url,
     required this.size,
@@ -707,8 +705,7 @@ class TabImageResponse {
 
   factory TabImageResponse.fromJson(Map<String, dynamic> json) {
     return TabImageResponse(
-      id: (json['id'] as int?) ?? 0,
-      tabId: (json['tab_id'] as int?) ?? 0,
+      id: json['public_id'] as String? ?? '',
       filename: json['filename'] ?? '',
       url: json['url'] ?? '',
       size: json['size'] ?? 0,
@@ -722,8 +719,7 @@ class TabImageResponse {
 
 /// Response object for tab settlements
 class SettlementResponse {
-  final int id;
-  final int tabId;
+  final String id;
   final String personName;
   final double amount;
   final bool paid;
@@ -731,7 +727,6 @@ class SettlementResponse {
 
   SettlementResponse({
     required this.id,
-    required this.tabId,
     required this.personName,
     required this.amount,
     required this.paid,
@@ -740,8 +735,7 @@ class SettlementResponse {
 
   factory SettlementResponse.fromJson(Map<String, dynamic> json) {
     return SettlementResponse(
-      id: (json['id'] as int?) ?? 0,
-      tabId: (json['tab_id'] as int?) ?? 0,
+      id: json['public_id'] as String? ?? '',
       personName: json['person_name'] ?? '',
       amount: (json['amount'] ?? 0).toDouble(),
       paid: json['paid'] ?? false,
@@ -4,7 +4,7 @@ import 'package:checks_frontend/models/tab.dart';
 void main() {
   AppTab makeTab({
     int? id,
-    int? backendId,
+    String? backendId,
     String? accessToken,
     bool finalized = false,
     String? memberToken,
@@ -54,7 +54,7 @@ void main() {
 
   group('AppTab.isSynced', () {
     test('returns true when backendId is set', () {
-      final tab = makeTab(backendId: 123);
+      final tab = makeTab(backendId: 'Kq3Vb8LmT2xYp9Rw5NcZa1');
       expect(tab.isSynced, isTrue);
     });
 
@@ -126,14 +126,14 @@ void main() {
     test('preserves all fields when no arguments given', () {
       final tab = makeTab(
         id: 1,
-        backendId: 10,
+        backendId: 'Zz3Vb8LmT2xYp9Rw5NcZa1',
         accessToken: 'tok',
         finalized: true,
       );
       final copy = tab.copyWith();
       expect(copy.id, equals(1));
       expect(copy.name, equals('Test Tab'));
-      expect(copy.backendId, equals(10));
+      expect(copy.backendId, equals('Zz3Vb8LmT2xYp9Rw5NcZa1'));
       expect(copy.accessToken, equals('tok'));
       expect(copy.isFinalized, isTrue);
       expect(copy.billIds, equals([1, 2, 3]));
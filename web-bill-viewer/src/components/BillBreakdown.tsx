@@ -23,9 +23,9 @@ export default function BillBreakdown({
     <div className="space-y-3 mb-6">
       <CollapsibleSection title="Items" icon={<FaReceipt size={14} />}>
         <div className="space-y-3 mt-4">
-          {items.map((item) => (
+          {items.map((item, index) => (
             <div
-              key={item.id}
+              key={index}
               className="flex justify-between items-center py-1"
             >
               <span className="font-medium text-[var(--accent)] dark:text-white">
@@ -224,10 +224,10 @@ export default function BillPageClient({ id, token }: BillPageClientProps) {
             )
             .map((share) => (
               <PersonShare
-                key={share.id}
+                key={share.public_id}
                 personShare={share}
                 hasVenmo={hasVenmo}
-                billId={bill.id}
+                billId={bill.public_id}
                 token={token}
               />
             ))}
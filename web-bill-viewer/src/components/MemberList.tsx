@@ -18,7 +18,7 @@ export default function MemberList({ members }: MemberListProps) {
       <div className="flex flex-wrap gap-2">
         {members.map((member) => (
           <div
-            key={member.id}
+            key={member.public_id}
             className="inline-flex items-center gap-2 bg-[var(--card-bg-light)] dark:bg-[var(--card-bg-dark)] rounded-full px-4 py-2 shadow-sm dark:shadow-none dark:border dark:border-[var(--border-dark)]"
           >
             {member.role === "creator" ? (
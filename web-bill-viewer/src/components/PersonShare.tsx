@@ -10,7 +10,7 @@ import { SiVenmo } from "react-icons/si";
 interface PersonShareProps {
   personShare: PersonShareType;
   hasVenmo?: string | null;
-  billId: number;
+  billId: string;
   token: string;
 }
 
@@ -32,7 +32,7 @@ export default function PersonShare({
     setPaid(newPaid);
 
     try {
-      await updatePersonSharePaid(billId, personShare.id, newPaid, token);
+      await updatePersonSharePaid(billId, personShare.public_id, newPaid, token);
     } catch {
       setPaid(!newPaid);
     } finally {
@@ -20,27 +20,27 @@ export default function SettlementCard({
   token,
 }: SettlementCardProps) {
   const [settlements, setSettlements] = useState(initialSettlements);
-  const [togglingId, setTogglingId] = useState<number | null>(null);
+  const [togglingId, setTogglingId] = useState<string | null>(null);
 
   const paidCount = settlements.filter((s) => s.paid).length;
 
   const togglePaid = async (settlement: TabSettlement) => {
     if (togglingId !== null) return;
     const newPaid = !settlement.paid;
-    setTogglingId(settlement.id);
+    setTogglingId(settlement.public_id);
 
     // Optimistic update
     setSettlements((prev) =>
-      prev.map((s) => (s.id === settlement.id ? { ...s, paid: newPaid } : s))
+      prev.map((s) => (s.public_id === settlement.public_id ? { ...s, paid: newPaid } : s))
     );
 
     try {
-      await updateSettlementPaid(tabId, settlement.id, newPaid, token);
+      await updateSettlementPaid(tabId, settlement.public_id, newPaid, token);
     } catch {
       // Revert on error
       setSettlements((prev) =>
         prev.map((s) =>
-          s.id === settlement.id ? { ...s, paid: !newPaid } : s
+          s.public_id === settlement.public_id ? { ...s, paid: !newPaid } : s
         )
       );
     } finally {
@@ -66,7 +66,7 @@ export default function SettlementCard({
       <div className="space-y-3">
         {settlements.map((settlement) => (
           <div
-            key={settlement.id}
+            key={settlement.public_id}
             className={`bg-[var(--card-bg-light)] dark:bg-[var(--card-bg-dark)] rounded-2xl px-5 py-4 shadow-sm dark:shadow-none flex items-center justify-between transition-opacity ${
               settlement.paid
                 ? "dark:border dark:border-emerald-800/50 opacity-75"
@@ -51,7 +51,7 @@ function BillCard({ bill }: { bill: Bill }) {
           <table className="w-full mt-3">
             <tbody>
               {bill.person_shares.map((share) => (
-                <tr key={share.id}>
+                <tr key={share.public_id}>
                   <td className="py-1.5 text-sm text-[var(--accent)] dark:text-gray-300">
                     <div className="flex items-center gap-2">
                       <span className="w-7 h-7 rounded-full bg-[var(--secondary)] dark:bg-white/10 flex items-center justify-center text-xs font-semibold text-[var(--text-secondary)]">
@@ -83,7 +83,7 @@ export default function TabBillList({ bills }: TabBillListProps) {
       </h2>
       <div className="space-y-3">
         {bills.map((bill) => (
-          <BillCard key={bill.id} bill={bill} />
+          <BillCard key={bill.public_id} bill={bill} />
         ))}
       </div>
     </div>
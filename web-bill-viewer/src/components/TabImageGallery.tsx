@@ -34,7 +34,7 @@ export default function TabImageGallery({
           <div className="grid grid-cols-2 sm:grid-cols-3 gap-3">
             {images.map((image, index) => (
               <button
-                key={image.id}
+                key={image.public_id}
                 onClick={() => setLightboxIndex(index)}
                 className="relative aspect-square rounded-xl overflow-hidden group focus:outline-none focus-visible:ring-2 focus-visible:ring-[var(--primary)] focus-visible:ring-offset-2"
               >
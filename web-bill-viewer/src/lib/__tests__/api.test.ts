@@ -7,7 +7,7 @@ import type { Tab, Bill } from "../api";
 describe("computeTabPersonTotals", () => {
   it("aggregates across multiple bills", () => {
     const tab: Tab = {
-      id: 1,
+      public_id: "1",
       name: "Trip",
       description: "",
       total_amount: 150,
@@ -16,7 +16,7 @@ describe("computeTabPersonTotals", () => {
       created_at: "2025-01-01",
       bills: [
         {
-          id: 1,
+          public_id: "1",
           name: "Dinner",
           subtotal: 80,
           tax: 5,
@@ -27,12 +27,12 @@ describe("computeTabPersonTotals", () => {
           payment_methods: [],
           items: [],
           person_shares: [
-            { id: 1, person_name: "Alice", items: [], subtotal: 40, tax_share: 2.5, tip_share: 7.5, total: 60, paid: false },
-            { id: 2, person_name: "Bob", items: [], subtotal: 30, tax_share: 2.5, tip_share: 7.5, total: 40, paid: false },
+            { public_id: "1", person_name: "Alice", items: [], subtotal: 40, tax_share: 2.5, tip_share: 7.5, total: 60, paid: false },
+            { public_id: "2", person_name: "Bob", items: [], subtotal: 30, tax_share: 2.5, tip_share: 7.5, total: 40, paid: false },
           ],
         },
         {
-          id: 2,
+          public_id: "2",
           name: "Lunch",
           subtotal: 40,
           tax: 3,
@@ -43,8 +43,8 @@ describe("computeTabPersonTotals", () => {
           payment_methods: [],
           items: [],
           person_shares: [
-            { id: 3, person_name: "Alice", items: [], subtotal: 25, tax_share: 2, tip_share: 3, total: 30, paid: false },
-            { id: 4, person_name: "Bob", items: [], subtotal: 15, tax_share: 1, tip_share: 4, total: 20, paid: false },
+            { public_id: "3", person_name: "Alice", items: [], subtotal: 25, tax_share: 2, tip_share: 3, total: 30, paid: false },
+            { public_id: "4", person_name: "Bob", items: [], subtotal: 15, tax_share: 1, tip_share: 4, total: 20, paid: false },
           ],
         },
       ],
@@ -62,7 +62,7 @@ describe("computeTabPersonTotals", () => {
 
   it("merges names case-insensitively", () => {
     const tab: Tab = {
-      id: 1,
+      public_id: "1",
       name: "Trip",
       description: "",
       total_amount: 100,
@@ -71,7 +71,7 @@ describe("computeTabPersonTotals", () => {
       created_at: "2025-01-01",
       bills: [
         {
-          id: 1,
+          public_id: "1",
           name: "Dinner",
           subtotal: 80,
           tax: 0,
@@ -82,8 +82,8 @@ describe("computeTabPersonTotals", () => {
           payment_methods: [],
           items: [],
           person_shares: [
-            { id: 1, person_name: "alice", items: [], subtotal: 40, tax_share: 0, tip_share: 0, total: 40, paid: false },
-            { id: 2, person_name: "Alice", items: [], subtotal: 40, tax_share: 0, tip_share: 0, total: 40, paid: false },
+            { public_id: "1", person_name: "alice", items: [], subtotal: 40, tax_share: 0, tip_share: 0, total: 40, paid: false },
+            { public_id: "2", person_name: "Alice", items: [], subtotal: 40, tax_share: 0, tip_share: 0, total: 40, paid: false },
           ],
         },
       ],
@@ -98,7 +98,7 @@ describe("computeTabPersonTotals", () => {
 
   it("sorts alphabetically by name", () => {
     const tab: Tab = {
-      id: 1,
+      public_id: "1",
       name: "Trip",
       description: "",
       total_amount: 100,
@@ -107,7 +107,7 @@ describe("computeTabPersonTotals", () => {
       created_at: "2025-01-01",
       bills: [
         {
-          id: 1,
+          public_id: "1",
           name: "Dinner",
           subtotal: 100,
           tax: 0,
@@ -118,9 +118,9 @@ describe("computeTabPersonTotals", () => {
           payment_methods: [],
           items: [],
           person_shares: [
-            { id: 1, person_name: "Charlie", items: [], subtotal: 10, tax_share: 0, tip_share: 0, total: 10, paid: false },
-            { id: 2, person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: false },
-            { id: 3, person_name: "Bob", items: [], subtotal: 30, tax_share: 0, tip_share: 0, total: 30, paid: false },
+            { public_id: "1", person_name: "Charlie", items: [], subtotal: 10, tax_share: 0, tip_share: 0, total: 10, paid: false },
+            { public_id: "2", person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: false },
+            { public_id: "3", person_name: "Bob", items: [], subtotal: 30, tax_share: 0, tip_share: 0, total: 30, paid: false },
           ],
         },
       ],
@@ -134,7 +134,7 @@ describe("computeTabPersonTotals", () => {
 
   it("returns empty array for tab with no bills", () => {
     const tab: Tab = {
-      id: 1,
+      public_id: "1",
       name: "Empty",
       description: "",
       total_amount: 0,
@@ -150,23 +150,23 @@ describe("computeTabPersonTotals", () => {
 
   it("all_paid is true when all shares for a person are paid", () => {
     const tab: Tab = {
-      id: 1, name: "Trip", description: "", total_amount: 100,
+      public_id: "1", name: "Trip", description: "", total_amount: 100,
       finalized: false, finalized_at: null, created_at: "2025-01-01",
       bills: [
         {
-          id: 1, name: "Dinner", subtotal: 50, tax: 0, tip_amount: 0,
+          public_id: "1", name: "Dinner", subtotal: 50, tax: 0, tip_amount: 0,
           tip_percentage: 0, total: 50, date: "2025-01-01",
           payment_methods: [], items: [],
           person_shares: [
-            { id: 1, person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: true },
+            { public_id: "1", person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: true },
           ],
         },
         {
-          id: 2, name: "Lunch", subtotal: 50, tax: 0, tip_amount: 0,
+          public_id: "2", name: "Lunch", subtotal: 50, tax: 0, tip_amount: 0,
           tip_percentage: 0, total: 50, date: "2025-01-02",
           payment_methods: [], items: [],
           person_shares: [
-            { id: 2, person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: true },
+            { public_id: "2", person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: true },
           ],
         },
       ],
@@ -178,23 +178,23 @@ describe("computeTabPersonTotals", () => {
 
   it("all_paid is false when any share for a person is unpaid", () => {
     const tab: Tab = {
-      id: 1, name: "Trip", description: "", total_amount: 100,
+      public_id: "1", name: "Trip", description: "", total_amount: 100,
       finalized: false, finalized_at: null, created_at: "2025-01-01",
       bills: [
         {
-          id: 1, name: "Dinner", subtotal: 50, tax: 0, tip_amount: 0,
+          public_id: "1", name: "Dinner", subtotal: 50, tax: 0, tip_amount: 0,
           tip_percentage: 0, total: 50, date: "2025-01-01",
           payment_methods: [], items: [],
           person_shares: [
-            { id: 1, person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: true },
+            { public_id: "1", person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: true },
           ],
         },
         {
-          id: 2, name: "Lunch", subtotal: 50, tax: 0, tip_amount: 0,
+          public_id: "2", name: "Lunch", subtotal: 50, tax: 0, tip_amount: 0,
           tip_percentage: 0, total: 50, date: "2025-01-02",
           payment_methods: [], items: [],
           person_shares: [
-            { id: 2, person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: false },
+            { public_id: "2", person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: false },
           ],
         },
       ],
@@ -206,15 +206,15 @@ describe("computeTabPersonTotals", () => {
 
   it("all_paid is false when no shares are paid", () => {
     const tab: Tab = {
-      id: 1, name: "Trip", description: "", total_amount: 50,
+      public_id: "1", name: "Trip", description: "", total_amount: 50,
       finalized: false, finalized_at: null, created_at: "2025-01-01",
       bills: [
         {
-          id: 1, name: "Dinner", subtotal: 50, tax: 0, tip_amount: 0,
+          public_id: "1", name: "Dinner", subtotal: 50, tax: 0, tip_amount: 0,
           tip_percentage: 0, total: 50, date: "2025-01-01",
           payment_methods: [], items: [],
           person_shares: [
-            { id: 1, person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: false },
+            { public_id: "1", person_name: "Alice", items: [], subtotal: 50, tax_share: 0, tip_share: 0, total: 50, paid: false },
           ],
         },
       ],
@@ -246,7 +246,7 @@ describe("getBill", () => {
 
   it("returns data on success", async () => {
     const mockBill: Bill = {
-      id: 1,
+      public_id: "1",
       name: "Dinner",
       subtotal: 100,
       tax: 8,
@@ -294,7 +294,7 @@ describe("updatePersonSharePaid", () => {
     const mockFetch = vi.fn().mockResolvedValue({ ok: true });
     vi.stubGlobal("fetch", mockFetch);
 
-    await updatePersonSharePaid(1, 5, true, "test-token");
+    await updatePersonSharePaid("1", "5", true, "test-token");
 
     expect(mockFetch).toHaveBeenCalledWith(
       expect.stringContaining("/api/bills/1/shares/5"),
@@ -308,7 +308,7 @@ describe("updatePersonSharePaid", () => {
   it("throws on failure", async () => {
     vi.stubGlobal("fetch", vi.fn().mockResolvedValue({ ok: false, status: 500 }));
 
-    await expect(updatePersonSharePaid(1, 5, true, "token")).rejects.toThrow();
+    await expect(updatePersonSharePaid("1", "5", true, "token")).rejects.toThrow();
   });
 });
 
@@ -321,7 +321,7 @@ describe("updateSettlementPaid", () => {
     const mockFetch = vi.fn().mockResolvedValue({ ok: true });
     vi.stubGlobal("fetch", mockFetch);
 
-    await updateSettlementPaid("1", 3, false, "test-token");
+    await updateSettlementPaid("1", "3", false, "test-token");
 
     expect(mockFetch).toHaveBeenCalledWith(
       expect.stringContaining("/api/tabs/1/settlements/3"),
@@ -335,6 +335,6 @@ describe("updateSettlementPaid", () => {
   it("throws on failure", async () => {
     vi.stubGlobal("fetch", vi.fn().mockResolvedValue({ ok: false, status: 500 }));
 
-    await expect(updateSettlementPaid("1", 3, true, "token")).rejects.toThrow();
+    await expect(updateSettlementPaid("1", "3", true, "token")).rejects.toThrow();
   });
 });
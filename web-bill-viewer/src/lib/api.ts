@@ -5,7 +5,7 @@ export interface ItemDetail {
 }
 
 export interface PersonShare {
-  id: number;
+  public_id: string;
   person_name: string;
   items: ItemDetail[];
   subtotal: number;
@@ -16,7 +16,6 @@ export interface PersonShare {
 }
 
 export interface BillItem {
-  id: number;
   name: string;
   price: number;
 }
@@ -27,7 +26,7 @@ export interface PaymentMethod {
 }
 
 export interface Bill {
-  id: number;
+  public_id: string;
   name: string;
   subtotal: number;
   tax: number;
@@ -41,7 +40,7 @@ export interface Bill {
 }
 
 export interface Tab {
-  id: number;
+  public_id: string;
   name: string;
   description: string;
   bills: Bill[];
@@ -52,8 +51,7 @@ export interface Tab {
 }
 
 export interface TabSettlement {
-  id: number;
-  tab_id: number;
+  public_id: string;
   person_name: string;
   amount: number;
   paid: boolean;
@@ -68,16 +66,14 @@ export interface TabPersonTotal {
 }
 
 export interface TabMember {
-  id: number;
-  tab_id: number;
+  public_id: string;
   display_name: string;
   role: string;
   joined_at: string;
 }
 
 export interface TabImage {
-  id: number;
-  tab_id: number;
+  public_id: string;
   filename: string;
   url: string;
   size: number;
@@ -179,7 +175,7 @@ export async function joinTab(
   id: string,
   token: string,
   displayName: string,
-): Promise<{ member_id: number; member_token: string; display_name: string; role: string } | null> {
+): Promise<{ member_id: string; member_token: string; display_name: string; role: string } | null> {
   const response = await fetch(
     `${API_BASE_URL}/api/tabs/${id}/join`,
     {
@@ -200,8 +196,8 @@ export async function joinTab(
 }
 
 export async function updatePersonSharePaid(
-  billId: number,
-  shareId: number,
+  billId: string,
+  shareId: string,
   paid: boolean,
   token: string,
 ): Promise<void> {
@@ -224,7 +220,7 @@ export async function updatePersonSharePaid(
 
 export async function updateSettlementPaid(
   tabId: string,
-  settlementId: number,
+  settlementId: string,
   paid: boolean,
   token: string,
 ): Promise<void> {